@@ -0,0 +1,45 @@
+// Command oidc-fake is a local OpenID Connect provider for trying single sign-on
+// without a real identity provider.
+//
+// Configure an organization with issuer http://localhost:9000, client ID
+// OIDC_CLIENT_ID and secret OIDC_CLIENT_SECRET. Every login is signed in as
+// FAKE_OIDC_EMAIL without asking for credentials; add login_hint=<email> to the
+// authorization URL to sign in as someone else.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/yourusername/invoicing-backend/internal/oidcfake"
+)
+
+func main() {
+	addr := getenv("FAKE_OIDC_ADDR", "localhost:9000")
+	email := getenv("FAKE_OIDC_EMAIL", "dev@example.com")
+
+	provider, err := oidcfake.New(
+		getenv("OIDC_CLIENT_ID", "dev"),
+		getenv("OIDC_CLIENT_SECRET", "dev-secret"),
+		oidcfake.Identity{
+			Subject:       "sub-" + email,
+			Email:         email,
+			EmailVerified: getenv("FAKE_OIDC_EMAIL_VERIFIED", "true") == "true",
+			Name:          getenv("FAKE_OIDC_NAME", "Dev User"),
+		},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Fake OIDC provider listening on http://%s, signing in as %s", addr, email)
+	log.Fatal(http.ListenAndServe(addr, provider.Handler()))
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,36 @@
+// Command paypal-fake is an in-memory stand-in for the PayPal subscriptions and
+// orders APIs, for local development without a sandbox account.
+//
+// Point the server at it with PAYPAL_API_URL=http://localhost:8081 and any
+// PAYPAL_CLIENT_ID. Following an approve link activates the subscription and
+// delivers the webhook to WEBHOOK_URL; cancelling delivers a CANCELLED event.
+// Approving an order delivers CHECKOUT.ORDER.APPROVED to PAYMENTS_WEBHOOK_URL.
+// Webhooks are signed with FAKE_PAYPAL_SIGNATURE, the only signature it accepts.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/yourusername/invoicing-backend/internal/paypalfake"
+)
+
+func main() {
+	addr := getenv("FAKE_PAYPAL_ADDR", "localhost:8081")
+	server := paypalfake.New(
+		getenv("FAKE_PAYPAL_SIGNATURE", "fake-signature"),
+		getenv("WEBHOOK_URL", "http://localhost:8080/api/webhooks/paypal"),
+		getenv("PAYMENTS_WEBHOOK_URL", "http://localhost:8080/api/webhooks/payments/paypal"),
+	)
+
+	log.Printf("Fake PayPal listening on http://%s, delivering webhooks to %s and %s", addr, server.WebhookURL, server.PaymentsWebhookURL)
+	log.Fatal(http.ListenAndServe(addr, server.Handler()))
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/yourusername/invoicing-backend/internal/cache"
 	"github.com/yourusername/invoicing-backend/internal/config"
 	"github.com/yourusername/invoicing-backend/internal/database"
 	"github.com/yourusername/invoicing-backend/internal/handlers"
 	"github.com/yourusername/invoicing-backend/internal/middleware"
+	"github.com/yourusername/invoicing-backend/internal/models"
 	"github.com/yourusername/invoicing-backend/internal/services"
 )
 
@@ -22,17 +26,26 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Run migrations as the schema owner
+	if err := database.RunMigrations(cfg.MigrationDatabaseURL); err != nil {
+		log.Fatal("Failed to run migrations:", err)
+	}
+
 	// Initialize database
 	db, err := database.Initialize(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	// Run migrations
-	if err := database.RunMigrations(cfg.DatabaseURL); err != nil {
-		log.Fatal("Failed to run migrations:", err)
+	// Refuse to serve tenants over a connection that row-level security does not apply to
+	if err := database.VerifyTenantIsolation(db); err != nil {
+		log.Fatal("Unsafe database role:", err)
 	}
 
+	// Authorization lookups (user, membership, subscription, request host) are cached in process.
+	// Swap the store for a shared one when running several replicas.
+	authzCache := services.NewAuthzCache(db, cache.NewLRU(cfg.AuthzCacheSize), cfg.AuthzCacheTTL)
+
 	// Initialize Gin router
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -40,8 +53,11 @@ func main() {
 
 	r := gin.Default()
 
-	// Add CORS middleware
-	r.Use(middleware.CORSMiddleware())
+	// Add CORS middleware; verified custom domains are looked up through the cache
+	r.Use(middleware.CORSMiddleware(cfg.BaseDomain, authzCache))
+
+	// Tag every request with an ID that is echoed back and stored in the audit log
+	r.Use(middleware.RequestID())
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -52,18 +68,88 @@ func main() {
 	})
 
 	// Initialize services
-	authService := services.NewAuthService(db)
-	clientService := services.NewClientService(db)
-	invoiceService := services.NewInvoiceService(db)
+	notifier := services.NewLogNotifier()
+	trial := services.TrialConfig{
+		Plan:           models.SubscriptionPlan(cfg.TrialPlan),
+		Duration:       time.Duration(cfg.TrialDays) * 24 * time.Hour,
+		ReminderBefore: time.Duration(cfg.TrialReminderDays) * 24 * time.Hour,
+	}
+	authService := services.NewAuthService(db, notifier, trial)
+	usageService := services.NewUsageService(db, notifier, cfg.FrontendURL)
+	clientService := services.NewClientService(db, usageService)
+	invoiceService := services.NewInvoiceService(db, notifier, usageService)
+	apiKeyService := services.NewAPIKeyService(db)
+	oidcService := services.NewOIDCService(db, authzCache, cfg.OIDCRedirectURL, cfg.FrontendURL)
+	sessionService := services.NewSessionService(db, authzCache)
+	invitationService := services.NewInvitationService(db, authzCache, notifier, usageService, cfg.FrontendURL)
+	memberService := services.NewMemberService(db, authzCache)
+	organizationService := services.NewOrganizationService(db, authzCache, cfg.BaseDomain)
+	roleService := services.NewRoleService(db, authzCache)
+	platformAdminService := services.NewPlatformAdminService(db, authzCache, sessionService)
+	auditService := services.NewAuditService(db)
+
+	// Billing providers are optional; their API URLs can point at local fakes for development
+	var billingProviders []services.BillingProvider
+	var paymentGateways []services.PaymentGateway
+	if cfg.PayPalClientID != "" {
+		paypalClient := services.NewPayPalClient(cfg.PayPalAPIURL, cfg.PayPalClientID, cfg.PayPalClientSecret)
+		billingProviders = append(billingProviders, services.NewPayPalBillingProvider(
+			paypalClient,
+			map[models.SubscriptionPlan]string{
+				models.SubscriptionPlanPro:      cfg.PayPalPlanPro,
+				models.SubscriptionPlanBusiness: cfg.PayPalPlanBusiness,
+			}, cfg.PayPalWebhookID))
+		if cfg.PayPalPaymentsWebhookID != "" {
+			paymentGateways = append(paymentGateways, services.NewPayPalPaymentGateway(paypalClient, cfg.PayPalPaymentsWebhookID))
+		}
+	}
+	if cfg.StripeSecretKey != "" {
+		billingProviders = append(billingProviders, services.NewStripeBillingProvider(
+			cfg.StripeAPIURL, cfg.StripeSecretKey, cfg.StripeWebhookSecret,
+			map[models.SubscriptionPlan]string{
+				models.SubscriptionPlanPro:      cfg.StripePricePro,
+				models.SubscriptionPlanBusiness: cfg.StripePriceBusiness,
+			}))
+		if cfg.StripePaymentsWebhookSecret != "" {
+			paymentGateways = append(paymentGateways, services.NewStripePaymentGateway(
+				cfg.StripeAPIURL, cfg.StripeSecretKey, cfg.StripePaymentsWebhookSecret))
+		}
+	}
+	planService := services.NewPlanService(db)
+	couponService := services.NewCouponService(db, authzCache)
+	subscriptionService := services.NewSubscriptionService(db, authzCache, notifier, billingProviders,
+		cfg.DefaultBillingProvider, cfg.FrontendURL, trial)
+	paymentMethodService := services.NewPaymentMethodService(db)
+	invoicePaymentService := services.NewInvoicePaymentService(db, notifier, paymentGateways,
+		cfg.DefaultPaymentGateway, cfg.FrontendURL)
+
+	// Trial reminders, trial ends and subscription expiry
+	if cfg.SubscriptionJobInterval > 0 {
+		go subscriptionService.RunLifecycle(context.Background(), cfg.SubscriptionJobInterval)
+	}
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(db)
-	rbacMiddleware := middleware.NewRBACMiddleware(db)
+	authMiddleware := middleware.NewAuthMiddleware(db, authzCache)
+	rbacMiddleware := middleware.NewRBACMiddleware(db, authzCache, subscriptionService, cfg.BaseDomain)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authService)
+	authHandler := handlers.NewAuthHandler(authService, sessionService)
 	clientHandler := handlers.NewClientHandler(clientService)
 	invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	ssoHandler := handlers.NewSSOHandler(oidcService, sessionService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	invitationHandler := handlers.NewInvitationHandler(invitationService, sessionService)
+	memberHandler := handlers.NewMemberHandler(memberService)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	adminHandler := handlers.NewAdminHandler(platformAdminService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionService, usageService)
+	planHandler := handlers.NewPlanHandler(planService)
+	couponHandler := handlers.NewCouponHandler(couponService)
+	invoicePaymentHandler := handlers.NewInvoicePaymentHandler(invoicePaymentService)
+	paymentMethodHandler := handlers.NewPaymentMethodHandler(paymentMethodService)
 
 	// API routes
 	api := r.Group("/api")
@@ -72,6 +158,117 @@ func main() {
 		api.POST("/auth/register", authHandler.Register)
 		api.POST("/auth/login", authHandler.Login)
 
+		// OpenID Connect single sign-on routes
+		api.GET("/auth/oidc/:organization_id/login", ssoHandler.BeginLogin)
+		api.GET("/auth/oidc/callback", ssoHandler.Callback)
+
+		// Plan catalog for pricing pages
+		api.GET("/plans", planHandler.GetPlans)
+
+		// Subdomain availability check used by sign-up and organization settings forms
+		api.GET("/subdomains/availability", organizationHandler.CheckSubdomainAvailability)
+
+		// Invitation acceptance routes (the signed token authenticates the invitee)
+		api.GET("/invitations/lookup", invitationHandler.LookupInvitation)
+		api.POST("/invitations/accept", invitationHandler.AcceptInvitation)
+
+		// Billing provider webhooks (authenticated by signature verification)
+		api.POST("/webhooks/paypal", subscriptionHandler.Webhook(models.BillingProviderPayPal))
+		api.POST("/webhooks/stripe", subscriptionHandler.Webhook(models.BillingProviderStripe))
+
+		// Invoice payment routes (the signed payment link authenticates the payer)
+		api.GET("/payments/lookup", invoicePaymentHandler.LookupPayment)
+		api.GET("/payments/pdf", invoicePaymentHandler.GetPaymentPDF)
+		api.GET("/payments/payment-code", invoicePaymentHandler.GetPaymentCode)
+		api.POST("/payments/checkout", invoicePaymentHandler.StartPayment)
+		api.POST("/webhooks/payments/paypal", invoicePaymentHandler.Webhook(models.PaymentGatewayPayPal))
+		api.POST("/webhooks/payments/stripe", invoicePaymentHandler.Webhook(models.PaymentGatewayStripe))
+
+		// Subscription management stays available while the subscription is past due
+		billing := api.Group("/subscription")
+		billing.Use(authMiddleware.JWTAuthMiddleware())
+		billing.Use(rbacMiddleware.OrganizationContextMiddleware())
+		{
+			billing.GET("",
+				rbacMiddleware.RequirePermission("organization", "read"),
+				subscriptionHandler.GetSubscription)
+			billing.GET("/usage",
+				rbacMiddleware.RequirePermission("organization", "read"),
+				subscriptionHandler.GetUsage)
+			billing.POST("/checkout",
+				rbacMiddleware.RequireOrgAdmin(),
+				subscriptionHandler.StartCheckout)
+			billing.POST("/change-plan",
+				rbacMiddleware.RequireOrgAdmin(),
+				subscriptionHandler.ChangePlan)
+			billing.POST("/coupon",
+				rbacMiddleware.RequireOrgAdmin(),
+				couponHandler.RedeemCoupon)
+			billing.POST("/portal",
+				rbacMiddleware.RequireOrgAdmin(),
+				subscriptionHandler.GetPortalURL)
+			billing.POST("/cancel",
+				rbacMiddleware.RequireOrgAdmin(),
+				subscriptionHandler.CancelSubscription)
+		}
+
+		// Account and security actions and the platform admin console don't change the
+		// organization's data, so they stay available while its subscription has lapsed
+		account := api.Group("/")
+		account.Use(authMiddleware.JWTAuthMiddleware())
+		account.Use(rbacMiddleware.OrganizationContextMiddleware())
+		{
+			// Clear a member's login lockout
+			account.POST("/users/:id/unlock",
+				rbacMiddleware.RequirePermission("users", "update"),
+				authHandler.UnlockUser)
+
+			// Force-logout a member from all devices
+			account.POST("/users/:id/logout",
+				rbacMiddleware.RequireOrgAdmin(),
+				sessionHandler.ForceLogoutMember)
+
+			// Current user's sessions and devices
+			account.GET("/me/sessions", sessionHandler.GetSessions)
+			account.DELETE("/me/sessions", sessionHandler.RevokeOtherSessions)
+			account.DELETE("/me/sessions/:id", sessionHandler.RevokeSession)
+
+			// Switch the organization used when requests don't specify one
+			account.POST("/me/current-organization", organizationHandler.SetCurrentOrganization)
+
+			// Current user context route
+			account.GET("/me", func(c *gin.Context) {
+				userID, _ := c.Get("user_id")
+				orgID, _ := c.Get("organization_id")
+				userRole, _ := c.Get("user_role")
+				impersonatorID, _ := c.Get("impersonator_id")
+				c.JSON(200, gin.H{
+					"user_id":         userID,
+					"organization_id": orgID,
+					"role":            userRole,
+					"impersonator_id": impersonatorID,
+				})
+			})
+
+			// Platform admin console (cross-tenant)
+			admin := account.Group("/admin", rbacMiddleware.RequirePlatformAdmin())
+			{
+				admin.GET("/organizations", adminHandler.SearchOrganizations)
+				admin.GET("/organizations/:id", adminHandler.GetOrganization)
+				admin.POST("/organizations/:id/suspend", adminHandler.SuspendOrganization)
+				admin.POST("/organizations/:id/unsuspend", adminHandler.UnsuspendOrganization)
+				admin.PUT("/organizations/:id/plan", adminHandler.ChangePlan)
+				admin.GET("/users", adminHandler.SearchUsers)
+				admin.GET("/users/:id", adminHandler.GetUser)
+				admin.POST("/users/:id/impersonate", adminHandler.ImpersonateUser)
+				admin.GET("/actions", adminHandler.GetActions)
+				admin.POST("/coupons", couponHandler.CreateCoupon)
+				admin.GET("/coupons", couponHandler.ListCoupons)
+				admin.GET("/coupons/:id", couponHandler.GetCoupon)
+				admin.POST("/coupons/:id/deactivate", couponHandler.DeactivateCoupon)
+			}
+		}
+
 		// Protected routes with RBAC
 		protected := api.Group("/")
 		protected.Use(authMiddleware.JWTAuthMiddleware())
@@ -81,7 +278,6 @@ func main() {
 			// Client management routes
 			protected.POST("/clients",
 				rbacMiddleware.RequirePermission("clients", "create"),
-				rbacMiddleware.EnforceUsageLimits("clients"),
 				clientHandler.CreateClient)
 			protected.GET("/clients",
 				rbacMiddleware.RequirePermission("clients", "read"),
@@ -99,7 +295,6 @@ func main() {
 			// Invoice management routes
 			protected.POST("/invoices",
 				rbacMiddleware.RequirePermission("invoices", "create"),
-				rbacMiddleware.EnforceUsageLimits("invoices"),
 				invoiceHandler.CreateInvoice)
 			protected.GET("/invoices",
 				rbacMiddleware.RequirePermission("invoices", "read"),
@@ -107,37 +302,178 @@ func main() {
 			protected.GET("/invoices/:id",
 				rbacMiddleware.RequirePermission("invoices", "read"),
 				invoiceHandler.GetInvoice)
+			protected.GET("/invoices/:id/pdf",
+				rbacMiddleware.RequirePermission("invoices", "read"),
+				invoiceHandler.GetInvoicePDF)
+			protected.GET("/invoices/:id/payment-code",
+				rbacMiddleware.RequirePermission("invoices", "read"),
+				invoiceHandler.GetInvoicePaymentCode)
 			protected.PUT("/invoices/:id",
 				rbacMiddleware.RequireOwnershipOrPermission("invoices", "update", "user_id"),
 				invoiceHandler.UpdateInvoice)
 			protected.PUT("/invoices/:id/status",
 				rbacMiddleware.RequireOwnershipOrPermission("invoices", "update", "user_id"),
 				invoiceHandler.UpdateInvoiceStatus)
+			protected.GET("/invoices/:id/approvals",
+				rbacMiddleware.RequirePermission("invoices", "read"),
+				invoiceHandler.GetApprovals)
+			protected.POST("/invoices/:id/approvals",
+				rbacMiddleware.RequireOwnershipOrPermission("invoices", "update", "user_id"),
+				invoiceHandler.RequestApproval)
+			protected.POST("/invoices/:id/approvals/approve",
+				rbacMiddleware.RequirePermission("invoices", "approve"),
+				invoiceHandler.ApproveInvoice)
+			protected.POST("/invoices/:id/approvals/reject",
+				rbacMiddleware.RequirePermission("invoices", "approve"),
+				invoiceHandler.RejectInvoice)
+			protected.POST("/invoices/:id/payment-link",
+				rbacMiddleware.RequireOwnershipOrPermission("invoices", "update", "user_id"),
+				invoicePaymentHandler.CreatePaymentLink)
+			protected.GET("/invoices/:id/payments",
+				rbacMiddleware.RequirePermission("invoices", "read"),
+				invoicePaymentHandler.GetPayments)
 			protected.DELETE("/invoices/:id",
 				rbacMiddleware.RequireOwnershipOrPermission("invoices", "delete", "user_id"),
 				invoiceHandler.DeleteInvoice)
 
-			// Organization management routes (for future implementation)
-			protected.GET("/organizations",
-				rbacMiddleware.RequireRole("platform_admin", "org_admin"),
-				func(c *gin.Context) { c.JSON(200, gin.H{"message": "Organizations endpoint - to be implemented"}) })
+			// Payment methods printed on invoices
+			protected.GET("/payment-methods",
+				rbacMiddleware.RequirePermission("invoices", "read"),
+				paymentMethodHandler.GetPaymentMethods)
+			protected.POST("/payment-methods",
+				rbacMiddleware.RequirePermission("organization", "update"),
+				paymentMethodHandler.CreatePaymentMethod)
+			protected.PUT("/payment-methods/:id",
+				rbacMiddleware.RequirePermission("organization", "update"),
+				paymentMethodHandler.UpdatePaymentMethod)
+			protected.DELETE("/payment-methods/:id",
+				rbacMiddleware.RequirePermission("organization", "update"),
+				paymentMethodHandler.DeletePaymentMethod)
 
-			// User management routes (for future implementation)
-			protected.GET("/users",
+			// API key management routes (human org admins only)
+			protected.GET("/api-keys",
+				rbacMiddleware.RequireOrgAdmin(),
+				apiKeyHandler.GetAPIKeys)
+			protected.POST("/api-keys",
+				rbacMiddleware.RequireOrgAdmin(),
+				rbacMiddleware.RequireFeature(models.FeatureAPIKeys),
+				apiKeyHandler.CreateAPIKey)
+			protected.POST("/api-keys/:id/rotate",
+				rbacMiddleware.RequireOrgAdmin(),
+				rbacMiddleware.RequireFeature(models.FeatureAPIKeys),
+				apiKeyHandler.RotateAPIKey)
+			protected.DELETE("/api-keys/:id",
+				rbacMiddleware.RequireOrgAdmin(),
+				apiKeyHandler.RevokeAPIKey)
+
+			// Role routes: system roles plus the organization's custom roles
+			protected.GET("/roles",
+				rbacMiddleware.RequirePermission("users", "read"),
+				roleHandler.GetRoles)
+			protected.GET("/roles/resources",
 				rbacMiddleware.RequirePermission("users", "read"),
-				func(c *gin.Context) { c.JSON(200, gin.H{"message": "Users endpoint - to be implemented"}) })
+				roleHandler.GetResources)
+			protected.GET("/roles/:id",
+				rbacMiddleware.RequirePermission("users", "read"),
+				roleHandler.GetRole)
+			protected.POST("/roles",
+				rbacMiddleware.RequireOrgAdmin(),
+				rbacMiddleware.RequireFeature(models.FeatureCustomRoles),
+				roleHandler.CreateRole)
+			protected.PUT("/roles/:id",
+				rbacMiddleware.RequireOrgAdmin(),
+				rbacMiddleware.RequireFeature(models.FeatureCustomRoles),
+				roleHandler.UpdateRole)
+			protected.DELETE("/roles/:id",
+				rbacMiddleware.RequireOrgAdmin(),
+				roleHandler.DeleteRole)
 
-			// Current user context route
-			protected.GET("/me", func(c *gin.Context) {
-				userID, _ := c.Get("user_id")
-				orgID, _ := c.Get("organization_id")
-				userRole, _ := c.Get("user_role")
-				c.JSON(200, gin.H{
-					"user_id":         userID,
-					"organization_id": orgID,
-					"role":            userRole,
-				})
-			})
+			// Audit log of mutating actions in the organization
+			protected.GET("/audit-events",
+				rbacMiddleware.RequireOrgAdmin(),
+				auditHandler.GetAuditEvents)
+
+			// Single sign-on configuration routes
+			protected.GET("/sso/config",
+				rbacMiddleware.RequireOrgAdmin(),
+				ssoHandler.GetSSOConfig)
+			protected.PUT("/sso/config",
+				rbacMiddleware.RequireOrgAdmin(),
+				rbacMiddleware.RequireFeature(models.FeatureSSO),
+				ssoHandler.UpdateSSOConfig)
+			protected.DELETE("/sso/config",
+				rbacMiddleware.RequireOrgAdmin(),
+				ssoHandler.DeleteSSOConfig)
+			protected.GET("/sso/domains",
+				rbacMiddleware.RequireOrgAdmin(),
+				ssoHandler.GetSSODomains)
+			protected.POST("/sso/domains",
+				rbacMiddleware.RequireOrgAdmin(),
+				ssoHandler.AddSSODomain)
+			protected.POST("/sso/domains/:id/verify",
+				rbacMiddleware.RequireOrgAdmin(),
+				ssoHandler.VerifySSODomain)
+			protected.DELETE("/sso/domains/:id",
+				rbacMiddleware.RequireOrgAdmin(),
+				ssoHandler.DeleteSSODomain)
+
+			// Link an identity of the organization's provider to the current user
+			protected.POST("/sso/link", ssoHandler.BeginLink)
+			protected.POST("/sso/link/complete", ssoHandler.CompleteLink)
+
+			// Organization management routes. The :organization_id path parameter
+			// selects the organization context, so permissions apply to that org.
+			protected.GET("/organizations", organizationHandler.GetOrganizations)
+			protected.POST("/organizations", organizationHandler.CreateOrganization)
+			protected.GET("/organizations/:organization_id",
+				organizationHandler.GetOrganization)
+			protected.PUT("/organizations/:organization_id",
+				rbacMiddleware.RequirePermission("organization", "update"),
+				organizationHandler.UpdateOrganization)
+			protected.PUT("/organizations/:organization_id/settings/:section",
+				rbacMiddleware.RequirePermission("organization", "update"),
+				organizationHandler.UpdateSettings)
+			protected.GET("/organizations/:organization_id/custom-domain",
+				rbacMiddleware.RequirePermission("organization", "read"),
+				organizationHandler.GetCustomDomain)
+			protected.PUT("/organizations/:organization_id/custom-domain",
+				rbacMiddleware.RequirePermission("organization", "update"),
+				organizationHandler.SetCustomDomain)
+			protected.POST("/organizations/:organization_id/custom-domain/verify",
+				rbacMiddleware.RequirePermission("organization", "update"),
+				organizationHandler.VerifyCustomDomain)
+			protected.DELETE("/organizations/:organization_id/custom-domain",
+				rbacMiddleware.RequirePermission("organization", "update"),
+				organizationHandler.RemoveCustomDomain)
+
+			// Membership invitation routes
+			protected.POST("/invitations",
+				rbacMiddleware.RequirePermission("users", "create"),
+				invitationHandler.CreateInvitation)
+			protected.GET("/invitations",
+				rbacMiddleware.RequirePermission("users", "read"),
+				invitationHandler.GetInvitations)
+			protected.POST("/invitations/:id/resend",
+				rbacMiddleware.RequirePermission("users", "create"),
+				invitationHandler.ResendInvitation)
+			protected.DELETE("/invitations/:id",
+				rbacMiddleware.RequirePermission("users", "delete"),
+				invitationHandler.RevokeInvitation)
+
+			// Member management routes for the current organization
+			protected.GET("/users",
+				rbacMiddleware.RequirePermission("users", "read"),
+				memberHandler.GetMembers)
+			protected.PUT("/users/:id/role",
+				rbacMiddleware.RequirePermission("users", "update"),
+				memberHandler.ChangeMemberRole)
+			protected.DELETE("/users/:id",
+				rbacMiddleware.RequirePermission("users", "delete"),
+				memberHandler.RemoveMember)
+			protected.POST("/users/transfer-admin",
+				rbacMiddleware.RequirePermission("users", "update"),
+				rbacMiddleware.RequireRole(models.RoleOrgAdmin),
+				memberHandler.TransferAdmin)
 		}
 	}
 
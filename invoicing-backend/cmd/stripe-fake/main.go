@@ -0,0 +1,38 @@
+// Command stripe-fake is an in-memory stand-in for the parts of the Stripe API
+// used for SaaS billing and invoice payments, for local development without a
+// Stripe account.
+//
+// Point the server at it with STRIPE_API_URL=http://localhost:12111, any
+// STRIPE_SECRET_KEY and the same STRIPE_WEBHOOK_SECRET and
+// STRIPE_PAYMENTS_WEBHOOK_SECRET. Opening a checkout URL completes the checkout;
+// subscription changes deliver signed webhooks to WEBHOOK_URL and invoice
+// payments to PAYMENTS_WEBHOOK_URL.
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/yourusername/invoicing-backend/internal/stripefake"
+)
+
+func main() {
+	addr := getenv("FAKE_STRIPE_ADDR", "localhost:12111")
+	server := stripefake.New(
+		getenv("WEBHOOK_URL", "http://localhost:8080/api/webhooks/stripe"),
+		getenv("STRIPE_WEBHOOK_SECRET", "whsec_dev"),
+		getenv("PAYMENTS_WEBHOOK_URL", "http://localhost:8080/api/webhooks/payments/stripe"),
+		getenv("STRIPE_PAYMENTS_WEBHOOK_SECRET", "whsec_payments_dev"),
+	)
+
+	log.Printf("Fake Stripe listening on http://%s, delivering webhooks to %s and %s", addr, server.WebhookURL, server.PaymentsWebhookURL)
+	log.Fatal(http.ListenAndServe(addr, server.Handler()))
+}
+
+func getenv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"time"
+)
+
+// Store is a byte-oriented key/value cache with per-entry expiry. The in-process
+// LRU is the default; a shared backend such as Redis can implement the same
+// interface, together with Generations, so that invalidations reach every replica.
+type Store interface {
+	// Get returns the value and true when the key exists and has not expired
+	Get(key string) ([]byte, bool)
+	// Set stores the value for ttl
+	Set(key string, value []byte, ttl time.Duration)
+	// Delete removes the keys; missing keys are ignored
+	Delete(keys ...string)
+}
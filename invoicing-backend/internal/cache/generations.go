@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Generations keeps per-key counters that are never evicted and never expire.
+// Bumping a generation makes every entry whose key embeds the previous value
+// unreachable, which invalidates a group of keys without scanning for them.
+//
+// A Store shared between replicas should implement Generations itself (for
+// example Redis INCR on keys without a TTL) so that a bump on one replica is seen
+// by all of them. Otherwise callers fall back to LocalGenerations, and other
+// replicas only see the change once their entries expire.
+type Generations interface {
+	// Generation returns the current value, also for a key that was never bumped
+	Generation(key string) int64
+	// Bump increments the value and returns the new one
+	Bump(key string) int64
+}
+
+// LocalGenerations is an in-process Generations. Counters start at the time it
+// was created, so a restarted process never reuses generations of a previous one
+// that may still be embedded in keys of a shared store. It grows by one counter
+// per key ever bumped, which is bounded by the number of invalidated groups.
+type LocalGenerations struct {
+	mu     sync.RWMutex
+	base   int64
+	values map[string]int64
+}
+
+func NewLocalGenerations() *LocalGenerations {
+	return &LocalGenerations{base: time.Now().UnixNano(), values: make(map[string]int64)}
+}
+
+func (g *LocalGenerations) Generation(key string) int64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.base + g.values[key]
+}
+
+func (g *LocalGenerations) Bump(key string) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.values[key]++
+	return g.base + g.values[key]
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// TenantRole holds the table privileges of the application. The role the
+// application logs in as is granted it and must own no tables, so row-level
+// security applies to every query it makes (see VerifyTenantIsolation).
+const TenantRole = "invoicing_tenant"
+
+// WithOrganization runs fn in a transaction scoped to a single organization.
+// Row-level security policies only expose that organization's rows to queries
+// made through tx, so a missing organization_id filter cannot leak other tenants' data.
+//
+// When db is already a transaction the scope only lasts for fn; the previous
+// organization context is restored afterwards. Scoping a transaction that is
+// already scoped to another organization is an error.
+func WithOrganization(db *gorm.DB, organizationID string, fn func(tx *gorm.DB) error) error {
+	if organizationID == "" {
+		return fmt.Errorf("organization context required")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var previous string
+		if err := tx.Raw("SELECT COALESCE(current_setting('app.current_organization_id', true), '')").
+			Scan(&previous).Error; err != nil {
+			return fmt.Errorf("failed to read organization context: %w", err)
+		}
+		if previous != "" && previous != organizationID {
+			return fmt.Errorf("transaction is already scoped to another organization")
+		}
+
+		if err := setOrganization(tx, organizationID); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return setOrganization(tx, previous)
+	})
+}
+
+// setOrganization sets the organization context until the end of the transaction
+func setOrganization(tx *gorm.DB, organizationID string) error {
+	// set_config(..., true) is SET LOCAL with a bind parameter
+	if err := tx.Exec("SELECT set_config('app.current_organization_id', ?, true)", organizationID).Error; err != nil {
+		return fmt.Errorf("failed to set organization context: %w", err)
+	}
+	return nil
+}
+
+// VerifyTenantIsolation checks that the connected role is subject to row-level
+// security: superusers, BYPASSRLS roles and table owners are not. FORCE ROW LEVEL
+// SECURITY covers owners too, but an owner can still disable it.
+func VerifyTenantIsolation(db *gorm.DB) error {
+	var role struct {
+		Name      string
+		Superuser bool
+		BypassRLS bool
+	}
+	if err := db.Raw("SELECT rolname AS name, rolsuper AS superuser, rolbypassrls AS bypass_rls FROM pg_roles WHERE rolname = current_user").
+		Scan(&role).Error; err != nil {
+		return fmt.Errorf("failed to look up database role: %w", err)
+	}
+	if role.Superuser || role.BypassRLS {
+		return fmt.Errorf("database role %q bypasses row-level security; connect as a role without SUPERUSER or BYPASSRLS", role.Name)
+	}
+
+	var owned []string
+	if err := db.Raw(`SELECT c.relname FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relrowsecurity
+		AND pg_has_role(current_user, c.relowner, 'USAGE')
+		ORDER BY c.relname`).Scan(&owned).Error; err != nil {
+		return fmt.Errorf("failed to look up table owners: %w", err)
+	}
+	if len(owned) > 0 {
+		return fmt.Errorf("database role %q owns tenant tables %v; run migrations as a different role", role.Name, owned)
+	}
+	return nil
+}
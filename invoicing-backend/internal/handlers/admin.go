@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// AdminHandler serves the platform admin console
+type AdminHandler struct {
+	adminService *services.PlatformAdminService
+	validator    *validator.Validate
+}
+
+func NewAdminHandler(adminService *services.PlatformAdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+		validator:    validator.New(),
+	}
+}
+
+// SearchOrganizations supports ?q=, ?status=active|suspended, ?limit= and ?offset=
+func (h *AdminHandler) SearchOrganizations(c *gin.Context) {
+	limit, offset := parsePagination(c)
+
+	orgs, total, err := h.adminService.SearchOrganizations(services.AdminSearchParams{
+		Query:  c.Query("q"),
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to search organizations")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"items":  orgs,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (h *AdminHandler) GetOrganization(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	org, err := h.adminService.GetOrganization(id.String())
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, org)
+}
+
+func (h *AdminHandler) SuspendOrganization(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	var req services.SuspendOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	org, err := h.adminService.SuspendOrganization(adminActor(c), id.String(), req.Reason)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, org)
+}
+
+func (h *AdminHandler) UnsuspendOrganization(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	var req services.SuspendOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	org, err := h.adminService.UnsuspendOrganization(adminActor(c), id.String(), req.Reason)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, org)
+}
+
+func (h *AdminHandler) ChangePlan(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	var req services.AdminChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	subscription, err := h.adminService.ChangePlan(adminActor(c), id.String(), &req)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, subscription)
+}
+
+// SearchUsers supports ?q=, ?limit= and ?offset=
+func (h *AdminHandler) SearchUsers(c *gin.Context) {
+	limit, offset := parsePagination(c)
+
+	users, total, err := h.adminService.SearchUsers(services.AdminSearchParams{
+		Query:  c.Query("q"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to search users")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"items":  users,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := h.adminService.GetUser(id.String())
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, user)
+}
+
+// ImpersonateUser returns a short-lived token for acting as the user
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req services.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	result, err := h.adminService.ImpersonateUser(adminActor(c), id.String(), req.Reason)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, result)
+}
+
+// GetActions lists the admin action log; supports ?admin_id=, ?action=, ?target_type=, ?target_id=
+func (h *AdminHandler) GetActions(c *gin.Context) {
+	limit, offset := parsePagination(c)
+
+	actions, total, err := h.adminService.GetActions(services.AdminActionFilter{
+		AdminID:    c.Query("admin_id"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch admin actions")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"items":  actions,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func adminActor(c *gin.Context) services.AdminActor {
+	return services.AdminActor{
+		AdminID:   utils.GetUserIDFromContext(c),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// parsePagination reads ?limit= and ?offset=, clamping the page size
+func parsePagination(c *gin.Context) (int, int) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	return limit, offset
+}
+
+func respondAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Organization not found")
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+	case errors.Is(err, services.ErrOrganizationSuspended):
+		utils.ErrorResponse(c, http.StatusConflict, "Organization is already suspended")
+	case errors.Is(err, services.ErrOrganizationActive):
+		utils.ErrorResponse(c, http.StatusConflict, "Organization is not suspended")
+	case errors.Is(err, services.ErrCannotImpersonate):
+		utils.ErrorResponse(c, http.StatusForbidden, "Platform admins cannot be impersonated")
+	case errors.Is(err, services.ErrUnknownPlan):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Unknown subscription plan")
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+	validator     *validator.Validate
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+		validator:     validator.New(),
+	}
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	userOrgRole, exists := c.Get("user_org_role")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "User role not found in context")
+		return
+	}
+
+	var req services.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	apiKey, rawKey, err := h.apiKeyService.CreateAPIKey(auditContext(c), organizationID.(string),
+		userOrgRole.(models.UserOrganizationRole).Role, &req)
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyPermissionsExceed) {
+			utils.ErrorResponse(c, http.StatusForbidden, "API key permissions exceed your role")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, gin.H{
+		"api_key": apiKey,
+		"key":     rawKey,
+	})
+}
+
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	keys, err := h.apiKeyService.GetAPIKeysByOrganization(organizationID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch API keys")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, keys)
+}
+
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	apiKey, rawKey, err := h.apiKeyService.RotateAPIKey(auditContext(c), keyID.String(), organizationID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"api_key": apiKey,
+		"key":     rawKey,
+	})
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid API key ID")
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(auditContext(c), keyID.String(), organizationID.(string)); err != nil {
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "API key not found")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke API key")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// GetAuditEvents supports ?resource_type=, ?resource_id=, ?actor_id=, ?action=,
+// ?from= and ?to= (RFC 3339), ?limit= and ?offset=
+func (h *AuditHandler) GetAuditEvents(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	limit, offset := parsePagination(c)
+	filter := services.AuditEventFilter{
+		ResourceType: c.Query("resource_type"),
+		ResourceID:   c.Query("resource_id"),
+		ActorID:      c.Query("actor_id"),
+		Action:       c.Query("action"),
+		Limit:        limit,
+		Offset:       offset,
+	}
+
+	for param, dest := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusBadRequest, "Invalid "+param+" timestamp, expected RFC 3339")
+				return
+			}
+			*dest = &t
+		}
+	}
+
+	events, total, err := h.auditService.GetEvents(organizationID.(string), filter)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch audit events")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"items":  events,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// auditContext describes the caller of the current request for the audit log
+func auditContext(c *gin.Context) services.AuditContext {
+	audit := services.AuditContext{
+		UserID:         utils.GetUserIDFromContext(c),
+		ImpersonatorID: c.GetString("impersonator_id"),
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		RequestID:      c.GetString("request_id"),
+	}
+
+	if apiKey, ok := c.Get("api_key"); ok {
+		audit.APIKeyID = apiKey.(models.APIKey).ID
+	}
+
+	return audit
+}
@@ -1,23 +1,28 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/yourusername/invoicing-backend/internal/services"
 	"github.com/yourusername/invoicing-backend/internal/utils"
 )
 
 type AuthHandler struct {
-	authService *services.AuthService
-	validator   *validator.Validate
+	authService    *services.AuthService
+	sessionService *services.SessionService
+	validator      *validator.Validate
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, sessionService *services.SessionService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
-		validator:   validator.New(),
+		authService:    authService,
+		sessionService: sessionService,
+		validator:      validator.New(),
 	}
 }
 
@@ -26,6 +31,8 @@ type RegisterRequest struct {
 	Password  string `json:"password" validate:"required,min=8"`
 	FirstName string `json:"first_name" validate:"required,min=1,max=100"`
 	LastName  string `json:"last_name" validate:"required,min=1,max=100"`
+	// CouponCode is redeemed for the organization's first paid plan
+	CouponCode string `json:"coupon_code" validate:"omitempty,max=50"`
 }
 
 type LoginRequest struct {
@@ -45,13 +52,17 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authService.Register(req.Email, req.Password, req.FirstName, req.LastName)
+	user, err := h.authService.Register(req.Email, req.Password, req.FirstName, req.LastName, req.CouponCode)
 	if err != nil {
+		if isCouponError(err) {
+			respondCouponError(c, err)
+			return
+		}
 		utils.ErrorResponse(c, http.StatusConflict, "User already exists")
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.ID)
+	_, token, err := h.sessionService.CreateSession(user.ID, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
 		return
@@ -75,13 +86,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authService.Login(req.Email, req.Password)
+	user, err := h.authService.Login(req.Email, req.Password, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
+		var lockedErr *services.LoginLockedError
+		if errors.As(err, &lockedErr) {
+			c.Header("Retry-After", strconv.Itoa(int(lockedErr.RetryAfter.Seconds())+1))
+			utils.ErrorResponse(c, http.StatusTooManyRequests, "Too many failed login attempts, please try again later")
+			return
+		}
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
-	token, err := utils.GenerateJWT(user.ID)
+	_, token, err := h.sessionService.CreateSession(user.ID, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
 		return
@@ -92,3 +109,26 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		"token": token,
 	})
 }
+
+// UnlockUser clears a member's login lockout (org admin action)
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.authService.UnlockAccount(organizationID.(string), userID.String()); err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
@@ -24,7 +24,7 @@ func NewClientHandler(clientService *services.ClientService) *ClientHandler {
 }
 
 func (h *ClientHandler) CreateClient(c *gin.Context) {
-	userID := utils.GetUserIDFromContext(c)
+	audit := auditContext(c)
 
 	// Get organization ID from RBAC middleware context
 	organizationID, exists := c.Get("organization_id")
@@ -39,8 +39,11 @@ func (h *ClientHandler) CreateClient(c *gin.Context) {
 		return
 	}
 
-	createdClient, err := h.clientService.CreateClient(userID, organizationID.(string), &client)
+	createdClient, err := h.clientService.CreateClient(audit, organizationID.(string), &client)
 	if err != nil {
+		if respondUsageLimitError(c, err) {
+			return
+		}
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create client")
 		return
 	}
@@ -93,7 +96,7 @@ func (h *ClientHandler) GetClient(c *gin.Context) {
 }
 
 func (h *ClientHandler) UpdateClient(c *gin.Context) {
-	userID := utils.GetUserIDFromContext(c)
+	audit := auditContext(c)
 
 	// Get organization ID from RBAC middleware context
 	organizationID, exists := c.Get("organization_id")
@@ -114,7 +117,7 @@ func (h *ClientHandler) UpdateClient(c *gin.Context) {
 		return
 	}
 
-	client, err := h.clientService.UpdateClient(clientID.String(), userID, organizationID.(string), &updateData)
+	client, err := h.clientService.UpdateClient(audit, clientID.String(), organizationID.(string), &updateData)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update client")
 		return
@@ -124,7 +127,7 @@ func (h *ClientHandler) UpdateClient(c *gin.Context) {
 }
 
 func (h *ClientHandler) DeleteClient(c *gin.Context) {
-	userID := utils.GetUserIDFromContext(c)
+	audit := auditContext(c)
 
 	// Get organization ID from RBAC middleware context
 	organizationID, exists := c.Get("organization_id")
@@ -139,7 +142,7 @@ func (h *ClientHandler) DeleteClient(c *gin.Context) {
 		return
 	}
 
-	err = h.clientService.DeleteClient(clientID.String(), userID, organizationID.(string))
+	err = h.clientService.DeleteClient(audit, clientID.String(), organizationID.(string))
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete client")
 		return
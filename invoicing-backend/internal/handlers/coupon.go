@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+// CouponHandler serves coupon management in the admin console and coupon
+// redemption for organizations
+type CouponHandler struct {
+	couponService *services.CouponService
+	validator     *validator.Validate
+}
+
+func NewCouponHandler(couponService *services.CouponService) *CouponHandler {
+	return &CouponHandler{
+		couponService: couponService,
+		validator:     validator.New(),
+	}
+}
+
+func (h *CouponHandler) CreateCoupon(c *gin.Context) {
+	var req services.CreateCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	coupon, err := h.couponService.CreateCoupon(adminActor(c), &req)
+	if err != nil {
+		respondCouponError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, coupon)
+}
+
+// ListCoupons supports ?q=, ?status=active|inactive, ?limit= and ?offset=
+func (h *CouponHandler) ListCoupons(c *gin.Context) {
+	limit, offset := parsePagination(c)
+
+	coupons, total, err := h.couponService.ListCoupons(services.AdminSearchParams{
+		Query:  c.Query("q"),
+		Status: c.Query("status"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to list coupons")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"items":  coupons,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetCoupon reports on the coupon's redemptions; ?limit= and ?offset= page through them
+func (h *CouponHandler) GetCoupon(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid coupon ID")
+		return
+	}
+
+	limit, offset := parsePagination(c)
+	report, err := h.couponService.GetCouponReport(id.String(), limit, offset)
+	if err != nil {
+		respondCouponError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, report)
+}
+
+// DeactivateCoupon stops new redemptions of the coupon
+func (h *CouponHandler) DeactivateCoupon(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid coupon ID")
+		return
+	}
+
+	coupon, err := h.couponService.DeactivateCoupon(adminActor(c), id.String())
+	if err != nil {
+		respondCouponError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, coupon)
+}
+
+// RedeemCoupon attaches a coupon to the organization's subscription
+func (h *CouponHandler) RedeemCoupon(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.RedeemCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	redemption, err := h.couponService.RedeemCoupon(auditContext(c), organizationID.(string), req.Code)
+	if err != nil {
+		respondCouponError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, redemption)
+}
+
+// isCouponError reports whether err is a coupon error respondCouponError maps
+func isCouponError(err error) bool {
+	for _, target := range []error{
+		services.ErrCouponNotFound, services.ErrInvalidCoupon, services.ErrCouponCodeTaken,
+		services.ErrCouponExpired, services.ErrCouponExhausted, services.ErrCouponNotApplicable,
+		services.ErrCouponAlreadyRedeemed, services.ErrDiscountActive, services.ErrCouponInactive,
+		services.ErrCouponNotSupported,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func respondCouponError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCouponNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Coupon not found")
+	case errors.Is(err, services.ErrInvalidCoupon), errors.Is(err, services.ErrUnknownPlan),
+		errors.Is(err, services.ErrCouponNotApplicable), errors.Is(err, services.ErrCouponNotSupported):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrCouponExpired), errors.Is(err, services.ErrCouponExhausted),
+		errors.Is(err, services.ErrCouponAlreadyRedeemed), errors.Is(err, services.ErrDiscountActive),
+		errors.Is(err, services.ErrCouponInactive), errors.Is(err, services.ErrCouponCodeTaken):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrSubscriptionNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	default:
+		log.Printf("coupon request failed: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Coupon request failed")
+	}
+}
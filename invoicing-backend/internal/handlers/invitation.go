@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type InvitationHandler struct {
+	invitationService *services.InvitationService
+	sessionService    *services.SessionService
+	validator         *validator.Validate
+}
+
+func NewInvitationHandler(invitationService *services.InvitationService, sessionService *services.SessionService) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+		sessionService:    sessionService,
+		validator:         validator.New(),
+	}
+}
+
+func (h *InvitationHandler) CreateInvitation(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	userOrgRole, exists := c.Get("user_org_role")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "User role not found in context")
+		return
+	}
+
+	var req services.CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(auditContext(c), organizationID.(string),
+		userOrgRole.(models.UserOrganizationRole).Role, &req)
+	if err != nil {
+		respondInvitationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, invitation)
+}
+
+func (h *InvitationHandler) GetInvitations(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invitations, err := h.invitationService.GetInvitationsByOrganization(organizationID.(string), c.Query("status"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch invitations")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, invitations)
+}
+
+func (h *InvitationHandler) ResendInvitation(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	invitation, err := h.invitationService.ResendInvitation(auditContext(c), invitationID.String(), organizationID.(string))
+	if err != nil {
+		respondInvitationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, invitation)
+}
+
+func (h *InvitationHandler) RevokeInvitation(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invitationID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invitation ID")
+		return
+	}
+
+	if err := h.invitationService.RevokeInvitation(auditContext(c), invitationID.String(), organizationID.(string)); err != nil {
+		respondInvitationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// LookupInvitation shows invitation details for the accept page (public)
+func (h *InvitationHandler) LookupInvitation(c *gin.Context) {
+	invitation, accountExists, err := h.invitationService.LookupInvitation(c.Query("token"))
+	if err != nil {
+		respondInvitationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"email":          invitation.Email,
+		"organization":   gin.H{"id": invitation.Organization.ID, "name": invitation.Organization.Name},
+		"role":           invitation.Role.Name,
+		"expires_at":     invitation.ExpiresAt,
+		"account_exists": accountExists,
+	})
+}
+
+// AcceptInvitation joins the organization and signs the user in (public)
+func (h *InvitationHandler) AcceptInvitation(c *gin.Context) {
+	var req services.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	user, err := h.invitationService.AcceptInvitation(auditContext(c), &req)
+	if err != nil {
+		respondInvitationError(c, err)
+		return
+	}
+
+	_, token, err := h.sessionService.CreateSession(user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"user":  user,
+		"token": token,
+	})
+}
+
+func respondInvitationError(c *gin.Context, err error) {
+	if respondUsageLimitError(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrInvitationNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Invitation not found")
+	case errors.Is(err, services.ErrInvitationInvalid):
+		utils.ErrorResponse(c, http.StatusGone, "Invitation is invalid or has expired")
+	case errors.Is(err, services.ErrSeatLimitReached):
+		utils.ErrorResponse(c, http.StatusForbidden, "User limit reached")
+	case errors.Is(err, services.ErrAlreadyMember):
+		utils.ErrorResponse(c, http.StatusConflict, "User is already a member of this organization")
+	case errors.Is(err, services.ErrRoleExceedsPermissions):
+		utils.ErrorResponse(c, http.StatusForbidden, "Cannot grant a role with more permissions than your own")
+	case errors.Is(err, services.ErrInvalidCredentials):
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid credentials")
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+}
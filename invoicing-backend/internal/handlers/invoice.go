@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -24,7 +26,7 @@ func NewInvoiceHandler(invoiceService *services.InvoiceService) *InvoiceHandler
 }
 
 func (h *InvoiceHandler) CreateInvoice(c *gin.Context) {
-	userID := utils.GetUserIDFromContext(c)
+	audit := auditContext(c)
 
 	// Get organization ID from RBAC middleware context
 	organizationID, exists := c.Get("organization_id")
@@ -39,9 +41,12 @@ func (h *InvoiceHandler) CreateInvoice(c *gin.Context) {
 		return
 	}
 
-	createdInvoice, err := h.invoiceService.CreateInvoice(userID, organizationID.(string), &invoice)
+	createdInvoice, err := h.invoiceService.CreateInvoice(audit, organizationID.(string), &invoice)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create invoice")
+		if respondUsageLimitError(c, err) {
+			return
+		}
+		respondInvoiceError(c, err, "Failed to create invoice")
 		return
 	}
 
@@ -92,8 +97,61 @@ func (h *InvoiceHandler) GetInvoice(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, invoice)
 }
 
+// GetInvoicePDF downloads the invoice as a PDF
+func (h *InvoiceHandler) GetInvoicePDF(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	data, invoice, err := h.invoiceService.InvoicePDF(invoiceID.String(), organizationID.(string))
+	if err != nil {
+		respondInvoiceError(c, err, "Failed to render invoice")
+		return
+	}
+
+	sendInvoicePDF(c, invoice, data)
+}
+
+// GetInvoicePaymentCode returns the invoice's EPC or Swiss QR-bill payment code as PNG or SVG
+func (h *InvoiceHandler) GetInvoicePaymentCode(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	codeType, format, ok := paymentCodeQuery(c)
+	if !ok {
+		return
+	}
+
+	code, err := h.invoiceService.InvoicePaymentCode(invoiceID.String(), organizationID.(string), codeType)
+	if err != nil {
+		respondInvoiceError(c, err, "Failed to render payment code")
+		return
+	}
+
+	sendPaymentCode(c, code, format)
+}
+
 func (h *InvoiceHandler) UpdateInvoice(c *gin.Context) {
-	userID := utils.GetUserIDFromContext(c)
+	audit := auditContext(c)
 
 	// Get organization ID from RBAC middleware context
 	organizationID, exists := c.Get("organization_id")
@@ -114,9 +172,9 @@ func (h *InvoiceHandler) UpdateInvoice(c *gin.Context) {
 		return
 	}
 
-	invoice, err := h.invoiceService.UpdateInvoice(invoiceID.String(), userID, organizationID.(string), &updateData)
+	invoice, err := h.invoiceService.UpdateInvoice(audit, invoiceID.String(), organizationID.(string), &updateData)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update invoice")
+		respondInvoiceError(c, err, "Failed to update invoice")
 		return
 	}
 
@@ -124,7 +182,7 @@ func (h *InvoiceHandler) UpdateInvoice(c *gin.Context) {
 }
 
 func (h *InvoiceHandler) UpdateInvoiceStatus(c *gin.Context) {
-	userID := utils.GetUserIDFromContext(c)
+	audit := auditContext(c)
 
 	// Get organization ID from RBAC middleware context
 	organizationID, exists := c.Get("organization_id")
@@ -146,10 +204,18 @@ func (h *InvoiceHandler) UpdateInvoiceStatus(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	if err := h.validator.Struct(request); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+	if !request.Status.IsValid() {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice status")
+		return
+	}
 
-	invoice, err := h.invoiceService.UpdateInvoiceStatus(invoiceID.String(), userID, organizationID.(string), request.Status)
+	invoice, err := h.invoiceService.UpdateInvoiceStatus(audit, invoiceID.String(), organizationID.(string), request.Status)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update invoice status")
+		respondInvoiceError(c, err, "Failed to update invoice status")
 		return
 	}
 
@@ -157,7 +223,7 @@ func (h *InvoiceHandler) UpdateInvoiceStatus(c *gin.Context) {
 }
 
 func (h *InvoiceHandler) DeleteInvoice(c *gin.Context) {
-	userID := utils.GetUserIDFromContext(c)
+	audit := auditContext(c)
 
 	// Get organization ID from RBAC middleware context
 	organizationID, exists := c.Get("organization_id")
@@ -172,7 +238,7 @@ func (h *InvoiceHandler) DeleteInvoice(c *gin.Context) {
 		return
 	}
 
-	err = h.invoiceService.DeleteInvoice(invoiceID.String(), userID, organizationID.(string))
+	err = h.invoiceService.DeleteInvoice(audit, invoiceID.String(), organizationID.(string))
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete invoice")
 		return
@@ -180,3 +246,142 @@ func (h *InvoiceHandler) DeleteInvoice(c *gin.Context) {
 
 	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Invoice deleted successfully"})
 }
+
+func (h *InvoiceHandler) GetApprovals(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	approvals, err := h.invoiceService.GetApprovals(invoiceID.String(), organizationID.(string))
+	if err != nil {
+		respondInvoiceError(c, err, "Failed to fetch approvals")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, approvals)
+}
+
+func (h *InvoiceHandler) RequestApproval(c *gin.Context) {
+	h.handleApproval(c, h.invoiceService.RequestApproval, http.StatusCreated)
+}
+
+func (h *InvoiceHandler) ApproveInvoice(c *gin.Context) {
+	h.handleApproval(c, h.invoiceService.ApproveInvoice, http.StatusOK)
+}
+
+func (h *InvoiceHandler) RejectInvoice(c *gin.Context) {
+	h.handleApproval(c, h.invoiceService.RejectInvoice, http.StatusOK)
+}
+
+// handleApproval parses the invoice ID and optional comment shared by the approval actions
+func (h *InvoiceHandler) handleApproval(c *gin.Context,
+	action func(services.AuditContext, string, string, string) (*models.InvoiceApproval, error), status int) {
+	audit := auditContext(c)
+
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	// The body is optional when no comment is given
+	var req services.ApprovalCommentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	approval, err := action(audit, invoiceID.String(), organizationID.(string), req.Comment)
+	if err != nil {
+		respondInvoiceError(c, err, "Failed to process approval")
+		return
+	}
+
+	utils.SuccessResponse(c, status, approval)
+}
+
+func respondInvoiceError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Invoice not found")
+	case errors.Is(err, services.ErrSelfApproval):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrRejectionReasonRequired), errors.Is(err, services.ErrPaymentMethodNotFound):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrInvoiceNotDraft),
+		errors.Is(err, services.ErrInvalidStatusTransition),
+		errors.Is(err, services.ErrInvoiceApprovalRequired),
+		errors.Is(err, services.ErrApprovalNotRequired),
+		errors.Is(err, services.ErrApprovalPending),
+		errors.Is(err, services.ErrApprovalAlreadyGranted),
+		errors.Is(err, services.ErrNoPendingApproval):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrPaymentCodeUnavailable):
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, fallback)
+	}
+}
+
+// sendInvoicePDF responds with the rendered invoice, named after its number
+func sendInvoicePDF(c *gin.Context, invoice *models.Invoice, data []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, invoice.InvoiceNumber))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// paymentCodeQuery reads the optional type (epc or swiss) and format (png or svg)
+// of a payment code request, responding itself when they are invalid
+func paymentCodeQuery(c *gin.Context) (services.PaymentCodeType, string, bool) {
+	codeType := services.PaymentCodeType(c.Query("type"))
+	switch codeType {
+	case "", services.PaymentCodeEPC, services.PaymentCodeSwiss:
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment code type")
+		return "", "", false
+	}
+
+	format := c.DefaultQuery("format", "png")
+	if format != "png" && format != "svg" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment code format")
+		return "", "", false
+	}
+	return codeType, format, true
+}
+
+// sendPaymentCode responds with the payment code image
+func sendPaymentCode(c *gin.Context, code *services.PaymentCode, format string) {
+	if format == "svg" {
+		c.Data(http.StatusOK, "image/svg+xml", code.SVG())
+		return
+	}
+
+	data, err := code.PNG(10)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to render payment code")
+		return
+	}
+	c.Data(http.StatusOK, "image/png", data)
+}
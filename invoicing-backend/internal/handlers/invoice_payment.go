@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+// InvoicePaymentHandler serves invoice payment links, the public payment page
+// API and the payment gateway webhooks
+type InvoicePaymentHandler struct {
+	paymentService *services.InvoicePaymentService
+	validator      *validator.Validate
+}
+
+func NewInvoicePaymentHandler(paymentService *services.InvoicePaymentService) *InvoicePaymentHandler {
+	return &InvoicePaymentHandler{
+		paymentService: paymentService,
+		validator:      validator.New(),
+	}
+}
+
+// CreatePaymentLink returns a new payment link for the invoice, revoking the previous one
+func (h *InvoicePaymentHandler) CreatePaymentLink(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	// The body is optional when the default gateway is used
+	var req services.CreatePaymentLinkRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	link, err := h.paymentService.CreatePaymentLink(auditContext(c), organizationID.(string), invoiceID.String(), req.Gateway)
+	if err != nil {
+		respondInvoicePaymentError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, link)
+}
+
+// GetPayments lists the online payment attempts for the invoice
+func (h *InvoicePaymentHandler) GetPayments(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	invoiceID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid invoice ID")
+		return
+	}
+
+	payments, err := h.paymentService.ListPayments(organizationID.(string), invoiceID.String())
+	if err != nil {
+		respondInvoicePaymentError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, payments)
+}
+
+// LookupPayment returns the invoice behind a payment link for the payment page (public)
+func (h *InvoicePaymentHandler) LookupPayment(c *gin.Context) {
+	invoice, err := h.paymentService.LookupPayment(c.Query("token"))
+	if err != nil {
+		respondInvoicePaymentError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, invoice)
+}
+
+// GetPaymentPDF downloads the invoice behind a payment link as a PDF (public)
+func (h *InvoicePaymentHandler) GetPaymentPDF(c *gin.Context) {
+	data, invoice, err := h.paymentService.PaymentLinkPDF(c.Query("token"))
+	if err != nil {
+		respondInvoicePaymentError(c, err)
+		return
+	}
+
+	sendInvoicePDF(c, invoice, data)
+}
+
+// GetPaymentCode returns the payment QR code of the invoice behind a payment link (public)
+func (h *InvoicePaymentHandler) GetPaymentCode(c *gin.Context) {
+	codeType, format, ok := paymentCodeQuery(c)
+	if !ok {
+		return
+	}
+
+	code, err := h.paymentService.PaymentLinkCode(c.Query("token"), codeType)
+	if err != nil {
+		respondInvoicePaymentError(c, err)
+		return
+	}
+
+	sendPaymentCode(c, code, format)
+}
+
+// StartPayment opens a gateway checkout for the invoice behind a payment link (public)
+func (h *InvoicePaymentHandler) StartPayment(c *gin.Context) {
+	var req services.StartPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	checkout, err := h.paymentService.StartPayment(c.Request.Context(), req.Token)
+	if err != nil {
+		respondInvoicePaymentError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"checkout_url": checkout.CheckoutURL})
+}
+
+// Webhook receives payment events from a gateway; the gateway authenticates it by signature
+func (h *InvoicePaymentHandler) Webhook(gateway string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := h.paymentService.HandleWebhook(c.Request.Context(), gateway, c.Request.Header, body); err != nil {
+			respondWebhookError(c, err)
+			return
+		}
+
+		utils.SuccessResponse(c, http.StatusOK, gin.H{"received": true})
+	}
+}
+
+func respondInvoicePaymentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Invoice not found")
+	case errors.Is(err, services.ErrPaymentLinkInvalid):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvoiceNotPayable), errors.Is(err, services.ErrInvoiceAlreadyPaid),
+		errors.Is(err, services.ErrPaymentAmountTooLow):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrPaymentCodeUnavailable):
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, services.ErrPaymentProviderNotConfigured):
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		log.Printf("invoice payment request failed: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Payment request failed")
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type MemberHandler struct {
+	memberService *services.MemberService
+	validator     *validator.Validate
+}
+
+func NewMemberHandler(memberService *services.MemberService) *MemberHandler {
+	return &MemberHandler{
+		memberService: memberService,
+		validator:     validator.New(),
+	}
+}
+
+func (h *MemberHandler) GetMembers(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	members, err := h.memberService.GetMembers(organizationID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch members")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, members)
+}
+
+func (h *MemberHandler) ChangeMemberRole(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	userOrgRole, exists := c.Get("user_org_role")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "User role not found in context")
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req services.ChangeMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	member, err := h.memberService.ChangeMemberRole(auditContext(c), organizationID.(string),
+		userOrgRole.(models.UserOrganizationRole).Role, memberID.String(), req.Role)
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, member)
+}
+
+func (h *MemberHandler) RemoveMember(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	userOrgRole, exists := c.Get("user_org_role")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "User role not found in context")
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if err := h.memberService.RemoveMember(auditContext(c), organizationID.(string),
+		userOrgRole.(models.UserOrganizationRole).Role, memberID.String()); err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func (h *MemberHandler) TransferAdmin(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.TransferAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	members, err := h.memberService.TransferAdmin(auditContext(c), organizationID.(string), &req)
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, members)
+}
+
+func respondMemberError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMemberNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Member not found")
+	case errors.Is(err, services.ErrLastOrgAdmin):
+		utils.ErrorResponse(c, http.StatusConflict, "Organization must keep at least one org_admin")
+	case errors.Is(err, services.ErrRoleExceedsPermissions):
+		utils.ErrorResponse(c, http.StatusForbidden, "Cannot manage a role with more permissions than your own")
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+}
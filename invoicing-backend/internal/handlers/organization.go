@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type OrganizationHandler struct {
+	organizationService *services.OrganizationService
+	validator           *validator.Validate
+}
+
+func NewOrganizationHandler(organizationService *services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{
+		organizationService: organizationService,
+		validator:           validator.New(),
+	}
+}
+
+// GetOrganizations lists the caller's organizations
+func (h *OrganizationHandler) GetOrganizations(c *gin.Context) {
+	userID := utils.GetUserIDFromContext(c)
+
+	organizations, err := h.organizationService.GetOrganizationsForUser(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch organizations")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, organizations)
+}
+
+func (h *OrganizationHandler) GetOrganization(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	org, err := h.organizationService.GetOrganizationByID(organizationID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Organization not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, org)
+}
+
+func (h *OrganizationHandler) CreateOrganization(c *gin.Context) {
+	userID := utils.GetUserIDFromContext(c)
+
+	var req services.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	org, err := h.organizationService.CreateOrganization(userID, &req)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, org)
+}
+
+func (h *OrganizationHandler) UpdateOrganization(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.UpdateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	org, err := h.organizationService.UpdateOrganization(auditContext(c), organizationID.(string), &req)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, org)
+}
+
+// UpdateSettings replaces one settings section, e.g. PUT /organizations/:organization_id/settings/invoice_settings
+func (h *OrganizationHandler) UpdateSettings(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var payload json.RawMessage
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	settings, err := h.organizationService.UpdateSettingsSection(auditContext(c), organizationID.(string), c.Param("section"), payload)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, settings)
+}
+
+// SetCurrentOrganization switches the organization used when requests don't name one
+func (h *OrganizationHandler) SetCurrentOrganization(c *gin.Context) {
+	userID := utils.GetUserIDFromContext(c)
+
+	var req struct {
+		OrganizationID string `json:"organization_id" validate:"required,uuid"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	org, err := h.organizationService.SetCurrentOrganization(userID, req.OrganizationID)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, org)
+}
+
+// CheckSubdomainAvailability reports whether ?subdomain= can be claimed
+func (h *OrganizationHandler) CheckSubdomainAvailability(c *gin.Context) {
+	subdomain := c.Query("subdomain")
+	if subdomain == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "subdomain query parameter is required")
+		return
+	}
+
+	availability, err := h.organizationService.CheckSubdomainAvailability(subdomain)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check subdomain")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, availability)
+}
+
+func (h *OrganizationHandler) GetCustomDomain(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	status, err := h.organizationService.GetCustomDomain(organizationID.(string))
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, status)
+}
+
+// SetCustomDomain assigns a custom domain and returns the TXT record that proves ownership
+func (h *OrganizationHandler) SetCustomDomain(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.CustomDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	status, err := h.organizationService.SetCustomDomain(auditContext(c), organizationID.(string), req.Domain)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, status)
+}
+
+func (h *OrganizationHandler) VerifyCustomDomain(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	status, err := h.organizationService.VerifyCustomDomain(c.Request.Context(), auditContext(c), organizationID.(string))
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, status)
+}
+
+func (h *OrganizationHandler) RemoveCustomDomain(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	if err := h.organizationService.RemoveCustomDomain(auditContext(c), organizationID.(string)); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Custom domain removed successfully"})
+}
+
+func respondOrganizationError(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		utils.ValidationErrorResponse(c, err)
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Organization not found")
+	case errors.Is(err, services.ErrSubdomainTaken):
+		utils.ErrorResponse(c, http.StatusConflict, "Subdomain is already taken")
+	case errors.Is(err, services.ErrSubdomainReserved), errors.Is(err, services.ErrInvalidSubdomain),
+		errors.Is(err, services.ErrInvalidCustomDomain):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrCustomDomainTaken):
+		utils.ErrorResponse(c, http.StatusConflict, "Custom domain is already in use")
+	case errors.Is(err, services.ErrCustomDomainNotSet):
+		utils.ErrorResponse(c, http.StatusNotFound, "Organization has no custom domain")
+	case errors.Is(err, services.ErrCustomDomainNotVerified):
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, services.ErrUnknownSettings):
+		utils.ErrorResponse(c, http.StatusNotFound, "Unknown settings section")
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+}
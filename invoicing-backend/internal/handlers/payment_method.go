@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type PaymentMethodHandler struct {
+	paymentMethodService *services.PaymentMethodService
+	validator            *validator.Validate
+}
+
+func NewPaymentMethodHandler(paymentMethodService *services.PaymentMethodService) *PaymentMethodHandler {
+	return &PaymentMethodHandler{
+		paymentMethodService: paymentMethodService,
+		validator:            validator.New(),
+	}
+}
+
+func (h *PaymentMethodHandler) GetPaymentMethods(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	methods, err := h.paymentMethodService.ListPaymentMethods(organizationID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch payment methods")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, methods)
+}
+
+func (h *PaymentMethodHandler) CreatePaymentMethod(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.PaymentMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	method, err := h.paymentMethodService.CreatePaymentMethod(auditContext(c), organizationID.(string), &req)
+	if err != nil {
+		respondPaymentMethodError(c, err, "Failed to create payment method")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, method)
+}
+
+// UpdatePaymentMethod replaces the payment method
+func (h *PaymentMethodHandler) UpdatePaymentMethod(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	methodID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment method ID")
+		return
+	}
+
+	var req services.PaymentMethodRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	method, err := h.paymentMethodService.UpdatePaymentMethod(auditContext(c), organizationID.(string), methodID.String(), &req)
+	if err != nil {
+		respondPaymentMethodError(c, err, "Failed to update payment method")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, method)
+}
+
+func (h *PaymentMethodHandler) DeletePaymentMethod(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	methodID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment method ID")
+		return
+	}
+
+	if err := h.paymentMethodService.DeletePaymentMethod(auditContext(c), organizationID.(string), methodID.String()); err != nil {
+		respondPaymentMethodError(c, err, "Failed to delete payment method")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Payment method deleted successfully"})
+}
+
+func respondPaymentMethodError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrPaymentMethodNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Payment method not found")
+	case errors.Is(err, services.ErrInvalidPaymentMethod):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, fallback)
+	}
+}
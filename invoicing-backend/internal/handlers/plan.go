@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type PlanHandler struct {
+	planService *services.PlanService
+}
+
+func NewPlanHandler(planService *services.PlanService) *PlanHandler {
+	return &PlanHandler{planService: planService}
+}
+
+// GetPlans lists the public plans with their prices, limits and features
+func (h *PlanHandler) GetPlans(c *gin.Context) {
+	plans, err := h.planService.ListPlans()
+	if err != nil {
+		log.Printf("failed to list plans: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch plans")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, plans)
+}
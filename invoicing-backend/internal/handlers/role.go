@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type RoleHandler struct {
+	roleService *services.RoleService
+	validator   *validator.Validate
+}
+
+func NewRoleHandler(roleService *services.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+		validator:   validator.New(),
+	}
+}
+
+// GetResources returns the resources and actions that can be granted to a role
+func (h *RoleHandler) GetResources(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, models.ResourceActions)
+}
+
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	roles, err := h.roleService.GetRoles(organizationID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch roles")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, roles)
+}
+
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role ID")
+		return
+	}
+
+	role, err := h.roleService.GetRoleByID(organizationID.(string), id.String())
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, role)
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	userOrgRole, exists := c.Get("user_org_role")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "User role not found in context")
+		return
+	}
+
+	var req services.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	role, err := h.roleService.CreateRole(auditContext(c), organizationID.(string), userOrgRole.(models.UserOrganizationRole).Role, &req)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, role)
+}
+
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	userOrgRole, exists := c.Get("user_org_role")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "User role not found in context")
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role ID")
+		return
+	}
+
+	var req services.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(auditContext(c), organizationID.(string), id.String(), userOrgRole.(models.UserOrganizationRole).Role, &req)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, role)
+}
+
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	userOrgRole, exists := c.Get("user_org_role")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "User role not found in context")
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid role ID")
+		return
+	}
+
+	if err := h.roleService.DeleteRole(auditContext(c), organizationID.(string), id.String(), userOrgRole.(models.UserOrganizationRole).Role); err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+func respondRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Role not found")
+	case errors.Is(err, services.ErrSystemRoleImmutable):
+		utils.ErrorResponse(c, http.StatusForbidden, "System roles cannot be modified")
+	case errors.Is(err, services.ErrRoleNameTaken):
+		utils.ErrorResponse(c, http.StatusConflict, "Role name is already in use")
+	case errors.Is(err, services.ErrRoleInUse):
+		utils.ErrorResponse(c, http.StatusConflict, "Role is still assigned to members, invitations or SSO")
+	case errors.Is(err, services.ErrRoleExceedsPermissions):
+		utils.ErrorResponse(c, http.StatusForbidden, "Cannot manage a role with more permissions than your own")
+	default:
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{sessionService: sessionService}
+}
+
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	userID := utils.GetUserIDFromContext(c)
+	currentSessionID := c.GetString("session_id")
+
+	sessions, err := h.sessionService.GetActiveSessions(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch sessions")
+		return
+	}
+
+	response := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, gin.H{
+			"id":           session.ID,
+			"user_agent":   session.UserAgent,
+			"ip_address":   session.IPAddress,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID == currentSessionID,
+			// Set when a platform admin is acting as the user
+			"impersonator_id": session.ImpersonatorID,
+		})
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, response)
+}
+
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID := utils.GetUserIDFromContext(c)
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(sessionID.String(), userID); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Session not found")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions signs the user out everywhere except the current session
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userID := utils.GetUserIDFromContext(c)
+
+	currentSessionID := c.GetString("session_id")
+	if currentSessionID == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Session management requires a user login")
+		return
+	}
+
+	revoked, err := h.sessionService.RevokeOtherSessions(userID, currentSessionID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"revoked": revoked})
+}
+
+// ForceLogoutMember ends all sessions of an organization member in this organization (org admin action)
+func (h *SessionHandler) ForceLogoutMember(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	memberID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	revoked, err := h.sessionService.ForceLogoutMember(organizationID.(string), memberID.String())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"revoked": revoked})
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+type SSOHandler struct {
+	oidcService    *services.OIDCService
+	sessionService *services.SessionService
+	validator      *validator.Validate
+}
+
+func NewSSOHandler(oidcService *services.OIDCService, sessionService *services.SessionService) *SSOHandler {
+	return &SSOHandler{
+		oidcService:    oidcService,
+		sessionService: sessionService,
+		validator:      validator.New(),
+	}
+}
+
+// BeginLogin redirects the browser to the organization's identity provider
+func (h *SSOHandler) BeginLogin(c *gin.Context) {
+	organizationID, err := uuid.Parse(c.Param("organization_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid organization ID")
+		return
+	}
+
+	authURL, err := h.oidcService.BeginLogin(c.Request.Context(), organizationID.String())
+	if err != nil {
+		if errors.Is(err, services.ErrSSONotConfigured) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Single sign-on is not configured for this organization")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadGateway, "Failed to contact identity provider")
+		return
+	}
+
+	// API clients can ask for the URL instead of following a redirect
+	if c.Query("redirect") == "false" {
+		utils.SuccessResponse(c, http.StatusOK, gin.H{"authorization_url": authURL})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the authorization code flow and issues a session token
+func (h *SSOHandler) Callback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Identity provider returned an error: "+errCode)
+		return
+	}
+
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Missing code or state")
+		return
+	}
+
+	// Link flows are finished by the signed-in user on the frontend
+	if linkURL, ok := h.oidcService.LinkCompletionURL(code, state); ok {
+		c.Redirect(http.StatusFound, linkURL)
+		return
+	}
+
+	user, err := h.oidcService.CompleteLogin(c.Request.Context(), code, state)
+	if err != nil {
+		respondSSOError(c, err)
+		return
+	}
+
+	_, token, err := h.sessionService.CreateSession(user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to generate token")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{
+		"user":  user,
+		"token": token,
+	})
+}
+
+type CompleteLinkRequest struct {
+	Code  string `json:"code" validate:"required"`
+	State string `json:"state" validate:"required"`
+}
+
+// BeginLink returns the identity provider URL for linking an identity of the
+// organization's provider to the current user
+func (h *SSOHandler) BeginLink(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	// Linking signs the user in through the identity, so impersonators can't do it
+	if _, impersonating := c.Get("impersonator_id"); impersonating {
+		utils.ErrorResponse(c, http.StatusForbidden, "Identities cannot be linked while impersonating")
+		return
+	}
+
+	authURL, err := h.oidcService.BeginLink(c.Request.Context(), c.GetString("user_id"), organizationID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrSSONotConfigured) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Single sign-on is not configured for this organization")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusBadGateway, "Failed to contact identity provider")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"authorization_url": authURL})
+}
+
+// CompleteLink links the identity returned to the callback to the current user
+func (h *SSOHandler) CompleteLink(c *gin.Context) {
+	if _, impersonating := c.Get("impersonator_id"); impersonating {
+		utils.ErrorResponse(c, http.StatusForbidden, "Identities cannot be linked while impersonating")
+		return
+	}
+
+	var req CompleteLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	user, err := h.oidcService.CompleteLink(c.Request.Context(), c.GetString("user_id"), req.Code, req.State)
+	if err != nil {
+		respondSSOError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"user": user})
+}
+
+func (h *SSOHandler) GetSSOConfig(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	cfg, err := h.oidcService.GetSSOConfig(organizationID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Single sign-on is not configured")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, cfg)
+}
+
+func (h *SSOHandler) UpdateSSOConfig(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.SSOConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	cfg, err := h.oidcService.UpsertSSOConfig(c.Request.Context(), organizationID.(string), &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, cfg)
+}
+
+func (h *SSOHandler) DeleteSSOConfig(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	if err := h.oidcService.DeleteSSOConfig(organizationID.(string)); err != nil {
+		if errors.Is(err, services.ErrSSONotConfigured) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Single sign-on is not configured")
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete SSO configuration")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "SSO configuration deleted successfully"})
+}
+
+func (h *SSOHandler) GetSSODomains(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	domains, err := h.oidcService.ListSSODomains(organizationID.(string))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch SSO domains")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, domains)
+}
+
+func (h *SSOHandler) AddSSODomain(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.SSODomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	status, err := h.oidcService.AddSSODomain(organizationID.(string), req.Domain)
+	if err != nil {
+		respondSSOError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, status)
+}
+
+func (h *SSOHandler) VerifySSODomain(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	domainID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid SSO domain ID")
+		return
+	}
+
+	status, err := h.oidcService.VerifySSODomain(c.Request.Context(), organizationID.(string), domainID.String())
+	if err != nil {
+		respondSSOError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, status)
+}
+
+func (h *SSOHandler) DeleteSSODomain(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	domainID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid SSO domain ID")
+		return
+	}
+
+	if err := h.oidcService.RemoveSSODomain(organizationID.(string), domainID.String()); err != nil {
+		respondSSOError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"message": "SSO domain removed successfully"})
+}
+
+func respondSSOError(c *gin.Context, err error) {
+	if respondUsageLimitError(c, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrSSOInvalidState):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid or expired login attempt")
+	case errors.Is(err, services.ErrSSONotConfigured):
+		utils.ErrorResponse(c, http.StatusNotFound, "Single sign-on is not configured for this organization")
+	case errors.Is(err, services.ErrSSOEmailNotAllowed):
+		utils.ErrorResponse(c, http.StatusForbidden, "Email domain is not allowed for this organization")
+	case errors.Is(err, services.ErrSSOLinkRequired), errors.Is(err, services.ErrSSOIdentityLinked):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrSSODomainNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "SSO domain not found")
+	case errors.Is(err, services.ErrSSODomainTaken):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrSSODomainNotVerified):
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Single sign-on failed")
+	}
+}
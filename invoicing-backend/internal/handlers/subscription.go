@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+// Webhook bodies larger than this are rejected
+const maxWebhookBodySize = 1 << 20
+
+type SubscriptionHandler struct {
+	subscriptionService *services.SubscriptionService
+	usageService        *services.UsageService
+	validator           *validator.Validate
+}
+
+func NewSubscriptionHandler(subscriptionService *services.SubscriptionService, usageService *services.UsageService) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		subscriptionService: subscriptionService,
+		usageService:        usageService,
+		validator:           validator.New(),
+	}
+}
+
+// GetSubscription returns the organization's subscription
+func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	subscription, err := h.subscriptionService.GetSubscription(organizationID.(string))
+	if err != nil {
+		respondSubscriptionError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, subscription)
+}
+
+// GetUsage returns the organization's usage in the current billing period and monthly history
+func (h *SubscriptionHandler) GetUsage(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	usage, err := h.usageService.GetUsage(organizationID.(string))
+	if err != nil {
+		respondSubscriptionError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, usage)
+}
+
+// StartCheckout returns the provider page where the customer completes the plan purchase or change
+func (h *SubscriptionHandler) StartCheckout(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	checkout, err := h.subscriptionService.StartCheckout(c.Request.Context(), auditContext(c), organizationID.(string), &req)
+	if err != nil {
+		respondSubscriptionError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, checkout)
+}
+
+// GetPortalURL returns a link to the billing provider's customer portal
+func (h *SubscriptionHandler) GetPortalURL(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	portalURL, err := h.subscriptionService.GetPortalURL(c.Request.Context(), organizationID.(string))
+	if err != nil {
+		respondSubscriptionError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, gin.H{"url": portalURL})
+}
+
+// CancelSubscription cancels the paid subscription and returns to the free plan
+func (h *SubscriptionHandler) CancelSubscription(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.CancelSubscriptionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	subscription, err := h.subscriptionService.CancelSubscription(c.Request.Context(), auditContext(c),
+		organizationID.(string), req.Reason)
+	if err != nil {
+		respondSubscriptionError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, subscription)
+}
+
+// ChangePlan upgrades or downgrades the organization's plan, or previews the change
+func (h *SubscriptionHandler) ChangePlan(c *gin.Context) {
+	// Get organization ID from RBAC middleware context
+	organizationID, exists := c.Get("organization_id")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
+		return
+	}
+
+	var req services.ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		utils.ValidationErrorResponse(c, err)
+		return
+	}
+
+	change, err := h.subscriptionService.ChangePlan(c.Request.Context(), auditContext(c), organizationID.(string), &req)
+	if err != nil {
+		respondSubscriptionError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, change)
+}
+
+// Webhook receives a billing provider's webhook events. Any non-2xx response makes the provider retry.
+func (h *SubscriptionHandler) Webhook(provider string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		if err := h.subscriptionService.HandleWebhook(c.Request.Context(), provider, c.Request.Header, body); err != nil {
+			respondWebhookError(c, err)
+			return
+		}
+
+		utils.SuccessResponse(c, http.StatusOK, gin.H{"received": true})
+	}
+}
+
+func respondWebhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidWebhookSignature):
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, services.ErrInvalidWebhookPayload):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrPaymentProviderNotConfigured):
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+	default:
+		log.Printf("failed to process webhook: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process webhook")
+	}
+}
+
+func respondSubscriptionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSubscriptionNotFound), errors.Is(err, services.ErrNoBillingSubscription):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrPlanNotPurchasable), errors.Is(err, services.ErrPortalNotSupported),
+		errors.Is(err, services.ErrUnknownPlan):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrAlreadyOnPlan), errors.Is(err, services.ErrBillingProviderMismatch):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrPaymentProviderNotConfigured):
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, err.Error())
+	case isCouponError(err):
+		respondCouponError(c, err)
+	default:
+		log.Printf("subscription request failed: %v", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Subscription request failed")
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+)
+
+// respondUsageLimitError writes a 403 with the exceeded limit and current usage.
+// It reports whether err was a usage limit error.
+func respondUsageLimitError(c *gin.Context, err error) bool {
+	var limitErr *services.UsageLimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	utils.ErrorResponseWithDetails(c, http.StatusForbidden, limitErr.Error(), limitErr)
+	return true
+}
@@ -6,23 +6,38 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/services"
 	"github.com/yourusername/invoicing-backend/internal/utils"
 	"gorm.io/gorm"
 )
 
 // AuthMiddleware provides authentication functionality
 type AuthMiddleware struct {
-	db *gorm.DB
+	db             *gorm.DB
+	authz          *services.AuthzCache
+	apiKeyService  *services.APIKeyService
+	sessionService *services.SessionService
 }
 
 // NewAuthMiddleware creates a new auth middleware instance
-func NewAuthMiddleware(db *gorm.DB) *AuthMiddleware {
-	return &AuthMiddleware{db: db}
+func NewAuthMiddleware(db *gorm.DB, authz *services.AuthzCache) *AuthMiddleware {
+	return &AuthMiddleware{
+		db:             db,
+		authz:          authz,
+		apiKeyService:  services.NewAPIKeyService(db),
+		sessionService: services.NewSessionService(db, authz),
+	}
 }
 
-// JWTAuthMiddleware validates JWT tokens and loads user context
+// JWTAuthMiddleware validates JWT tokens or API keys and loads user context
 func (auth *AuthMiddleware) JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API keys may be sent in a dedicated header instead of Authorization
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			auth.authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Authorization header required")
@@ -37,19 +52,41 @@ func (auth *AuthMiddleware) JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		userID, err := utils.ValidateJWT(tokenString)
+		if strings.HasPrefix(tokenString, models.APIKeyPrefix) {
+			auth.authenticateAPIKey(c, tokenString)
+			return
+		}
+
+		claims, err := utils.ParseJWT(tokenString)
+		if err != nil || claims.SessionID == "" {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token")
+			c.Abort()
+			return
+		}
+		userID := claims.UserID
+
+		// Tokens are only honoured while their server-side session is active
+		session, err := auth.sessionService.ValidateSession(claims.SessionID, userID.String(), c.ClientIP())
 		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Session revoked or expired")
+			c.Abort()
+			return
+		}
+
+		// The token and the session must agree on whether this is an impersonation
+		impersonatorID := ""
+		if session.IsImpersonation() {
+			impersonatorID = *session.ImpersonatorID
+		}
+		if claims.ImpersonatorID != impersonatorID {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired token")
 			c.Abort()
 			return
 		}
 
-		// Load user with organization roles for context
-		var user models.User
-		if err := auth.db.Preload("UserOrganizationRoles.Role").
-			Preload("UserOrganizationRoles.Organization").
-			Preload("CurrentOrganization").
-			First(&user, "id = ?", userID).Error; err != nil {
+		// Load user with organization memberships for context
+		user, err := auth.authz.GetUser(userID.String())
+		if err != nil {
 			utils.ErrorResponse(c, http.StatusUnauthorized, "User not found")
 			c.Abort()
 			return
@@ -57,11 +94,48 @@ func (auth *AuthMiddleware) JWTAuthMiddleware() gin.HandlerFunc {
 
 		// Set user context
 		c.Set("user_id", userID.String())
-		c.Set("user", user)
+		c.Set("user", *user)
+		c.Set("session_id", claims.SessionID)
+		c.Set("session_started_at", session.CreatedAt)
+
+		if session.IsImpersonation() {
+			// Every impersonated request is marked and recorded
+			c.Set("impersonator_id", impersonatorID)
+			c.Header("X-Impersonated-By", impersonatorID)
+			c.Next()
+			auth.sessionService.RecordImpersonatedRequest(session, c.Request.Method, c.Request.URL.Path,
+				c.Writer.Status(), c.ClientIP(), c.Request.UserAgent())
+			return
+		}
+
 		c.Next()
 	}
 }
 
+// authenticateAPIKey validates an API key and sets the same context keys as a user JWT.
+// The key acts on behalf of the user who created it; its permissions are applied
+// by OrganizationContextMiddleware.
+func (auth *AuthMiddleware) authenticateAPIKey(c *gin.Context, rawKey string) {
+	apiKey, err := auth.apiKeyService.Authenticate(rawKey, c.ClientIP())
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid or expired API key")
+		c.Abort()
+		return
+	}
+
+	user, err := auth.authz.GetUser(apiKey.CreatedBy)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "API key owner not found")
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", apiKey.CreatedBy)
+	c.Set("user", *user)
+	c.Set("api_key", *apiKey)
+	c.Next()
+}
+
 // Legacy function for backwards compatibility
 func JWTAuthMiddleware() gin.HandlerFunc {
 	// This will require database access to be fully functional
@@ -110,22 +184,27 @@ func (auth *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			return
 		}
 
-		userID, err := utils.ValidateJWT(tokenString)
-		if err != nil {
+		claims, err := utils.ParseJWT(tokenString)
+		if err != nil || claims.SessionID == "" {
 			// Invalid token, continue without setting user context
 			c.Next()
 			return
 		}
+		userID := claims.UserID
+
+		if _, err := auth.sessionService.ValidateSession(claims.SessionID, userID.String(), c.ClientIP()); err != nil {
+			// Revoked or expired session, continue without setting user context
+			c.Next()
+			return
+		}
 
 		// Valid token, set user context
 		c.Set("user_id", userID.String())
+		c.Set("session_id", claims.SessionID)
 
 		// Load user data if needed
-		var user models.User
-		if err := auth.db.Preload("UserOrganizationRoles.Role").
-			Preload("UserOrganizationRoles.Organization").
-			First(&user, "id = ?", userID).Error; err == nil {
-			c.Set("user", user)
+		if user, err := auth.authz.GetUser(userID.String()); err == nil {
+			c.Set("user", *user)
 		}
 
 		c.Next()
@@ -1,20 +1,32 @@
 package middleware
 
 import (
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/yourusername/invoicing-backend/internal/services"
 )
 
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware allows the local frontend, the organization portals served from
+// subdomains of baseDomain when it is set, and verified custom domains
+func CORSMiddleware(baseDomain string, authz *services.AuthzCache) gin.HandlerFunc {
+	origins := []string{
+		"http://localhost:3000",
+		"http://127.0.0.1:3000",
+		"https://localhost:3000",
+		"https://127.0.0.1:3000",
+	}
+	if baseDomain != "" {
+		origins = append(origins, "https://*."+baseDomain)
+	}
+
 	return cors.New(cors.Config{
-		AllowOrigins: []string{
-			"http://localhost:3000",
-			"http://127.0.0.1:3000",
-			"https://localhost:3000",
-			"https://127.0.0.1:3000",
-		},
+		AllowOrigins:    origins,
+		AllowWildcard:   baseDomain != "",
+		AllowOriginFunc: customDomainOrigin(authz),
 		AllowMethods: []string{
 			"GET",
 			"POST",
@@ -32,12 +44,32 @@ func CORSMiddleware() gin.HandlerFunc {
 			"DNT",
 			"Cache-Control",
 			"X-Requested-With",
+			"X-API-Key",
+			"X-Request-ID",
 		},
 		ExposeHeaders: []string{
 			"Content-Length",
 			"Content-Type",
+			"X-Request-ID",
 		},
 		AllowCredentials: true,
 		MaxAge:           24 * time.Hour,
 	})
 }
+
+// customDomainOrigin accepts https origins on the default port whose host is an
+// organization's verified custom domain
+func customDomainOrigin(authz *services.AuthzCache) func(origin string) bool {
+	return func(origin string) bool {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme != "https" || u.Port() != "" || u.Path != "" {
+			return false
+		}
+		host := strings.ToLower(u.Hostname())
+		if host == "" {
+			return false
+		}
+		orgID, err := authz.GetOrganizationIDByCustomDomain(host)
+		return err == nil && orgID != ""
+	}
+}
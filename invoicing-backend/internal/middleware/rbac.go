@@ -1,22 +1,36 @@
 package middleware
 
 import (
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yourusername/invoicing-backend/internal/database"
 	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/services"
 	"github.com/yourusername/invoicing-backend/internal/utils"
 	"gorm.io/gorm"
 )
 
 // RBACMiddleware provides role-based access control functionality
 type RBACMiddleware struct {
-	db *gorm.DB
+	db            *gorm.DB
+	authz         *services.AuthzCache
+	subscriptions *services.SubscriptionService
+	baseDomain    string
 }
 
-// NewRBACMiddleware creates a new RBAC middleware instance
-func NewRBACMiddleware(db *gorm.DB) *RBACMiddleware {
-	return &RBACMiddleware{db: db}
+// NewRBACMiddleware creates a new RBAC middleware instance. Requests to
+// <subdomain>.<baseDomain> or a verified custom domain resolve to that organization.
+func NewRBACMiddleware(db *gorm.DB, authz *services.AuthzCache, subscriptions *services.SubscriptionService, baseDomain string) *RBACMiddleware {
+	return &RBACMiddleware{
+		db:            db,
+		authz:         authz,
+		subscriptions: subscriptions,
+		baseDomain:    strings.ToLower(strings.TrimSuffix(baseDomain, ".")),
+	}
 }
 
 // OrganizationContextMiddleware extracts and validates organization context
@@ -31,14 +45,18 @@ func (rbac *RBACMiddleware) OrganizationContextMiddleware() gin.HandlerFunc {
 
 		userID := userIDStr.(string)
 
+		// API keys are bound to a single organization and carry their own permissions
+		if apiKeyInterface, ok := c.Get("api_key"); ok {
+			rbac.setAPIKeyOrganizationContext(c, apiKeyInterface.(models.APIKey))
+			return
+		}
+
 		// Get organization ID from header, query param, or path param
 		orgID := rbac.extractOrganizationID(c)
 		if orgID == "" {
 			// If no org ID specified, use user's current organization
-			var user models.User
-			if err := rbac.db.Preload("UserOrganizationRoles.Role").
-				Preload("UserOrganizationRoles.Organization").
-				First(&user, "id = ?", userID).Error; err != nil {
+			user, err := rbac.contextUser(c, userID)
+			if err != nil {
 				utils.ErrorResponse(c, http.StatusUnauthorized, "User not found")
 				c.Abort()
 				return
@@ -53,14 +71,27 @@ func (rbac *RBACMiddleware) OrganizationContextMiddleware() gin.HandlerFunc {
 		}
 
 		// Verify user has access to this organization
-		var userOrgRole models.UserOrganizationRole
-		if err := rbac.db.Preload("Role").Preload("Organization").
-			Where("user_id = ? AND organization_id = ?", userID, orgID).
-			First(&userOrgRole).Error; err != nil {
+		membership, err := rbac.authz.GetMembership(userID, orgID)
+		if err != nil {
 			utils.ErrorResponse(c, http.StatusForbidden, "Access denied to organization")
 			c.Abort()
 			return
 		}
+		userOrgRole := *membership
+
+		// Sessions started before a force logout from this organization no longer reach it
+		if startedAt, ok := c.Get("session_started_at"); ok && userOrgRole.RevokesSession(startedAt.(time.Time)) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Session revoked for this organization")
+			c.Abort()
+			return
+		}
+
+		// Suspended organizations are only reachable by platform admins
+		if userOrgRole.Organization.IsSuspended() && userOrgRole.Role.Name != models.RolePlatformAdmin {
+			utils.ErrorResponse(c, http.StatusForbidden, "Organization is suspended")
+			c.Abort()
+			return
+		}
 
 		// Set context variables
 		c.Set("organization_id", orgID)
@@ -72,6 +103,44 @@ func (rbac *RBACMiddleware) OrganizationContextMiddleware() gin.HandlerFunc {
 	}
 }
 
+// setAPIKeyOrganizationContext populates the organization context for an API key request.
+// The key acts on behalf of its creator, so it only keeps the permissions the creator's
+// current role still grants, and stops working once the creator leaves the organization.
+func (rbac *RBACMiddleware) setAPIKeyOrganizationContext(c *gin.Context, apiKey models.APIKey) {
+	if orgID := rbac.extractOrganizationID(c); orgID != "" && orgID != apiKey.OrganizationID {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied to organization")
+		c.Abort()
+		return
+	}
+
+	membership, err := rbac.authz.GetMembership(apiKey.CreatedBy, apiKey.OrganizationID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusForbidden, "Access denied to organization")
+		c.Abort()
+		return
+	}
+	if membership.Organization.IsSuspended() {
+		utils.ErrorResponse(c, http.StatusForbidden, "Organization is suspended")
+		c.Abort()
+		return
+	}
+
+	role := apiKey.AsRole()
+	role.Permissions = role.Permissions.Intersect(membership.Role.Permissions)
+	userOrgRole := models.UserOrganizationRole{
+		UserID:         apiKey.CreatedBy,
+		OrganizationID: apiKey.OrganizationID,
+		Role:           role,
+	}
+
+	c.Set("organization_id", apiKey.OrganizationID)
+	c.Set("user_role", userOrgRole.Role.Name)
+	c.Set("role_permissions", userOrgRole.Role.Permissions)
+	c.Set("user_org_role", userOrgRole)
+
+	c.Next()
+}
+
 // RequirePermission creates middleware that checks specific resource/action permissions
 func (rbac *RBACMiddleware) RequirePermission(resource, action string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -117,7 +186,8 @@ func (rbac *RBACMiddleware) RequireOwnershipOrPermission(resource, action string
 		// Check ownership-based permissions
 		resourceID := c.Param("id")
 		if resourceID != "" {
-			isOwner := rbac.checkResourceOwnership(userID.(string), resource, resourceID, ownerField)
+			orgID, _ := c.Get("organization_id")
+			isOwner := rbac.checkResourceOwnership(userID.(string), orgID.(string), resource, resourceID, ownerField)
 			ownResource := "own_" + resource
 			if isOwner && userOrgRole.Role.HasPermission(ownResource, action) {
 				c.Next()
@@ -153,9 +223,18 @@ func (rbac *RBACMiddleware) RequireRole(allowedRoles ...string) gin.HandlerFunc
 	}
 }
 
-// RequirePlatformAdmin ensures only platform admins can access the endpoint
+// RequirePlatformAdmin ensures only platform admins can access the endpoint.
+// Impersonation sessions never count, even when the impersonated user is an admin.
 func (rbac *RBACMiddleware) RequirePlatformAdmin() gin.HandlerFunc {
-	return rbac.RequireRole(models.RolePlatformAdmin)
+	requireRole := rbac.RequireRole(models.RolePlatformAdmin)
+	return func(c *gin.Context) {
+		if _, impersonating := c.Get("impersonator_id"); impersonating {
+			utils.ErrorResponse(c, http.StatusForbidden, "Not available while impersonating")
+			c.Abort()
+			return
+		}
+		requireRole(c)
+	}
 }
 
 // RequireOrgAdmin ensures only organization admins (or platform admins) can access
@@ -163,7 +242,9 @@ func (rbac *RBACMiddleware) RequireOrgAdmin() gin.HandlerFunc {
 	return rbac.RequireRole(models.RolePlatformAdmin, models.RoleOrgAdmin)
 }
 
-// RequireActiveSubscription ensures the organization has an active subscription
+// RequireActiveSubscription ensures the organization has a subscription. Past due and
+// expired subscriptions make the organization read-only: reads (including exports) pass,
+// changes are rejected until billing is resolved.
 func (rbac *RBACMiddleware) RequireActiveSubscription() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		orgID, exists := c.Get("organization_id")
@@ -173,72 +254,125 @@ func (rbac *RBACMiddleware) RequireActiveSubscription() gin.HandlerFunc {
 			return
 		}
 
-		var subscription models.Subscription
-		if err := rbac.db.Where("organization_id = ? AND status = ?",
-			orgID, models.SubscriptionStatusActive).First(&subscription).Error; err != nil {
+		subscription, err := rbac.authz.GetSubscription(orgID.(string))
+		if err != nil {
 			utils.ErrorResponse(c, http.StatusForbidden, "Active subscription required")
 			c.Abort()
 			return
 		}
 
-		// Check if subscription is expired
-		if subscription.IsExpired() {
-			utils.ErrorResponse(c, http.StatusForbidden, "Subscription expired")
-			c.Abort()
-			return
+		if !isReadOnlyRequest(c.Request.Method) {
+			if subscription.Status == models.SubscriptionStatusPastDue || subscription.Status == models.SubscriptionStatusUnpaid {
+				utils.ErrorResponseWithDetails(c, http.StatusForbidden,
+					"Subscription is past due; the organization is read-only until payment is resolved",
+					gin.H{"status": subscription.Status, "read_only": true})
+				c.Abort()
+				return
+			}
+			if !subscription.IsActive() || subscription.IsExpired() {
+				utils.ErrorResponseWithDetails(c, http.StatusForbidden,
+					"Subscription expired; the organization is read-only until the subscription is renewed",
+					gin.H{"status": models.SubscriptionStatusExpired, "read_only": true})
+				c.Abort()
+				return
+			}
 		}
 
-		c.Set("subscription", subscription)
+		// Past the downgrade grace period an organization over its limits is read-only;
+		// deletes stay allowed so it can get back under them
+		if subscription.LimitGraceExpired() && !isReadOnlyOrDelete(c.Request.Method) {
+			overages, err := rbac.subscriptions.CheckLimitGrace(subscription)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check plan limits")
+				c.Abort()
+				return
+			}
+			if len(overages) > 0 {
+				utils.ErrorResponseWithDetails(c, http.StatusForbidden,
+					"Organization exceeds its plan limits and is read-only; remove resources or upgrade", overages)
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("subscription", *subscription)
 		c.Next()
 	}
 }
 
-// EnforceUsageLimits middleware that checks subscription limits before allowing resource creation
-func (rbac *RBACMiddleware) EnforceUsageLimits(resourceType string) gin.HandlerFunc {
+// RequireFeature ensures the organization's plan includes the feature. Use it after
+// RequireActiveSubscription; which plans include a feature is set in the plans table.
+func (rbac *RBACMiddleware) RequireFeature(feature string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Only enforce limits on POST/CREATE requests
-		if c.Request.Method != "POST" {
-			c.Next()
-			return
-		}
-
-		subscriptionInterface, exists := c.Get("subscription")
-		if !exists {
-			utils.ErrorResponse(c, http.StatusForbidden, "Subscription context required")
-			c.Abort()
-			return
-		}
-
-		subscription := subscriptionInterface.(models.Subscription)
-		orgID, _ := c.Get("organization_id")
-
-		switch resourceType {
-		case "invoices":
-			if !rbac.checkInvoiceLimit(subscription, orgID.(string)) {
-				utils.ErrorResponse(c, http.StatusForbidden, "Monthly invoice limit reached")
-				c.Abort()
-				return
+		var subscription *models.Subscription
+		if sub, ok := c.Get("subscription"); ok {
+			if s, ok := sub.(models.Subscription); ok {
+				subscription = &s
 			}
-		case "clients":
-			if !rbac.checkClientLimit(subscription, orgID.(string)) {
-				utils.ErrorResponse(c, http.StatusForbidden, "Client limit reached")
+		}
+		if subscription == nil {
+			orgID, exists := c.Get("organization_id")
+			if !exists {
+				utils.ErrorResponse(c, http.StatusForbidden, "Organization context required")
 				c.Abort()
 				return
 			}
-		case "users":
-			if !rbac.checkUserLimit(subscription, orgID.(string)) {
-				utils.ErrorResponse(c, http.StatusForbidden, "User limit reached")
+
+			var err error
+			subscription, err = rbac.authz.GetSubscription(orgID.(string))
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusForbidden, "Active subscription required")
 				c.Abort()
 				return
 			}
 		}
 
+		plan, err := rbac.authz.GetPlan(subscription.PlanType)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusForbidden, "Plan not available")
+			c.Abort()
+			return
+		}
+
+		if !plan.HasFeature(feature) {
+			utils.ErrorResponseWithDetails(c, http.StatusForbidden, "Your plan does not include this feature",
+				gin.H{"feature": feature, "plan": plan.Code})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
 
 // Helper methods
 
+func isReadOnlyRequest(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func isReadOnlyOrDelete(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// contextUser returns the user loaded by the auth middleware, falling back to the cache
+func (rbac *RBACMiddleware) contextUser(c *gin.Context, userID string) (*models.User, error) {
+	if user, ok := c.Get("user"); ok {
+		if u, ok := user.(models.User); ok {
+			return &u, nil
+		}
+	}
+	return rbac.authz.GetUser(userID)
+}
+
 func (rbac *RBACMiddleware) extractOrganizationID(c *gin.Context) string {
 	// Try header first (preferred for API clients)
 	if orgID := c.GetHeader("X-Organization-ID"); orgID != "" {
@@ -255,41 +389,60 @@ func (rbac *RBACMiddleware) extractOrganizationID(c *gin.Context) string {
 		return orgID
 	}
 
-	return ""
+	// Fall back to the organization owning the request host
+	return rbac.organizationIDFromHost(c.Request.Host)
+}
+
+// organizationIDFromHost resolves <subdomain>.<baseDomain> or a verified custom domain
+func (rbac *RBACMiddleware) organizationIDFromHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" || host == rbac.baseDomain || net.ParseIP(host) != nil {
+		return ""
+	}
+
+	// Looked up on every request without an explicit organization, so cached
+	var orgID string
+	var err error
+	if rbac.baseDomain != "" && strings.HasSuffix(host, "."+rbac.baseDomain) {
+		subdomain := strings.TrimSuffix(host, "."+rbac.baseDomain)
+		if strings.Contains(subdomain, ".") || models.IsReservedSubdomain(subdomain) {
+			return ""
+		}
+		orgID, err = rbac.authz.GetOrganizationIDBySubdomain(subdomain)
+	} else {
+		orgID, err = rbac.authz.GetOrganizationIDByCustomDomain(host)
+	}
+	if err != nil {
+		return ""
+	}
+	return orgID
 }
 
-func (rbac *RBACMiddleware) checkResourceOwnership(userID, resourceType, resourceID, ownerField string) bool {
+func (rbac *RBACMiddleware) checkResourceOwnership(userID, orgID, resourceType, resourceID, ownerField string) bool {
 	var count int64
 
+	var model interface{}
 	switch resourceType {
 	case "invoices":
-		rbac.db.Model(&models.Invoice{}).Where("id = ? AND user_id = ?", resourceID, userID).Count(&count)
+		model = &models.Invoice{}
 	case "clients":
-		rbac.db.Model(&models.Client{}).Where("id = ? AND user_id = ?", resourceID, userID).Count(&count)
+		model = &models.Client{}
 	default:
 		return false
 	}
 
-	return count > 0
-}
-
-func (rbac *RBACMiddleware) checkInvoiceLimit(subscription models.Subscription, orgID string) bool {
-	var count int64
-	rbac.db.Model(&models.Invoice{}).
-		Where("organization_id = ? AND EXTRACT(MONTH FROM created_at) = EXTRACT(MONTH FROM CURRENT_DATE) AND EXTRACT(YEAR FROM created_at) = EXTRACT(YEAR FROM CURRENT_DATE)", orgID).
-		Count(&count)
-
-	return subscription.CanCreateInvoices(int(count))
-}
-
-func (rbac *RBACMiddleware) checkClientLimit(subscription models.Subscription, orgID string) bool {
-	var count int64
-	rbac.db.Model(&models.Client{}).Where("organization_id = ?", orgID).Count(&count)
-	return subscription.CanCreateClients(int(count))
-}
+	// The owning user must match within the current organization only
+	err := database.WithOrganization(rbac.db, orgID, func(tx *gorm.DB) error {
+		return tx.Model(model).
+			Where("id = ? AND organization_id = ? AND user_id = ?", resourceID, orgID, userID).
+			Count(&count).Error
+	})
+	if err != nil {
+		return false
+	}
 
-func (rbac *RBACMiddleware) checkUserLimit(subscription models.Subscription, orgID string) bool {
-	var count int64
-	rbac.db.Model(&models.UserOrganizationRole{}).Where("organization_id = ?", orgID).Count(&count)
-	return subscription.CanAddUsers(int(count))
+	return count > 0
 }
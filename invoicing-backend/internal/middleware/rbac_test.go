@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/cache"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/services"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+)
+
+func TestRequireActiveSubscription(t *testing.T) {
+	db := testutil.DB(t)
+	gin.SetMode(gin.TestMode)
+
+	user := models.User{Email: "user-" + uuid.NewString()[:8] + "@owner.example.com", FirstName: "Test", LastName: "User"}
+	if err := user.SetPassword("password123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM users WHERE id = ?", user.ID)
+	})
+
+	authz := services.NewAuthzCache(db, cache.NewLRU(100), time.Minute)
+	org, err := services.NewOrganizationService(db, authz, "example.com").
+		CreateOrganization(user.ID.String(), &services.CreateOrganizationRequest{Name: "Lapsed " + uuid.NewString()[:8]})
+	if err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	orgID := org.ID.String()
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM organizations WHERE id = ?", org.ID)
+	})
+
+	subscriptions := services.NewSubscriptionService(db, authz, services.NewLogNotifier(), nil, "", "", services.TrialConfig{})
+	rbac := NewRBACMiddleware(db, authz, subscriptions, "example.com")
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", user.ID.String())
+	})
+	router.Use(rbac.OrganizationContextMiddleware(), rbac.RequireActiveSubscription())
+	router.Any("/clients", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	lapsed := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name      string
+		status    models.SubscriptionStatus
+		periodEnd *time.Time
+		writable  bool
+	}{
+		{"active", models.SubscriptionStatusActive, nil, true},
+		{"expired", models.SubscriptionStatusExpired, nil, false},
+		{"period ended", models.SubscriptionStatusActive, &lapsed, false},
+		{"past due", models.SubscriptionStatusPastDue, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := db.Model(&models.Subscription{}).Where("organization_id = ?", orgID).Updates(map[string]interface{}{
+				"status":             tt.status,
+				"current_period_end": tt.periodEnd,
+			}).Error; err != nil {
+				t.Fatal(err)
+			}
+			authz.InvalidateOrganization(orgID)
+
+			for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+				req := httptest.NewRequest(method, "/clients", nil)
+				req.Header.Set("X-Organization-ID", orgID)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				// Reads stay available so a lapsed organization can still export its data
+				if method == http.MethodGet || tt.writable {
+					if rec.Code != http.StatusOK {
+						t.Errorf("%s: status %d, want 200: %s", method, rec.Code, rec.Body)
+					}
+					continue
+				}
+
+				var body struct {
+					Details struct {
+						ReadOnly bool `json:"read_only"`
+					} `json:"details"`
+				}
+				if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if rec.Code != http.StatusForbidden || !body.Details.ReadOnly {
+					t.Errorf("%s: status %d, want 403 read-only: %s", method, rec.Code, rec.Body)
+				}
+			}
+		})
+	}
+}
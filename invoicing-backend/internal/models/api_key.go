@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// RoleAPIKey is the synthetic role name used for requests authenticated with an API key
+const RoleAPIKey = "api_key"
+
+// APIKeyPrefix marks a bearer token as an API key rather than a user JWT
+const APIKeyPrefix = "inv_"
+
+type APIKey struct {
+	ID             string          `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string          `json:"organization_id" gorm:"not null;index"`
+	CreatedBy      string          `json:"created_by" gorm:"not null;index"`
+	Name           string          `json:"name" gorm:"not null;size:100" validate:"required,min=1,max=100"`
+	KeyPrefix      string          `json:"key_prefix" gorm:"not null;size:20"`
+	KeyHash        string          `json:"-" gorm:"not null;uniqueIndex;size:64"`
+	Permissions    RolePermissions `json:"permissions" gorm:"type:jsonb;not null;default:'{}'"`
+	ExpiresAt      *time.Time      `json:"expires_at"`
+	LastUsedAt     *time.Time      `json:"last_used_at"`
+	LastUsedIP     string          `json:"last_used_ip" gorm:"size:45"`
+	RevokedAt      *time.Time      `json:"revoked_at"`
+	CreatedAt      time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	Organization  Organization `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	CreatedByUser User         `json:"-" gorm:"foreignKey:CreatedBy;constraint:OnDelete:CASCADE;"`
+}
+
+// IsRevoked returns true if the key has been revoked
+func (k *APIKey) IsRevoked() bool {
+	return k.RevokedAt != nil
+}
+
+// IsExpired returns true if the key has passed its expiry time
+func (k *APIKey) IsExpired() bool {
+	if k.ExpiresAt == nil {
+		return false
+	}
+	return k.ExpiresAt.Before(time.Now())
+}
+
+// IsUsable returns true if the key can still authenticate requests
+func (k *APIKey) IsUsable() bool {
+	return !k.IsRevoked() && !k.IsExpired()
+}
+
+// AsRole returns a synthetic role carrying the key's permissions so the
+// regular RBAC checks can be applied to API key requests
+func (k *APIKey) AsRole() Role {
+	return Role{
+		Name:        RoleAPIKey,
+		Description: "API key: " + k.Name,
+		Permissions: k.Permissions,
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// Audit actor types
+const (
+	AuditActorUser   = "user"
+	AuditActorAPIKey = "api_key"
+	AuditActorSystem = "system" // e.g. billing provider webhooks
+)
+
+// Audit actions
+const (
+	AuditActionCreate       = "create"
+	AuditActionUpdate       = "update"
+	AuditActionDelete       = "delete"
+	AuditActionStatusChange = "status_change"
+	AuditActionRoleChange   = "role_change"
+	AuditActionJoin         = "join"
+	AuditActionResend       = "resend"
+	AuditActionRotate       = "rotate"
+	AuditActionRevoke       = "revoke"
+	AuditActionRequest      = "approval_request"
+	AuditActionApprove      = "approve"
+	AuditActionReject       = "reject"
+)
+
+// Audited resource types
+const (
+	AuditResourceClient               = "client"
+	AuditResourceInvoice              = "invoice"
+	AuditResourceInvoicePayment       = "invoice_payment"
+	AuditResourcePaymentMethod        = "payment_method"
+	AuditResourceMember               = "member"
+	AuditResourceInvitation           = "invitation"
+	AuditResourceOrganization         = "organization"
+	AuditResourceOrganizationSettings = "organization_settings"
+	AuditResourceRole                 = "role"
+	AuditResourceAPIKey               = "api_key"
+	AuditResourceSubscription         = "subscription"
+)
+
+// AuditEvent is an append-only record of a mutating action within an organization.
+// Changes holds the fields that differ, as {"before": {...}, "after": {...}}.
+type AuditEvent struct {
+	ID             string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string    `json:"organization_id" gorm:"not null;index"`
+	ActorType      string    `json:"actor_type" gorm:"not null;size:20"`
+	ActorUserID    *string   `json:"actor_user_id" gorm:"type:uuid;index"`
+	ActorAPIKeyID  *string   `json:"actor_api_key_id" gorm:"type:uuid"`
+	ImpersonatorID *string   `json:"impersonator_id,omitempty" gorm:"type:uuid"`
+	Action         string    `json:"action" gorm:"not null;size:50"`
+	ResourceType   string    `json:"resource_type" gorm:"not null;size:50"`
+	ResourceID     string    `json:"resource_id" gorm:"not null;size:255"`
+	Changes        JSONMap   `json:"changes" gorm:"type:jsonb;not null;default:'{}'"`
+	IPAddress      string    `json:"ip_address" gorm:"size:45"`
+	UserAgent      string    `json:"user_agent" gorm:"type:text"`
+	RequestID      string    `json:"request_id" gorm:"size:100;index"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
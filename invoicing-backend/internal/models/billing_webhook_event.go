@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// BillingWebhookEvent records a processed provider webhook so redeliveries are ignored
+type BillingWebhookEvent struct {
+	ID          string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Provider    string    `json:"provider" gorm:"not null;size:20;uniqueIndex:idx_billing_webhook_events_provider_event"`
+	EventID     string    `json:"event_id" gorm:"not null;size:255;uniqueIndex:idx_billing_webhook_events_provider_event"`
+	EventType   string    `json:"event_type" gorm:"not null;size:100"`
+	ResourceID  string    `json:"resource_id" gorm:"size:255;index"`
+	ProcessedAt time.Time `json:"processed_at" gorm:"autoCreateTime"`
+}
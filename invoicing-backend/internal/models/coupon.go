@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	CouponDiscountPercent = "percent"
+	CouponDiscountFixed   = "fixed"
+)
+
+// How long a redeemed coupon keeps discounting the plan price
+const (
+	CouponDurationOnce      = "once"
+	CouponDurationRepeating = "repeating"
+	CouponDurationForever   = "forever"
+)
+
+// Coupon is a discount on paid plans, redeemed by code at sign-up or on a plan change
+type Coupon struct {
+	ID               string      `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Code             string      `json:"code" gorm:"not null;size:50;uniqueIndex"` // upper case
+	Name             string      `json:"name" gorm:"not null;size:100"`
+	DiscountType     string      `json:"discount_type" gorm:"not null;size:10"`
+	PercentOff       float64     `json:"percent_off" gorm:"type:decimal(5,2);not null;default:0"`
+	AmountOff        float64     `json:"amount_off" gorm:"type:decimal(12,2);not null;default:0"`
+	Currency         string      `json:"currency,omitempty" gorm:"size:3"` // of AmountOff
+	Duration         string      `json:"duration" gorm:"not null;size:10"`
+	DurationInMonths int         `json:"duration_in_months,omitempty" gorm:"not null;default:0"`
+	MaxRedemptions   *int        `json:"max_redemptions"` // nil means unlimited
+	TimesRedeemed    int         `json:"times_redeemed" gorm:"not null;default:0"`
+	ExpiresAt        *time.Time  `json:"expires_at"`
+	Plans            CouponPlans `json:"plans" gorm:"type:jsonb;not null;default:'[]'"` // empty means every paid plan
+	IsActive         bool        `json:"is_active" gorm:"not null;default:true"`
+	CreatedBy        *string     `json:"created_by" gorm:"type:uuid"`
+	CreatedAt        time.Time   `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time   `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// CouponRedemption is an organization's use of a coupon. The discount starts when
+// the organization's paid subscription does.
+type CouponRedemption struct {
+	ID             string     `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	CouponID       string     `json:"coupon_id" gorm:"type:uuid;not null;uniqueIndex:idx_coupon_redemptions_coupon_organization"`
+	OrganizationID string     `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_coupon_redemptions_coupon_organization"`
+	RedeemedBy     *string    `json:"redeemed_by" gorm:"type:uuid"`
+	AppliedAt      *time.Time `json:"applied_at"` // nil until a paid plan is billed with it
+	EndsAt         *time.Time `json:"ends_at"`    // nil while pending and for forever coupons
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+
+	// Relationships
+	Coupon *Coupon `json:"coupon,omitempty" gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// IsExpired reports whether the coupon can no longer be redeemed because its expiry passed
+func (c *Coupon) IsExpired(now time.Time) bool {
+	return c.ExpiresAt != nil && !now.Before(*c.ExpiresAt)
+}
+
+// IsExhausted reports whether the coupon reached its redemption limit
+func (c *Coupon) IsExhausted() bool {
+	return c.MaxRedemptions != nil && c.TimesRedeemed >= *c.MaxRedemptions
+}
+
+// AppliesTo reports whether the coupon discounts the plan. The free plan has nothing to discount.
+func (c *Coupon) AppliesTo(plan SubscriptionPlan) bool {
+	if plan == SubscriptionPlanFree {
+		return false
+	}
+	if len(c.Plans) == 0 {
+		return true
+	}
+	for _, p := range c.Plans {
+		if p == plan {
+			return true
+		}
+	}
+	return false
+}
+
+// Discount returns the amount taken off a price in the currency. Fixed discounts
+// only apply in their own currency and never exceed the price.
+func (c *Coupon) Discount(amount float64, currency string) float64 {
+	if amount <= 0 {
+		return 0
+	}
+
+	var discount float64
+	switch c.DiscountType {
+	case CouponDiscountPercent:
+		discount = amount * c.PercentOff / 100
+	case CouponDiscountFixed:
+		if c.Currency != currency {
+			return 0
+		}
+		discount = c.AmountOff
+	}
+	return math.Round(math.Min(discount, amount)*100) / 100
+}
+
+// IsActive reports whether the redemption still discounts the subscription at now.
+// A pending redemption counts as active: it waits for a paid plan.
+func (r *CouponRedemption) IsActive(now time.Time) bool {
+	return r.EndsAt == nil || now.Before(*r.EndsAt)
+}
+
+// CouponPlans lists the plans a coupon is restricted to
+type CouponPlans []SubscriptionPlan
+
+// Implement the driver.Valuer interface for GORM JSONB support
+func (p CouponPlans) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]SubscriptionPlan(p))
+}
+
+// Implement the sql.Scanner interface for GORM JSONB support
+func (p *CouponPlans) Scan(value interface{}) error {
+	*p = CouponPlans{}
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal CouponPlans value: %v", value)
+	}
+
+	return json.Unmarshal(bytes, (*[]SubscriptionPlan)(p))
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+type InvitationStatus string
+
+const (
+	InvitationStatusPending  InvitationStatus = "pending"
+	InvitationStatusAccepted InvitationStatus = "accepted"
+	InvitationStatusRevoked  InvitationStatus = "revoked"
+)
+
+// OrganizationInvitation invites an email address to join an organization with a role
+type OrganizationInvitation struct {
+	ID             string           `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string           `json:"organization_id" gorm:"not null;index"`
+	Email          string           `json:"email" gorm:"not null;size:255"`
+	RoleID         string           `json:"role_id" gorm:"not null"`
+	InvitedBy      *string          `json:"invited_by"`
+	TokenHash      string           `json:"-" gorm:"not null;size:64"`
+	Status         InvitationStatus `json:"status" gorm:"not null;default:pending;index"`
+	ExpiresAt      time.Time        `json:"expires_at" gorm:"not null"`
+	LastSentAt     time.Time        `json:"last_sent_at" gorm:"not null"`
+	SendCount      int              `json:"send_count" gorm:"not null;default:1"`
+	AcceptedAt     *time.Time       `json:"accepted_at"`
+	AcceptedBy     *string          `json:"accepted_by"`
+	CreatedAt      time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	Organization  Organization `json:"organization" gorm:"constraint:OnDelete:CASCADE;"`
+	Role          Role         `json:"role"`
+	InvitedByUser *User        `json:"invited_by_user,omitempty" gorm:"foreignKey:InvitedBy;constraint:OnDelete:SET NULL;"`
+}
+
+// IsExpired returns true if the invitation can no longer be accepted because of its age
+func (i *OrganizationInvitation) IsExpired() bool {
+	return i.ExpiresAt.Before(time.Now())
+}
+
+// IsAcceptable returns true if the invitation is pending and not expired
+func (i *OrganizationInvitation) IsAcceptable() bool {
+	return i.Status == InvitationStatusPending && !i.IsExpired()
+}
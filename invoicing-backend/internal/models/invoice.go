@@ -16,6 +16,33 @@ const (
 	InvoiceStatusCancelled InvoiceStatus = "cancelled"
 )
 
+// invoiceStatusTransitions lists the statuses each status can change to. Paid and
+// cancelled invoices are final.
+var invoiceStatusTransitions = map[InvoiceStatus][]InvoiceStatus{
+	InvoiceStatusDraft:   {InvoiceStatusSent, InvoiceStatusCancelled},
+	InvoiceStatusSent:    {InvoiceStatusPaid, InvoiceStatusOverdue, InvoiceStatusCancelled},
+	InvoiceStatusOverdue: {InvoiceStatusSent, InvoiceStatusPaid, InvoiceStatusCancelled},
+}
+
+// IsValid reports whether s is a known invoice status
+func (s InvoiceStatus) IsValid() bool {
+	switch s {
+	case InvoiceStatusDraft, InvoiceStatusSent, InvoiceStatusPaid, InvoiceStatusOverdue, InvoiceStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether an invoice in status s may be moved to next
+func (s InvoiceStatus) CanTransitionTo(next InvoiceStatus) bool {
+	for _, allowed := range invoiceStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 type Invoice struct {
 	Base
 	UserID         string        `json:"user_id" gorm:"not null;index"`
@@ -33,6 +60,19 @@ type Invoice struct {
 	Notes          string        `json:"notes" gorm:"type:text"`
 	Terms          string        `json:"terms" gorm:"type:text"`
 
+	// Empty when the invoice has never been submitted for approval
+	ApprovalStatus InvoiceApprovalStatus `json:"approval_status,omitempty" gorm:"size:20"`
+
+	// Payment methods printed on the invoice; empty prints the organization's defaults
+	PaymentMethodIDs PaymentMethodIDs `json:"payment_method_ids" gorm:"type:jsonb;not null;default:'[]'"`
+	// Resolved from PaymentMethodIDs when the invoice is loaded
+	PaymentMethods []PaymentMethod `json:"payment_methods" gorm:"-"`
+
+	// Set when an online payment settled the invoice
+	PaidAt *time.Time `json:"paid_at"`
+	// Hash of the nonce in the current payment link; a new link revokes the previous one
+	PaymentLinkTokenHash string `json:"-" gorm:"size:64"`
+
 	// Relationships
 	User         User          `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
 	Organization Organization  `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
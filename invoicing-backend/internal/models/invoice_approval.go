@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+type InvoiceApprovalStatus string
+
+const (
+	InvoiceApprovalPending   InvoiceApprovalStatus = "pending"
+	InvoiceApprovalApproved  InvoiceApprovalStatus = "approved"
+	InvoiceApprovalRejected  InvoiceApprovalStatus = "rejected"
+	InvoiceApprovalCancelled InvoiceApprovalStatus = "cancelled" // the invoice was edited while pending
+)
+
+// InvoiceApproval is one request to approve a draft invoice and its outcome.
+// An invoice keeps the full history; at most one request is pending at a time.
+type InvoiceApproval struct {
+	ID              string                `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID  string                `json:"organization_id" gorm:"type:uuid;not null;index"`
+	InvoiceID       string                `json:"invoice_id" gorm:"type:uuid;not null;index"`
+	RequestedBy     string                `json:"requested_by" gorm:"type:uuid;not null"`
+	RequestComment  string                `json:"request_comment" gorm:"type:text"`
+	TotalAmount     float64               `json:"total_amount" gorm:"type:decimal(12,2);not null"`
+	Status          InvoiceApprovalStatus `json:"status" gorm:"not null;size:20;default:pending"`
+	DecidedBy       *string               `json:"decided_by" gorm:"type:uuid"`
+	DecisionComment string                `json:"decision_comment" gorm:"type:text"`
+	DecidedAt       *time.Time            `json:"decided_at"`
+	CreatedAt       time.Time             `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time             `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+type InvoicePaymentStatus string
+
+const (
+	InvoicePaymentPending   InvoicePaymentStatus = "pending"
+	InvoicePaymentSucceeded InvoicePaymentStatus = "succeeded"
+	InvoicePaymentFailed    InvoicePaymentStatus = "failed"
+	InvoicePaymentExpired   InvoicePaymentStatus = "expired"
+	// The gateway collected less than the invoice total
+	InvoicePaymentPartial InvoicePaymentStatus = "partial"
+	// The gateway collected more than the invoice total, or another currency
+	InvoicePaymentMismatched InvoicePaymentStatus = "mismatched"
+)
+
+// Settled reports whether money was collected; a settled payment doesn't change again
+func (s InvoicePaymentStatus) Settled() bool {
+	return s == InvoicePaymentSucceeded || s == InvoicePaymentPartial || s == InvoicePaymentMismatched
+}
+
+// Payment gateways clients pay invoices through
+const (
+	PaymentGatewayStripe = "stripe"
+	PaymentGatewayPayPal = "paypal"
+)
+
+// InvoicePayment is a client's attempt to pay an invoice online, from the gateway
+// checkout being opened until the gateway reports the outcome
+type InvoicePayment struct {
+	ID               string               `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID   string               `json:"organization_id" gorm:"type:uuid;not null;index"`
+	InvoiceID        string               `json:"invoice_id" gorm:"type:uuid;not null;index"`
+	Gateway          string               `json:"gateway" gorm:"not null;size:20;uniqueIndex:idx_invoice_payments_gateway_session"`
+	SessionID        string               `json:"session_id" gorm:"not null;size:255;uniqueIndex:idx_invoice_payments_gateway_session"` // checkout session or order
+	GatewayPaymentID string               `json:"gateway_payment_id" gorm:"size:255"`                                                   // payment intent or capture
+	Amount           float64              `json:"amount" gorm:"type:decimal(12,2);not null"`
+	Currency         string               `json:"currency" gorm:"not null;size:3"`
+	Status           InvoicePaymentStatus `json:"status" gorm:"not null;size:20;default:pending"`
+	PaidAt           *time.Time           `json:"paid_at"`
+	CreatedAt        time.Time            `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time            `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "testing"
+
+func TestInvoiceStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to InvoiceStatus
+		want     bool
+	}{
+		{InvoiceStatusDraft, InvoiceStatusSent, true},
+		{InvoiceStatusDraft, InvoiceStatusCancelled, true},
+		{InvoiceStatusDraft, InvoiceStatusPaid, false},
+		{InvoiceStatusDraft, InvoiceStatusOverdue, false},
+		{InvoiceStatusDraft, InvoiceStatusDraft, false},
+		{InvoiceStatusSent, InvoiceStatusPaid, true},
+		{InvoiceStatusSent, InvoiceStatusOverdue, true},
+		{InvoiceStatusSent, InvoiceStatusCancelled, true},
+		{InvoiceStatusSent, InvoiceStatusDraft, false},
+		{InvoiceStatusOverdue, InvoiceStatusSent, true},
+		{InvoiceStatusOverdue, InvoiceStatusPaid, true},
+		{InvoiceStatusOverdue, InvoiceStatusDraft, false},
+		{InvoiceStatusPaid, InvoiceStatusSent, false},
+		{InvoiceStatusPaid, InvoiceStatusCancelled, false},
+		{InvoiceStatusCancelled, InvoiceStatusDraft, false},
+		{InvoiceStatusCancelled, InvoiceStatusSent, false},
+		{InvoiceStatus("void"), InvoiceStatusSent, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestInvoiceStatusIsValid(t *testing.T) {
+	for _, s := range []InvoiceStatus{InvoiceStatusDraft, InvoiceStatusSent, InvoiceStatusPaid, InvoiceStatusOverdue, InvoiceStatusCancelled} {
+		if !s.IsValid() {
+			t.Errorf("%s is not valid", s)
+		}
+	}
+	for _, s := range []InvoiceStatus{"", "void", "PAID"} {
+		if s.IsValid() {
+			t.Errorf("%q is valid", s)
+		}
+	}
+}
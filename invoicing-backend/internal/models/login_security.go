@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// Login throttle scopes
+const (
+	LoginThrottleScopeAccount = "account"
+	LoginThrottleScopeIP      = "ip"
+)
+
+// Login failure reasons recorded in the audit trail
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureLocked             = "locked"
+)
+
+// LoginThrottle tracks consecutive failed logins for an account or client IP
+type LoginThrottle struct {
+	Scope          string     `json:"scope" gorm:"primaryKey;size:20"`
+	Key            string     `json:"key" gorm:"primaryKey;size:255"`
+	FailureCount   int        `json:"failure_count" gorm:"not null;default:0"`
+	FirstFailureAt time.Time  `json:"first_failure_at"`
+	LastFailureAt  time.Time  `json:"last_failure_at"`
+	LockedUntil    *time.Time `json:"locked_until"`
+}
+
+// IsLocked returns true if the throttle currently blocks login attempts
+func (t *LoginThrottle) IsLocked() bool {
+	return t.LockedUntil != nil && t.LockedUntil.After(time.Now())
+}
+
+// LoginAttempt is an audit record of a single login attempt
+type LoginAttempt struct {
+	ID            string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Email         string    `json:"email" gorm:"not null;size:255"`
+	UserID        *string   `json:"user_id" gorm:"index"`
+	IPAddress     string    `json:"ip_address" gorm:"size:45"`
+	UserAgent     string    `json:"user_agent" gorm:"type:text"`
+	Succeeded     bool      `json:"succeeded" gorm:"not null;default:false"`
+	FailureReason string    `json:"failure_reason" gorm:"size:50"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
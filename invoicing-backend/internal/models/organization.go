@@ -4,6 +4,8 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type Organization struct {
@@ -12,6 +14,16 @@ type Organization struct {
 	Subdomain string               `json:"subdomain" gorm:"unique;size:100"`
 	Settings  OrganizationSettings `json:"settings" gorm:"type:jsonb;not null;default:'{}'"`
 
+	// White-label domain; requests to it resolve to the organization once DNS ownership is verified
+	CustomDomain           *string    `json:"custom_domain" gorm:"unique;size:255"`
+	CustomDomainToken      string     `json:"-" gorm:"size:64"`
+	CustomDomainVerifiedAt *time.Time `json:"custom_domain_verified_at"`
+
+	// Set while a platform admin has suspended the organization
+	SuspendedAt     *time.Time `json:"suspended_at"`
+	SuspendedReason string     `json:"suspended_reason,omitempty" gorm:"type:text"`
+	SuspendedBy     *string    `json:"suspended_by,omitempty" gorm:"type:uuid"`
+
 	// Relationships
 	Subscription          *Subscription          `json:"subscription" gorm:"constraint:OnDelete:SET NULL;"`
 	Users                 []User                 `json:"users" gorm:"many2many:user_organization_roles;"`
@@ -20,33 +32,94 @@ type Organization struct {
 	Invoices              []Invoice              `json:"invoices" gorm:"constraint:OnDelete:CASCADE;"`
 }
 
+// ReservedSubdomains cannot be claimed by organizations because they are used
+// by the platform itself or could be mistaken for it
+var ReservedSubdomains = map[string]bool{
+	"admin": true, "api": true, "app": true, "assets": true, "auth": true,
+	"billing": true, "blog": true, "cdn": true, "dashboard": true, "dev": true,
+	"docs": true, "help": true, "internal": true, "login": true, "mail": true,
+	"platform": true, "portal": true, "root": true, "security": true, "smtp": true,
+	"sso": true, "staging": true, "static": true, "status": true, "support": true,
+	"system": true, "test": true, "www": true,
+}
+
+// IsReservedSubdomain reports whether the subdomain is reserved for the platform
+func IsReservedSubdomain(subdomain string) bool {
+	return ReservedSubdomains[strings.ToLower(subdomain)]
+}
+
+// IsSuspended returns true if a platform admin has suspended the organization
+func (o *Organization) IsSuspended() bool {
+	return o.SuspendedAt != nil
+}
+
 // OrganizationSettings represents the JSON settings for an organization
 type OrganizationSettings struct {
-	IsDefault              bool `json:"is_default,omitempty"`
-	CreatedDuringMigration bool `json:"created_during_migration,omitempty"`
-	CompanyAddress         struct {
-		AddressLine1 string `json:"address_line1,omitempty"`
-		AddressLine2 string `json:"address_line2,omitempty"`
-		City         string `json:"city,omitempty"`
-		State        string `json:"state,omitempty"`
-		PostalCode   string `json:"postal_code,omitempty"`
-		Country      string `json:"country,omitempty"`
-	} `json:"company_address,omitempty"`
-	BrandingSettings struct {
-		LogoURL      string `json:"logo_url,omitempty"`
-		PrimaryColor string `json:"primary_color,omitempty"`
-		Theme        string `json:"theme,omitempty"`
-	} `json:"branding_settings,omitempty"`
-	InvoiceSettings struct {
-		DefaultCurrency     string  `json:"default_currency,omitempty"`
-		DefaultTaxRate      float64 `json:"default_tax_rate,omitempty"`
-		InvoiceNumberPrefix string  `json:"invoice_number_prefix,omitempty"`
-		PaymentTermsDays    int     `json:"payment_terms_days,omitempty"`
-	} `json:"invoice_settings,omitempty"`
-	NotificationSettings struct {
-		EmailNotifications bool `json:"email_notifications,omitempty"`
-		SlackIntegration   bool `json:"slack_integration,omitempty"`
-	} `json:"notification_settings,omitempty"`
+	IsDefault              bool                 `json:"is_default,omitempty"`
+	CreatedDuringMigration bool                 `json:"created_during_migration,omitempty"`
+	CompanyAddress         CompanyAddress       `json:"company_address,omitempty"`
+	BrandingSettings       BrandingSettings     `json:"branding_settings,omitempty"`
+	InvoiceSettings        InvoiceSettings      `json:"invoice_settings,omitempty"`
+	NotificationSettings   NotificationSettings `json:"notification_settings,omitempty"`
+	ApprovalSettings       ApprovalSettings     `json:"approval_settings,omitempty"`
+}
+
+// Settings section names accepted by the settings API
+const (
+	SettingsSectionCompanyAddress = "company_address"
+	SettingsSectionBranding       = "branding_settings"
+	SettingsSectionInvoice        = "invoice_settings"
+	SettingsSectionNotification   = "notification_settings"
+	SettingsSectionApproval       = "approval_settings"
+)
+
+type CompanyAddress struct {
+	AddressLine1 string `json:"address_line1,omitempty" validate:"max=255"`
+	AddressLine2 string `json:"address_line2,omitempty" validate:"max=255"`
+	City         string `json:"city,omitempty" validate:"max=100"`
+	State        string `json:"state,omitempty" validate:"max=100"`
+	PostalCode   string `json:"postal_code,omitempty" validate:"max=20"`
+	Country      string `json:"country,omitempty" validate:"max=100"`
+}
+
+type BrandingSettings struct {
+	LogoURL      string `json:"logo_url,omitempty" validate:"omitempty,url,max=500"`
+	PrimaryColor string `json:"primary_color,omitempty" validate:"omitempty,hexcolor"`
+	Theme        string `json:"theme,omitempty" validate:"omitempty,oneof=light dark system"`
+}
+
+type InvoiceSettings struct {
+	DefaultCurrency     string  `json:"default_currency,omitempty" validate:"omitempty,iso4217"`
+	DefaultTaxRate      float64 `json:"default_tax_rate,omitempty" validate:"gte=0,lte=1"`
+	InvoiceNumberPrefix string  `json:"invoice_number_prefix,omitempty" validate:"omitempty,max=20,alphanum"`
+	PaymentTermsDays    int     `json:"payment_terms_days,omitempty" validate:"gte=0,lte=365"`
+}
+
+// ApprovalSettings controls which invoices need approval before they can be sent.
+// The threshold is compared to the invoice total in the invoice's own currency.
+type ApprovalSettings struct {
+	Enabled         bool    `json:"enabled,omitempty"`
+	AmountThreshold float64 `json:"amount_threshold,omitempty" validate:"gte=0"` // 0 disables the threshold
+	// RequireForOrgUsers requires approval of every invoice created by a member who
+	// can't approve invoices, whether an org_user or on a custom role
+	RequireForOrgUsers bool `json:"require_for_org_users,omitempty"`
+}
+
+// Requires reports whether an invoice with this total must be approved before it
+// is sent. creatorCanApprove is whether its creator holds invoices:approve.
+func (a ApprovalSettings) Requires(totalAmount float64, creatorCanApprove bool) bool {
+	if !a.Enabled {
+		return false
+	}
+	if a.AmountThreshold > 0 && totalAmount >= a.AmountThreshold {
+		return true
+	}
+	return a.RequireForOrgUsers && !creatorCanApprove
+}
+
+type NotificationSettings struct {
+	EmailNotifications bool `json:"email_notifications,omitempty"`
+	SlackIntegration   bool `json:"slack_integration,omitempty"`
 }
 
 // Implement the driver.Valuer interface for GORM JSONB support
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type PaymentMethodType string
+
+const (
+	PaymentMethodBankTransfer PaymentMethodType = "bank_transfer"
+	PaymentMethodACH          PaymentMethodType = "ach"
+	PaymentMethodPayPalMe     PaymentMethodType = "paypal_me"
+	PaymentMethodCustom       PaymentMethodType = "custom"
+)
+
+// PaymentMethod tells an organization's clients how to pay its invoices offline.
+// Which fields apply depends on Type; Instructions can accompany any type.
+type PaymentMethod struct {
+	ID             string            `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string            `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Type           PaymentMethodType `json:"type" gorm:"not null;size:20"`
+	Name           string            `json:"name" gorm:"not null;size:100"`
+	// Default methods are printed on invoices that don't select any
+	IsDefault bool `json:"is_default" gorm:"not null;default:false"`
+
+	// Bank transfer and ACH
+	AccountHolder string `json:"account_holder,omitempty" gorm:"size:140"`
+	BankName      string `json:"bank_name,omitempty" gorm:"size:140"`
+	IBAN          string `json:"iban,omitempty" gorm:"size:34"` // electronic format, without spaces
+	BIC           string `json:"bic,omitempty" gorm:"size:11"`
+	RoutingNumber string `json:"routing_number,omitempty" gorm:"size:9"`
+	AccountNumber string `json:"account_number,omitempty" gorm:"size:17"`
+
+	PayPalMeUsername string `json:"paypal_me_username,omitempty" gorm:"column:paypal_me_username;size:20"`
+	Instructions     string `json:"instructions,omitempty" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// PayPalMeURL is the link clients pay a paypal_me method through
+func (m *PaymentMethod) PayPalMeURL() string {
+	if m.PayPalMeUsername == "" {
+		return ""
+	}
+	return "https://paypal.me/" + m.PayPalMeUsername
+}
+
+// PaymentMethodIDs lists the payment methods selected for an invoice, in print order
+type PaymentMethodIDs []string
+
+// Implement the driver.Valuer interface for GORM JSONB support
+func (p PaymentMethodIDs) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(p))
+}
+
+// Implement the sql.Scanner interface for GORM JSONB support
+func (p *PaymentMethodIDs) Scan(value interface{}) error {
+	*p = PaymentMethodIDs{}
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal PaymentMethodIDs value: %v", value)
+	}
+
+	return json.Unmarshal(bytes, (*[]string)(p))
+}
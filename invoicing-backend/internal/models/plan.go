@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Feature entitlements a plan can include, checked with RequireFeature
+const (
+	FeatureRecurringInvoices = "recurring_invoices"
+	FeatureCustomRoles       = "custom_roles"
+	FeatureAPIKeys           = "api_keys"
+	FeatureSSO               = "sso"
+)
+
+const (
+	BillingIntervalMonth = "month"
+	BillingIntervalYear  = "year"
+)
+
+// DefaultPlanCurrency is the currency plan changes are quoted in
+const DefaultPlanCurrency = "USD"
+
+// Plan is an entry of the plan catalog. Subscriptions copy its limits when they
+// move to the plan; features are looked up from the plan on each request.
+type Plan struct {
+	Code                SubscriptionPlan `json:"code" gorm:"primarykey;size:50"`
+	Name                string           `json:"name" gorm:"not null;size:100"`
+	Description         string           `json:"description" gorm:"type:text"`
+	BillingInterval     string           `json:"billing_interval" gorm:"not null;size:10;default:month"`
+	MonthlyInvoiceLimit int              `json:"monthly_invoice_limit" gorm:"not null"` // -1 means unlimited
+	MonthlyClientLimit  int              `json:"monthly_client_limit" gorm:"not null"`
+	MonthlyUserLimit    int              `json:"monthly_user_limit" gorm:"not null"`
+	Features            PlanFeatures     `json:"features" gorm:"type:jsonb;not null;default:'{}'"`
+	IsPublic            bool             `json:"is_public" gorm:"not null;default:true"`
+	SortOrder           int              `json:"sort_order" gorm:"not null;default:0"`
+	CreatedAt           time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt           time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	Prices []PlanPrice `json:"prices" gorm:"foreignKey:PlanCode;references:Code;constraint:OnDelete:CASCADE;"`
+}
+
+// PlanPrice is the price of a plan per billing interval in one currency
+type PlanPrice struct {
+	ID       string           `json:"-" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	PlanCode SubscriptionPlan `json:"-" gorm:"not null;size:50;uniqueIndex:idx_plan_prices_plan_currency"`
+	Currency string           `json:"currency" gorm:"not null;size:3;uniqueIndex:idx_plan_prices_plan_currency"`
+	Amount   float64          `json:"amount" gorm:"type:decimal(12,2);not null"`
+}
+
+// HasFeature reports whether the plan includes the feature
+func (p *Plan) HasFeature(feature string) bool {
+	return p.Features[feature]
+}
+
+// Price returns the plan's price in the currency, if it is offered in it
+func (p *Plan) Price(currency string) (float64, bool) {
+	for _, price := range p.Prices {
+		if price.Currency == currency {
+			return price.Amount, true
+		}
+	}
+	return 0, false
+}
+
+// PlanFeatures maps feature names to whether the plan includes them
+type PlanFeatures map[string]bool
+
+// Implement the driver.Valuer interface for GORM JSONB support
+func (f PlanFeatures) Value() (driver.Value, error) {
+	if f == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]bool(f))
+}
+
+// Implement the sql.Scanner interface for GORM JSONB support
+func (f *PlanFeatures) Scan(value interface{}) error {
+	*f = PlanFeatures{}
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal PlanFeatures value: %v", value)
+	}
+
+	return json.Unmarshal(bytes, (*map[string]bool)(f))
+}
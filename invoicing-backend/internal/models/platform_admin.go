@@ -0,0 +1,59 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Platform admin action names
+const (
+	AdminActionSuspendOrganization   = "organization.suspend"
+	AdminActionUnsuspendOrganization = "organization.unsuspend"
+	AdminActionChangePlan            = "subscription.change_plan"
+	AdminActionImpersonationStart    = "impersonation.start"
+	AdminActionImpersonationRequest  = "impersonation.request"
+	AdminActionCreateCoupon          = "coupon.create"
+	AdminActionDeactivateCoupon      = "coupon.deactivate"
+)
+
+// PlatformAdminAction is an append-only record of an action taken by a platform admin
+type PlatformAdminAction struct {
+	ID         string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	AdminID    *string   `json:"admin_id" gorm:"type:uuid;index"`
+	Action     string    `json:"action" gorm:"not null;size:100"`
+	TargetType string    `json:"target_type" gorm:"not null;size:50"`
+	TargetID   string    `json:"target_id" gorm:"not null;size:255"`
+	SessionID  *string   `json:"session_id" gorm:"type:uuid;index"`
+	Details    JSONMap   `json:"details" gorm:"type:jsonb;not null;default:'{}'"`
+	IPAddress  string    `json:"ip_address" gorm:"size:45"`
+	UserAgent  string    `json:"user_agent" gorm:"type:text"`
+	CreatedAt  time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// JSONMap is a free-form JSON object stored in a JSONB column
+type JSONMap map[string]interface{}
+
+// Implement the driver.Valuer interface for GORM JSONB support
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]interface{}(m))
+}
+
+// Implement the sql.Scanner interface for GORM JSONB support
+func (m *JSONMap) Scan(value interface{}) error {
+	*m = JSONMap{}
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal JSONMap value: %v", value)
+	}
+
+	return json.Unmarshal(bytes, (*map[string]interface{})(m))
+}
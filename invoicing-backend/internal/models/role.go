@@ -8,30 +8,22 @@ import (
 )
 
 type Role struct {
-	ID           string          `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
-	Name         string          `json:"name" gorm:"unique;not null;size:100;index" validate:"required"`
-	Description  string          `json:"description" gorm:"type:text"`
-	Permissions  RolePermissions `json:"permissions" gorm:"type:jsonb;not null;default:'{}'"`
-	IsSystemRole bool            `json:"is_system_role" gorm:"not null;default:false;index"`
-	CreatedAt    time.Time       `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt    time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+	ID             string          `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID *string         `json:"organization_id" gorm:"type:uuid;index"` // nil for system roles
+	Name           string          `json:"name" gorm:"not null;size:100;index" validate:"required"`
+	Description    string          `json:"description" gorm:"type:text"`
+	Permissions    RolePermissions `json:"permissions" gorm:"type:jsonb;not null;default:'{}'"`
+	IsSystemRole   bool            `json:"is_system_role" gorm:"not null;default:false;index"`
+	CreatedAt      time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
 
 	// Relationships (without soft deletes for system roles)
-	UserOrganizationRoles []UserOrganizationRole `json:"user_organization_roles" gorm:"constraint:OnDelete:RESTRICT;"`
+	UserOrganizationRoles []UserOrganizationRole `json:"user_organization_roles,omitempty" gorm:"constraint:OnDelete:RESTRICT;"`
 }
 
-// RolePermissions represents the JSON permissions structure for a role
-type RolePermissions struct {
-	Organizations []string `json:"organizations,omitempty"` // ["create", "read", "update", "delete"]
-	Users         []string `json:"users,omitempty"`         // ["create", "read", "update", "delete"]
-	Invoices      []string `json:"invoices,omitempty"`      // ["create", "read", "update", "delete"]
-	Clients       []string `json:"clients,omitempty"`       // ["create", "read", "update", "delete"]
-	Subscriptions []string `json:"subscriptions,omitempty"` // ["create", "read", "update", "delete"]
-	OwnInvoices   []string `json:"own_invoices,omitempty"`  // ["delete"] - for own content only
-	OwnClients    []string `json:"own_clients,omitempty"`   // ["delete"] - for own content only
-	Organization  []string `json:"organization,omitempty"`  // ["read", "update"] - current org
-	Subscription  []string `json:"subscription,omitempty"`  // ["read", "update"] - current org subscription
-}
+// RolePermissions maps a resource to the actions granted on it, stored as JSON, e.g.
+// {"invoices": ["create", "read"], "own_clients": ["delete"]}
+type RolePermissions map[string][]string
 
 // System role constants
 const (
@@ -43,11 +35,12 @@ const (
 
 // Permission action constants
 const (
-	PermissionCreate = "create"
-	PermissionRead   = "read"
-	PermissionUpdate = "update"
-	PermissionDelete = "delete"
-	PermissionManage = "manage" // Special permission that includes all CRUD operations
+	PermissionCreate  = "create"
+	PermissionRead    = "read"
+	PermissionUpdate  = "update"
+	PermissionDelete  = "delete"
+	PermissionApprove = "approve"
+	PermissionManage  = "manage" // Special permission that includes all CRUD operations
 )
 
 // Resource constants
@@ -63,15 +56,32 @@ const (
 	ResourceSubscription  = "subscription"
 )
 
+// ResourceActions lists every known resource and the actions that can be granted on it.
+// Adding a resource only requires a new entry here; PermissionManage is always allowed.
+var ResourceActions = map[string][]string{
+	ResourceOrganizations: {PermissionCreate, PermissionRead, PermissionUpdate, PermissionDelete},
+	ResourceUsers:         {PermissionCreate, PermissionRead, PermissionUpdate, PermissionDelete},
+	ResourceInvoices:      {PermissionCreate, PermissionRead, PermissionUpdate, PermissionDelete, PermissionApprove},
+	ResourceClients:       {PermissionCreate, PermissionRead, PermissionUpdate, PermissionDelete},
+	ResourceSubscriptions: {PermissionCreate, PermissionRead, PermissionUpdate, PermissionDelete},
+	ResourceOwnInvoices:   {PermissionRead, PermissionUpdate, PermissionDelete},
+	ResourceOwnClients:    {PermissionRead, PermissionUpdate, PermissionDelete},
+	ResourceOrganization:  {PermissionRead, PermissionUpdate},
+	ResourceSubscription:  {PermissionRead, PermissionUpdate},
+}
+
 // Implement the driver.Valuer interface for GORM JSONB support
 func (rp RolePermissions) Value() (driver.Value, error) {
-	return json.Marshal(rp)
+	if rp == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string][]string(rp))
 }
 
 // Implement the sql.Scanner interface for GORM JSONB support
 func (rp *RolePermissions) Scan(value interface{}) error {
+	*rp = RolePermissions{}
 	if value == nil {
-		*rp = RolePermissions{}
 		return nil
 	}
 
@@ -80,38 +90,29 @@ func (rp *RolePermissions) Scan(value interface{}) error {
 		return fmt.Errorf("failed to unmarshal RolePermissions value: %v", value)
 	}
 
-	return json.Unmarshal(bytes, rp)
+	return json.Unmarshal(bytes, (*map[string][]string)(rp))
 }
 
-// HasPermission checks if the role has a specific permission for a resource
-func (r *Role) HasPermission(resource, action string) bool {
-	var permissions []string
-
-	switch resource {
-	case ResourceOrganizations:
-		permissions = r.Permissions.Organizations
-	case ResourceUsers:
-		permissions = r.Permissions.Users
-	case ResourceInvoices:
-		permissions = r.Permissions.Invoices
-	case ResourceClients:
-		permissions = r.Permissions.Clients
-	case ResourceSubscriptions:
-		permissions = r.Permissions.Subscriptions
-	case ResourceOwnInvoices:
-		permissions = r.Permissions.OwnInvoices
-	case ResourceOwnClients:
-		permissions = r.Permissions.OwnClients
-	case ResourceOrganization:
-		permissions = r.Permissions.Organization
-	case ResourceSubscription:
-		permissions = r.Permissions.Subscription
-	default:
-		return false
+// Validate checks that only known resources and actions are granted
+func (rp RolePermissions) Validate() error {
+	for resource, actions := range rp {
+		allowed, ok := ResourceActions[resource]
+		if !ok {
+			return fmt.Errorf("unknown resource %q", resource)
+		}
+		for _, action := range actions {
+			if action != PermissionManage && !containsString(allowed, action) {
+				return fmt.Errorf("unknown action %q for resource %q", action, resource)
+			}
+		}
 	}
+	return nil
+}
 
+// Allows checks if the permissions grant an action on a resource
+func (rp RolePermissions) Allows(resource, action string) bool {
 	// Check for specific permission or manage permission (which includes all)
-	for _, perm := range permissions {
+	for _, perm := range rp[resource] {
 		if perm == action || perm == PermissionManage {
 			return true
 		}
@@ -120,36 +121,112 @@ func (r *Role) HasPermission(resource, action string) bool {
 	return false
 }
 
+// IsSubsetOf checks that every action granted here is also granted by parent
+func (rp RolePermissions) IsSubsetOf(parent RolePermissions) bool {
+	for resource, actions := range rp {
+		for _, action := range actions {
+			if action == PermissionManage {
+				for _, a := range ResourceActions[resource] {
+					if !parent.Allows(resource, a) {
+						return false
+					}
+				}
+				continue
+			}
+			if !parent.Allows(resource, action) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Intersect returns the actions granted both here and by other. A manage grant is
+// kept when other also grants manage, and otherwise narrowed to the actions other allows.
+func (rp RolePermissions) Intersect(other RolePermissions) RolePermissions {
+	result := RolePermissions{}
+	for resource, actions := range rp {
+		var granted []string
+		for _, action := range actions {
+			switch {
+			case action == PermissionManage && other.Allows(resource, PermissionManage):
+				granted = append(granted, PermissionManage)
+			case action == PermissionManage:
+				for _, a := range ResourceActions[resource] {
+					if other.Allows(resource, a) && !containsString(granted, a) {
+						granted = append(granted, a)
+					}
+				}
+			case other.Allows(resource, action) && !containsString(granted, action):
+				granted = append(granted, action)
+			}
+		}
+		if len(granted) > 0 {
+			result[resource] = granted
+		}
+	}
+	return result
+}
+
+// IsCustom returns true for roles defined by an organization
+func (r *Role) IsCustom() bool {
+	return r.OrganizationID != nil
+}
+
+// IsReservedRoleName returns true for names used by system roles
+func IsReservedRoleName(name string) bool {
+	switch name {
+	case RolePlatformAdmin, RoleOrgAdmin, RoleOrgUser, RoleOrgViewer, RoleAPIKey:
+		return true
+	default:
+		return false
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission checks if the role has a specific permission for a resource
+func (r *Role) HasPermission(resource, action string) bool {
+	return r.Permissions.Allows(resource, action)
+}
+
 // GetDefaultPermissions returns the default permissions for system roles
 func GetDefaultPermissions(roleName string) RolePermissions {
 	switch roleName {
 	case RolePlatformAdmin:
 		return RolePermissions{
-			Organizations: []string{PermissionManage},
-			Users:         []string{PermissionManage},
-			Subscriptions: []string{PermissionManage},
-			Invoices:      []string{PermissionRead, PermissionUpdate, PermissionDelete},
-			Clients:       []string{PermissionRead, PermissionUpdate, PermissionDelete},
+			ResourceOrganizations: {PermissionManage},
+			ResourceUsers:         {PermissionManage},
+			ResourceSubscriptions: {PermissionManage},
+			ResourceInvoices:      {PermissionRead, PermissionUpdate, PermissionDelete},
+			ResourceClients:       {PermissionRead, PermissionUpdate, PermissionDelete},
 		}
 	case RoleOrgAdmin:
 		return RolePermissions{
-			Organization: []string{PermissionRead, PermissionUpdate},
-			Users:        []string{PermissionManage},
-			Invoices:     []string{PermissionManage},
-			Clients:      []string{PermissionManage},
-			Subscription: []string{PermissionRead, PermissionUpdate},
+			ResourceOrganization: {PermissionRead, PermissionUpdate},
+			ResourceUsers:        {PermissionManage},
+			ResourceInvoices:     {PermissionManage},
+			ResourceClients:      {PermissionManage},
+			ResourceSubscription: {PermissionRead, PermissionUpdate},
 		}
 	case RoleOrgUser:
 		return RolePermissions{
-			Invoices:    []string{PermissionCreate, PermissionRead, PermissionUpdate},
-			Clients:     []string{PermissionCreate, PermissionRead, PermissionUpdate},
-			OwnInvoices: []string{PermissionDelete},
-			OwnClients:  []string{PermissionDelete},
+			ResourceInvoices:    {PermissionCreate, PermissionRead, PermissionUpdate},
+			ResourceClients:     {PermissionCreate, PermissionRead, PermissionUpdate},
+			ResourceOwnInvoices: {PermissionDelete},
+			ResourceOwnClients:  {PermissionDelete},
 		}
 	case RoleOrgViewer:
 		return RolePermissions{
-			Invoices: []string{PermissionRead},
-			Clients:  []string{PermissionRead},
+			ResourceInvoices: {PermissionRead},
+			ResourceClients:  {PermissionRead},
 		}
 	default:
 		return RolePermissions{}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRolePermissionsIntersect(t *testing.T) {
+	tests := []struct {
+		name string
+		key  RolePermissions
+		role RolePermissions
+		want RolePermissions
+	}{
+		{
+			name: "keeps actions the role grants",
+			key:  RolePermissions{"invoices": {"create", "read"}, "clients": {"read"}},
+			role: RolePermissions{"invoices": {"read"}, "clients": {"manage"}},
+			want: RolePermissions{"invoices": {"read"}, "clients": {"read"}},
+		},
+		{
+			name: "keeps manage when the role has it",
+			key:  RolePermissions{"invoices": {"manage"}},
+			role: RolePermissions{"invoices": {"manage"}},
+			want: RolePermissions{"invoices": {"manage"}},
+		},
+		{
+			name: "narrows manage to the role's actions",
+			key:  RolePermissions{"invoices": {"manage"}},
+			role: RolePermissions{"invoices": {"read", "update"}},
+			want: RolePermissions{"invoices": {"read", "update"}},
+		},
+		{
+			name: "drops resources the role lost",
+			key:  RolePermissions{"invoices": {"read"}, "organization": {"update"}},
+			role: RolePermissions{"invoices": {"read"}},
+			want: RolePermissions{"invoices": {"read"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.key.Intersect(tt.role)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Intersect() = %v, want %v", got, tt.want)
+			}
+			if !got.IsSubsetOf(tt.role) || !got.IsSubsetOf(tt.key) {
+				t.Errorf("Intersect() = %v is not a subset of both inputs", got)
+			}
+		})
+	}
+}
+
+func TestRolePermissionsValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions RolePermissions
+		valid       bool
+	}{
+		{"empty", RolePermissions{}, true},
+		{"known actions", RolePermissions{"invoices": {"create", "read", "approve"}, "own_clients": {"delete"}}, true},
+		{"manage on any resource", RolePermissions{"organization": {"manage"}}, true},
+		{"system role defaults", GetDefaultPermissions(RoleOrgAdmin), true},
+		{"unknown resource", RolePermissions{"payments": {"read"}}, false},
+		{"unknown action", RolePermissions{"invoices": {"export"}}, false},
+		{"action of another resource", RolePermissions{"clients": {"approve"}}, false},
+		{"create on an own resource", RolePermissions{"own_invoices": {"create"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.permissions.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestRolePermissionsIsSubsetOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		role   RolePermissions
+		parent RolePermissions
+		want   bool
+	}{
+		{"empty", RolePermissions{}, RolePermissions{}, true},
+		{"same actions", RolePermissions{"invoices": {"read"}}, RolePermissions{"invoices": {"read"}}, true},
+		{"fewer actions", RolePermissions{"invoices": {"read"}}, RolePermissions{"invoices": {"create", "read"}}, true},
+		{"covered by manage", RolePermissions{"invoices": {"approve", "delete"}}, RolePermissions{"invoices": {"manage"}}, true},
+		{"manage covered by every action", RolePermissions{"organization": {"manage"}}, RolePermissions{"organization": {"read", "update"}}, true},
+		{"manage covered by manage", RolePermissions{"users": {"manage"}}, GetDefaultPermissions(RoleOrgAdmin), true},
+		{"viewer within user", GetDefaultPermissions(RoleOrgViewer), GetDefaultPermissions(RoleOrgUser), true},
+
+		{"extra action", RolePermissions{"invoices": {"read", "approve"}}, RolePermissions{"invoices": {"read"}}, false},
+		{"extra resource", RolePermissions{"subscription": {"read"}}, RolePermissions{"invoices": {"manage"}}, false},
+		{"manage over some actions", RolePermissions{"invoices": {"manage"}}, RolePermissions{"invoices": {"create", "read", "update"}}, false},
+		{"own resource is not the resource", RolePermissions{"invoices": {"delete"}}, RolePermissions{"own_invoices": {"delete"}}, false},
+		{"admin within user", GetDefaultPermissions(RoleOrgAdmin), GetDefaultPermissions(RoleOrgUser), false},
+		{"anything within nothing", RolePermissions{"clients": {"read"}}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.role.IsSubsetOf(tt.parent); got != tt.want {
+				t.Errorf("IsSubsetOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 type SubscriptionPlan string
 type SubscriptionStatus string
 
+// Plans the code relies on; the plans table can hold more
 const (
 	SubscriptionPlanFree     SubscriptionPlan = "free"
 	SubscriptionPlanPro      SubscriptionPlan = "pro"
@@ -21,6 +22,12 @@ const (
 	SubscriptionStatusUnpaid    SubscriptionStatus = "unpaid"
 )
 
+// SaaS billing providers; an organization is billed by one at a time
+const (
+	BillingProviderPayPal = "paypal"
+	BillingProviderStripe = "stripe"
+)
+
 type Subscription struct {
 	ID                   string             `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
 	OrganizationID       string             `json:"organization_id" gorm:"not null;index"`
@@ -28,12 +35,19 @@ type Subscription struct {
 	Status               SubscriptionStatus `json:"status" gorm:"not null;default:active;index"`
 	PayPalSubscriptionID string             `json:"paypal_subscription_id" gorm:"column:paypal_subscription_id;size:255;index"`
 	PayPalPlanID         string             `json:"paypal_plan_id" gorm:"column:paypal_plan_id;size:255"`
+	BillingProvider      string             `json:"billing_provider" gorm:"size:20"`
+	StripeCustomerID     string             `json:"stripe_customer_id" gorm:"size:255;index"`
+	StripeSubscriptionID string             `json:"stripe_subscription_id" gorm:"size:255;index"`
+	StripePriceID        string             `json:"stripe_price_id" gorm:"size:255"`
 	CurrentPeriodStart   *time.Time         `json:"current_period_start"`
 	CurrentPeriodEnd     *time.Time         `json:"current_period_end"`
 	TrialEnd             *time.Time         `json:"trial_end"`
+	TrialReminderSentAt  *time.Time         `json:"-"`
 	MonthlyInvoiceLimit  int                `json:"monthly_invoice_limit" gorm:"not null;default:5"`
 	MonthlyClientLimit   int                `json:"monthly_client_limit" gorm:"not null;default:2"`
 	MonthlyUserLimit     int                `json:"monthly_user_limit" gorm:"not null;default:1"`
+	LimitGraceEndsAt     *time.Time         `json:"limit_grace_ends_at"` // set while over limits after a plan change
+	CouponRedemptionID   *string            `json:"coupon_redemption_id" gorm:"type:uuid"`
 	CreatedAt            time.Time          `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt            time.Time          `json:"updated_at" gorm:"autoUpdateTime"`
 
@@ -41,32 +55,29 @@ type Subscription struct {
 	Organization Organization `json:"organization" gorm:"constraint:OnDelete:CASCADE;"`
 }
 
-// GetPlanLimits returns the limits for a given subscription plan
-func GetPlanLimits(plan SubscriptionPlan) (invoiceLimit, clientLimit, userLimit int) {
-	switch plan {
-	case SubscriptionPlanFree:
-		return 5, 2, 1
-	case SubscriptionPlanPro:
-		return 100, -1, 5 // -1 means unlimited
-	case SubscriptionPlanBusiness:
-		return -1, -1, -1 // -1 means unlimited
-	default:
-		return 5, 2, 1 // Default to free plan limits
-	}
+// ApplyPlan switches the subscription to the plan and copies its limits. It is the
+// only place limits are set from the plan.
+func (s *Subscription) ApplyPlan(plan *Plan) {
+	s.PlanType = plan.Code
+	s.MonthlyInvoiceLimit = plan.MonthlyInvoiceLimit
+	s.MonthlyClientLimit = plan.MonthlyClientLimit
+	s.MonthlyUserLimit = plan.MonthlyUserLimit
 }
 
-// GetPlanPrice returns the monthly price for a given subscription plan in USD
-func GetPlanPrice(plan SubscriptionPlan) float64 {
-	switch plan {
-	case SubscriptionPlanFree:
-		return 0.0
-	case SubscriptionPlanPro:
-		return 15.0
-	case SubscriptionPlanBusiness:
-		return 50.0
-	default:
-		return 0.0
+// LimitGraceExpired reports whether the grace period for getting back under the plan's limits has ended
+func (s *Subscription) LimitGraceExpired() bool {
+	return s.LimitGraceEndsAt != nil && s.LimitGraceEndsAt.Before(time.Now())
+}
+
+// ProviderSubscriptionID returns the billing provider's ID of the paid subscription, if any
+func (s *Subscription) ProviderSubscriptionID() string {
+	switch s.BillingProvider {
+	case BillingProviderPayPal:
+		return s.PayPalSubscriptionID
+	case BillingProviderStripe:
+		return s.StripeSubscriptionID
 	}
+	return ""
 }
 
 // IsActive returns true if the subscription is currently active
@@ -74,6 +85,44 @@ func (s *Subscription) IsActive() bool {
 	return s.Status == SubscriptionStatusActive
 }
 
+// UsagePeriod returns the billing period containing now that metered usage counts
+// against: the provider's current period, otherwise monthly periods anchored on the
+// day the subscription started
+func (s *Subscription) UsagePeriod(now time.Time) (start, end time.Time) {
+	if s.CurrentPeriodStart != nil && s.CurrentPeriodEnd != nil &&
+		!now.Before(*s.CurrentPeriodStart) && now.Before(*s.CurrentPeriodEnd) {
+		return *s.CurrentPeriodStart, *s.CurrentPeriodEnd
+	}
+
+	anchor := s.CreatedAt
+	if anchor.IsZero() || anchor.After(now) {
+		anchor = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	months := (now.Year()-anchor.Year())*12 + int(now.Month()-anchor.Month())
+	start = addMonths(anchor, months)
+	if start.After(now) {
+		months--
+		start = addMonths(anchor, months)
+	}
+	return start, addMonths(anchor, months+1)
+}
+
+// addMonths adds months to t, keeping the day of month where the target month has it
+// and using the month's last day otherwise (Jan 31 + 1 month is Feb 28 or 29)
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// IsTrialing reports whether the plan is a trial that hasn't ended yet
+func (s *Subscription) IsTrialing() bool {
+	return s.TrialEnd != nil && s.TrialEnd.After(time.Now())
+}
+
 // IsExpired returns true if the subscription is expired
 func (s *Subscription) IsExpired() bool {
 	if s.CurrentPeriodEnd == nil {
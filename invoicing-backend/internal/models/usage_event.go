@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// UsageEvent meters a change in a metered resource: +1 when one is created, -1 when
+// one is removed and stops counting against the plan
+type UsageEvent struct {
+	ID             string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string    `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Resource       string    `json:"resource" gorm:"not null;size:20"`
+	ResourceID     string    `json:"resource_id" gorm:"type:uuid"`
+	Quantity       int       `json:"quantity" gorm:"not null"`
+	OccurredAt     time.Time `json:"occurred_at" gorm:"not null;autoCreateTime"`
+}
+
+// UsageThresholdNotification records that admins were told about a usage threshold,
+// so each threshold is announced once per billing period
+type UsageThresholdNotification struct {
+	ID             string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	OrganizationID string    `json:"organization_id" gorm:"type:uuid;not null"`
+	Resource       string    `json:"resource" gorm:"not null;size:20"`
+	Threshold      int       `json:"threshold" gorm:"not null"`
+	PeriodStart    time.Time `json:"period_start" gorm:"not null"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
@@ -14,6 +14,9 @@ type UserOrganizationRole struct {
 	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 
+	// Sessions started before this can't act in the organization (set by a force logout)
+	SessionsRevokedAt *time.Time `json:"sessions_revoked_at,omitempty"`
+
 	// Relationships (without soft deletes)
 	User           User         `json:"user" gorm:"constraint:OnDelete:CASCADE;"`
 	Organization   Organization `json:"organization" gorm:"constraint:OnDelete:CASCADE;"`
@@ -21,6 +24,12 @@ type UserOrganizationRole struct {
 	AssignedByUser *User        `json:"assigned_by_user" gorm:"foreignKey:AssignedBy;constraint:OnDelete:SET NULL;"`
 }
 
+// RevokesSession returns true if a session started at startedAt was force-logged
+// out of the organization
+func (uor *UserOrganizationRole) RevokesSession(startedAt time.Time) bool {
+	return uor.SessionsRevokedAt != nil && startedAt.Before(*uor.SessionsRevokedAt)
+}
+
 // UserPermissionContext represents the context for checking user permissions
 type UserPermissionContext struct {
 	UserID         string
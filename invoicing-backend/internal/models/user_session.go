@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// UserSession is a server-side login session. Login tokens carry the session ID
+// so that sessions can be listed and revoked.
+type UserSession struct {
+	ID         string     `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	UserID     string     `json:"user_id" gorm:"not null;index"`
+	UserAgent  string     `json:"user_agent" gorm:"type:text"`
+	IPAddress  string     `json:"ip_address" gorm:"size:45"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null;index"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	RevokedBy  *string    `json:"revoked_by"`
+
+	// Set when a platform admin is acting as the user
+	ImpersonatorID      *string `json:"impersonator_id,omitempty" gorm:"type:uuid;index"`
+	ImpersonationReason string  `json:"impersonation_reason,omitempty" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	User User `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+}
+
+// IsActive returns true if the session has not been revoked and has not expired
+func (s *UserSession) IsActive() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
+
+// IsImpersonation returns true if the session was started by a platform admin acting as the user
+func (s *UserSession) IsImpersonation() bool {
+	return s.ImpersonatorID != nil
+}
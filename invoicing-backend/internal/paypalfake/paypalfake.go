@@ -0,0 +1,406 @@
+// Package paypalfake is an in-memory stand-in for the PayPal subscriptions and
+// orders APIs, for local development and tests without a sandbox account.
+//
+// Following an approve link activates the subscription and delivers the webhook to
+// WebhookURL; cancelling delivers a CANCELLED event. Approving an order delivers
+// CHECKOUT.ORDER.APPROVED to PaymentsWebhookURL. Links point at the host the
+// server was reached on.
+package paypalfake
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type subscription struct {
+	ID          string                 `json:"id"`
+	PlanID      string                 `json:"plan_id"`
+	Status      string                 `json:"status"`
+	CustomID    string                 `json:"custom_id"`
+	StartTime   *time.Time             `json:"start_time,omitempty"`
+	BillingInfo map[string]interface{} `json:"billing_info,omitempty"`
+	Links       []map[string]string    `json:"links"`
+
+	returnURL   string
+	pendingPlan string
+}
+
+type money struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type capture struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	CustomID string `json:"custom_id"`
+	Amount   money  `json:"amount"`
+}
+
+type purchaseUnit struct {
+	ReferenceID string `json:"reference_id"`
+	CustomID    string `json:"custom_id"`
+	Description string `json:"description,omitempty"`
+	Amount      money  `json:"amount"`
+	Payments    *struct {
+		Captures []capture `json:"captures"`
+	} `json:"payments,omitempty"`
+}
+
+type order struct {
+	ID            string              `json:"id"`
+	Intent        string              `json:"intent"`
+	Status        string              `json:"status"`
+	PurchaseUnits []purchaseUnit      `json:"purchase_units"`
+	Links         []map[string]string `json:"links"`
+
+	returnURL string
+}
+
+// Server holds the subscriptions and orders in memory
+type Server struct {
+	// WebhookURL receives BILLING.SUBSCRIPTION.* events
+	WebhookURL string
+	// PaymentsWebhookURL receives CHECKOUT.ORDER.APPROVED events
+	PaymentsWebhookURL string
+	// Signature is the PAYPAL-TRANSMISSION-SIG webhooks carry and the only one
+	// signature verification accepts
+	Signature string
+
+	mu            sync.Mutex
+	subscriptions map[string]*subscription
+	orders        map[string]*order
+}
+
+// New creates an empty server whose webhooks carry signature
+func New(signature, webhookURL, paymentsWebhookURL string) *Server {
+	return &Server{
+		WebhookURL:         webhookURL,
+		PaymentsWebhookURL: paymentsWebhookURL,
+		Signature:          signature,
+		subscriptions:      make(map[string]*subscription),
+		orders:             make(map[string]*order),
+	}
+}
+
+func (f *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/oauth2/token", f.token)
+	mux.HandleFunc("POST /v1/billing/subscriptions", f.create)
+	mux.HandleFunc("GET /v1/billing/subscriptions/{id}", f.get)
+	mux.HandleFunc("POST /v1/billing/subscriptions/{id}/revise", f.revise)
+	mux.HandleFunc("POST /v1/billing/subscriptions/{id}/cancel", f.cancel)
+	mux.HandleFunc("POST /v2/checkout/orders", f.createOrder)
+	mux.HandleFunc("GET /v2/checkout/orders/{id}", f.getOrder)
+	mux.HandleFunc("POST /v2/checkout/orders/{id}/capture", f.captureOrder)
+	mux.HandleFunc("POST /v1/notifications/verify-webhook-signature", f.verify)
+	mux.HandleFunc("GET /approve/{id}", f.approve)
+	mux.HandleFunc("GET /checkoutnow/{id}", f.approveOrder)
+	return mux
+}
+
+func (f *Server) token(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "fake-" + newID(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (f *Server) create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PlanID             string `json:"plan_id"`
+		CustomID           string `json:"custom_id"`
+		ApplicationContext struct {
+			ReturnURL string `json:"return_url"`
+		} `json:"application_context"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PlanID == "" {
+		http.Error(w, `{"name":"INVALID_REQUEST"}`, http.StatusBadRequest)
+		return
+	}
+
+	sub := &subscription{
+		ID:        "I-" + newID(),
+		PlanID:    req.PlanID,
+		Status:    "APPROVAL_PENDING",
+		CustomID:  req.CustomID,
+		returnURL: req.ApplicationContext.ReturnURL,
+	}
+	sub.Links = []map[string]string{{"rel": "approve", "href": "http://" + r.Host + "/approve/" + sub.ID}}
+
+	f.mu.Lock()
+	f.subscriptions[sub.ID] = sub
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+func (f *Server) get(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sub, ok := f.subscriptions[r.PathValue("id")]
+	if !ok {
+		http.Error(w, `{"name":"RESOURCE_NOT_FOUND"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (f *Server) revise(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PlanID             string `json:"plan_id"`
+		ApplicationContext struct {
+			ReturnURL string `json:"return_url"`
+		} `json:"application_context"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PlanID == "" {
+		http.Error(w, `{"name":"INVALID_REQUEST"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sub, ok := f.subscriptions[r.PathValue("id")]
+	if !ok {
+		http.Error(w, `{"name":"RESOURCE_NOT_FOUND"}`, http.StatusNotFound)
+		return
+	}
+	sub.pendingPlan = req.PlanID
+	sub.returnURL = req.ApplicationContext.ReturnURL
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"plan_id": req.PlanID,
+		"links":   []map[string]string{{"rel": "approve", "href": "http://" + r.Host + "/approve/" + sub.ID}},
+	})
+}
+
+func (f *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	sub, ok := f.subscriptions[r.PathValue("id")]
+	if ok {
+		sub.Status = "CANCELLED"
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		http.Error(w, `{"name":"RESOURCE_NOT_FOUND"}`, http.StatusNotFound)
+		return
+	}
+
+	go f.deliver(f.WebhookURL, "BILLING.SUBSCRIPTION.CANCELLED", "subscription", sub.ID, sub)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *Server) verify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TransmissionSig string `json:"transmission_sig"`
+	}
+	status := "FAILURE"
+	if err := json.NewDecoder(r.Body).Decode(&req); err == nil && req.TransmissionSig == f.Signature {
+		status = "SUCCESS"
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"verification_status": status})
+}
+
+// approve plays the payer approving the subscription (or plan change) on PayPal
+func (f *Server) approve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	sub, ok := f.subscriptions[r.PathValue("id")]
+	if !ok {
+		f.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+
+	eventType := "BILLING.SUBSCRIPTION.ACTIVATED"
+	if sub.pendingPlan != "" {
+		eventType = "BILLING.SUBSCRIPTION.UPDATED"
+		sub.PlanID = sub.pendingPlan
+		sub.pendingPlan = ""
+	}
+
+	now := time.Now().UTC()
+	next := now.AddDate(0, 1, 0)
+	sub.Status = "ACTIVE"
+	if sub.StartTime == nil {
+		sub.StartTime = &now
+	}
+	sub.BillingInfo = map[string]interface{}{
+		"next_billing_time": next,
+		"last_payment":      map[string]interface{}{"time": now},
+	}
+	returnURL := sub.returnURL
+	f.mu.Unlock()
+
+	f.deliver(f.WebhookURL, eventType, "subscription", sub.ID, sub)
+
+	if returnURL != "" {
+		http.Redirect(w, r, returnURL, http.StatusFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (f *Server) createOrder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Intent             string         `json:"intent"`
+		PurchaseUnits      []purchaseUnit `json:"purchase_units"`
+		ApplicationContext struct {
+			ReturnURL string `json:"return_url"`
+		} `json:"application_context"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Intent != "CAPTURE" ||
+		len(req.PurchaseUnits) != 1 || req.PurchaseUnits[0].Amount.Value == "" {
+		http.Error(w, `{"name":"INVALID_REQUEST"}`, http.StatusBadRequest)
+		return
+	}
+
+	o := &order{
+		ID:            newID(),
+		Intent:        req.Intent,
+		Status:        "CREATED",
+		PurchaseUnits: req.PurchaseUnits,
+		returnURL:     req.ApplicationContext.ReturnURL,
+	}
+	o.Links = []map[string]string{{"rel": "approve", "href": "http://" + r.Host + "/checkoutnow/" + o.ID}}
+
+	f.mu.Lock()
+	f.orders[o.ID] = o
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, o)
+}
+
+func (f *Server) getOrder(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	o, ok := f.orders[r.PathValue("id")]
+	if !ok {
+		http.Error(w, `{"name":"RESOURCE_NOT_FOUND"}`, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// captureOrder settles an approved order; like PayPal it refuses a second capture
+func (f *Server) captureOrder(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	o, ok := f.orders[r.PathValue("id")]
+	if !ok {
+		http.Error(w, `{"name":"RESOURCE_NOT_FOUND"}`, http.StatusNotFound)
+		return
+	}
+	switch o.Status {
+	case "COMPLETED":
+		http.Error(w, `{"name":"UNPROCESSABLE_ENTITY","details":[{"issue":"ORDER_ALREADY_CAPTURED"}]}`, http.StatusUnprocessableEntity)
+		return
+	case "APPROVED":
+	default:
+		http.Error(w, `{"name":"UNPROCESSABLE_ENTITY","details":[{"issue":"ORDER_NOT_APPROVED"}]}`, http.StatusUnprocessableEntity)
+		return
+	}
+
+	o.Status = "COMPLETED"
+	unit := &o.PurchaseUnits[0]
+	unit.Payments = &struct {
+		Captures []capture `json:"captures"`
+	}{Captures: []capture{{ID: newID(), Status: "COMPLETED", CustomID: unit.CustomID, Amount: unit.Amount}}}
+
+	writeJSON(w, http.StatusCreated, o)
+}
+
+// approveOrder plays the payer approving the order on PayPal
+func (f *Server) approveOrder(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	o, ok := f.orders[r.PathValue("id")]
+	if !ok || o.Status != "CREATED" {
+		f.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	o.Status = "APPROVED"
+	returnURL := o.returnURL
+	f.mu.Unlock()
+
+	f.deliver(f.PaymentsWebhookURL, "CHECKOUT.ORDER.APPROVED", "checkout-order", o.ID, o)
+
+	if returnURL != "" {
+		http.Redirect(w, r, returnURL, http.StatusFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// Event returns the headers and body of a signed webhook event with a new ID
+func (f *Server) Event(eventType, resourceType string, resource interface{}) (http.Header, []byte, error) {
+	f.mu.Lock()
+	body, err := json.Marshal(map[string]interface{}{
+		"id":            "WH-" + newID(),
+		"event_type":    eventType,
+		"resource_type": resourceType,
+		"create_time":   time.Now().UTC(),
+		"resource":      resource,
+	})
+	f.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("PAYPAL-AUTH-ALGO", "SHA256withRSA")
+	headers.Set("PAYPAL-TRANSMISSION-ID", newID())
+	headers.Set("PAYPAL-TRANSMISSION-TIME", time.Now().UTC().Format(time.RFC3339))
+	headers.Set("PAYPAL-TRANSMISSION-SIG", f.Signature)
+	return headers, body, nil
+}
+
+func (f *Server) deliver(url, eventType, resourceType, resourceID string, resource interface{}) {
+	if url == "" {
+		return
+	}
+	headers, body, err := f.Event(eventType, resourceType, resource)
+	if err != nil {
+		log.Printf("failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("failed to build %s webhook: %v", eventType, err)
+		return
+	}
+	req.Header = headers
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("failed to deliver %s webhook: %v", eventType, err)
+		return
+	}
+	resp.Body.Close()
+	log.Printf("delivered %s for %s: %d", eventType, resourceID, resp.StatusCode)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
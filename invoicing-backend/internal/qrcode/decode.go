@@ -0,0 +1,338 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+var ErrUnreadable = errors.New("unreadable QR code")
+
+// Error correction levels by the two level bits of the format information
+var formatLevels = [4]byte{'M', 'L', 'H', 'Q'}
+
+// Symbol is a QR code read back by Decode
+type Symbol struct {
+	Version int
+	// Level is the error correction level: 'L', 'M', 'Q' or 'H'
+	Level byte
+	Mask  int
+	Data  []byte
+	// Corrected counts the codewords Reed-Solomon decoding repaired
+	Corrected int
+}
+
+// Decode reads a byte mode symbol at level M from its modules, e.g. as sampled from
+// a rendered image, repairing damaged codewords like a scanner would. It lets tests
+// check rendered codes end to end.
+func Decode(size int, dark func(x, y int) bool) (*Symbol, error) {
+	version := (size - 17) / 4
+	if version < 1 || version > 40 || version*4+17 != size {
+		return nil, fmt.Errorf("%w: %d modules is no QR code size", ErrUnreadable, size)
+	}
+
+	format, ok := readFormat(size, dark)
+	if !ok {
+		return nil, fmt.Errorf("%w: format information damaged", ErrUnreadable)
+	}
+	symbol := &Symbol{Version: version, Level: formatLevels[format>>3], Mask: format & 7}
+	if symbol.Level != 'M' {
+		return nil, fmt.Errorf("%w: error correction level %c is not supported", ErrUnreadable, symbol.Level)
+	}
+	if version >= 7 && !checkVersion(size, version, dark) {
+		return nil, fmt.Errorf("%w: version information doesn't match version %d", ErrUnreadable, version)
+	}
+
+	// Lay out the function patterns of the version so only data modules are read
+	c := &Code{
+		Version:  version,
+		Size:     size,
+		modules:  make([]bool, size*size),
+		function: make([]bool, size*size),
+	}
+	c.drawFunctionPatterns()
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c.modules[y*size+x] = dark(x, y)
+		}
+	}
+	c.applyMask(symbol.Mask)
+
+	blocks := deinterleave(c.readCodewords(), version)
+	eccLen := eccCodewordsPerBlock[version]
+	var data []byte
+	for _, block := range blocks {
+		corrected, err := correctErrors(block, eccLen)
+		if err != nil {
+			return nil, err
+		}
+		symbol.Corrected += corrected
+		data = append(data, block[:len(block)-eccLen]...)
+	}
+
+	symbol.Data, ok = readByteSegment(data, version)
+	if !ok {
+		return nil, fmt.Errorf("%w: no byte mode segment", ErrUnreadable)
+	}
+	return symbol, nil
+}
+
+// readFormat returns the five format bits (level and mask) from the copy that is
+// closest to a valid format code, within the three errors the code corrects
+func readFormat(size int, dark func(x, y int) bool) (int, bool) {
+	var first, second int
+	for i := 0; i <= 5; i++ {
+		first |= bit(dark(8, i)) << i
+	}
+	first |= bit(dark(8, 7))<<6 | bit(dark(8, 8))<<7 | bit(dark(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		first |= bit(dark(14-i, 8)) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= bit(dark(size-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(dark(8, size-15+i)) << i
+	}
+
+	best, bestDistance := 0, 4
+	for data := 0; data < 32; data++ {
+		rem := data
+		for i := 0; i < 10; i++ {
+			rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+		}
+		code := (data<<10 | rem) ^ 0x5412
+		for _, read := range []int{first, second} {
+			if distance := bits.OnesCount(uint(code ^ read)); distance < bestDistance {
+				best, bestDistance = data, distance
+			}
+		}
+	}
+	return best, bestDistance < 4
+}
+
+// checkVersion reports whether either copy of the version information is within
+// three errors of the version's code
+func checkVersion(size, version int, dark func(x, y int) bool) bool {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	code := version<<12 | rem
+
+	var first, second int
+	for i := 0; i < 18; i++ {
+		a, b := size-11+i%3, i/3
+		first |= bit(dark(a, b)) << i
+		second |= bit(dark(b, a)) << i
+	}
+	return bits.OnesCount(uint(code^first)) <= 3 || bits.OnesCount(uint(code^second)) <= 3
+}
+
+// readCodewords reads the data modules in the order drawCodewords fills them
+func (c *Code) readCodewords() []byte {
+	codewords := make([]byte, rawDataModules(c.Version)/8)
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y*c.Size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				if c.modules[y*c.Size+x] {
+					codewords[i>>3] |= 1 << (7 - i&7)
+				}
+				i++
+			}
+		}
+	}
+	return codewords
+}
+
+// deinterleave undoes addErrorCorrection's interleaving into blocks of data
+// codewords followed by their error correction codewords
+func deinterleave(codewords []byte, version int) [][]byte {
+	numBlocks := eccBlocks[version]
+	eccLen := eccCodewordsPerBlock[version]
+	rawCodewords := rawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortDataLen := rawCodewords/numBlocks - eccLen
+
+	blocks := make([][]byte, numBlocks)
+	dataLens := make([]int, numBlocks)
+	for i := range blocks {
+		dataLens[i] = shortDataLen
+		if i >= numShortBlocks {
+			dataLens[i]++
+		}
+		blocks[i] = make([]byte, 0, dataLens[i]+eccLen)
+	}
+
+	next := 0
+	for i := 0; i <= shortDataLen; i++ {
+		for b := range blocks {
+			if i < dataLens[b] {
+				blocks[b] = append(blocks[b], codewords[next])
+				next++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[next])
+			next++
+		}
+	}
+	return blocks
+}
+
+// correctErrors repairs the block in place with Berlekamp-Massey and Forney and
+// returns how many codewords it changed. The generator's roots are α^0 … α^(eccLen-1).
+func correctErrors(block []byte, eccLen int) (int, error) {
+	n := len(block)
+	syndromes := make([]byte, eccLen)
+	clean := true
+	for j := range syndromes {
+		root := gfExp(j)
+		var s byte
+		for _, b := range block {
+			s = gfMultiply(s, root) ^ b
+		}
+		syndromes[j] = s
+		clean = clean && s == 0
+	}
+	if clean {
+		return 0, nil
+	}
+
+	// Error locator Λ(x), lowest degree first
+	locator, previous := []byte{1}, []byte{1}
+	numErrors, shift, scale := 0, 1, byte(1)
+	for k := 0; k < eccLen; k++ {
+		delta := syndromes[k]
+		for i := 1; i <= numErrors && i < len(locator); i++ {
+			delta ^= gfMultiply(locator[i], syndromes[k-i])
+		}
+		if delta == 0 {
+			shift++
+			continue
+		}
+		factor := gfMultiply(delta, gfInverse(scale))
+		next := make([]byte, max(len(locator), len(previous)+shift))
+		copy(next, locator)
+		for i, p := range previous {
+			next[i+shift] ^= gfMultiply(factor, p)
+		}
+		if 2*numErrors <= k {
+			previous, numErrors, scale, shift = locator, k+1-numErrors, delta, 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	if 2*numErrors > eccLen {
+		return 0, fmt.Errorf("%w: too many damaged codewords", ErrUnreadable)
+	}
+
+	// Error evaluator Ω(x) = S(x)Λ(x) mod x^eccLen
+	evaluator := make([]byte, eccLen)
+	for i, s := range syndromes {
+		for j, l := range locator {
+			if i+j < eccLen {
+				evaluator[i+j] ^= gfMultiply(s, l)
+			}
+		}
+	}
+
+	// Chien search over the codeword positions, then Forney for each magnitude
+	found := 0
+	for i := range block {
+		x := gfExp(n - 1 - i) // locator X of position i
+		xInverse := gfInverse(x)
+		if evalPolynomial(locator, xInverse) != 0 {
+			continue
+		}
+		var derivative byte
+		for k := 1; k < len(locator); k += 2 {
+			derivative ^= gfMultiply(locator[k], gfPower(xInverse, k-1))
+		}
+		if derivative == 0 {
+			return 0, fmt.Errorf("%w: error locator has a repeated root", ErrUnreadable)
+		}
+		magnitude := gfMultiply(x, gfMultiply(evalPolynomial(evaluator, xInverse), gfInverse(derivative)))
+		block[i] ^= magnitude
+		found++
+	}
+	if found != numErrors {
+		return 0, fmt.Errorf("%w: too many damaged codewords", ErrUnreadable)
+	}
+	return found, nil
+}
+
+// readByteSegment parses the mode indicator, character count and bytes of a byte mode segment
+func readByteSegment(data []byte, version int) ([]byte, bool) {
+	pos := 0
+	read := func(length int) int {
+		value := 0
+		for i := 0; i < length; i++ {
+			value = value<<1 | int(data[pos>>3]>>(7-pos&7)&1)
+			pos++
+		}
+		return value
+	}
+
+	if 4+countBits(version) > len(data)*8 || read(4) != 0x4 {
+		return nil, false
+	}
+	count := read(countBits(version))
+	if pos+count*8 > len(data)*8 {
+		return nil, false
+	}
+	result := make([]byte, count)
+	for i := range result {
+		result[i] = byte(read(8))
+	}
+	return result, true
+}
+
+// evalPolynomial evaluates a polynomial given lowest degree first
+func evalPolynomial(p []byte, x byte) byte {
+	var result byte
+	for i := len(p) - 1; i >= 0; i-- {
+		result = gfMultiply(result, x) ^ p[i]
+	}
+	return result
+}
+
+// gfExp returns α^e for the generator α = 2
+func gfExp(e int) byte {
+	return gfPower(0x02, e)
+}
+
+func gfPower(x byte, e int) byte {
+	result := byte(1)
+	for i := 0; i < e%255; i++ {
+		result = gfMultiply(result, x)
+	}
+	return result
+}
+
+func gfInverse(x byte) byte {
+	return gfPower(x, 254)
+}
+
+func bit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,84 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Byte mode capacity at level M around the changes in layout: version information
+// starts at version 7 and 16-bit character counts at version 10
+var capacities = map[int]int{1: 14, 6: 106, 7: 122, 9: 180, 10: 213, 40: 2331}
+
+func TestEncodeDecode(t *testing.T) {
+	for version, capacity := range capacities {
+		for _, length := range []int{capacity, capacity + 1} {
+			if version == 40 && length > capacity {
+				continue
+			}
+			data := []byte(strings.Repeat("SPC\n0200\n1\nCH44 3199 9123 0008 8901 2\n", 70)[:length])
+
+			code, err := Encode(data)
+			if err != nil {
+				t.Fatalf("%d bytes: %v", length, err)
+			}
+			want := version
+			if length > capacity {
+				want++
+			}
+			if code.Version != want || code.Size != want*4+17 {
+				t.Errorf("%d bytes: version %d of size %d, want version %d", length, code.Version, code.Size, want)
+			}
+
+			symbol, err := Decode(code.Size, code.Dark)
+			if err != nil {
+				t.Fatalf("%d bytes: %v", length, err)
+			}
+			if symbol.Version != code.Version || symbol.Level != 'M' || symbol.Corrected != 0 {
+				t.Errorf("%d bytes: decoded version %d, level %c, %d corrections", length, symbol.Version, symbol.Level, symbol.Corrected)
+			}
+			if !bytes.Equal(symbol.Data, data) {
+				t.Errorf("%d bytes: decoded %q", length, symbol.Data)
+			}
+		}
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	if _, err := Encode(make([]byte, capacities[40]+1)); !errors.Is(err, ErrDataTooLong) {
+		t.Fatalf("err = %v, want ErrDataTooLong", err)
+	}
+}
+
+func TestDecodeDamaged(t *testing.T) {
+	data := []byte("BCD\n002\n1\nSCT\nBFSWDE33BER\nWikimedia Foerdergesellschaft\nDE33100205000001194700\nEUR123.45")
+	code, err := Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cover a square in the middle, as logos (and the Swiss cross) do
+	damaged := func(side int) func(x, y int) bool {
+		from := (code.Size - side) / 2
+		return func(x, y int) bool {
+			if x >= from && x < from+side && y >= from && y < from+side {
+				return true
+			}
+			return code.Dark(x, y)
+		}
+	}
+
+	symbol, err := Decode(code.Size, damaged(5))
+	if err != nil {
+		t.Fatalf("small damage not repaired: %v", err)
+	}
+	if symbol.Corrected == 0 || !bytes.Equal(symbol.Data, data) {
+		t.Fatalf("decoded %q with %d corrections", symbol.Data, symbol.Corrected)
+	}
+
+	// Level M repairs about 15% of the codewords, not a third of the symbol
+	if _, err := Decode(code.Size, damaged(code.Size/2)); !errors.Is(err, ErrUnreadable) {
+		t.Fatalf("err = %v, want ErrUnreadable", err)
+	}
+}
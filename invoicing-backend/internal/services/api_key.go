@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPIKeyNotFound          = errors.New("api key not found")
+	ErrAPIKeyInvalid           = errors.New("invalid api key")
+	ErrAPIKeyPermissionsExceed = errors.New("api key permissions exceed your role")
+)
+
+// apiKeyUsageInterval is how often the last use of a key is written; requests in
+// between from the same IP don't update it
+const apiKeyUsageInterval = time.Minute
+
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{db: db}
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string                 `json:"name" validate:"required,min=1,max=100"`
+	Permissions models.RolePermissions `json:"permissions"`
+	ExpiresAt   *time.Time             `json:"expires_at"`
+}
+
+// CreateAPIKey creates a new key for the organization and returns it along with
+// the plaintext secret, which is only ever available at creation or rotation time
+func (s *APIKeyService) CreateAPIKey(audit AuditContext, organizationID string, creatorRole models.Role, req *CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	if err := req.Permissions.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	if !req.Permissions.IsSubsetOf(creatorRole.Permissions) {
+		return nil, "", ErrAPIKeyPermissionsExceed
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, "", fmt.Errorf("expiry must be in the future")
+	}
+
+	rawKey, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	apiKey := &models.APIKey{
+		OrganizationID: organizationID,
+		CreatedBy:      audit.UserID,
+		Name:           req.Name,
+		KeyPrefix:      prefix,
+		KeyHash:        utils.HashToken(rawKey),
+		Permissions:    req.Permissions,
+		ExpiresAt:      req.ExpiresAt,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(apiKey).Error; err != nil {
+			return fmt.Errorf("failed to create api key: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionCreate,
+			models.AuditResourceAPIKey, apiKey.ID, nil, apiKey)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return apiKey, rawKey, nil
+}
+
+func (s *APIKeyService) GetAPIKeysByOrganization(organizationID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.Where("organization_id = ?", organizationID).
+		Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *APIKeyService) GetAPIKeyByID(keyID, organizationID string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.db.Where("id = ? AND organization_id = ?", keyID, organizationID).First(&key).Error; err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+// RotateAPIKey replaces the secret of an active key, immediately invalidating the old one
+func (s *APIKeyService) RotateAPIKey(audit AuditContext, keyID, organizationID string) (*models.APIKey, string, error) {
+	key, err := s.GetAPIKeyByID(keyID, organizationID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !key.IsUsable() {
+		return nil, "", fmt.Errorf("cannot rotate a revoked or expired api key")
+	}
+
+	rawKey, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	before := *key
+	key.KeyPrefix = prefix
+	key.KeyHash = utils.HashToken(rawKey)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(key).Error; err != nil {
+			return fmt.Errorf("failed to rotate api key: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionRotate,
+			models.AuditResourceAPIKey, key.ID, &before, key)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, rawKey, nil
+}
+
+func (s *APIKeyService) RevokeAPIKey(audit AuditContext, keyID, organizationID string) error {
+	key, err := s.GetAPIKeyByID(keyID, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if key.IsRevoked() {
+		return nil
+	}
+
+	before := *key
+	now := time.Now()
+	key.RevokedAt = &now
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(key).Error; err != nil {
+			return fmt.Errorf("failed to revoke api key: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionRevoke,
+			models.AuditResourceAPIKey, key.ID, &before, key)
+	})
+}
+
+// Authenticate resolves a raw key to an active API key and records its usage,
+// at most once per apiKeyUsageInterval while the client IP stays the same
+func (s *APIKeyService) Authenticate(rawKey, clientIP string) (*models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, models.APIKeyPrefix) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	var key models.APIKey
+	if err := s.db.Where("key_hash = ?", utils.HashToken(rawKey)).First(&key).Error; err != nil {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if !key.IsUsable() {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	now := time.Now()
+	if key.LastUsedAt != nil && key.LastUsedIP == clientIP && now.Sub(*key.LastUsedAt) < apiKeyUsageInterval {
+		return &key, nil
+	}
+
+	key.LastUsedAt = &now
+	key.LastUsedIP = clientIP
+	if err := s.db.Model(&key).UpdateColumns(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": clientIP,
+	}).Error; err != nil {
+		// Usage tracking must not block an otherwise valid request
+		log.Printf("failed to record api key usage for %s: %v", key.ID, err)
+	}
+
+	return &key, nil
+}
+
+// generateAPIKey returns a new raw key and the short prefix shown to users to identify it
+func generateAPIKey() (string, string, error) {
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	rawKey := models.APIKeyPrefix + secret
+	return rawKey, rawKey[:len(models.APIKeyPrefix)+8], nil
+}
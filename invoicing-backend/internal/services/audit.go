@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// AuditContext identifies who performed an action and the request it came from
+type AuditContext struct {
+	UserID         string
+	APIKeyID       string
+	ImpersonatorID string
+	IPAddress      string
+	UserAgent      string
+	RequestID      string
+}
+
+type AuditEventFilter struct {
+	ResourceType string
+	ResourceID   string
+	ActorID      string
+	Action       string
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+	Offset       int
+}
+
+type AuditService struct {
+	db *gorm.DB
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{db: db}
+}
+
+// GetEvents lists the organization's audit events, newest first
+func (s *AuditService) GetEvents(organizationID string, filter AuditEventFilter) ([]models.AuditEvent, int64, error) {
+	var events []models.AuditEvent
+	var total int64
+
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		query := tx.Model(&models.AuditEvent{}).Where("organization_id = ?", organizationID)
+		if filter.ResourceType != "" {
+			query = query.Where("resource_type = ?", filter.ResourceType)
+		}
+		if filter.ResourceID != "" {
+			query = query.Where("resource_id = ?", filter.ResourceID)
+		}
+		if filter.ActorID != "" {
+			query = query.Where("actor_user_id = ? OR actor_api_key_id = ?", filter.ActorID, filter.ActorID)
+		}
+		if filter.Action != "" {
+			query = query.Where("action = ?", filter.Action)
+		}
+		if filter.From != nil {
+			query = query.Where("created_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			query = query.Where("created_at < ?", *filter.To)
+		}
+
+		// Reusable for both the count and the page query
+		query = query.Session(&gorm.Session{})
+
+		if err := query.Count(&total).Error; err != nil {
+			return err
+		}
+		return query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&events).Error
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// recordAuditEvent appends an audit event within tx. before and after are the
+// resource before and after the change (nil for creates and deletes respectively);
+// only fields that differ are stored.
+func recordAuditEvent(tx *gorm.DB, audit AuditContext, organizationID, action, resourceType, resourceID string, before, after interface{}) error {
+	event := models.AuditEvent{
+		OrganizationID: organizationID,
+		ActorType:      models.AuditActorUser,
+		Action:         action,
+		ResourceType:   resourceType,
+		ResourceID:     resourceID,
+		Changes:        auditChanges(auditSnapshot(before), auditSnapshot(after)),
+		IPAddress:      audit.IPAddress,
+		UserAgent:      audit.UserAgent,
+		RequestID:      audit.RequestID,
+	}
+	if audit.UserID != "" {
+		event.ActorUserID = &audit.UserID
+	}
+	if audit.APIKeyID != "" {
+		event.ActorType = models.AuditActorAPIKey
+		event.ActorAPIKeyID = &audit.APIKeyID
+	}
+	if audit.ImpersonatorID != "" {
+		event.ImpersonatorID = &audit.ImpersonatorID
+	}
+	if audit.UserID == "" && audit.APIKeyID == "" {
+		event.ActorType = models.AuditActorSystem
+	}
+
+	err := database.WithOrganization(tx, organizationID, func(tx *gorm.DB) error {
+		return tx.Create(&event).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record audit event: %w", err)
+	}
+	return nil
+}
+
+// auditSnapshot converts a resource to its JSON representation
+func auditSnapshot(v interface{}) map[string]interface{} {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// auditChanges keeps only the fields that differ between before and after
+func auditChanges(before, after map[string]interface{}) models.JSONMap {
+	changes := models.JSONMap{}
+	if before == nil && after == nil {
+		return changes
+	}
+	if before == nil {
+		changes["after"] = after
+		return changes
+	}
+	if after == nil {
+		changes["before"] = before
+		return changes
+	}
+
+	changedBefore := map[string]interface{}{}
+	changedAfter := map[string]interface{}{}
+	for key, value := range after {
+		if key == "updated_at" {
+			continue
+		}
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			changedBefore[key] = before[key]
+			changedAfter[key] = value
+		}
+	}
+	for key, old := range before {
+		if _, ok := after[key]; !ok {
+			changedBefore[key] = old
+			changedAfter[key] = nil
+		}
+	}
+
+	changes["before"] = changedBefore
+	changes["after"] = changedAfter
+	return changes
+}
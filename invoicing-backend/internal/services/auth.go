@@ -1,18 +1,32 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/yourusername/invoicing-backend/internal/models"
 	"gorm.io/gorm"
 )
 
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
 type AuthService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	throttle *LoginThrottleService
+	notifier Notifier
+	trial    TrialConfig
 }
 
-func NewAuthService(db *gorm.DB) *AuthService {
-	return &AuthService{db: db}
+// NewAuthService registers new organizations with the given trial
+func NewAuthService(db *gorm.DB, notifier Notifier, trial TrialConfig) *AuthService {
+	return &AuthService{
+		db:       db,
+		throttle: NewLoginThrottleService(db),
+		notifier: notifier,
+		trial:    trial,
+	}
 }
 
 type RegisterRequest struct {
@@ -20,6 +34,8 @@ type RegisterRequest struct {
 	Password  string `json:"password" validate:"required,min=8"`
 	FirstName string `json:"first_name" validate:"required,min=1,max=100"`
 	LastName  string `json:"last_name" validate:"required,min=1,max=100"`
+	// CouponCode is redeemed for the organization's first paid plan
+	CouponCode string `json:"coupon_code" validate:"omitempty,max=50"`
 }
 
 type LoginRequest struct {
@@ -27,7 +43,9 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
-func (s *AuthService) Register(email, password, firstName, lastName string) (*models.User, error) {
+// Register creates the user with their own organization. couponCode, if not empty,
+// is redeemed for the organization's first paid plan.
+func (s *AuthService) Register(email, password, firstName, lastName, couponCode string) (*models.User, error) {
 	// Check if user already exists
 	var existingUser models.User
 	if err := s.db.Where("email = ?", email).First(&existingUser).Error; err == nil {
@@ -73,7 +91,7 @@ func (s *AuthService) Register(email, password, firstName, lastName string) (*mo
 
 	// Get or create org_admin role
 	var orgAdminRole models.Role
-	if err := tx.Where("name = ?", models.RoleOrgAdmin).First(&orgAdminRole).Error; err != nil {
+	if err := tx.Where("name = ? AND organization_id IS NULL", models.RoleOrgAdmin).First(&orgAdminRole).Error; err != nil {
 		// Create default role if it doesn't exist
 		orgAdminRole = models.Role{
 			Name:         models.RoleOrgAdmin,
@@ -89,8 +107,8 @@ func (s *AuthService) Register(email, password, firstName, lastName string) (*mo
 
 	// Assign user to organization with org_admin role
 	userOrgRole := models.UserOrganizationRole{
-		UserID:         user.ID,
-		OrganizationID: organization.ID,
+		UserID:         user.ID.String(),
+		OrganizationID: organization.ID.String(),
 		RoleID:         orgAdminRole.ID,
 	}
 
@@ -99,14 +117,19 @@ func (s *AuthService) Register(email, password, firstName, lastName string) (*mo
 		return nil, fmt.Errorf("failed to assign user to organization: %w", err)
 	}
 
-	// Create a free trial subscription
+	// Start with a trial, or on the free plan when trials are disabled
 	subscription := models.Subscription{
-		OrganizationID:      organization.ID,
-		PlanType:            models.SubscriptionPlanFree,
-		Status:              models.SubscriptionStatusActive,
-		MonthlyInvoiceLimit: 10,
-		MonthlyClientLimit:  50,
-		MonthlyUserLimit:    3,
+		OrganizationID: organization.ID.String(),
+	}
+	if err := startSubscription(tx, &subscription, s.trial); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if couponCode != "" {
+		if _, err := redeemCoupon(tx, &subscription, couponCode, "", user.ID.String(), time.Now()); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 	}
 
 	if err := tx.Create(&subscription).Error; err != nil {
@@ -122,19 +145,94 @@ func (s *AuthService) Register(email, password, firstName, lastName string) (*mo
 	return &user, nil
 }
 
-func (s *AuthService) Login(email, password string) (*models.User, error) {
+// Login verifies credentials. Repeated failures lock the account and the client IP
+// with exponential backoff; every attempt is recorded in login_attempts.
+func (s *AuthService) Login(email, password, clientIP, userAgent string) (*models.User, error) {
+	if err := s.throttle.CheckLocked(email, clientIP); err != nil {
+		var lockedErr *LoginLockedError
+		if errors.As(err, &lockedErr) {
+			s.recordLoginAttempt(email, nil, clientIP, userAgent, models.LoginFailureLocked)
+		}
+		return nil, err
+	}
+
 	var user models.User
 	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		s.handleFailedLogin(email, nil, clientIP, userAgent)
+		return nil, ErrInvalidCredentials
 	}
 
 	if !user.CheckPassword(password) {
-		return nil, fmt.Errorf("invalid credentials")
+		s.handleFailedLogin(email, &user, clientIP, userAgent)
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.throttle.ResetAccount(email); err != nil {
+		log.Printf("failed to reset login throttle for %s: %v", email, err)
 	}
+	userID := user.ID.String()
+	s.recordLoginAttempt(email, &userID, clientIP, userAgent, "")
 
 	return &user, nil
 }
 
+// UnlockAccount clears the lockout of a member of the given organization, and the
+// locks of IPs that only failed against that member's account
+func (s *AuthService) UnlockAccount(organizationID, userID string) error {
+	var user models.User
+	if err := s.db.Joins("JOIN user_organization_roles uor ON uor.user_id = users.id").
+		Where("users.id = ? AND uor.organization_id = ?", userID, organizationID).
+		First(&user).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := s.throttle.ResetAccount(user.Email); err != nil {
+		return err
+	}
+	return s.throttle.ResetAccountIPs(user.Email)
+}
+
+func (s *AuthService) handleFailedLogin(email string, user *models.User, clientIP, userAgent string) {
+	var userID *string
+	if user != nil {
+		id := user.ID.String()
+		userID = &id
+	}
+	s.recordLoginAttempt(email, userID, clientIP, userAgent, models.LoginFailureInvalidCredentials)
+
+	locked, err := s.throttle.RecordFailure(email, clientIP)
+	if err != nil {
+		log.Printf("failed to record login failure for %s: %v", email, err)
+		return
+	}
+
+	// Only notify real accounts, and only when this failure triggered the lock
+	if locked && user != nil {
+		if err := s.notifier.Notify(Notification{
+			To:      user.Email,
+			Subject: "Your account has been temporarily locked",
+			Body: fmt.Sprintf("We detected several failed sign-in attempts to your account from %s. "+
+				"Sign-in has been temporarily disabled. If this wasn't you, consider changing your password.", clientIP),
+		}); err != nil {
+			log.Printf("failed to send lockout notification to %s: %v", user.Email, err)
+		}
+	}
+}
+
+func (s *AuthService) recordLoginAttempt(email string, userID *string, clientIP, userAgent, failureReason string) {
+	attempt := models.LoginAttempt{
+		Email:         normalizeEmail(email),
+		UserID:        userID,
+		IPAddress:     clientIP,
+		UserAgent:     userAgent,
+		Succeeded:     failureReason == "",
+		FailureReason: failureReason,
+	}
+	if err := s.db.Create(&attempt).Error; err != nil {
+		log.Printf("failed to record login attempt for %s: %v", email, err)
+	}
+}
+
 func (s *AuthService) GetUserByID(userID string) (*models.User, error) {
 	var user models.User
 	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
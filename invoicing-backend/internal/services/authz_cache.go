@@ -0,0 +1,258 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/cache"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuthzUserNotFound       = errors.New("user not found")
+	ErrAuthzMembershipNotFound = errors.New("user is not a member of the organization")
+	ErrAuthzNoSubscription     = errors.New("organization has no subscription")
+)
+
+// AuthzCache serves the user, membership and subscription lookups made on every
+// protected request. Entries expire after the TTL; services invalidate them
+// after committing changes to roles, memberships, organizations or subscriptions.
+//
+// Organization-wide invalidation bumps a generation number that is part of the
+// membership and subscription keys, so it works without key scans on any Store.
+// Generations live outside the store's evictable entries: in the store when it
+// implements cache.Generations, which a store shared between replicas should, and
+// in process memory otherwise.
+// Invalidation methods are no-ops on a nil *AuthzCache, so services work without one.
+type AuthzCache struct {
+	db          *gorm.DB
+	store       cache.Store
+	generations cache.Generations
+	ttl         time.Duration
+}
+
+func NewAuthzCache(db *gorm.DB, store cache.Store, ttl time.Duration) *AuthzCache {
+	generations, ok := store.(cache.Generations)
+	if !ok {
+		generations = cache.NewLocalGenerations()
+	}
+	return &AuthzCache{db: db, store: store, generations: generations, ttl: ttl}
+}
+
+// GetUser returns the user with its memberships (without nested relations).
+// Users read from the cache don't carry their password hash.
+func (c *AuthzCache) GetUser(userID string) (*models.User, error) {
+	var user models.User
+	err := c.readThrough(c.userKey(userID), &user, func() error {
+		if err := c.db.Preload("UserOrganizationRoles", func(tx *gorm.DB) *gorm.DB {
+			return tx.Order("assigned_at ASC")
+		}).First(&user, "id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAuthzUserNotFound
+			}
+			return fmt.Errorf("failed to load user: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetMembership returns the user's role in the organization, with the role and organization loaded
+func (c *AuthzCache) GetMembership(userID, organizationID string) (*models.UserOrganizationRole, error) {
+	var membership models.UserOrganizationRole
+	err := c.readThrough(c.membershipKey(userID, organizationID), &membership, func() error {
+		if err := c.db.Preload("Role").Preload("Organization").
+			Where("user_id = ? AND organization_id = ?", userID, organizationID).
+			First(&membership).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAuthzMembershipNotFound
+			}
+			return fmt.Errorf("failed to load membership: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// GetSubscription returns the organization's current subscription: the latest one
+// that wasn't cancelled, which may be past due or expired
+func (c *AuthzCache) GetSubscription(organizationID string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := c.readThrough(c.subscriptionKey(organizationID), &subscription, func() error {
+		if err := c.db.Where("organization_id = ? AND status <> ?", organizationID, models.SubscriptionStatusCancelled).
+			Order("created_at DESC").First(&subscription).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAuthzNoSubscription
+			}
+			return fmt.Errorf("failed to load subscription: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// GetPlan returns a plan of the catalog. Plans are shared by all organizations and
+// not invalidated; catalog edits take effect once cached entries expire.
+func (c *AuthzCache) GetPlan(code models.SubscriptionPlan) (*models.Plan, error) {
+	var plan models.Plan
+	err := c.readThrough(c.planKey(code), &plan, func() error {
+		if err := c.db.First(&plan, "code = ?", code).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownPlan
+			}
+			return fmt.Errorf("failed to load plan: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// GetOrganizationIDBySubdomain returns the ID of the organization using the
+// subdomain, or "" when none does. Misses are cached too, since hosts come from
+// untrusted requests.
+func (c *AuthzCache) GetOrganizationIDBySubdomain(subdomain string) (string, error) {
+	return c.organizationIDByHost(c.subdomainKey(subdomain), "subdomain = ?", subdomain)
+}
+
+// GetOrganizationIDByCustomDomain returns the ID of the organization whose verified
+// custom domain is domain, or "" when there is none. Misses are cached too.
+func (c *AuthzCache) GetOrganizationIDByCustomDomain(domain string) (string, error) {
+	return c.organizationIDByHost(c.customDomainKey(domain),
+		"LOWER(custom_domain) = ? AND custom_domain_verified_at IS NOT NULL", domain)
+}
+
+func (c *AuthzCache) organizationIDByHost(key, condition, name string) (string, error) {
+	var entry struct {
+		OrganizationID string `json:"organization_id"`
+	}
+	err := c.readThrough(key, &entry, func() error {
+		var org models.Organization
+		if err := c.db.Select("id").Where(condition, strings.ToLower(name)).First(&org).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("failed to look up organization by host: %w", err)
+		}
+		entry.OrganizationID = org.ID.String()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return entry.OrganizationID, nil
+}
+
+// InvalidateUser drops the cached user, e.g. after the current organization changed
+func (c *AuthzCache) InvalidateUser(userID string) {
+	if c == nil {
+		return
+	}
+	c.store.Delete(c.userKey(userID))
+}
+
+// InvalidateMembership drops one user's membership in an organization and the cached user
+func (c *AuthzCache) InvalidateMembership(userID, organizationID string) {
+	if c == nil {
+		return
+	}
+	c.store.Delete(c.userKey(userID), c.membershipKey(userID, organizationID))
+}
+
+// InvalidateOrganization drops every membership and the subscription of the organization
+func (c *AuthzCache) InvalidateOrganization(organizationID string) {
+	if c == nil {
+		return
+	}
+	c.generations.Bump(c.generationKey(organizationID))
+}
+
+// InvalidateSubdomain drops the cached organization of a subdomain, including a cached miss
+func (c *AuthzCache) InvalidateSubdomain(subdomain string) {
+	if c == nil || subdomain == "" {
+		return
+	}
+	c.store.Delete(c.subdomainKey(subdomain))
+}
+
+// InvalidateCustomDomain drops the cached organization of a custom domain, including a cached miss
+func (c *AuthzCache) InvalidateCustomDomain(domain string) {
+	if c == nil || domain == "" {
+		return
+	}
+	c.store.Delete(c.customDomainKey(domain))
+}
+
+// readThrough decodes the cached value into dest, or calls load to fill dest and caches it.
+// Lookup failures are not cached.
+func (c *AuthzCache) readThrough(key string, dest interface{}, load func() error) error {
+	if c.ttl <= 0 {
+		return load()
+	}
+
+	if data, ok := c.store.Get(key); ok {
+		if err := json.Unmarshal(data, dest); err == nil {
+			return nil
+		}
+		// Discard a partially decoded entry before loading from the database
+		reflect.ValueOf(dest).Elem().Set(reflect.Zero(reflect.TypeOf(dest).Elem()))
+	}
+
+	if err := load(); err != nil {
+		return err
+	}
+
+	if data, err := json.Marshal(dest); err == nil {
+		c.store.Set(key, data, c.ttl)
+	}
+	return nil
+}
+
+func (c *AuthzCache) generation(organizationID string) string {
+	return strconv.FormatInt(c.generations.Generation(c.generationKey(organizationID)), 10)
+}
+
+func (c *AuthzCache) userKey(userID string) string {
+	return "authz:user:" + userID
+}
+
+func (c *AuthzCache) membershipKey(userID, organizationID string) string {
+	return "authz:membership:" + organizationID + ":" + c.generation(organizationID) + ":" + userID
+}
+
+func (c *AuthzCache) subscriptionKey(organizationID string) string {
+	return "authz:subscription:" + organizationID + ":" + c.generation(organizationID)
+}
+
+func (c *AuthzCache) planKey(code models.SubscriptionPlan) string {
+	return "authz:plan:" + string(code)
+}
+
+func (c *AuthzCache) subdomainKey(subdomain string) string {
+	return "authz:subdomain:" + strings.ToLower(subdomain)
+}
+
+func (c *AuthzCache) customDomainKey(domain string) string {
+	return "authz:custom_domain:" + strings.ToLower(domain)
+}
+
+func (c *AuthzCache) generationKey(organizationID string) string {
+	return "authz:generation:" + organizationID
+}
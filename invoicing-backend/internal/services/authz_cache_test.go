@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/cache"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestAuthzCacheGenerationSurvivesEviction(t *testing.T) {
+	authz := NewAuthzCache(nil, cache.NewLRU(2), time.Minute)
+
+	before := authz.subscriptionKey("org-1")
+	authz.InvalidateOrganization("org-1")
+	after := authz.subscriptionKey("org-1")
+	if after == before {
+		t.Fatal("invalidation did not change the subscription key")
+	}
+
+	// Fill the LRU well past its capacity
+	for i := 0; i < 10; i++ {
+		authz.store.Set(fmt.Sprintf("filler-%d", i), []byte("x"), time.Minute)
+	}
+	if got := authz.subscriptionKey("org-1"); got != after {
+		t.Fatalf("key after eviction = %q, want %q", got, after)
+	}
+	if got := authz.subscriptionKey("org-1"); got == before {
+		t.Fatal("eviction brought back the invalidated key")
+	}
+}
+
+// sharedStore stands in for a store shared between replicas, such as Redis
+type sharedStore struct {
+	*cache.LRU
+	*cache.LocalGenerations
+}
+
+func TestAuthzCacheSharesStoreGenerations(t *testing.T) {
+	store := sharedStore{cache.NewLRU(100), cache.NewLocalGenerations()}
+	replicaA := NewAuthzCache(nil, store, time.Minute)
+	replicaB := NewAuthzCache(nil, store, time.Minute)
+
+	before := replicaB.membershipKey("user-1", "org-1")
+	replicaA.InvalidateOrganization("org-1")
+	if replicaB.membershipKey("user-1", "org-1") == before {
+		t.Fatal("invalidation on one replica was not seen by the other")
+	}
+}
+
+func TestLocalGenerationsDoNotRepeatAcrossRestarts(t *testing.T) {
+	first := cache.NewLocalGenerations()
+	first.Bump("org-1")
+	bumped := first.Generation("org-1")
+
+	time.Sleep(time.Millisecond)
+	restarted := cache.NewLocalGenerations()
+	if restarted.Generation("org-1") <= bumped {
+		t.Fatalf("restarted generation %d does not exceed previous %d", restarted.Generation("org-1"), bumped)
+	}
+}
+
+// queryCounter is a gorm logger that counts executed statements
+type queryCounter struct {
+	queries atomic.Int64
+}
+
+func (q *queryCounter) LogMode(logger.LogLevel) logger.Interface      { return q }
+func (q *queryCounter) Info(context.Context, string, ...interface{})  {}
+func (q *queryCounter) Warn(context.Context, string, ...interface{})  {}
+func (q *queryCounter) Error(context.Context, string, ...interface{}) {}
+func (q *queryCounter) Trace(context.Context, time.Time, func() (string, int64), error) {
+	q.queries.Add(1)
+}
+
+// BenchmarkAuthzRequestLookups resolves what a protected request needs (user,
+// membership, subscription) and reports the database queries per request
+func BenchmarkAuthzRequestLookups(b *testing.B) {
+	db := testutil.DB(b)
+	owner, org := newTestOrganization(b, db, 5)
+	userID, orgID := owner.ID.String(), org.ID.String()
+
+	for _, bench := range []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{"uncached", 0},
+		{"cached", time.Minute},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			counter := &queryCounter{}
+			authz := NewAuthzCache(db.Session(&gorm.Session{Logger: counter}), cache.NewLRU(1000), bench.ttl)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := authz.GetUser(userID); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := authz.GetMembership(userID, orgID); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := authz.GetSubscription(orgID); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(counter.queries.Load())/float64(b.N), "queries/op")
+		})
+	}
+}
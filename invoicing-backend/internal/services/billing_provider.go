@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+)
+
+var ErrPortalNotSupported = errors.New("billing provider has no customer portal")
+
+// BillingProvider bills organizations for their SaaS plan. Implementations talk to
+// the provider's API; the SubscriptionService owns the local subscription state.
+type BillingProvider interface {
+	// Name is the models.BillingProvider* value stored on subscriptions
+	Name() string
+	// Checkout starts a subscription to params.Plan, or moves the existing one to it
+	Checkout(ctx context.Context, params CheckoutParams) (*BillingCheckout, error)
+	// SupportsCoupons reports whether Checkout takes a CheckoutParams.Coupon
+	SupportsCoupons() bool
+	// PortalURL returns a link where the customer manages payment details and invoices
+	PortalURL(ctx context.Context, subscription *models.Subscription, returnURL string) (string, error)
+	Cancel(ctx context.Context, subscription *models.Subscription, reason string) error
+	// ParseWebhook verifies the request signature and decodes the event
+	ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*BillingEvent, error)
+}
+
+type CheckoutParams struct {
+	OrganizationID   string
+	OrganizationName string
+	Plan             models.SubscriptionPlan
+	// Subscription is the organization's current subscription
+	Subscription *models.Subscription
+	// Coupon is a discount to start with the checkout, nil for none
+	Coupon     *models.Coupon
+	SuccessURL string
+	CancelURL  string
+}
+
+// BillingCheckout is where the customer completes a checkout. CheckoutURL is empty
+// when the change was made without customer interaction. Either way the local
+// subscription changes once the provider confirms it via webhook.
+type BillingCheckout struct {
+	Provider               string `json:"provider"`
+	CheckoutURL            string `json:"checkout_url,omitempty"`
+	ProviderSubscriptionID string `json:"provider_subscription_id,omitempty"`
+	// CustomerID is the provider's customer created for the organization, if any
+	CustomerID string `json:"-"`
+}
+
+// BillingEvent is a verified webhook event. Subscription is nil for events that
+// don't change a subscription.
+type BillingEvent struct {
+	ID           string
+	Type         string
+	Subscription *ProviderSubscription
+}
+
+// ProviderSubscription is a subscription as reported by a billing provider
+type ProviderSubscription struct {
+	ID         string
+	CustomerID string
+	PlanID     string
+	// Plan is our plan for PlanID, empty if the provider plan is unknown
+	Plan models.SubscriptionPlan
+	// OrganizationID is the organization the subscription was created for, if reported
+	OrganizationID string
+	// Status is active, past_due, or cancelled (the subscription ended)
+	Status      models.SubscriptionStatus
+	PeriodStart *time.Time
+	PeriodEnd   *time.Time
+}
+
+// planForProviderPlan finds our plan for a provider's plan or price ID
+func planForProviderPlan(plans map[models.SubscriptionPlan]string, providerPlanID string) models.SubscriptionPlan {
+	for plan, id := range plans {
+		if id != "" && id == providerPlanID {
+			return plan
+		}
+	}
+	return ""
+}
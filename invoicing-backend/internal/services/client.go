@@ -3,21 +3,23 @@ package services
 import (
 	"fmt"
 
+	"github.com/yourusername/invoicing-backend/internal/database"
 	"github.com/yourusername/invoicing-backend/internal/models"
 	"gorm.io/gorm"
 )
 
 type ClientService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	usage *UsageService
 }
 
-func NewClientService(db *gorm.DB) *ClientService {
-	return &ClientService{db: db}
+func NewClientService(db *gorm.DB, usage *UsageService) *ClientService {
+	return &ClientService{db: db, usage: usage}
 }
 
-func (s *ClientService) CreateClient(userID, organizationID string, clientData *models.Client) (*models.Client, error) {
+func (s *ClientService) CreateClient(audit AuditContext, organizationID string, clientData *models.Client) (*models.Client, error) {
 	client := &models.Client{
-		UserID:         userID,
+		UserID:         audit.UserID,
 		OrganizationID: organizationID,
 		Name:           clientData.Name,
 		Email:          clientData.Email,
@@ -32,66 +34,114 @@ func (s *ClientService) CreateClient(userID, organizationID string, clientData *
 		TaxID:          clientData.TaxID,
 	}
 
-	if err := s.db.Create(client).Error; err != nil {
-		return nil, fmt.Errorf("failed to create client: %w", err)
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if err := checkClientQuota(tx, organizationID); err != nil {
+			return err
+		}
+
+		if err := tx.Create(client).Error; err != nil {
+			return fmt.Errorf("failed to create client: %w", err)
+		}
+		if err := recordUsage(tx, organizationID, UsageResourceClients, client.ID.String(), 1); err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionCreate,
+			models.AuditResourceClient, client.ID.String(), nil, client)
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	s.usage.NotifyThresholds(organizationID, UsageResourceClients)
+
 	return client, nil
 }
 
 func (s *ClientService) GetClientsByOrganization(userID, organizationID string) ([]models.Client, error) {
 	var clients []models.Client
-	// Filter by both organization_id (multi-tenant isolation) and user_id (permissions)
-	if err := s.db.Where("organization_id = ? AND deleted_at IS NULL", organizationID).Find(&clients).Error; err != nil {
+	// Filter by organization_id; row-level security enforces the same in the database
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		return tx.Where("organization_id = ? AND deleted_at IS NULL", organizationID).Find(&clients).Error
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to fetch clients: %w", err)
 	}
 	return clients, nil
 }
 
 func (s *ClientService) GetClientByID(clientID, userID, organizationID string) (*models.Client, error) {
-	var client models.Client
-	// Filter by organization_id for multi-tenant isolation and user_id for ownership
-	if err := s.db.Where("id = ? AND organization_id = ? AND deleted_at IS NULL", clientID, organizationID).First(&client).Error; err != nil {
-		return nil, fmt.Errorf("client not found")
+	var client *models.Client
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		client, err = getClient(tx, clientID, organizationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &client, nil
+	return client, nil
 }
 
-func (s *ClientService) UpdateClient(clientID, userID, organizationID string, updateData *models.Client) (*models.Client, error) {
-	client, err := s.GetClientByID(clientID, userID, organizationID)
+func (s *ClientService) UpdateClient(audit AuditContext, clientID, organizationID string, updateData *models.Client) (*models.Client, error) {
+	var client *models.Client
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		client, err = getClient(tx, clientID, organizationID)
+		if err != nil {
+			return err
+		}
+		before := *client
+
+		// Update fields
+		client.Name = updateData.Name
+		client.Email = updateData.Email
+		client.Phone = updateData.Phone
+		client.CompanyName = updateData.CompanyName
+		client.AddressLine1 = updateData.AddressLine1
+		client.AddressLine2 = updateData.AddressLine2
+		client.City = updateData.City
+		client.State = updateData.State
+		client.PostalCode = updateData.PostalCode
+		client.Country = updateData.Country
+		client.TaxID = updateData.TaxID
+
+		if err := tx.Save(client).Error; err != nil {
+			return fmt.Errorf("failed to update client: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceClient, client.ID.String(), &before, client)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Update fields
-	client.Name = updateData.Name
-	client.Email = updateData.Email
-	client.Phone = updateData.Phone
-	client.CompanyName = updateData.CompanyName
-	client.AddressLine1 = updateData.AddressLine1
-	client.AddressLine2 = updateData.AddressLine2
-	client.City = updateData.City
-	client.State = updateData.State
-	client.PostalCode = updateData.PostalCode
-	client.Country = updateData.Country
-	client.TaxID = updateData.TaxID
-
-	if err := s.db.Save(client).Error; err != nil {
-		return nil, fmt.Errorf("failed to update client: %w", err)
-	}
-
 	return client, nil
 }
 
-func (s *ClientService) DeleteClient(clientID, userID, organizationID string) error {
-	client, err := s.GetClientByID(clientID, userID, organizationID)
-	if err != nil {
-		return err
-	}
+func (s *ClientService) DeleteClient(audit AuditContext, clientID, organizationID string) error {
+	return database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		client, err := getClient(tx, clientID, organizationID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(client).Error; err != nil {
+			return fmt.Errorf("failed to delete client: %w", err)
+		}
+		if err := recordUsage(tx, organizationID, UsageResourceClients, client.ID.String(), -1); err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionDelete,
+			models.AuditResourceClient, client.ID.String(), client, nil)
+	})
+}
 
-	if err := s.db.Delete(client).Error; err != nil {
-		return fmt.Errorf("failed to delete client: %w", err)
+// getClient loads a client within a tenant-scoped transaction
+func getClient(tx *gorm.DB, clientID, organizationID string) (*models.Client, error) {
+	var client models.Client
+	// Filter by organization_id for multi-tenant isolation
+	if err := tx.Where("id = ? AND organization_id = ? AND deleted_at IS NULL", clientID, organizationID).First(&client).Error; err != nil {
+		return nil, fmt.Errorf("client not found")
 	}
-
-	return nil
+	return &client, nil
 }
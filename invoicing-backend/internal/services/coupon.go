@@ -0,0 +1,512 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCouponNotFound        = errors.New("coupon not found")
+	ErrInvalidCoupon         = errors.New("invalid coupon")
+	ErrCouponCodeTaken       = errors.New("coupon code is already in use")
+	ErrCouponExpired         = errors.New("coupon has expired")
+	ErrCouponExhausted       = errors.New("coupon has reached its redemption limit")
+	ErrCouponNotApplicable   = errors.New("coupon does not apply to this plan")
+	ErrCouponAlreadyRedeemed = errors.New("organization has already redeemed this coupon")
+	ErrDiscountActive        = errors.New("organization already has an active discount")
+	ErrCouponInactive        = errors.New("coupon is already deactivated")
+	ErrCouponNotSupported    = errors.New("billing provider does not support coupons")
+)
+
+// Coupon codes are also the coupon IDs at Stripe
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,50}$`)
+
+// Redemption states in coupon reports
+const (
+	CouponRedemptionPending = "pending"
+	CouponRedemptionActive  = "active"
+	CouponRedemptionEnded   = "ended"
+)
+
+type CreateCouponRequest struct {
+	Code         string `json:"code" validate:"required,min=3,max=50"`
+	Name         string `json:"name" validate:"required,max=100"`
+	DiscountType string `json:"discount_type" validate:"required,oneof=percent fixed"`
+	// PercentOff is required for percent coupons, AmountOff for fixed ones
+	PercentOff float64 `json:"percent_off" validate:"omitempty,gt=0,lte=100"`
+	AmountOff  float64 `json:"amount_off" validate:"omitempty,gt=0"`
+	// Currency of AmountOff, defaults to the plan currency
+	Currency         string `json:"currency" validate:"omitempty,len=3"`
+	Duration         string `json:"duration" validate:"required,oneof=once repeating forever"`
+	DurationInMonths int    `json:"duration_in_months" validate:"omitempty,min=1,max=36"`
+	// MaxRedemptions limits the number of organizations redeeming the coupon; nil is unlimited
+	MaxRedemptions *int       `json:"max_redemptions" validate:"omitempty,min=1"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	// Plans restricts the coupon to these paid plans; empty applies to all of them
+	Plans []models.SubscriptionPlan `json:"plans" validate:"omitempty,dive,required,max=50"`
+}
+
+type RedeemCouponRequest struct {
+	Code string `json:"code" validate:"required,max=50"`
+}
+
+// CouponRedemptionReport is one organization's redemption of a coupon
+type CouponRedemptionReport struct {
+	ID               string     `json:"id"`
+	OrganizationID   string     `json:"organization_id"`
+	OrganizationName string     `json:"organization_name"`
+	RedeemedBy       *string    `json:"redeemed_by"`
+	RedeemedAt       time.Time  `json:"redeemed_at"`
+	AppliedAt        *time.Time `json:"applied_at"`
+	EndsAt           *time.Time `json:"ends_at"`
+	Status           string     `json:"status" gorm:"-"`
+}
+
+// CouponReport is a coupon with its redemptions. Pending redemptions wait for the
+// organization's first paid subscription.
+type CouponReport struct {
+	Coupon               models.Coupon            `json:"coupon"`
+	Redeemed             int64                    `json:"redeemed"`
+	Pending              int64                    `json:"pending"`
+	Active               int64                    `json:"active"`
+	Ended                int64                    `json:"ended"`
+	RemainingRedemptions *int                     `json:"remaining_redemptions"`
+	Redemptions          []CouponRedemptionReport `json:"redemptions"`
+	Total                int64                    `json:"total"`
+	Limit                int                      `json:"limit"`
+	Offset               int                      `json:"offset"`
+}
+
+// CouponService manages the coupon catalog for platform admins and coupon
+// redemption for organizations
+type CouponService struct {
+	db    *gorm.DB
+	authz *AuthzCache
+}
+
+func NewCouponService(db *gorm.DB, authz *AuthzCache) *CouponService {
+	return &CouponService{db: db, authz: authz}
+}
+
+// CreateCoupon adds a coupon to the catalog
+func (s *CouponService) CreateCoupon(actor AdminActor, req *CreateCouponRequest) (*models.Coupon, error) {
+	coupon := models.Coupon{
+		Code:           normalizeCouponCode(req.Code),
+		Name:           strings.TrimSpace(req.Name),
+		DiscountType:   req.DiscountType,
+		Duration:       req.Duration,
+		MaxRedemptions: req.MaxRedemptions,
+		ExpiresAt:      req.ExpiresAt,
+		Plans:          models.CouponPlans(req.Plans),
+		IsActive:       true,
+	}
+	if actor.AdminID != "" {
+		coupon.CreatedBy = &actor.AdminID
+	}
+
+	if !couponCodePattern.MatchString(coupon.Code) {
+		return nil, fmt.Errorf("%w: code may only contain letters, digits, dashes and underscores", ErrInvalidCoupon)
+	}
+
+	switch req.DiscountType {
+	case models.CouponDiscountPercent:
+		if req.PercentOff <= 0 || req.AmountOff != 0 {
+			return nil, fmt.Errorf("%w: percent coupons need percent_off and no amount_off", ErrInvalidCoupon)
+		}
+		coupon.PercentOff = req.PercentOff
+	case models.CouponDiscountFixed:
+		if req.AmountOff <= 0 || req.PercentOff != 0 {
+			return nil, fmt.Errorf("%w: fixed coupons need amount_off and no percent_off", ErrInvalidCoupon)
+		}
+		coupon.AmountOff = roundCents(req.AmountOff)
+		coupon.Currency = strings.ToUpper(req.Currency)
+		if coupon.Currency == "" {
+			coupon.Currency = models.DefaultPlanCurrency
+		}
+	}
+
+	if req.Duration == models.CouponDurationRepeating {
+		if req.DurationInMonths <= 0 {
+			return nil, fmt.Errorf("%w: repeating coupons need duration_in_months", ErrInvalidCoupon)
+		}
+		coupon.DurationInMonths = req.DurationInMonths
+	} else if req.DurationInMonths != 0 {
+		return nil, fmt.Errorf("%w: duration_in_months only applies to repeating coupons", ErrInvalidCoupon)
+	}
+
+	if coupon.ExpiresAt != nil && !coupon.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidCoupon)
+	}
+
+	for _, code := range coupon.Plans {
+		if code == models.SubscriptionPlanFree {
+			return nil, fmt.Errorf("%w: the free plan cannot be discounted", ErrInvalidCoupon)
+		}
+		if _, err := findPlan(s.db, code); err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Coupon{}).Where("code = ?", coupon.Code).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check coupon code: %w", err)
+		}
+		if count > 0 {
+			return ErrCouponCodeTaken
+		}
+
+		if err := tx.Create(&coupon).Error; err != nil {
+			return fmt.Errorf("failed to create coupon: %w", err)
+		}
+
+		return recordAdminAction(tx, actor, models.AdminActionCreateCoupon, "coupon", coupon.ID,
+			models.JSONMap{"code": coupon.Code})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &coupon, nil
+}
+
+// ListCoupons finds coupons by code or name. Status is active (redeemable now) or inactive.
+func (s *CouponService) ListCoupons(params AdminSearchParams) ([]models.Coupon, int64, error) {
+	query := s.db.Model(&models.Coupon{})
+	if q := strings.TrimSpace(params.Query); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(code) LIKE ? OR LOWER(name) LIKE ?", like, like)
+	}
+	switch params.Status {
+	case "active":
+		query = query.Where("is_active AND (expires_at IS NULL OR expires_at > ?)", time.Now()).
+			Where("max_redemptions IS NULL OR times_redeemed < max_redemptions")
+	case "inactive":
+		query = query.Where("NOT is_active OR expires_at <= ? OR times_redeemed >= max_redemptions", time.Now())
+	}
+
+	// Reusable for both the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count coupons: %w", err)
+	}
+
+	var coupons []models.Coupon
+	if err := query.Order("created_at DESC").Limit(params.Limit).Offset(params.Offset).
+		Find(&coupons).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch coupons: %w", err)
+	}
+	return coupons, total, nil
+}
+
+// GetCouponReport returns the coupon with redemption counts and a page of its
+// redemptions, newest first
+func (s *CouponService) GetCouponReport(couponID string, limit, offset int) (*CouponReport, error) {
+	var coupon models.Coupon
+	if err := s.db.First(&coupon, "id = ?", couponID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch coupon: %w", err)
+	}
+
+	now := time.Now()
+	report := &CouponReport{Coupon: coupon, Limit: limit, Offset: offset}
+	if coupon.MaxRedemptions != nil {
+		remaining := max(*coupon.MaxRedemptions-coupon.TimesRedeemed, 0)
+		report.RemainingRedemptions = &remaining
+	}
+
+	var counts struct {
+		Redeemed int64
+		Pending  int64
+		Active   int64
+	}
+	if err := s.db.Model(&models.CouponRedemption{}).
+		Select("COUNT(*) AS redeemed, "+
+			"COUNT(*) FILTER (WHERE applied_at IS NULL) AS pending, "+
+			"COUNT(*) FILTER (WHERE applied_at IS NOT NULL AND (ends_at IS NULL OR ends_at > ?)) AS active", now).
+		Where("coupon_id = ?", couponID).Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count coupon redemptions: %w", err)
+	}
+	report.Redeemed, report.Pending, report.Active = counts.Redeemed, counts.Pending, counts.Active
+	report.Ended = counts.Redeemed - counts.Pending - counts.Active
+	report.Total = counts.Redeemed
+
+	if err := s.db.Table("coupon_redemptions AS r").
+		Select("r.id, r.organization_id, o.name AS organization_name, r.redeemed_by, "+
+			"r.created_at AS redeemed_at, r.applied_at, r.ends_at").
+		Joins("JOIN organizations o ON o.id = r.organization_id").
+		Where("r.coupon_id = ?", couponID).
+		Order("r.created_at DESC").Limit(limit).Offset(offset).
+		Scan(&report.Redemptions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch coupon redemptions: %w", err)
+	}
+	if report.Redemptions == nil {
+		report.Redemptions = []CouponRedemptionReport{}
+	}
+	for i := range report.Redemptions {
+		redemption := &report.Redemptions[i]
+		switch {
+		case redemption.AppliedAt == nil:
+			redemption.Status = CouponRedemptionPending
+		case redemption.EndsAt == nil || now.Before(*redemption.EndsAt):
+			redemption.Status = CouponRedemptionActive
+		default:
+			redemption.Status = CouponRedemptionEnded
+		}
+	}
+
+	return report, nil
+}
+
+// DeactivateCoupon stops new redemptions. Discounts already redeemed keep running.
+func (s *CouponService) DeactivateCoupon(actor AdminActor, couponID string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&coupon, "id = ?", couponID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCouponNotFound
+			}
+			return fmt.Errorf("failed to fetch coupon: %w", err)
+		}
+		if !coupon.IsActive {
+			return ErrCouponInactive
+		}
+
+		coupon.IsActive = false
+		if err := tx.Model(&coupon).Update("is_active", false).Error; err != nil {
+			return fmt.Errorf("failed to deactivate coupon: %w", err)
+		}
+
+		return recordAdminAction(tx, actor, models.AdminActionDeactivateCoupon, "coupon", coupon.ID,
+			models.JSONMap{"code": coupon.Code, "times_redeemed": coupon.TimesRedeemed})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &coupon, nil
+}
+
+// RedeemCoupon attaches the coupon to the organization's subscription. The discount
+// starts with the next paid subscription, or the next plan change of the current one.
+func (s *CouponService) RedeemCoupon(audit AuditContext, organizationID, code string) (*models.CouponRedemption, error) {
+	redemption, err := redeemForOrganization(s.db, audit, organizationID, code, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateOrganization(organizationID)
+	return redemption, nil
+}
+
+// redeemForOrganization redeems the coupon onto the organization's current
+// subscription. plan is the plan being bought, "" if not known yet.
+func redeemForOrganization(db *gorm.DB, audit AuditContext, organizationID, code string, plan models.SubscriptionPlan) (*models.CouponRedemption, error) {
+	var redemption *models.CouponRedemption
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		subscription, err := currentSubscription(tx, organizationID)
+		if err != nil {
+			return err
+		}
+		before := *subscription
+
+		redemption, err = redeemCoupon(tx, subscription, code, plan, audit.UserID, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := tx.Omit("Organization").Save(subscription).Error; err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceSubscription, subscription.ID, &before, subscription)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return redemption, nil
+}
+
+// redeemCoupon counts a redemption of the coupon and links it from the subscription,
+// which the caller saves. tx must hold the organization lock. A pending coupon the
+// organization redeemed earlier is released for the new one.
+func redeemCoupon(tx *gorm.DB, subscription *models.Subscription, code string, plan models.SubscriptionPlan, redeemedBy string, now time.Time) (*models.CouponRedemption, error) {
+	coupon, err := checkCoupon(tx, subscription.OrganizationID, code, plan, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.CouponRedemptionID != nil {
+		var current models.CouponRedemption
+		err := tx.First(&current, "id = ?", *subscription.CouponRedemptionID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to fetch coupon redemption: %w", err)
+		}
+		if err == nil && current.AppliedAt != nil && current.IsActive(now) {
+			return nil, ErrDiscountActive
+		}
+		if err == nil && current.AppliedAt == nil {
+			if err := releaseRedemption(tx, &current); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Guards the limit against concurrent redemptions by other organizations
+	result := tx.Model(&models.Coupon{}).
+		Where("id = ? AND is_active AND (max_redemptions IS NULL OR times_redeemed < max_redemptions)", coupon.ID).
+		Update("times_redeemed", gorm.Expr("times_redeemed + 1"))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to redeem coupon: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCouponExhausted
+	}
+
+	redemption := models.CouponRedemption{
+		CouponID:       coupon.ID,
+		OrganizationID: subscription.OrganizationID,
+	}
+	if redeemedBy != "" {
+		redemption.RedeemedBy = &redeemedBy
+	}
+	if err := tx.Create(&redemption).Error; err != nil {
+		return nil, fmt.Errorf("failed to record coupon redemption: %w", err)
+	}
+	redemption.Coupon = coupon
+
+	subscription.CouponRedemptionID = &redemption.ID
+	return &redemption, nil
+}
+
+// checkCoupon returns the coupon if the organization may redeem it for the plan
+// ("" skips the plan restriction)
+func checkCoupon(db *gorm.DB, organizationID, code string, plan models.SubscriptionPlan, now time.Time) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := db.First(&coupon, "code = ? AND is_active", normalizeCouponCode(code)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch coupon: %w", err)
+	}
+
+	if coupon.IsExpired(now) {
+		return nil, ErrCouponExpired
+	}
+	if coupon.IsExhausted() {
+		return nil, ErrCouponExhausted
+	}
+	if plan != "" && !coupon.AppliesTo(plan) {
+		return nil, ErrCouponNotApplicable
+	}
+
+	var count int64
+	if err := db.Model(&models.CouponRedemption{}).
+		Where("coupon_id = ? AND organization_id = ?", coupon.ID, organizationID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check coupon redemptions: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrCouponAlreadyRedeemed
+	}
+
+	return &coupon, nil
+}
+
+// releaseRedemption gives a pending redemption back to its coupon
+func releaseRedemption(tx *gorm.DB, redemption *models.CouponRedemption) error {
+	if err := tx.Delete(redemption).Error; err != nil {
+		return fmt.Errorf("failed to release coupon redemption: %w", err)
+	}
+	if err := tx.Model(&models.Coupon{}).Where("id = ? AND times_redeemed > 0", redemption.CouponID).
+		Update("times_redeemed", gorm.Expr("times_redeemed - 1")).Error; err != nil {
+		return fmt.Errorf("failed to release coupon redemption: %w", err)
+	}
+	return nil
+}
+
+// subscriptionDiscount returns the subscription's coupon redemption, with its coupon,
+// if the discount hasn't ended
+func subscriptionDiscount(db *gorm.DB, subscription *models.Subscription, now time.Time) (*models.CouponRedemption, error) {
+	if subscription.CouponRedemptionID == nil {
+		return nil, nil
+	}
+
+	var redemption models.CouponRedemption
+	if err := db.Preload("Coupon").First(&redemption, "id = ?", *subscription.CouponRedemptionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch coupon redemption: %w", err)
+	}
+	if !redemption.IsActive(now) || redemption.Coupon == nil {
+		return nil, nil
+	}
+	return &redemption, nil
+}
+
+// startDiscount starts a pending discount once the subscription is billed for a
+// plan the coupon applies to. periodEnd is the end of the first billing period.
+func startDiscount(tx *gorm.DB, subscription *models.Subscription, periodEnd *time.Time, now time.Time) error {
+	redemption, err := subscriptionDiscount(tx, subscription, now)
+	if err != nil || redemption == nil || redemption.AppliedAt != nil {
+		return err
+	}
+	if !redemption.Coupon.AppliesTo(subscription.PlanType) {
+		return nil
+	}
+
+	redemption.AppliedAt = &now
+	switch redemption.Coupon.Duration {
+	case models.CouponDurationOnce:
+		end := now.AddDate(0, 1, 0)
+		if periodEnd != nil && periodEnd.After(now) {
+			end = *periodEnd
+		}
+		redemption.EndsAt = &end
+	case models.CouponDurationRepeating:
+		end := now.AddDate(0, redemption.Coupon.DurationInMonths, 0)
+		redemption.EndsAt = &end
+	}
+
+	if err := tx.Model(redemption).Updates(map[string]interface{}{
+		"applied_at": redemption.AppliedAt,
+		"ends_at":    redemption.EndsAt,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to start discount: %w", err)
+	}
+	return nil
+}
+
+// endDiscount ends a running discount when its paid subscription ends. A pending
+// coupon stays for the next paid subscription.
+func endDiscount(tx *gorm.DB, subscription *models.Subscription, now time.Time) error {
+	redemption, err := subscriptionDiscount(tx, subscription, now)
+	if err != nil || redemption == nil || redemption.AppliedAt == nil {
+		return err
+	}
+
+	if err := tx.Model(redemption).Update("ends_at", now).Error; err != nil {
+		return fmt.Errorf("failed to end discount: %w", err)
+	}
+	subscription.CouponRedemptionID = nil
+	return nil
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+// newTestCoupon creates a coupon with a unique code from req, deleted when the test ends
+func newTestCoupon(t *testing.T, db *gorm.DB, req CreateCouponRequest) *models.Coupon {
+	t.Helper()
+
+	req.Code = "TEST-" + strings.ToUpper(uuid.NewString()[:8])
+	if req.Name == "" {
+		req.Name = "Test coupon"
+	}
+	coupon, err := NewCouponService(db, newTestAuthz(db)).CreateCoupon(AdminActor{}, &req)
+	if err != nil {
+		t.Fatalf("failed to create coupon: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM coupons WHERE id = ?", coupon.ID)
+	})
+	return coupon
+}
+
+// assertRedemptions checks how often the coupon was redeemed, and whether the
+// organization's subscription holds a redemption
+func assertRedemptions(t *testing.T, db *gorm.DB, coupon *models.Coupon, org *models.Organization, times int, linked bool) {
+	t.Helper()
+
+	var got models.Coupon
+	if err := db.First(&got, "id = ?", coupon.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if err := db.Model(&models.CouponRedemption{}).Where("coupon_id = ?", coupon.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.TimesRedeemed != times || count != int64(times) {
+		t.Errorf("times_redeemed = %d with %d redemptions, want %d", got.TimesRedeemed, count, times)
+	}
+
+	subscription, err := currentSubscription(db, org.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (subscription.CouponRedemptionID != nil) != linked {
+		t.Errorf("subscription coupon redemption = %v, want linked %v", subscription.CouponRedemptionID, linked)
+	}
+}
+
+func TestCheckoutCoupon(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+	_, stripeServer, _ := newFakeStripe(t)
+	_, paypalServer, _ := newFakePayPal(t)
+
+	subscriptions := NewSubscriptionService(db, newTestAuthz(db), NewLogNotifier(),
+		[]BillingProvider{newTestStripeProvider(stripeServer), newTestPayPalProvider(paypalServer)},
+		models.BillingProviderStripe, "http://app.example.com", TrialConfig{})
+	limit := 1
+
+	t.Run("failed checkout", func(t *testing.T) {
+		owner, org := newTestOrganization(t, db, 5)
+		audit := AuditContext{UserID: owner.ID.String()}
+		coupon := newTestCoupon(t, db, CreateCouponRequest{
+			DiscountType:   models.CouponDiscountPercent,
+			PercentOff:     20,
+			Duration:       models.CouponDurationForever,
+			MaxRedemptions: &limit,
+		})
+
+		// The fake Stripe has no price for the business plan
+		_, err := subscriptions.StartCheckout(ctx, audit, org.ID.String(), &CheckoutRequest{
+			PlanType:   models.SubscriptionPlanBusiness,
+			CouponCode: coupon.Code,
+		})
+		if !errors.Is(err, ErrPlanNotPurchasable) {
+			t.Fatalf("err = %v, want ErrPlanNotPurchasable", err)
+		}
+		assertRedemptions(t, db, coupon, org, 0, false)
+
+		// The code is still good for a checkout that goes through
+		checkout, err := subscriptions.StartCheckout(ctx, audit, org.ID.String(), &CheckoutRequest{
+			PlanType:   models.SubscriptionPlanPro,
+			CouponCode: coupon.Code,
+		})
+		if err != nil {
+			t.Fatalf("retry failed: %v", err)
+		}
+		if checkout.CheckoutURL == "" {
+			t.Fatal("no checkout URL")
+		}
+		assertRedemptions(t, db, coupon, org, 1, true)
+	})
+
+	t.Run("PayPal", func(t *testing.T) {
+		owner, org := newTestOrganization(t, db, 5)
+		audit := AuditContext{UserID: owner.ID.String()}
+		coupon := newTestCoupon(t, db, CreateCouponRequest{
+			DiscountType: models.CouponDiscountPercent,
+			PercentOff:   20,
+			Duration:     models.CouponDurationForever,
+		})
+
+		_, err := subscriptions.StartCheckout(ctx, audit, org.ID.String(), &CheckoutRequest{
+			PlanType:   models.SubscriptionPlanPro,
+			Provider:   models.BillingProviderPayPal,
+			CouponCode: coupon.Code,
+		})
+		if !errors.Is(err, ErrCouponNotSupported) {
+			t.Fatalf("err = %v, want ErrCouponNotSupported", err)
+		}
+		assertRedemptions(t, db, coupon, org, 0, false)
+
+		// A coupon redeemed on its own waits for a provider that takes it
+		if _, err := NewCouponService(db, newTestAuthz(db)).RedeemCoupon(audit, org.ID.String(), coupon.Code); err != nil {
+			t.Fatal(err)
+		}
+		checkout, err := subscriptions.StartCheckout(ctx, audit, org.ID.String(), &CheckoutRequest{
+			PlanType: models.SubscriptionPlanPro,
+			Provider: models.BillingProviderPayPal,
+		})
+		if err != nil {
+			t.Fatalf("PayPal checkout with a pending coupon failed: %v", err)
+		}
+		if checkout.CheckoutURL == "" {
+			t.Fatal("no approve URL")
+		}
+		assertRedemptions(t, db, coupon, org, 1, true)
+	})
+}
+
+func TestCreateCouponValidation(t *testing.T) {
+	db := testutil.DB(t)
+	coupons := NewCouponService(db, newTestAuthz(db))
+	past := time.Now().Add(-time.Hour)
+	months := 3
+
+	tests := []struct {
+		name    string
+		req     CreateCouponRequest
+		wantErr error
+	}{
+		{"code with spaces", CreateCouponRequest{Code: "SPRING SALE", DiscountType: models.CouponDiscountPercent, PercentOff: 10, Duration: models.CouponDurationOnce}, ErrInvalidCoupon},
+		{"percent without percent_off", CreateCouponRequest{DiscountType: models.CouponDiscountPercent, Duration: models.CouponDurationOnce}, ErrInvalidCoupon},
+		{"percent with amount_off", CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 10, AmountOff: 5, Duration: models.CouponDurationOnce}, ErrInvalidCoupon},
+		{"fixed without amount_off", CreateCouponRequest{DiscountType: models.CouponDiscountFixed, Duration: models.CouponDurationOnce}, ErrInvalidCoupon},
+		{"fixed with percent_off", CreateCouponRequest{DiscountType: models.CouponDiscountFixed, AmountOff: 5, PercentOff: 10, Duration: models.CouponDurationOnce}, ErrInvalidCoupon},
+		{"repeating without months", CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 10, Duration: models.CouponDurationRepeating}, ErrInvalidCoupon},
+		{"months on a forever coupon", CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 10, Duration: models.CouponDurationForever, DurationInMonths: months}, ErrInvalidCoupon},
+		{"expired", CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 10, Duration: models.CouponDurationOnce, ExpiresAt: &past}, ErrInvalidCoupon},
+		{"free plan", CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 10, Duration: models.CouponDurationOnce, Plans: []models.SubscriptionPlan{models.SubscriptionPlanFree}}, ErrInvalidCoupon},
+		{"unknown plan", CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 10, Duration: models.CouponDurationOnce, Plans: []models.SubscriptionPlan{"platinum"}}, ErrUnknownPlan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			if req.Code == "" {
+				req.Code = "TEST-" + strings.ToUpper(uuid.NewString()[:8])
+			}
+			req.Name = "Invalid coupon"
+			if _, err := coupons.CreateCoupon(AdminActor{}, &req); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		code := "test-" + uuid.NewString()[:8]
+		coupon, err := coupons.CreateCoupon(AdminActor{}, &CreateCouponRequest{
+			Code:             " " + code + " ",
+			Name:             "Three months off",
+			DiscountType:     models.CouponDiscountFixed,
+			AmountOff:        4.999,
+			Duration:         models.CouponDurationRepeating,
+			DurationInMonths: months,
+			Plans:            []models.SubscriptionPlan{models.SubscriptionPlanPro},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			db.Exec("DELETE FROM coupons WHERE id = ?", coupon.ID)
+		})
+		if coupon.Code != strings.ToUpper(code) || coupon.AmountOff != 5 || coupon.Currency != models.DefaultPlanCurrency ||
+			coupon.DurationInMonths != months || !coupon.IsActive {
+			t.Fatalf("coupon = %+v, want an active upper case code, 5.00 %s off for %d months", coupon, models.DefaultPlanCurrency, months)
+		}
+
+		// Codes are unique regardless of case
+		_, err = coupons.CreateCoupon(AdminActor{}, &CreateCouponRequest{
+			Code:         code,
+			Name:         "Duplicate",
+			DiscountType: models.CouponDiscountPercent,
+			PercentOff:   10,
+			Duration:     models.CouponDurationOnce,
+		})
+		if !errors.Is(err, ErrCouponCodeTaken) {
+			t.Fatalf("err = %v, want ErrCouponCodeTaken", err)
+		}
+	})
+}
+
+func TestCouponRedemptionLimitUnderConcurrentRedeems(t *testing.T) {
+	db := testutil.DB(t)
+	coupons := NewCouponService(db, newTestAuthz(db))
+
+	const limit, attempts = 2, 10
+	maxRedemptions := limit
+	coupon := newTestCoupon(t, db, CreateCouponRequest{
+		DiscountType:   models.CouponDiscountPercent,
+		PercentOff:     50,
+		Duration:       models.CouponDurationOnce,
+		MaxRedemptions: &maxRedemptions,
+	})
+
+	type redeemer struct {
+		owner *models.User
+		org   *models.Organization
+	}
+	redeemers := make([]redeemer, attempts)
+	for i := range redeemers {
+		redeemers[i].owner, redeemers[i].org = newTestOrganization(t, db, 5)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		start     = make(chan struct{})
+	)
+	for _, r := range redeemers {
+		wg.Add(1)
+		go func(r redeemer) {
+			defer wg.Done()
+			<-start
+			_, err := coupons.RedeemCoupon(AuditContext{UserID: r.owner.ID.String()}, r.org.ID.String(), coupon.Code)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, ErrCouponExhausted):
+				t.Errorf("unexpected error: %v", err)
+			}
+		}(r)
+	}
+	close(start)
+	wg.Wait()
+
+	if succeeded != limit {
+		t.Errorf("%d redemptions succeeded, want %d", succeeded, limit)
+	}
+	var got models.Coupon
+	if err := db.First(&got, "id = ?", coupon.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.TimesRedeemed != limit {
+		t.Errorf("times_redeemed = %d, want %d", got.TimesRedeemed, limit)
+	}
+}
+
+func TestRedeemCouponReleasesPendingRedemption(t *testing.T) {
+	db := testutil.DB(t)
+	coupons := NewCouponService(db, newTestAuthz(db))
+
+	owner, org := newTestOrganization(t, db, 5)
+	audit := AuditContext{UserID: owner.ID.String()}
+	orgID := org.ID.String()
+	first := newTestCoupon(t, db, CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 10, Duration: models.CouponDurationForever})
+	second := newTestCoupon(t, db, CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 20, Duration: models.CouponDurationForever})
+	third := newTestCoupon(t, db, CreateCouponRequest{DiscountType: models.CouponDiscountPercent, PercentOff: 30, Duration: models.CouponDurationForever})
+
+	if _, err := coupons.RedeemCoupon(audit, orgID, first.Code); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coupons.RedeemCoupon(audit, orgID, first.Code); !errors.Is(err, ErrCouponAlreadyRedeemed) {
+		t.Fatalf("second redemption: err = %v, want ErrCouponAlreadyRedeemed", err)
+	}
+
+	// A pending coupon is given back when the organization redeems another one
+	redemption, err := coupons.RedeemCoupon(audit, orgID, second.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRedemptions(t, db, first, org, 0, true)
+	assertRedemptions(t, db, second, org, 1, true)
+	subscription, err := currentSubscription(db, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscription.CouponRedemptionID == nil || *subscription.CouponRedemptionID != redemption.ID {
+		t.Fatalf("subscription redemption = %v, want %s", subscription.CouponRedemptionID, redemption.ID)
+	}
+
+	// A running discount is not replaced
+	subscription.PlanType = models.SubscriptionPlanPro
+	if err := startDiscount(db, subscription, nil, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coupons.RedeemCoupon(audit, orgID, third.Code); !errors.Is(err, ErrDiscountActive) {
+		t.Fatalf("redemption during a discount: err = %v, want ErrDiscountActive", err)
+	}
+	assertRedemptions(t, db, second, org, 1, true)
+	assertRedemptions(t, db, third, org, 0, true)
+}
+
+func TestDiscountDuration(t *testing.T) {
+	db := testutil.DB(t)
+	coupons := NewCouponService(db, newTestAuthz(db))
+
+	now := time.Now().Truncate(time.Second)
+	periodEnd := now.AddDate(0, 0, 20)
+	endsAt := func(d time.Time) *time.Time { return &d }
+
+	tests := []struct {
+		name      string
+		req       CreateCouponRequest
+		plan      models.SubscriptionPlan
+		periodEnd *time.Time
+		applied   bool
+		endsAt    *time.Time
+	}{
+		{"once ends with the billing period", CreateCouponRequest{Duration: models.CouponDurationOnce},
+			models.SubscriptionPlanPro, &periodEnd, true, endsAt(periodEnd)},
+		{"once without a period lasts a month", CreateCouponRequest{Duration: models.CouponDurationOnce},
+			models.SubscriptionPlanPro, nil, true, endsAt(now.AddDate(0, 1, 0))},
+		{"once with a past period lasts a month", CreateCouponRequest{Duration: models.CouponDurationOnce},
+			models.SubscriptionPlanPro, endsAt(now.Add(-time.Hour)), true, endsAt(now.AddDate(0, 1, 0))},
+		{"repeating", CreateCouponRequest{Duration: models.CouponDurationRepeating, DurationInMonths: 3},
+			models.SubscriptionPlanPro, &periodEnd, true, endsAt(now.AddDate(0, 3, 0))},
+		{"forever", CreateCouponRequest{Duration: models.CouponDurationForever},
+			models.SubscriptionPlanPro, &periodEnd, true, nil},
+		{"other plan stays pending", CreateCouponRequest{Duration: models.CouponDurationForever, Plans: []models.SubscriptionPlan{models.SubscriptionPlanBusiness}},
+			models.SubscriptionPlanPro, &periodEnd, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, org := newTestOrganization(t, db, 5)
+			req := tt.req
+			req.DiscountType, req.PercentOff = models.CouponDiscountPercent, 25
+			coupon := newTestCoupon(t, db, req)
+			redemption, err := coupons.RedeemCoupon(AuditContext{UserID: owner.ID.String()}, org.ID.String(), coupon.Code)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subscription, err := currentSubscription(db, org.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			subscription.PlanType = tt.plan
+			if err := startDiscount(db, subscription, tt.periodEnd, now); err != nil {
+				t.Fatal(err)
+			}
+
+			var got models.CouponRedemption
+			if err := db.First(&got, "id = ?", redemption.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if (got.AppliedAt != nil) != tt.applied {
+				t.Fatalf("applied_at = %v, want applied %v", got.AppliedAt, tt.applied)
+			}
+			if (got.EndsAt == nil) != (tt.endsAt == nil) || (got.EndsAt != nil && !got.EndsAt.Equal(*tt.endsAt)) {
+				t.Fatalf("ends_at = %v, want %v", got.EndsAt, tt.endsAt)
+			}
+
+			// Ending the paid subscription ends a running discount; a pending one waits
+			cancelledAt := now.Add(time.Hour)
+			if err := endDiscount(db, subscription, cancelledAt); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.First(&got, "id = ?", redemption.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if tt.applied {
+				if subscription.CouponRedemptionID != nil || got.EndsAt == nil || !got.EndsAt.Equal(cancelledAt) {
+					t.Fatalf("after end: subscription redemption %v, ends_at %v; want unlinked, ended at %v",
+						subscription.CouponRedemptionID, got.EndsAt, cancelledAt)
+				}
+			} else if subscription.CouponRedemptionID == nil || got.EndsAt != nil {
+				t.Fatalf("pending discount ended: subscription redemption %v, ends_at %v", subscription.CouponRedemptionID, got.EndsAt)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/cache"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// uniqueEmail returns an address no other test run uses
+func uniqueEmail(domain string) string {
+	return "user-" + uuid.NewString()[:8] + "@" + domain
+}
+
+func newTestAuthz(db *gorm.DB) *AuthzCache {
+	return NewAuthzCache(db, cache.NewLRU(1000), time.Minute)
+}
+
+// newTestUser creates a user that is deleted when the test ends
+func newTestUser(t testing.TB, db *gorm.DB, email string) *models.User {
+	t.Helper()
+
+	user := models.User{Email: email, FirstName: "Test", LastName: "User"}
+	if err := user.SetPassword("password123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM users WHERE id = ?", user.ID)
+	})
+	return &user
+}
+
+// newTestOrganization creates an organization owned by a new user, on the free plan
+// with userLimit seats. Both are deleted when the test ends.
+func newTestOrganization(t testing.TB, db *gorm.DB, userLimit int) (*models.User, *models.Organization) {
+	t.Helper()
+
+	owner := newTestUser(t, db, uniqueEmail("owner.example.com"))
+
+	var org *models.Organization
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		org, err = createOrganizationWithOwner(tx, owner.ID.String(), "Test "+uuid.NewString()[:8], "")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to create organization: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec("DELETE FROM organizations WHERE id = ?", org.ID)
+	})
+
+	if err := db.Model(&models.Subscription{}).Where("organization_id = ?", org.ID).
+		Update("monthly_user_limit", userLimit).Error; err != nil {
+		t.Fatalf("failed to set user limit: %v", err)
+	}
+	return owner, org
+}
+
+// newTestMember adds a new user to the organization with the role
+func newTestMember(t testing.TB, db *gorm.DB, org *models.Organization, roleID string) *models.User {
+	t.Helper()
+
+	member := newTestUser(t, db, uniqueEmail("member.example.com"))
+	if err := db.Create(&models.UserOrganizationRole{
+		UserID:         member.ID.String(),
+		OrganizationID: org.ID.String(),
+		RoleID:         roleID,
+		AssignedAt:     time.Now(),
+	}).Error; err != nil {
+		t.Fatalf("failed to add member: %v", err)
+	}
+	return member
+}
+
+// newTestClient creates a client of the organization through db
+func newTestClient(t *testing.T, db *gorm.DB, owner *models.User, org *models.Organization) *models.Client {
+	t.Helper()
+
+	clients := NewClientService(db, NewUsageService(db, NewLogNotifier(), ""))
+	client, err := clients.CreateClient(AuditContext{UserID: owner.ID.String()}, org.ID.String(),
+		&models.Client{Name: "Tenant client", Email: uniqueEmail("client.example.com")})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return client
+}
+
+// newTestInvoice creates a draft invoice for the client with a single item of total
+func newTestInvoice(t *testing.T, db *gorm.DB, owner *models.User, client *models.Client, total float64) *models.Invoice {
+	t.Helper()
+
+	invoices := NewInvoiceService(db, NewLogNotifier(), NewUsageService(db, NewLogNotifier(), ""))
+	invoice, err := invoices.CreateInvoice(AuditContext{UserID: owner.ID.String()}, client.OrganizationID, &models.Invoice{
+		ClientID: client.ID.String(),
+		DueDate:  time.Now().AddDate(0, 0, 30),
+		Currency: "EUR",
+		InvoiceItems: []models.InvoiceItem{
+			{Description: "Consulting", Quantity: 1, UnitPrice: total, TotalPrice: total},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create invoice: %v", err)
+	}
+	return invoice
+}
+
+// systemRole returns the ID of a built-in role
+func systemRole(t *testing.T, db *gorm.DB, name string) string {
+	t.Helper()
+
+	var role models.Role
+	if err := db.Where("name = ? AND organization_id IS NULL", name).First(&role).Error; err != nil {
+		t.Fatalf("role %q not found: %v", name, err)
+	}
+	return role.ID
+}
+
+// webhookDelivery is a webhook a fake billing provider delivered
+type webhookDelivery struct {
+	headers http.Header
+	body    []byte
+}
+
+// newWebhookReceiver collects the webhooks posted to the returned server
+func newWebhookReceiver(t *testing.T) (*httptest.Server, <-chan webhookDelivery) {
+	t.Helper()
+
+	deliveries := make(chan webhookDelivery, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		deliveries <- webhookDelivery{headers: r.Header.Clone(), body: body}
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver, deliveries
+}
+
+// followCheckout plays the customer completing a fake provider's checkout or approve link
+func followCheckout(t *testing.T, checkoutURL string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(checkoutURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("checkout returned %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,446 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	invitationPurpose  = "invitation"
+	invitationLifetime = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvitationNotFound     = errors.New("invitation not found")
+	ErrInvitationInvalid      = errors.New("invalid or expired invitation")
+	ErrSeatLimitReached       = errors.New("user limit reached for this organization")
+	ErrAlreadyMember          = errors.New("user is already a member of this organization")
+	ErrRoleExceedsPermissions = errors.New("cannot grant a role with more permissions than your own")
+)
+
+type InvitationService struct {
+	db          *gorm.DB
+	authz       *AuthzCache
+	notifier    Notifier
+	usage       *UsageService
+	frontendURL string
+}
+
+func NewInvitationService(db *gorm.DB, authz *AuthzCache, notifier Notifier, usage *UsageService, frontendURL string) *InvitationService {
+	return &InvitationService{
+		db:          db,
+		authz:       authz,
+		notifier:    notifier,
+		usage:       usage,
+		frontendURL: frontendURL,
+	}
+}
+
+type CreateInvitationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	Password  string `json:"password" validate:"required,min=8"`
+	FirstName string `json:"first_name" validate:"omitempty,min=1,max=100"`
+	LastName  string `json:"last_name" validate:"omitempty,min=1,max=100"`
+}
+
+// CreateInvitation invites an email to the organization and sends the signed link
+func (s *InvitationService) CreateInvitation(audit AuditContext, organizationID string, inviterRole models.Role, req *CreateInvitationRequest) (*models.OrganizationInvitation, error) {
+	email := normalizeEmail(req.Email)
+	invitedBy := audit.UserID
+
+	role, err := findAssignableRole(s.db, organizationID, req.Role, inviterRole)
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation *models.OrganizationInvitation
+	var nonce string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Serialize seat checks for the organization
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		var memberCount int64
+		if err := tx.Model(&models.UserOrganizationRole{}).
+			Joins("JOIN users ON users.id = user_organization_roles.user_id").
+			Where("user_organization_roles.organization_id = ? AND LOWER(users.email) = ?", organizationID, email).
+			Count(&memberCount).Error; err != nil {
+			return fmt.Errorf("failed to check membership: %w", err)
+		}
+		if memberCount > 0 {
+			return ErrAlreadyMember
+		}
+
+		// Replace any earlier pending invitation for the same email
+		if err := tx.Model(&models.OrganizationInvitation{}).
+			Where("organization_id = ? AND LOWER(email) = ? AND status = ?", organizationID, email, models.InvitationStatusPending).
+			Update("status", models.InvitationStatusRevoked).Error; err != nil {
+			return fmt.Errorf("failed to replace pending invitation: %w", err)
+		}
+
+		if err := checkSeatAvailable(tx, organizationID); err != nil {
+			return err
+		}
+
+		nonce, err = utils.GenerateRandomToken(16)
+		if err != nil {
+			return fmt.Errorf("failed to generate invitation token: %w", err)
+		}
+
+		now := time.Now()
+		invitation = &models.OrganizationInvitation{
+			OrganizationID: organizationID,
+			Email:          email,
+			RoleID:         role.ID,
+			InvitedBy:      &invitedBy,
+			TokenHash:      utils.HashToken(nonce),
+			Status:         models.InvitationStatusPending,
+			ExpiresAt:      now.Add(invitationLifetime),
+			LastSentAt:     now,
+			SendCount:      1,
+		}
+		if err := tx.Omit("Organization", "Role", "InvitedByUser").Create(invitation).Error; err != nil {
+			return fmt.Errorf("failed to create invitation: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionCreate,
+			models.AuditResourceInvitation, invitation.ID, nil, invitation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invitation.Role = *role
+	s.sendInvitation(invitation, nonce)
+	s.usage.NotifyThresholds(organizationID, UsageResourceUsers)
+
+	return invitation, nil
+}
+
+// GetInvitationsByOrganization lists invitations, optionally filtered by status
+func (s *InvitationService) GetInvitationsByOrganization(organizationID, status string) ([]models.OrganizationInvitation, error) {
+	var invitations []models.OrganizationInvitation
+	query := s.db.Preload("Role").Where("organization_id = ?", organizationID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// ResendInvitation issues a fresh link (invalidating the previous one) and extends the expiry
+func (s *InvitationService) ResendInvitation(audit AuditContext, invitationID, organizationID string) (*models.OrganizationInvitation, error) {
+	invitation, err := s.getInvitation(invitationID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.Status != models.InvitationStatusPending {
+		return nil, fmt.Errorf("only pending invitations can be resent")
+	}
+
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+
+	before := *invitation
+	now := time.Now()
+	invitation.TokenHash = utils.HashToken(nonce)
+	invitation.ExpiresAt = now.Add(invitationLifetime)
+	invitation.LastSentAt = now
+	invitation.SendCount++
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Organization", "Role", "InvitedByUser").Save(invitation).Error; err != nil {
+			return fmt.Errorf("failed to resend invitation: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionResend,
+			models.AuditResourceInvitation, invitation.ID, &before, invitation)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.sendInvitation(invitation, nonce)
+
+	return invitation, nil
+}
+
+func (s *InvitationService) RevokeInvitation(audit AuditContext, invitationID, organizationID string) error {
+	invitation, err := s.getInvitation(invitationID, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if invitation.Status != models.InvitationStatusPending {
+		return fmt.Errorf("only pending invitations can be revoked")
+	}
+
+	before := *invitation
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(invitation).Update("status", models.InvitationStatusRevoked).Error; err != nil {
+			return fmt.Errorf("failed to revoke invitation: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionRevoke,
+			models.AuditResourceInvitation, invitation.ID, &before, invitation)
+	})
+}
+
+// LookupInvitation resolves a token for display before acceptance
+func (s *InvitationService) LookupInvitation(token string) (*models.OrganizationInvitation, bool, error) {
+	invitation, err := s.resolveToken(s.db, token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.User{}).Where("LOWER(email) = ?", invitation.Email).Count(&count).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	return invitation, count > 0, nil
+}
+
+// AcceptInvitation adds the invitee to the organization. Existing users must
+// confirm with their password; new users are registered with the given details.
+// The invitee is recorded as the actor of the audit event.
+func (s *InvitationService) AcceptInvitation(audit AuditContext, req *AcceptInvitationRequest) (*models.User, error) {
+	var user models.User
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		invitation, err := s.resolveToken(tx, req.Token)
+		if err != nil {
+			return err
+		}
+
+		if err := lockOrganization(tx, invitation.OrganizationID); err != nil {
+			return err
+		}
+
+		err = tx.Where("LOWER(email) = ?", invitation.Email).First(&user).Error
+		switch {
+		case err == nil:
+			if !user.CheckPassword(req.Password) {
+				return ErrInvalidCredentials
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if req.FirstName == "" || req.LastName == "" {
+				return fmt.Errorf("first_name and last_name are required to create an account")
+			}
+			user = models.User{
+				Email:                 invitation.Email,
+				FirstName:             req.FirstName,
+				LastName:              req.LastName,
+				CurrentOrganizationID: &invitation.OrganizationID,
+			}
+			if err := user.SetPassword(req.Password); err != nil {
+				return fmt.Errorf("failed to hash password: %w", err)
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+		default:
+			return fmt.Errorf("failed to look up user: %w", err)
+		}
+
+		var memberCount int64
+		if err := tx.Model(&models.UserOrganizationRole{}).
+			Where("user_id = ? AND organization_id = ?", user.ID.String(), invitation.OrganizationID).
+			Count(&memberCount).Error; err != nil {
+			return fmt.Errorf("failed to check membership: %w", err)
+		}
+		if memberCount > 0 {
+			return ErrAlreadyMember
+		}
+
+		// Pending invitations already hold a seat, so only members are counted here
+		if err := checkMemberSeatAvailable(tx, invitation.OrganizationID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		userOrgRole := models.UserOrganizationRole{
+			UserID:         user.ID.String(),
+			OrganizationID: invitation.OrganizationID,
+			RoleID:         invitation.RoleID,
+			AssignedBy:     invitation.InvitedBy,
+			AssignedAt:     now,
+		}
+		if err := tx.Create(&userOrgRole).Error; err != nil {
+			return fmt.Errorf("failed to add user to organization: %w", err)
+		}
+
+		acceptedBy := user.ID.String()
+		if err := tx.Model(invitation).Updates(map[string]interface{}{
+			"status":      models.InvitationStatusAccepted,
+			"accepted_at": now,
+			"accepted_by": acceptedBy,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update invitation: %w", err)
+		}
+
+		audit.UserID = acceptedBy
+		userOrgRole.User = user
+		userOrgRole.Role = invitation.Role
+		return recordAuditEvent(tx, audit, invitation.OrganizationID, models.AuditActionJoin,
+			models.AuditResourceMember, acceptedBy, nil, toMember(userOrgRole))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The cached user doesn't list the new membership yet
+	s.authz.InvalidateUser(user.ID.String())
+	return &user, nil
+}
+
+func (s *InvitationService) getInvitation(invitationID, organizationID string) (*models.OrganizationInvitation, error) {
+	var invitation models.OrganizationInvitation
+	if err := s.db.Preload("Role").
+		Where("id = ? AND organization_id = ?", invitationID, organizationID).
+		First(&invitation).Error; err != nil {
+		return nil, ErrInvitationNotFound
+	}
+	return &invitation, nil
+}
+
+// resolveToken verifies the link signature and that it is the latest link for a pending invitation
+func (s *InvitationService) resolveToken(db *gorm.DB, token string) (*models.OrganizationInvitation, error) {
+	claims, err := utils.ParseClaims(token, invitationPurpose)
+	if err != nil {
+		return nil, ErrInvitationInvalid
+	}
+
+	invitationID, _ := claims["invitation_id"].(string)
+	nonce, _ := claims["nonce"].(string)
+	if invitationID == "" || nonce == "" {
+		return nil, ErrInvitationInvalid
+	}
+
+	var invitation models.OrganizationInvitation
+	if err := db.Preload("Role").Preload("Organization").
+		Where("id = ?", invitationID).First(&invitation).Error; err != nil {
+		return nil, ErrInvitationInvalid
+	}
+
+	if invitation.TokenHash != utils.HashToken(nonce) || !invitation.IsAcceptable() {
+		return nil, ErrInvitationInvalid
+	}
+
+	return &invitation, nil
+}
+
+func (s *InvitationService) sendInvitation(invitation *models.OrganizationInvitation, nonce string) {
+	token, err := utils.SignClaims(jwt.MapClaims{
+		"purpose":       invitationPurpose,
+		"invitation_id": invitation.ID,
+		"nonce":         nonce,
+		"exp":           invitation.ExpiresAt.Unix(),
+		"iat":           time.Now().Unix(),
+	})
+	if err != nil {
+		log.Printf("failed to sign invitation %s: %v", invitation.ID, err)
+		return
+	}
+
+	var org models.Organization
+	orgName := "an organization"
+	if err := s.db.Select("name").First(&org, "id = ?", invitation.OrganizationID).Error; err == nil {
+		orgName = org.Name
+	}
+
+	link := strings.TrimSuffix(s.frontendURL, "/") + "/invitations/accept?token=" + url.QueryEscape(token)
+	if err := s.notifier.Notify(Notification{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You've been invited to join %s", orgName),
+		Body: fmt.Sprintf("You have been invited to join %s as %s. Accept the invitation here: %s\n\nThis link expires on %s.",
+			orgName, invitation.Role.Name, link, invitation.ExpiresAt.Format(time.RFC1123)),
+	}); err != nil {
+		log.Printf("failed to send invitation %s: %v", invitation.ID, err)
+	}
+}
+
+// lockOrganization takes a row lock on the organization for the rest of the transaction
+func lockOrganization(tx *gorm.DB, organizationID string) error {
+	if err := tx.Exec("SELECT id FROM organizations WHERE id = ? FOR UPDATE", organizationID).Error; err != nil {
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+	return nil
+}
+
+// checkSeatAvailable counts members plus pending invitations against the plan's user limit
+func checkSeatAvailable(tx *gorm.DB, organizationID string) error {
+	subscription, err := activeSubscription(tx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	seats, err := seatUsage(tx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if !subscription.CanAddUsers(int(seats)) {
+		return &UsageLimitError{Resource: UsageResourceUsers, Usage: seats, Limit: subscription.MonthlyUserLimit}
+	}
+	return nil
+}
+
+// seatUsage counts members plus pending invitations
+func seatUsage(tx *gorm.DB, organizationID string) (int64, error) {
+	var members, pending int64
+	if err := tx.Model(&models.UserOrganizationRole{}).
+		Where("organization_id = ?", organizationID).Count(&members).Error; err != nil {
+		return 0, fmt.Errorf("failed to count members: %w", err)
+	}
+	if err := tx.Model(&models.OrganizationInvitation{}).
+		Where("organization_id = ? AND status = ? AND expires_at > ?", organizationID, models.InvitationStatusPending, time.Now()).
+		Count(&pending).Error; err != nil {
+		return 0, fmt.Errorf("failed to count invitations: %w", err)
+	}
+	return members + pending, nil
+}
+
+// checkMemberSeatAvailable counts only current members against the plan's user limit
+func checkMemberSeatAvailable(tx *gorm.DB, organizationID string) error {
+	subscription, err := activeSubscription(tx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	var members int64
+	if err := tx.Model(&models.UserOrganizationRole{}).
+		Where("organization_id = ?", organizationID).Count(&members).Error; err != nil {
+		return fmt.Errorf("failed to count members: %w", err)
+	}
+
+	if !subscription.CanAddUsers(int(members)) {
+		return &UsageLimitError{Resource: UsageResourceUsers, Usage: members, Limit: subscription.MonthlyUserLimit}
+	}
+	return nil
+}
+
+func activeSubscription(tx *gorm.DB, organizationID string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	if err := tx.Where("organization_id = ? AND status = ?", organizationID, models.SubscriptionStatusActive).
+		First(&subscription).Error; err != nil {
+		return nil, fmt.Errorf("active subscription required")
+	}
+	return &subscription, nil
+}
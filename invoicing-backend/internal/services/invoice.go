@@ -1,28 +1,32 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/yourusername/invoicing-backend/internal/database"
 	"github.com/yourusername/invoicing-backend/internal/models"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrInvoiceNotFound         = errors.New("invoice not found")
+	ErrInvoiceNotDraft         = errors.New("can only update draft invoices")
+	ErrInvalidStatusTransition = errors.New("invoice cannot change to this status")
+)
+
 type InvoiceService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	notifier Notifier
+	usage    *UsageService
 }
 
-func NewInvoiceService(db *gorm.DB) *InvoiceService {
-	return &InvoiceService{db: db}
+func NewInvoiceService(db *gorm.DB, notifier Notifier, usage *UsageService) *InvoiceService {
+	return &InvoiceService{db: db, notifier: notifier, usage: usage}
 }
 
-func (s *InvoiceService) CreateInvoice(userID, organizationID string, invoiceData *models.Invoice) (*models.Invoice, error) {
-	// Generate invoice number
-	invoiceNumber, err := s.generateInvoiceNumber(userID, organizationID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate invoice number: %w", err)
-	}
-
+func (s *InvoiceService) CreateInvoice(audit AuditContext, organizationID string, invoiceData *models.Invoice) (*models.Invoice, error) {
 	// Calculate totals
 	subtotal := 0.0
 	for _, item := range invoiceData.InvoiceItems {
@@ -33,10 +37,9 @@ func (s *InvoiceService) CreateInvoice(userID, organizationID string, invoiceDat
 	totalAmount := subtotal + taxAmount
 
 	invoice := &models.Invoice{
-		UserID:         userID,
+		UserID:         audit.UserID,
 		OrganizationID: organizationID,
 		ClientID:       invoiceData.ClientID,
-		InvoiceNumber:  invoiceNumber,
 		IssueDate:      time.Now(),
 		DueDate:        invoiceData.DueDate,
 		Status:         models.InvoiceStatusDraft,
@@ -50,108 +53,222 @@ func (s *InvoiceService) CreateInvoice(userID, organizationID string, invoiceDat
 		InvoiceItems:   invoiceData.InvoiceItems,
 	}
 
-	if err := s.db.Create(invoice).Error; err != nil {
-		return nil, fmt.Errorf("failed to create invoice: %w", err)
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if err := checkInvoiceQuota(tx, organizationID); err != nil {
+			return err
+		}
+
+		paymentMethodIDs, err := checkPaymentMethods(tx, organizationID, invoiceData.PaymentMethodIDs)
+		if err != nil {
+			return err
+		}
+		invoice.PaymentMethodIDs = paymentMethodIDs
+
+		// Generate invoice number
+		invoiceNumber, err := generateInvoiceNumber(tx, organizationID)
+		if err != nil {
+			return fmt.Errorf("failed to generate invoice number: %w", err)
+		}
+		invoice.InvoiceNumber = invoiceNumber
+
+		if err := tx.Create(invoice).Error; err != nil {
+			return fmt.Errorf("failed to create invoice: %w", err)
+		}
+		if err := recordUsage(tx, organizationID, UsageResourceInvoices, invoice.ID.String(), 1); err != nil {
+			return err
+		}
+		if err := loadPaymentMethods(tx, organizationID, invoice); err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionCreate,
+			models.AuditResourceInvoice, invoice.ID.String(), nil, invoice)
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	s.usage.NotifyThresholds(organizationID, UsageResourceInvoices)
+
 	return invoice, nil
 }
 
 func (s *InvoiceService) GetInvoicesByOrganization(userID, organizationID string) ([]models.Invoice, error) {
 	var invoices []models.Invoice
-	// Filter by organization_id for multi-tenant isolation
-	if err := s.db.Preload("Client").Preload("InvoiceItems").
-		Where("organization_id = ? AND deleted_at IS NULL", organizationID).
-		Order("created_at DESC").Find(&invoices).Error; err != nil {
+	// Filter by organization_id; row-level security enforces the same in the database
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if err := tx.Preload("Client").Preload("InvoiceItems").
+			Where("organization_id = ? AND deleted_at IS NULL", organizationID).
+			Order("created_at DESC").Find(&invoices).Error; err != nil {
+			return err
+		}
+
+		refs := make([]*models.Invoice, len(invoices))
+		for i := range invoices {
+			refs[i] = &invoices[i]
+		}
+		return loadPaymentMethods(tx, organizationID, refs...)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to fetch invoices: %w", err)
 	}
 	return invoices, nil
 }
 
 func (s *InvoiceService) GetInvoiceByID(invoiceID, userID, organizationID string) (*models.Invoice, error) {
-	var invoice models.Invoice
-	// Filter by organization_id for multi-tenant isolation
-	if err := s.db.Preload("Client").Preload("InvoiceItems").
-		Where("id = ? AND organization_id = ? AND deleted_at IS NULL", invoiceID, organizationID).
-		First(&invoice).Error; err != nil {
-		return nil, fmt.Errorf("invoice not found")
-	}
-	return &invoice, nil
-}
-
-func (s *InvoiceService) UpdateInvoice(invoiceID, userID, organizationID string, updateData *models.Invoice) (*models.Invoice, error) {
-	invoice, err := s.GetInvoiceByID(invoiceID, userID, organizationID)
+	var invoice *models.Invoice
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		invoice, err = getInvoice(tx, invoiceID, organizationID)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return invoice, nil
+}
 
-	// Only allow updates for draft invoices
-	if invoice.Status != models.InvoiceStatusDraft {
-		return nil, fmt.Errorf("can only update draft invoices")
-	}
+func (s *InvoiceService) UpdateInvoice(audit AuditContext, invoiceID, organizationID string, updateData *models.Invoice) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		invoice, err = getInvoice(tx, invoiceID, organizationID)
+		if err != nil {
+			return err
+		}
 
-	// Update fields
-	invoice.ClientID = updateData.ClientID
-	invoice.DueDate = updateData.DueDate
-	invoice.Currency = updateData.Currency
-	invoice.TaxRate = updateData.TaxRate
-	invoice.Notes = updateData.Notes
-	invoice.Terms = updateData.Terms
-
-	// Update invoice items
-	if err := s.db.Where("invoice_id = ?", invoiceID).Delete(&models.InvoiceItem{}).Error; err != nil {
-		return nil, fmt.Errorf("failed to update invoice items: %w", err)
-	}
+		// Only allow updates for draft invoices
+		if invoice.Status != models.InvoiceStatusDraft {
+			return ErrInvoiceNotDraft
+		}
+		before := auditSnapshot(invoice)
 
-	invoice.InvoiceItems = updateData.InvoiceItems
+		// An approval covers the invoice as it was reviewed
+		if err := resetApproval(tx, invoice); err != nil {
+			return err
+		}
 
-	// Recalculate totals
-	subtotal := 0.0
-	for _, item := range invoice.InvoiceItems {
-		subtotal += item.TotalPrice
-	}
-	invoice.Subtotal = subtotal
-	invoice.TaxAmount = subtotal * invoice.TaxRate
-	invoice.TotalAmount = subtotal + invoice.TaxAmount
+		// Update fields
+		invoice.ClientID = updateData.ClientID
+		invoice.DueDate = updateData.DueDate
+		invoice.Currency = updateData.Currency
+		invoice.TaxRate = updateData.TaxRate
+		invoice.Notes = updateData.Notes
+		invoice.Terms = updateData.Terms
+
+		invoice.PaymentMethodIDs, err = checkPaymentMethods(tx, organizationID, updateData.PaymentMethodIDs)
+		if err != nil {
+			return err
+		}
+		if err := loadPaymentMethods(tx, organizationID, invoice); err != nil {
+			return err
+		}
+
+		// Update invoice items
+		if err := tx.Where("invoice_id = ?", invoiceID).Delete(&models.InvoiceItem{}).Error; err != nil {
+			return fmt.Errorf("failed to update invoice items: %w", err)
+		}
+
+		invoice.InvoiceItems = updateData.InvoiceItems
+
+		// Recalculate totals
+		subtotal := 0.0
+		for _, item := range invoice.InvoiceItems {
+			subtotal += item.TotalPrice
+		}
+		invoice.Subtotal = subtotal
+		invoice.TaxAmount = subtotal * invoice.TaxRate
+		invoice.TotalAmount = subtotal + invoice.TaxAmount
 
-	if err := s.db.Save(invoice).Error; err != nil {
-		return nil, fmt.Errorf("failed to update invoice: %w", err)
+		if err := tx.Save(invoice).Error; err != nil {
+			return fmt.Errorf("failed to update invoice: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceInvoice, invoice.ID.String(), before, invoice)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return invoice, nil
 }
 
-func (s *InvoiceService) UpdateInvoiceStatus(invoiceID, userID, organizationID string, status models.InvoiceStatus) (*models.Invoice, error) {
-	invoice, err := s.GetInvoiceByID(invoiceID, userID, organizationID)
+func (s *InvoiceService) UpdateInvoiceStatus(audit AuditContext, invoiceID, organizationID string, status models.InvoiceStatus) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		invoice, err = getInvoice(tx, invoiceID, organizationID)
+		if err != nil {
+			return err
+		}
+
+		if !invoice.Status.CanTransitionTo(status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, invoice.Status, status)
+		}
+
+		// Only cancelling skips approval; anything else takes the invoice out of review
+		if invoice.Status == models.InvoiceStatusDraft && status != models.InvoiceStatusCancelled &&
+			invoice.ApprovalStatus != models.InvoiceApprovalApproved {
+			if invoice.ApprovalStatus == models.InvoiceApprovalPending || invoice.ApprovalStatus == models.InvoiceApprovalRejected {
+				return ErrInvoiceApprovalRequired
+			}
+			required, err := approvalRequired(tx, organizationID, invoice)
+			if err != nil {
+				return err
+			}
+			if required {
+				return ErrInvoiceApprovalRequired
+			}
+		}
+
+		before := auditSnapshot(invoice)
+		invoice.Status = status
+		if err := tx.Save(invoice).Error; err != nil {
+			return fmt.Errorf("failed to update invoice status: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionStatusChange,
+			models.AuditResourceInvoice, invoice.ID.String(), before, invoice)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	invoice.Status = status
-	if err := s.db.Save(invoice).Error; err != nil {
-		return nil, fmt.Errorf("failed to update invoice status: %w", err)
-	}
-
 	return invoice, nil
 }
 
-func (s *InvoiceService) DeleteInvoice(invoiceID, userID, organizationID string) error {
-	invoice, err := s.GetInvoiceByID(invoiceID, userID, organizationID)
-	if err != nil {
-		return err
-	}
+func (s *InvoiceService) DeleteInvoice(audit AuditContext, invoiceID, organizationID string) error {
+	return database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		invoice, err := getInvoice(tx, invoiceID, organizationID)
+		if err != nil {
+			return err
+		}
 
-	if err := s.db.Delete(invoice).Error; err != nil {
-		return fmt.Errorf("failed to delete invoice: %w", err)
-	}
+		if err := tx.Delete(invoice).Error; err != nil {
+			return fmt.Errorf("failed to delete invoice: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionDelete,
+			models.AuditResourceInvoice, invoice.ID.String(), invoice, nil)
+	})
+}
 
-	return nil
+// getInvoice loads an invoice with its client and items within a tenant-scoped transaction
+func getInvoice(tx *gorm.DB, invoiceID, organizationID string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	// Filter by organization_id for multi-tenant isolation
+	if err := tx.Preload("Client").Preload("InvoiceItems").
+		Where("id = ? AND organization_id = ? AND deleted_at IS NULL", invoiceID, organizationID).
+		First(&invoice).Error; err != nil {
+		return nil, ErrInvoiceNotFound
+	}
+	if err := loadPaymentMethods(tx, organizationID, &invoice); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
 }
 
-func (s *InvoiceService) generateInvoiceNumber(userID, organizationID string) (string, error) {
+func generateInvoiceNumber(tx *gorm.DB, organizationID string) (string, error) {
 	var count int64
 	// Count invoices within the organization for proper numbering
-	if err := s.db.Model(&models.Invoice{}).Where("organization_id = ?", organizationID).Count(&count).Error; err != nil {
+	if err := tx.Model(&models.Invoice{}).Where("organization_id = ?", organizationID).Count(&count).Error; err != nil {
 		return "", err
 	}
 
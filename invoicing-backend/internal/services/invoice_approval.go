@@ -0,0 +1,263 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvoiceApprovalRequired = errors.New("invoice must be approved before it can be sent")
+	ErrApprovalNotRequired     = errors.New("invoice does not require approval")
+	ErrApprovalPending         = errors.New("invoice already has a pending approval request")
+	ErrApprovalAlreadyGranted  = errors.New("invoice is already approved")
+	ErrNoPendingApproval       = errors.New("invoice has no pending approval request")
+	ErrSelfApproval            = errors.New("approval requests cannot be decided by the requester or the invoice creator")
+	ErrRejectionReasonRequired = errors.New("a comment is required when rejecting an invoice")
+)
+
+type ApprovalCommentRequest struct {
+	Comment string `json:"comment" validate:"max=2000"`
+}
+
+// RequestApproval submits a draft invoice for approval and notifies the
+// members allowed to approve it
+func (s *InvoiceService) RequestApproval(audit AuditContext, invoiceID, organizationID, comment string) (*models.InvoiceApproval, error) {
+	var approval *models.InvoiceApproval
+	var invoice *models.Invoice
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		invoice, err = getInvoice(tx, invoiceID, organizationID)
+		if err != nil {
+			return err
+		}
+
+		if invoice.Status != models.InvoiceStatusDraft {
+			return ErrInvoiceNotDraft
+		}
+		switch invoice.ApprovalStatus {
+		case models.InvoiceApprovalPending:
+			return ErrApprovalPending
+		case models.InvoiceApprovalApproved:
+			return ErrApprovalAlreadyGranted
+		}
+
+		required, err := approvalRequired(tx, organizationID, invoice)
+		if err != nil {
+			return err
+		}
+		if !required {
+			return ErrApprovalNotRequired
+		}
+
+		approval = &models.InvoiceApproval{
+			OrganizationID: organizationID,
+			InvoiceID:      invoice.ID.String(),
+			RequestedBy:    audit.UserID,
+			RequestComment: comment,
+			TotalAmount:    invoice.TotalAmount,
+			Status:         models.InvoiceApprovalPending,
+		}
+		if err := tx.Create(approval).Error; err != nil {
+			return fmt.Errorf("failed to create approval request: %w", err)
+		}
+
+		before := auditSnapshot(invoice)
+		invoice.ApprovalStatus = models.InvoiceApprovalPending
+		if err := tx.Model(invoice).Update("approval_status", invoice.ApprovalStatus).Error; err != nil {
+			return fmt.Errorf("failed to update invoice: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionRequest,
+			models.AuditResourceInvoice, invoice.ID.String(), before, invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyApprovers(organizationID, invoice, approval)
+	return approval, nil
+}
+
+// ApproveInvoice approves the pending request, allowing the invoice to be sent
+func (s *InvoiceService) ApproveInvoice(audit AuditContext, invoiceID, organizationID, comment string) (*models.InvoiceApproval, error) {
+	return s.decideApproval(audit, invoiceID, organizationID, models.InvoiceApprovalApproved, comment)
+}
+
+// RejectInvoice rejects the pending request. The invoice stays a draft and can
+// be edited and submitted again.
+func (s *InvoiceService) RejectInvoice(audit AuditContext, invoiceID, organizationID, comment string) (*models.InvoiceApproval, error) {
+	if strings.TrimSpace(comment) == "" {
+		return nil, ErrRejectionReasonRequired
+	}
+	return s.decideApproval(audit, invoiceID, organizationID, models.InvoiceApprovalRejected, comment)
+}
+
+// GetApprovals returns the invoice's approval history, newest first
+func (s *InvoiceService) GetApprovals(invoiceID, organizationID string) ([]models.InvoiceApproval, error) {
+	var approvals []models.InvoiceApproval
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if _, err := getInvoice(tx, invoiceID, organizationID); err != nil {
+			return err
+		}
+		if err := tx.Where("invoice_id = ? AND organization_id = ?", invoiceID, organizationID).
+			Order("created_at DESC").Find(&approvals).Error; err != nil {
+			return fmt.Errorf("failed to fetch approvals: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+func (s *InvoiceService) decideApproval(audit AuditContext, invoiceID, organizationID string, status models.InvoiceApprovalStatus, comment string) (*models.InvoiceApproval, error) {
+	var approval models.InvoiceApproval
+	var invoice *models.Invoice
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		invoice, err = getInvoice(tx, invoiceID, organizationID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("invoice_id = ? AND organization_id = ? AND status = ?", invoiceID, organizationID, models.InvoiceApprovalPending).
+			First(&approval).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNoPendingApproval
+			}
+			return fmt.Errorf("failed to fetch approval request: %w", err)
+		}
+
+		// Neither the requester nor the invoice's creator may sign it off
+		if approval.RequestedBy == audit.UserID || invoice.UserID == audit.UserID {
+			return ErrSelfApproval
+		}
+
+		now := time.Now()
+		approval.Status = status
+		approval.DecidedBy = &audit.UserID
+		approval.DecisionComment = comment
+		approval.DecidedAt = &now
+
+		// Conditional on the request still being pending so concurrent decisions can't both win
+		result := tx.Model(&approval).Where("status = ?", models.InvoiceApprovalPending).
+			Select("status", "decided_by", "decision_comment", "decided_at").Updates(&approval)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update approval request: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNoPendingApproval
+		}
+
+		before := auditSnapshot(invoice)
+		invoice.ApprovalStatus = status
+		if err := tx.Model(invoice).Update("approval_status", invoice.ApprovalStatus).Error; err != nil {
+			return fmt.Errorf("failed to update invoice: %w", err)
+		}
+
+		action := models.AuditActionApprove
+		if status == models.InvoiceApprovalRejected {
+			action = models.AuditActionReject
+		}
+		return recordAuditEvent(tx, audit, organizationID, action,
+			models.AuditResourceInvoice, invoice.ID.String(), before, invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyRequester(invoice, &approval)
+	return &approval, nil
+}
+
+// approvalRequired applies the organization's approval settings to the invoice
+func approvalRequired(tx *gorm.DB, organizationID string, invoice *models.Invoice) (bool, error) {
+	var org models.Organization
+	if err := tx.Select("id", "settings").First(&org, "id = ?", organizationID).Error; err != nil {
+		return false, ErrOrganizationNotFound
+	}
+
+	settings := org.Settings.ApprovalSettings
+	if !settings.Enabled {
+		return false, nil
+	}
+
+	// Decided by permission rather than role name, so custom roles are covered too. A
+	// creator who left the organization can approve nothing.
+	creatorCanApprove := false
+	if settings.RequireForOrgUsers {
+		var creatorRole models.Role
+		err := tx.Table("user_organization_roles").
+			Select("roles.permissions").
+			Joins("JOIN roles ON roles.id = user_organization_roles.role_id").
+			Where("user_organization_roles.user_id = ? AND user_organization_roles.organization_id = ?", invoice.UserID, organizationID).
+			Limit(1).Scan(&creatorRole).Error
+		if err != nil {
+			return false, fmt.Errorf("failed to look up invoice creator role: %w", err)
+		}
+		creatorCanApprove = creatorRole.HasPermission(models.ResourceInvoices, models.PermissionApprove)
+	}
+
+	return settings.Requires(invoice.TotalAmount, creatorCanApprove), nil
+}
+
+// resetApproval withdraws a pending or granted approval after the invoice was edited
+func resetApproval(tx *gorm.DB, invoice *models.Invoice) error {
+	if invoice.ApprovalStatus != models.InvoiceApprovalPending && invoice.ApprovalStatus != models.InvoiceApprovalApproved {
+		return nil
+	}
+
+	if err := tx.Model(&models.InvoiceApproval{}).
+		Where("invoice_id = ? AND status = ?", invoice.ID.String(), models.InvoiceApprovalPending).
+		Update("status", models.InvoiceApprovalCancelled).Error; err != nil {
+		return fmt.Errorf("failed to cancel approval request: %w", err)
+	}
+	invoice.ApprovalStatus = ""
+	return nil
+}
+
+func (s *InvoiceService) notifyApprovers(organizationID string, invoice *models.Invoice, approval *models.InvoiceApproval) {
+	var memberships []models.UserOrganizationRole
+	if err := s.db.Preload("User").Preload("Role").
+		Where("organization_id = ?", organizationID).Find(&memberships).Error; err != nil {
+		log.Printf("failed to load approvers for invoice %s: %v", invoice.ID, err)
+		return
+	}
+
+	for _, m := range memberships {
+		if m.UserID == approval.RequestedBy || !m.Role.Permissions.Allows(models.ResourceInvoices, models.PermissionApprove) {
+			continue
+		}
+		if err := s.notifier.Notify(Notification{
+			To:      m.User.Email,
+			Subject: fmt.Sprintf("Invoice %s is waiting for your approval", invoice.InvoiceNumber),
+			Body: fmt.Sprintf("Invoice %s for %.2f %s was submitted for approval.\n\n%s",
+				invoice.InvoiceNumber, invoice.TotalAmount, invoice.Currency, approval.RequestComment),
+		}); err != nil {
+			log.Printf("failed to notify approver %s: %v", m.UserID, err)
+		}
+	}
+}
+
+func (s *InvoiceService) notifyRequester(invoice *models.Invoice, approval *models.InvoiceApproval) {
+	var requester models.User
+	if err := s.db.First(&requester, "id = ?", approval.RequestedBy).Error; err != nil {
+		log.Printf("failed to load approval requester %s: %v", approval.RequestedBy, err)
+		return
+	}
+
+	if err := s.notifier.Notify(Notification{
+		To:      requester.Email,
+		Subject: fmt.Sprintf("Invoice %s was %s", invoice.InvoiceNumber, approval.Status),
+		Body:    approval.DecisionComment,
+	}); err != nil {
+		log.Printf("failed to notify approval requester %s: %v", approval.RequestedBy, err)
+	}
+}
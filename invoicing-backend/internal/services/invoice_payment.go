@@ -0,0 +1,556 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const paymentLinkPurpose = "invoice_payment"
+
+var (
+	ErrPaymentLinkInvalid  = errors.New("invalid or revoked payment link")
+	ErrInvoiceNotPayable   = errors.New("only sent or overdue invoices can be paid online")
+	ErrInvoiceAlreadyPaid  = errors.New("invoice is already paid")
+	ErrPaymentAmountTooLow = errors.New("invoice total must be greater than zero")
+)
+
+// InvoicePaymentService lets an organization's clients pay invoices online through
+// a payment link. The link opens a checkout with a PaymentGateway, whose webhook
+// records the payment and marks the invoice paid.
+type InvoicePaymentService struct {
+	db             *gorm.DB
+	notifier       Notifier
+	gateways       map[string]PaymentGateway
+	defaultGateway string
+	frontendURL    string
+}
+
+func NewInvoicePaymentService(db *gorm.DB, notifier Notifier, gateways []PaymentGateway, defaultGateway, frontendURL string) *InvoicePaymentService {
+	byName := make(map[string]PaymentGateway, len(gateways))
+	for _, g := range gateways {
+		byName[g.Name()] = g
+	}
+	return &InvoicePaymentService{
+		db:             db,
+		notifier:       notifier,
+		gateways:       byName,
+		defaultGateway: defaultGateway,
+		frontendURL:    strings.TrimSuffix(frontendURL, "/"),
+	}
+}
+
+type CreatePaymentLinkRequest struct {
+	// Gateway defaults to the configured default gateway
+	Gateway string `json:"gateway" validate:"omitempty,oneof=stripe paypal"`
+}
+
+type StartPaymentRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// PaymentLink is the public URL the client follows to pay the invoice
+type PaymentLink struct {
+	URL     string `json:"url"`
+	Gateway string `json:"gateway"`
+}
+
+// PublicInvoice is what the payment page shows to the client
+type PublicInvoice struct {
+	InvoiceNumber    string               `json:"invoice_number"`
+	OrganizationName string               `json:"organization_name"`
+	ClientName       string               `json:"client_name"`
+	Status           models.InvoiceStatus `json:"status"`
+	IssueDate        time.Time            `json:"issue_date"`
+	DueDate          time.Time            `json:"due_date"`
+	Currency         string               `json:"currency"`
+	Subtotal         float64              `json:"subtotal"`
+	TaxAmount        float64              `json:"tax_amount"`
+	TotalAmount      float64              `json:"total_amount"`
+	Items            []PublicInvoiceItem  `json:"items"`
+	PaidAt           *time.Time           `json:"paid_at"`
+	Gateway          string               `json:"gateway"`
+	// Offline alternatives to paying through the gateway
+	PaymentMethods []PublicPaymentMethod `json:"payment_methods"`
+	Notes          string                `json:"notes,omitempty"`
+	Terms          string                `json:"terms,omitempty"`
+}
+
+type PublicInvoiceItem struct {
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	TotalPrice  float64 `json:"total_price"`
+}
+
+// PublicPaymentMethod is a payment method as shown to the client
+type PublicPaymentMethod struct {
+	Type          models.PaymentMethodType `json:"type"`
+	Name          string                   `json:"name"`
+	AccountHolder string                   `json:"account_holder,omitempty"`
+	BankName      string                   `json:"bank_name,omitempty"`
+	IBAN          string                   `json:"iban,omitempty"`
+	BIC           string                   `json:"bic,omitempty"`
+	RoutingNumber string                   `json:"routing_number,omitempty"`
+	AccountNumber string                   `json:"account_number,omitempty"`
+	PayPalMeURL   string                   `json:"paypal_me_url,omitempty"`
+	Instructions  string                   `json:"instructions,omitempty"`
+	// Lines is the method as printed on the invoice
+	Lines []string `json:"lines"`
+}
+
+// CreatePaymentLink signs a payment link for the invoice. Only the latest link
+// works; creating a new one revokes the previous one.
+func (s *InvoicePaymentService) CreatePaymentLink(audit AuditContext, organizationID, invoiceID, gateway string) (*PaymentLink, error) {
+	if gateway == "" {
+		gateway = s.defaultGateway
+	}
+	if _, ok := s.gateways[gateway]; !ok {
+		return nil, ErrPaymentProviderNotConfigured
+	}
+
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate payment link: %w", err)
+	}
+
+	err = database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		invoice, err := getInvoice(tx, invoiceID, organizationID)
+		if err != nil {
+			return err
+		}
+		if err := checkPayable(invoice); err != nil {
+			return err
+		}
+
+		before := auditSnapshot(invoice)
+		invoice.PaymentLinkTokenHash = utils.HashToken(nonce)
+		if err := tx.Model(invoice).Update("payment_link_token_hash", invoice.PaymentLinkTokenHash).Error; err != nil {
+			return fmt.Errorf("failed to save payment link: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionRotate,
+			models.AuditResourceInvoice, invoice.ID.String(), before, invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := utils.SignClaims(jwt.MapClaims{
+		"purpose":         paymentLinkPurpose,
+		"organization_id": organizationID,
+		"invoice_id":      invoiceID,
+		"gateway":         gateway,
+		"nonce":           nonce,
+		"iat":             time.Now().Unix(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign payment link: %w", err)
+	}
+
+	return &PaymentLink{URL: s.frontendURL + "/pay?token=" + url.QueryEscape(token), Gateway: gateway}, nil
+}
+
+// LookupPayment resolves a payment link for display on the payment page
+func (s *InvoicePaymentService) LookupPayment(token string) (*PublicInvoice, error) {
+	link, invoice, err := s.lookupInvoice(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var org models.Organization
+	if err := s.db.Select("name").First(&org, "id = ?", link.organizationID).Error; err != nil {
+		return nil, ErrPaymentLinkInvalid
+	}
+
+	public := &PublicInvoice{
+		InvoiceNumber:    invoice.InvoiceNumber,
+		OrganizationName: org.Name,
+		ClientName:       invoice.Client.Name,
+		Status:           invoice.Status,
+		IssueDate:        invoice.IssueDate,
+		DueDate:          invoice.DueDate,
+		Currency:         invoice.Currency,
+		Subtotal:         invoice.Subtotal,
+		TaxAmount:        invoice.TaxAmount,
+		TotalAmount:      invoice.TotalAmount,
+		Items:            make([]PublicInvoiceItem, 0, len(invoice.InvoiceItems)),
+		PaidAt:           invoice.PaidAt,
+		Gateway:          link.gateway,
+		PaymentMethods:   make([]PublicPaymentMethod, 0, len(invoice.PaymentMethods)),
+		Notes:            invoice.Notes,
+		Terms:            invoice.Terms,
+	}
+	for _, item := range invoice.InvoiceItems {
+		public.Items = append(public.Items, PublicInvoiceItem{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			TotalPrice:  item.TotalPrice,
+		})
+	}
+	for _, method := range invoice.PaymentMethods {
+		public.PaymentMethods = append(public.PaymentMethods, PublicPaymentMethod{
+			Type:          method.Type,
+			Name:          method.Name,
+			AccountHolder: method.AccountHolder,
+			BankName:      method.BankName,
+			IBAN:          method.IBAN,
+			BIC:           method.BIC,
+			RoutingNumber: method.RoutingNumber,
+			AccountNumber: method.AccountNumber,
+			PayPalMeURL:   method.PayPalMeURL(),
+			Instructions:  method.Instructions,
+			Lines:         PaymentMethodLines(&method, invoice.InvoiceNumber),
+		})
+	}
+	return public, nil
+}
+
+// PaymentLinkPDF renders the invoice behind a payment link as a PDF
+func (s *InvoicePaymentService) PaymentLinkPDF(token string) ([]byte, *models.Invoice, error) {
+	_, invoice, err := s.lookupInvoice(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := renderInvoicePDF(s.db, invoice)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, invoice, nil
+}
+
+// PaymentLinkCode builds a payment QR code for the invoice behind a payment link
+func (s *InvoicePaymentService) PaymentLinkCode(token string, codeType PaymentCodeType) (*PaymentCode, error) {
+	_, invoice, err := s.lookupInvoice(token)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := invoiceOrganization(s.db, invoice)
+	if err != nil {
+		return nil, err
+	}
+	return buildPaymentCode(org, invoice, codeType)
+}
+
+// lookupInvoice loads the invoice behind a payment link
+func (s *InvoicePaymentService) lookupInvoice(token string) (*paymentLinkClaims, *models.Invoice, error) {
+	link, err := parsePaymentLink(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var invoice *models.Invoice
+	err = database.WithOrganization(s.db, link.organizationID, func(tx *gorm.DB) error {
+		invoice, err = resolvePaymentLink(tx, link)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return link, invoice, nil
+}
+
+// StartPayment opens a gateway checkout for the invoice behind the link and
+// records it as a pending payment
+func (s *InvoicePaymentService) StartPayment(ctx context.Context, token string) (*PaymentCheckout, error) {
+	link, err := parsePaymentLink(token)
+	if err != nil {
+		return nil, err
+	}
+	gateway, ok := s.gateways[link.gateway]
+	if !ok {
+		return nil, ErrPaymentProviderNotConfigured
+	}
+
+	var invoice *models.Invoice
+	err = database.WithOrganization(s.db, link.organizationID, func(tx *gorm.DB) error {
+		invoice, err = resolvePaymentLink(tx, link)
+		if err != nil {
+			return err
+		}
+		return checkPayable(invoice)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if invoice.TotalAmount <= 0 {
+		return nil, ErrPaymentAmountTooLow
+	}
+
+	var org models.Organization
+	if err := s.db.Select("name").First(&org, "id = ?", link.organizationID).Error; err != nil {
+		return nil, ErrPaymentLinkInvalid
+	}
+
+	returnURL := s.frontendURL + "/pay?token=" + url.QueryEscape(token)
+	checkout, err := gateway.CreateCheckout(ctx, PaymentCheckoutParams{
+		OrganizationID:   link.organizationID,
+		OrganizationName: org.Name,
+		InvoiceID:        invoice.ID.String(),
+		InvoiceNumber:    invoice.InvoiceNumber,
+		Amount:           invoice.TotalAmount,
+		Currency:         invoice.Currency,
+		CustomerEmail:    invoice.Client.Email,
+		SuccessURL:       returnURL + "&payment=success",
+		CancelURL:        returnURL + "&payment=cancelled",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payment := models.InvoicePayment{
+		OrganizationID: link.organizationID,
+		InvoiceID:      invoice.ID.String(),
+		Gateway:        gateway.Name(),
+		SessionID:      checkout.SessionID,
+		Amount:         invoice.TotalAmount,
+		Currency:       invoice.Currency,
+		Status:         models.InvoicePaymentPending,
+	}
+	err = database.WithOrganization(s.db, link.organizationID, func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return fmt.Errorf("failed to record payment: %w", err)
+		}
+		return recordAuditEvent(tx, AuditContext{}, link.organizationID, models.AuditActionCreate,
+			models.AuditResourceInvoicePayment, payment.ID, nil, payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return checkout, nil
+}
+
+// ListPayments returns the online payment attempts for an invoice, newest first
+func (s *InvoicePaymentService) ListPayments(organizationID, invoiceID string) ([]models.InvoicePayment, error) {
+	var payments []models.InvoicePayment
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if _, err := getInvoice(tx, invoiceID, organizationID); err != nil {
+			return err
+		}
+		return tx.Where("organization_id = ? AND invoice_id = ?", organizationID, invoiceID).
+			Order("created_at DESC").Find(&payments).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// HandleWebhook verifies and applies a gateway event. Events are recorded so
+// redeliveries are ignored.
+func (s *InvoicePaymentService) HandleWebhook(ctx context.Context, gatewayName string, headers http.Header, body []byte) error {
+	gateway, ok := s.gateways[gatewayName]
+	if !ok {
+		return ErrPaymentProviderNotConfigured
+	}
+
+	event, err := gateway.ParseWebhook(ctx, headers, body)
+	if err != nil {
+		return err
+	}
+
+	var paidInvoice *models.Invoice
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		record := models.BillingWebhookEvent{
+			Provider:  gatewayName + "_payments",
+			EventID:   event.ID,
+			EventType: event.Type,
+		}
+		if event.Payment != nil {
+			record.ResourceID = event.Payment.SessionID
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return fmt.Errorf("failed to record webhook event: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil // already processed
+		}
+
+		if event.Payment == nil || event.Payment.OrganizationID == "" {
+			return nil
+		}
+
+		return database.WithOrganization(tx, event.Payment.OrganizationID, func(tx *gorm.DB) error {
+			paidInvoice, err = applyGatewayPayment(tx, gatewayName, event.Payment)
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if paidInvoice != nil {
+		s.notifyPaid(paidInvoice)
+	}
+	return nil
+}
+
+// applyGatewayPayment updates the payment opened for the gateway session and,
+// when the invoice total was collected, marks its invoice paid. Partial and
+// mismatched payments are recorded without touching the invoice. It returns
+// the invoice if it was paid.
+func applyGatewayPayment(tx *gorm.DB, gatewayName string, gatewayPayment *GatewayPayment) (*models.Invoice, error) {
+	organizationID := gatewayPayment.OrganizationID
+
+	var payment models.InvoicePayment
+	err := tx.Where("organization_id = ? AND gateway = ? AND session_id = ?", organizationID, gatewayName, gatewayPayment.SessionID).
+		First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("ignoring %s payment for unknown session %s", gatewayName, gatewayPayment.SessionID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load payment: %w", err)
+	}
+	// A settled payment doesn't go back, e.g. when a session expires after succeeding
+	if payment.Status.Settled() || payment.Status == gatewayPayment.Status {
+		return nil, nil
+	}
+
+	before := payment
+	now := time.Now()
+	payment.Status = gatewayPayment.Status
+	if gatewayPayment.PaymentID != "" {
+		payment.GatewayPaymentID = gatewayPayment.PaymentID
+	}
+	var invoice *models.Invoice
+	if payment.Status == models.InvoicePaymentSucceeded {
+		invoice, err = getInvoice(tx, payment.InvoiceID, organizationID)
+		if err != nil {
+			return nil, err
+		}
+		payment.PaidAt = &now
+		payment.Amount = gatewayPayment.Amount
+		payment.Currency = gatewayPayment.Currency
+		payment.Status = collectedStatus(invoice, gatewayPayment)
+		if payment.Status != models.InvoicePaymentSucceeded {
+			log.Printf("%s payment %s collected %.2f %s for invoice %s of %.2f %s; not marking it paid",
+				gatewayName, payment.ID, payment.Amount, payment.Currency, invoice.ID, invoice.TotalAmount, invoice.Currency)
+		}
+	}
+	if err := tx.Save(&payment).Error; err != nil {
+		return nil, fmt.Errorf("failed to update payment: %w", err)
+	}
+	if err := recordAuditEvent(tx, AuditContext{}, organizationID, models.AuditActionStatusChange,
+		models.AuditResourceInvoicePayment, payment.ID, before, payment); err != nil {
+		return nil, err
+	}
+
+	if payment.Status != models.InvoicePaymentSucceeded {
+		return nil, nil
+	}
+	if invoice.Status == models.InvoiceStatusPaid {
+		log.Printf("invoice %s was paid again by %s payment %s", invoice.ID, gatewayName, payment.ID)
+		return nil, nil
+	}
+
+	invoiceBefore := auditSnapshot(invoice)
+	invoice.Status = models.InvoiceStatusPaid
+	invoice.PaidAt = &now
+	if err := tx.Save(invoice).Error; err != nil {
+		return nil, fmt.Errorf("failed to mark invoice paid: %w", err)
+	}
+	if err := recordAuditEvent(tx, AuditContext{}, organizationID, models.AuditActionStatusChange,
+		models.AuditResourceInvoice, invoice.ID.String(), invoiceBefore, invoice); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// collectedStatus compares what the gateway collected with the invoice total, in
+// the currency's minor units
+func collectedStatus(invoice *models.Invoice, gatewayPayment *GatewayPayment) models.InvoicePaymentStatus {
+	if !strings.EqualFold(gatewayPayment.Currency, invoice.Currency) {
+		return models.InvoicePaymentMismatched
+	}
+	collected := toMinorUnits(gatewayPayment.Amount, invoice.Currency)
+	total := toMinorUnits(invoice.TotalAmount, invoice.Currency)
+	switch {
+	case collected < total:
+		return models.InvoicePaymentPartial
+	case collected > total:
+		return models.InvoicePaymentMismatched
+	}
+	return models.InvoicePaymentSucceeded
+}
+
+// notifyPaid tells the invoice's author that the client paid it
+func (s *InvoicePaymentService) notifyPaid(invoice *models.Invoice) {
+	var user models.User
+	if err := s.db.Select("email").First(&user, "id = ?", invoice.UserID).Error; err != nil {
+		log.Printf("failed to load author of invoice %s: %v", invoice.ID, err)
+		return
+	}
+
+	if err := s.notifier.Notify(Notification{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Invoice %s was paid", invoice.InvoiceNumber),
+		Body: fmt.Sprintf("%s paid invoice %s (%.2f %s) online.",
+			invoice.Client.Name, invoice.InvoiceNumber, invoice.TotalAmount, invoice.Currency),
+	}); err != nil {
+		log.Printf("failed to send payment notice for invoice %s: %v", invoice.ID, err)
+	}
+}
+
+// checkPayable reports whether the client can pay the invoice online
+func checkPayable(invoice *models.Invoice) error {
+	switch invoice.Status {
+	case models.InvoiceStatusSent, models.InvoiceStatusOverdue:
+		return nil
+	case models.InvoiceStatusPaid:
+		return ErrInvoiceAlreadyPaid
+	}
+	return ErrInvoiceNotPayable
+}
+
+type paymentLinkClaims struct {
+	organizationID string
+	invoiceID      string
+	gateway        string
+	nonce          string
+}
+
+func parsePaymentLink(token string) (*paymentLinkClaims, error) {
+	claims, err := utils.ParseClaims(token, paymentLinkPurpose)
+	if err != nil {
+		return nil, ErrPaymentLinkInvalid
+	}
+
+	link := &paymentLinkClaims{}
+	link.organizationID, _ = claims["organization_id"].(string)
+	link.invoiceID, _ = claims["invoice_id"].(string)
+	link.gateway, _ = claims["gateway"].(string)
+	link.nonce, _ = claims["nonce"].(string)
+	if link.organizationID == "" || link.invoiceID == "" || link.nonce == "" {
+		return nil, ErrPaymentLinkInvalid
+	}
+	return link, nil
+}
+
+// resolvePaymentLink loads the invoice if the link is its latest payment link
+func resolvePaymentLink(tx *gorm.DB, link *paymentLinkClaims) (*models.Invoice, error) {
+	invoice, err := getInvoice(tx, link.invoiceID, link.organizationID)
+	if err != nil {
+		return nil, ErrPaymentLinkInvalid
+	}
+	if invoice.PaymentLinkTokenHash == "" || invoice.PaymentLinkTokenHash != utils.HashToken(link.nonce) {
+		return nil, ErrPaymentLinkInvalid
+	}
+	return invoice, nil
+}
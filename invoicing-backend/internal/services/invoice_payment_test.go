@@ -0,0 +1,372 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/paypalfake"
+	"github.com/yourusername/invoicing-backend/internal/stripefake"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+const testStripePaymentsSecret = "whsec_payments_test"
+
+func TestCollectedStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    float64
+		currency string
+		amount   float64
+		paid     string
+		want     models.InvoicePaymentStatus
+	}{
+		{"exact", 119.99, "EUR", 119.99, "EUR", models.InvoicePaymentSucceeded},
+		{"currency case", 119.99, "EUR", 119.99, "eur", models.InvoicePaymentSucceeded},
+		{"float rounding", 0.1 + 0.2, "EUR", 0.3, "EUR", models.InvoicePaymentSucceeded},
+		{"partial", 119.99, "EUR", 50, "EUR", models.InvoicePaymentPartial},
+		{"one cent short", 119.99, "EUR", 119.98, "EUR", models.InvoicePaymentPartial},
+		{"overpaid", 119.99, "EUR", 120, "EUR", models.InvoicePaymentMismatched},
+		{"other currency", 119.99, "EUR", 119.99, "USD", models.InvoicePaymentMismatched},
+		{"zero decimal currency", 5000, "JPY", 5000, "JPY", models.InvoicePaymentSucceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := &models.Invoice{TotalAmount: tt.total, Currency: tt.currency}
+			got := collectedStatus(invoice, &GatewayPayment{Amount: tt.amount, Currency: tt.paid})
+			if got != tt.want {
+				t.Fatalf("collectedStatus = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// newSentInvoice creates an invoice of total and sends it, so it can be paid online
+func newSentInvoice(t *testing.T, db *gorm.DB, owner *models.User, client *models.Client, total float64) *models.Invoice {
+	t.Helper()
+
+	invoice := newTestInvoice(t, db, owner, client, total)
+	invoices := NewInvoiceService(db, NewLogNotifier(), NewUsageService(db, NewLogNotifier(), ""))
+	invoice, err := invoices.UpdateInvoiceStatus(AuditContext{UserID: owner.ID.String()}, invoice.ID.String(),
+		client.OrganizationID, models.InvoiceStatusSent)
+	if err != nil {
+		t.Fatalf("failed to send invoice: %v", err)
+	}
+	return invoice
+}
+
+func TestStripeInvoicePayment(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+
+	receiver, deliveries := newWebhookReceiver(t)
+	fake := stripefake.New("", "", receiver.URL, testStripePaymentsSecret)
+	server := httptest.NewServer(fake.Handler())
+	t.Cleanup(server.Close)
+
+	owner, org := newTestOrganization(t, db, 5)
+	orgID := org.ID.String()
+	audit := AuditContext{UserID: owner.ID.String()}
+	client := newTestClient(t, db, owner, org)
+	invoices := NewInvoiceService(db, NewLogNotifier(), NewUsageService(db, NewLogNotifier(), ""))
+	payments := NewInvoicePaymentService(db, NewLogNotifier(),
+		[]PaymentGateway{NewStripePaymentGateway(server.URL, "sk_test", testStripePaymentsSecret)},
+		models.PaymentGatewayStripe, "http://app.example.com")
+
+	// startPayment opens a checkout for the invoice through its payment link
+	startPayment := func(t *testing.T, invoice *models.Invoice) *PaymentCheckout {
+		t.Helper()
+		link, err := payments.CreatePaymentLink(audit, orgID, invoice.ID.String(), "")
+		if err != nil {
+			t.Fatalf("failed to create payment link: %v", err)
+		}
+		linkURL, err := url.Parse(link.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkout, err := payments.StartPayment(ctx, linkURL.Query().Get("token"))
+		if err != nil {
+			t.Fatalf("failed to start payment: %v", err)
+		}
+		return checkout
+	}
+
+	// completeSession sends the session's completion as if the gateway collected amount
+	completeSession := func(t *testing.T, invoice *models.Invoice, sessionID string, amount int64, currency string) {
+		t.Helper()
+		headers, body, err := fake.Event(testStripePaymentsSecret, StripeEventCheckoutCompleted, map[string]interface{}{
+			"id":             sessionID,
+			"object":         "checkout.session",
+			"mode":           "payment",
+			"payment_status": "paid",
+			"payment_intent": "pi_test",
+			"amount_total":   amount,
+			"currency":       currency,
+			"metadata":       map[string]string{"organization_id": orgID, "invoice_id": invoice.ID.String()},
+		}, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := payments.HandleWebhook(ctx, models.PaymentGatewayStripe, headers, body); err != nil {
+			t.Fatalf("payment webhook failed: %v", err)
+		}
+	}
+
+	// assertPayment checks the invoice's status and its only payment
+	assertPayment := func(t *testing.T, invoice *models.Invoice, invoiceStatus models.InvoiceStatus, paymentStatus models.InvoicePaymentStatus) {
+		t.Helper()
+		got, err := invoices.GetInvoiceByID(invoice.ID.String(), owner.ID.String(), orgID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != invoiceStatus {
+			t.Errorf("invoice status = %s, want %s", got.Status, invoiceStatus)
+		}
+		if (got.PaidAt != nil) != (invoiceStatus == models.InvoiceStatusPaid) {
+			t.Errorf("invoice paid_at = %v with status %s", got.PaidAt, got.Status)
+		}
+
+		list, err := payments.ListPayments(orgID, invoice.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].Status != paymentStatus {
+			t.Fatalf("payments = %+v, want one %s payment", list, paymentStatus)
+		}
+	}
+
+	t.Run("paid in full", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		followCheckout(t, checkout.CheckoutURL)
+		paid := <-deliveries
+		if err := payments.HandleWebhook(ctx, models.PaymentGatewayStripe, paid.headers, paid.body); err != nil {
+			t.Fatalf("payment webhook failed: %v", err)
+		}
+		assertPayment(t, invoice, models.InvoiceStatusPaid, models.InvoicePaymentSucceeded)
+
+		// A redelivery is acknowledged without effect
+		if err := payments.HandleWebhook(ctx, models.PaymentGatewayStripe, paid.headers, paid.body); err != nil {
+			t.Fatalf("redelivery failed: %v", err)
+		}
+		assertPayment(t, invoice, models.InvoiceStatusPaid, models.InvoicePaymentSucceeded)
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		completeSession(t, invoice, checkout.SessionID, 5000, "eur")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentPartial)
+
+		// A later event for the session doesn't turn the payment into a full one
+		completeSession(t, invoice, checkout.SessionID, 11999, "eur")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentPartial)
+	})
+
+	t.Run("other currency", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		completeSession(t, invoice, checkout.SessionID, 11999, "usd")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentMismatched)
+	})
+
+	t.Run("overpaid", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		completeSession(t, invoice, checkout.SessionID, 12999, "eur")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentMismatched)
+	})
+}
+
+func TestPayPalMoney(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     PayPalMoney
+	}{
+		{119.99, "eur", PayPalMoney{CurrencyCode: "EUR", Value: "119.99"}},
+		{100, "USD", PayPalMoney{CurrencyCode: "USD", Value: "100.00"}},
+		{0.1 + 0.2, "EUR", PayPalMoney{CurrencyCode: "EUR", Value: "0.30"}},
+		{5000, "jpy", PayPalMoney{CurrencyCode: "JPY", Value: "5000"}},
+	}
+	for _, tt := range tests {
+		if got := paypalMoney(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("paypalMoney(%v, %q) = %+v, want %+v", tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestPayPalInvoicePayment(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+
+	receiver, deliveries := newWebhookReceiver(t)
+	fake := paypalfake.New(testPayPalSignature, "", receiver.URL)
+	server := httptest.NewServer(fake.Handler())
+	t.Cleanup(server.Close)
+
+	owner, org := newTestOrganization(t, db, 5)
+	orgID := org.ID.String()
+	audit := AuditContext{UserID: owner.ID.String()}
+	client := newTestClient(t, db, owner, org)
+	paypal := NewPayPalClient(server.URL, "test-client", "test-secret")
+	invoices := NewInvoiceService(db, NewLogNotifier(), NewUsageService(db, NewLogNotifier(), ""))
+	payments := NewInvoicePaymentService(db, NewLogNotifier(),
+		[]PaymentGateway{NewPayPalPaymentGateway(paypal, "WH-TEST")},
+		models.PaymentGatewayPayPal, "http://app.example.com")
+
+	// startPayment opens an order for the invoice through its payment link
+	startPayment := func(t *testing.T, invoice *models.Invoice) *PaymentCheckout {
+		t.Helper()
+		link, err := payments.CreatePaymentLink(audit, orgID, invoice.ID.String(), "")
+		if err != nil {
+			t.Fatalf("failed to create payment link: %v", err)
+		}
+		linkURL, err := url.Parse(link.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkout, err := payments.StartPayment(ctx, linkURL.Query().Get("token"))
+		if err != nil {
+			t.Fatalf("failed to start payment: %v", err)
+		}
+		return checkout
+	}
+
+	// settleCapture sends a capture event for the order as if PayPal collected amount
+	settleCapture := func(t *testing.T, eventType, orderID, status, amount, currency string) {
+		t.Helper()
+		headers, body, err := fake.Event(eventType, "capture", map[string]interface{}{
+			"id":        "CAP-" + orderID,
+			"status":    status,
+			"custom_id": orgID,
+			"amount":    map[string]string{"currency_code": currency, "value": amount},
+			"supplementary_data": map[string]interface{}{
+				"related_ids": map[string]string{"order_id": orderID},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := payments.HandleWebhook(ctx, models.PaymentGatewayPayPal, headers, body); err != nil {
+			t.Fatalf("payment webhook failed: %v", err)
+		}
+	}
+
+	// assertPayment checks the invoice's status and its only payment
+	assertPayment := func(t *testing.T, invoice *models.Invoice, invoiceStatus models.InvoiceStatus, paymentStatus models.InvoicePaymentStatus) *models.InvoicePayment {
+		t.Helper()
+		got, err := invoices.GetInvoiceByID(invoice.ID.String(), owner.ID.String(), orgID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != invoiceStatus {
+			t.Errorf("invoice status = %s, want %s", got.Status, invoiceStatus)
+		}
+		if (got.PaidAt != nil) != (invoiceStatus == models.InvoiceStatusPaid) {
+			t.Errorf("invoice paid_at = %v with status %s", got.PaidAt, got.Status)
+		}
+
+		list, err := payments.ListPayments(orgID, invoice.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].Status != paymentStatus {
+			t.Fatalf("payments = %+v, want one %s payment", list, paymentStatus)
+		}
+		return &list[0]
+	}
+
+	t.Run("paid in full", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		// The order carries the invoice total and our IDs, which PayPal echoes back
+		order, err := paypal.GetOrder(ctx, checkout.SessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit := order.PurchaseUnits[0]
+		if unit.ReferenceID != invoice.ID.String() || unit.CustomID != orgID ||
+			unit.Amount != (PayPalMoney{CurrencyCode: "EUR", Value: "119.99"}) {
+			t.Fatalf("order purchase unit = %+v", unit)
+		}
+
+		followCheckout(t, checkout.CheckoutURL)
+		approved := <-deliveries
+		if err := payments.HandleWebhook(ctx, models.PaymentGatewayPayPal, approved.headers, approved.body); err != nil {
+			t.Fatalf("payment webhook failed: %v", err)
+		}
+		payment := assertPayment(t, invoice, models.InvoiceStatusPaid, models.InvoicePaymentSucceeded)
+		if payment.Amount != 119.99 || payment.Currency != "EUR" || payment.GatewayPaymentID == "" {
+			t.Errorf("payment collected %.2f %s with capture %q", payment.Amount, payment.Currency, payment.GatewayPaymentID)
+		}
+
+		// PayPal refuses the second capture of a redelivery; the completed order is
+		// fetched instead and the event acknowledged without effect
+		if err := payments.HandleWebhook(ctx, models.PaymentGatewayPayPal, approved.headers, approved.body); err != nil {
+			t.Fatalf("redelivery failed: %v", err)
+		}
+		assertPayment(t, invoice, models.InvoiceStatusPaid, models.InvoicePaymentSucceeded)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		headers, body, err := fake.Event(PayPalEventCaptureCompleted, "capture", map[string]string{"id": "CAP-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers.Set("PAYPAL-TRANSMISSION-SIG", "forged")
+		if err := payments.HandleWebhook(ctx, models.PaymentGatewayPayPal, headers, body); !errors.Is(err, ErrInvalidWebhookSignature) {
+			t.Fatalf("err = %v, want ErrInvalidWebhookSignature", err)
+		}
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		settleCapture(t, PayPalEventCaptureCompleted, checkout.SessionID, PayPalCaptureCompleted, "50.00", "EUR")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentPartial)
+
+		// A later capture for the order doesn't turn the payment into a full one
+		settleCapture(t, PayPalEventCaptureCompleted, checkout.SessionID, PayPalCaptureCompleted, "119.99", "EUR")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentPartial)
+	})
+
+	t.Run("other currency", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		settleCapture(t, PayPalEventCaptureCompleted, checkout.SessionID, PayPalCaptureCompleted, "119.99", "USD")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentMismatched)
+	})
+
+	t.Run("overpaid", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		settleCapture(t, PayPalEventCaptureCompleted, checkout.SessionID, PayPalCaptureCompleted, "129.99", "EUR")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentMismatched)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		invoice := newSentInvoice(t, db, owner, client, 119.99)
+		checkout := startPayment(t, invoice)
+
+		// Pending captures wait for the event that settles them
+		settleCapture(t, PayPalEventCaptureCompleted, checkout.SessionID, "PENDING", "119.99", "EUR")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentPending)
+
+		settleCapture(t, PayPalEventCaptureDenied, checkout.SessionID, PayPalCaptureDeclined, "119.99", "EUR")
+		assertPayment(t, invoice, models.InvoiceStatusSent, models.InvoicePaymentFailed)
+	})
+}
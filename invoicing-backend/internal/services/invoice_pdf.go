@@ -0,0 +1,379 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/pdf"
+	"gorm.io/gorm"
+)
+
+const (
+	pdfMargin      = 50.0
+	pdfContentEdge = pdf.PageWidth - pdfMargin
+)
+
+// InvoicePDF renders the invoice as a PDF
+func (s *InvoiceService) InvoicePDF(invoiceID, organizationID string) ([]byte, *models.Invoice, error) {
+	var invoice *models.Invoice
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		invoice, err = getInvoice(tx, invoiceID, organizationID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := renderInvoicePDF(s.db, invoice)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, invoice, nil
+}
+
+// renderInvoicePDF lays out an invoice loaded with getInvoice
+func renderInvoicePDF(db *gorm.DB, invoice *models.Invoice) ([]byte, error) {
+	org, err := invoiceOrganization(db, invoice)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &invoiceLayout{doc: pdf.New("Invoice " + invoice.InvoiceNumber), y: pdfMargin}
+	l.header(org, invoice)
+	l.items(invoice)
+	l.totals(invoice)
+	l.paymentMethods(invoice)
+
+	// The payment code is left out when the invoice's payment methods don't support one
+	code, err := buildPaymentCode(org, invoice, "")
+	if err != nil && !errors.Is(err, ErrPaymentCodeUnavailable) {
+		return nil, err
+	}
+	if code != nil {
+		l.paymentCode(code, invoice)
+	}
+	l.section("Notes", invoice.Notes)
+	l.section("Terms", invoice.Terms)
+
+	data, err := l.doc.Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render invoice PDF: %w", err)
+	}
+	return data, nil
+}
+
+// invoiceOrganization loads what invoices print about their organization
+func invoiceOrganization(db *gorm.DB, invoice *models.Invoice) (*models.Organization, error) {
+	var org models.Organization
+	if err := db.Select("id", "name", "settings").First(&org, "id = ?", invoice.OrganizationID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load organization: %w", err)
+	}
+	return &org, nil
+}
+
+// invoiceLayout tracks the vertical position while an invoice is drawn top to bottom
+type invoiceLayout struct {
+	doc *pdf.Document
+	y   float64
+}
+
+// reserve starts a new page unless height more points fit on the current one
+func (l *invoiceLayout) reserve(height float64) {
+	if l.y+height > pdf.PageHeight-pdfMargin {
+		l.doc.AddPage()
+		l.y = pdfMargin
+	}
+}
+
+func (l *invoiceLayout) header(org *models.Organization, invoice *models.Invoice) {
+	top := l.y
+	l.doc.Text(pdfMargin, top+18, pdf.Bold, 18, org.Name)
+	y := top + 36
+	for _, line := range addressLines(org.Settings.CompanyAddress) {
+		l.doc.Text(pdfMargin, y, pdf.Regular, 10, line)
+		y += 13
+	}
+
+	l.doc.TextRight(pdfContentEdge, top+18, pdf.Bold, 20, "INVOICE")
+	details := [][2]string{
+		{"Invoice number", invoice.InvoiceNumber},
+		{"Issue date", invoice.IssueDate.Format("2006-01-02")},
+		{"Due date", invoice.DueDate.Format("2006-01-02")},
+	}
+	if invoice.Status == models.InvoiceStatusPaid && invoice.PaidAt != nil {
+		details = append(details, [2]string{"Paid", invoice.PaidAt.Format("2006-01-02")})
+	}
+	dy := top + 36
+	for _, d := range details {
+		l.doc.TextRight(pdfContentEdge-110, dy, pdf.Regular, 10, d[0])
+		l.doc.TextRight(pdfContentEdge, dy, pdf.Bold, 10, d[1])
+		dy += 13
+	}
+
+	l.y = math.Max(y, dy) + 20
+	client := invoice.Client
+	l.doc.Text(pdfMargin, l.y, pdf.Bold, 10, "Bill to")
+	l.y += 14
+	lines := []string{client.Name}
+	if client.CompanyName != "" {
+		lines = append(lines, client.CompanyName)
+	}
+	lines = append(lines, addressLines(models.CompanyAddress{
+		AddressLine1: client.AddressLine1,
+		AddressLine2: client.AddressLine2,
+		City:         client.City,
+		State:        client.State,
+		PostalCode:   client.PostalCode,
+		Country:      client.Country,
+	})...)
+	if client.Email != "" {
+		lines = append(lines, client.Email)
+	}
+	if client.TaxID != "" {
+		lines = append(lines, "Tax ID: "+client.TaxID)
+	}
+	for _, line := range lines {
+		l.doc.Text(pdfMargin, l.y, pdf.Regular, 10, line)
+		l.y += 13
+	}
+	l.y += 20
+}
+
+// Right edges of the numeric item columns
+const (
+	pdfQuantityEdge  = pdfContentEdge - 170
+	pdfUnitPriceEdge = pdfContentEdge - 85
+)
+
+func (l *invoiceLayout) itemsHeader() {
+	l.doc.SetGray(0.92)
+	l.doc.Rect(pdfMargin, l.y, pdfContentEdge-pdfMargin, 20)
+	l.doc.SetGray(0)
+	l.doc.Text(pdfMargin+6, l.y+14, pdf.Bold, 10, "Description")
+	l.doc.TextRight(pdfQuantityEdge, l.y+14, pdf.Bold, 10, "Qty")
+	l.doc.TextRight(pdfUnitPriceEdge, l.y+14, pdf.Bold, 10, "Unit price")
+	l.doc.TextRight(pdfContentEdge-6, l.y+14, pdf.Bold, 10, "Amount")
+	l.y += 20
+}
+
+func (l *invoiceLayout) items(invoice *models.Invoice) {
+	l.reserve(60)
+	l.itemsHeader()
+
+	for _, item := range invoice.InvoiceItems {
+		lines := pdf.Wrap(pdf.Regular, 10, item.Description, pdfQuantityEdge-pdfMargin-60)
+		height := float64(len(lines))*13 + 8
+		if l.y+height > pdf.PageHeight-pdfMargin {
+			l.reserve(height)
+			l.itemsHeader()
+		}
+
+		l.doc.TextRight(pdfQuantityEdge, l.y+14, pdf.Regular, 10, strconv.FormatFloat(item.Quantity, 'f', -1, 64))
+		l.doc.TextRight(pdfUnitPriceEdge, l.y+14, pdf.Regular, 10, formatMoney(item.UnitPrice, invoice.Currency))
+		l.doc.TextRight(pdfContentEdge-6, l.y+14, pdf.Regular, 10, formatMoney(item.TotalPrice, invoice.Currency))
+		for i, line := range lines {
+			l.doc.Text(pdfMargin+6, l.y+14+float64(i)*13, pdf.Regular, 10, line)
+		}
+		l.y += height
+		l.doc.SetGray(0.8)
+		l.doc.Line(pdfMargin, l.y, pdfContentEdge, l.y, 0.5)
+		l.doc.SetGray(0)
+	}
+	l.y += 10
+}
+
+func (l *invoiceLayout) totals(invoice *models.Invoice) {
+	l.reserve(60)
+	rows := [][2]string{
+		{"Subtotal", formatMoney(invoice.Subtotal, invoice.Currency)},
+		{fmt.Sprintf("Tax (%s%%)", strconv.FormatFloat(invoice.TaxRate*100, 'f', -1, 64)), formatMoney(invoice.TaxAmount, invoice.Currency)},
+	}
+	for _, row := range rows {
+		l.y += 14
+		l.doc.TextRight(pdfUnitPriceEdge, l.y, pdf.Regular, 10, row[0])
+		l.doc.TextRight(pdfContentEdge-6, l.y, pdf.Regular, 10, row[1])
+	}
+	l.y += 18
+	l.doc.TextRight(pdfUnitPriceEdge, l.y, pdf.Bold, 12, "Total")
+	l.doc.TextRight(pdfContentEdge-6, l.y, pdf.Bold, 12, formatMoney(invoice.TotalAmount, invoice.Currency)+" "+invoice.Currency)
+	l.y += 30
+}
+
+func (l *invoiceLayout) paymentMethods(invoice *models.Invoice) {
+	if len(invoice.PaymentMethods) == 0 {
+		return
+	}
+
+	l.reserve(40)
+	l.doc.Text(pdfMargin, l.y, pdf.Bold, 12, "Payment instructions")
+	l.y += 18
+	for _, method := range invoice.PaymentMethods {
+		var lines []string
+		for _, line := range PaymentMethodLines(&method, invoice.InvoiceNumber) {
+			lines = append(lines, pdf.Wrap(pdf.Regular, 10, line, pdfContentEdge-pdfMargin-10)...)
+		}
+
+		l.reserve(float64(len(lines))*13 + 22)
+		l.doc.Text(pdfMargin, l.y, pdf.Bold, 10, method.Name)
+		l.y += 13
+		for _, line := range lines {
+			l.doc.Text(pdfMargin+10, l.y, pdf.Regular, 10, line)
+			l.y += 13
+		}
+		l.y += 8
+	}
+	l.y += 10
+}
+
+// Side of printed payment codes: 46 mm, as the Swiss QR-bill prescribes
+const pdfPaymentCodeSide = 46 / 25.4 * 72
+
+func (l *invoiceLayout) paymentCode(code *PaymentCode, invoice *models.Invoice) {
+	l.reserve(pdfPaymentCodeSide + 44)
+	title := "Pay by SEPA transfer"
+	if code.Type == PaymentCodeSwiss {
+		title = "Payment part (Swiss QR-bill)"
+	}
+	l.doc.Text(pdfMargin, l.y, pdf.Bold, 12, title)
+	l.y += 20
+
+	module := pdfPaymentCodeSide / float64(code.QR.Size)
+	for _, shape := range code.shapes() {
+		if shape.dark {
+			l.doc.SetGray(0)
+		} else {
+			l.doc.SetGray(1)
+		}
+		l.doc.Rect(pdfMargin+shape.x*module, l.y+shape.y*module, shape.w*module, shape.h*module)
+	}
+	l.doc.SetGray(0)
+
+	x := pdfMargin + pdfPaymentCodeSide + 20
+	y := l.y + 10
+	details := [][2]string{
+		{"Account / Payable to", FormatIBAN(code.IBAN)},
+		{"", code.Creditor},
+		{"Reference", FormatPaymentReference(code.Reference)},
+		{"Amount", formatMoney(invoice.TotalAmount, invoice.Currency) + " " + strings.ToUpper(invoice.Currency)},
+	}
+	for _, d := range details {
+		if d[1] == "" {
+			continue
+		}
+		if d[0] != "" {
+			l.doc.Text(x, y, pdf.Bold, 8, d[0])
+			y += 11
+		}
+		l.doc.Text(x, y, pdf.Regular, 10, d[1])
+		y += 16
+	}
+	l.doc.Text(x, y, pdf.Regular, 8, "Scan the code with your banking app to pay.")
+
+	l.y += pdfPaymentCodeSide + 24
+}
+
+func (l *invoiceLayout) section(title, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+
+	l.reserve(40)
+	l.doc.Text(pdfMargin, l.y, pdf.Bold, 12, title)
+	l.y += 16
+	for _, line := range pdf.Wrap(pdf.Regular, 10, text, pdfContentEdge-pdfMargin) {
+		l.reserve(13)
+		l.doc.Text(pdfMargin, l.y, pdf.Regular, 10, line)
+		l.y += 13
+	}
+	l.y += 14
+}
+
+// PaymentMethodLines describes how to pay with the method, as printed on invoices.
+// Transfers quote the invoice number as the payment reference.
+func PaymentMethodLines(method *models.PaymentMethod, invoiceNumber string) []string {
+	var lines []string
+	add := func(label, value string) {
+		if value != "" {
+			lines = append(lines, label+": "+value)
+		}
+	}
+
+	switch method.Type {
+	case models.PaymentMethodBankTransfer:
+		add("Account holder", method.AccountHolder)
+		add("Bank", method.BankName)
+		add("IBAN", FormatIBAN(method.IBAN))
+		add("BIC", method.BIC)
+		add("Reference", invoiceNumber)
+	case models.PaymentMethodACH:
+		add("Account holder", method.AccountHolder)
+		add("Bank", method.BankName)
+		add("Routing number", method.RoutingNumber)
+		add("Account number", method.AccountNumber)
+		add("Reference", invoiceNumber)
+	case models.PaymentMethodPayPalMe:
+		add("PayPal", method.PayPalMeURL())
+	}
+	if method.Instructions != "" {
+		lines = append(lines, method.Instructions)
+	}
+	return lines
+}
+
+func addressLines(a models.CompanyAddress) []string {
+	var lines []string
+	for _, line := range []string{
+		a.AddressLine1,
+		a.AddressLine2,
+		strings.TrimSpace(strings.Join(nonEmpty(a.PostalCode, a.City), " ")),
+		a.State,
+		a.Country,
+	} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// formatMoney formats an amount with thousands separators and the currency's decimals
+func formatMoney(amount float64, currency string) string {
+	decimals := 2
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		decimals = 0
+	}
+	s := strconv.FormatFloat(math.Abs(amount), 'f', decimals, 64)
+
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i:]
+	}
+	var b strings.Builder
+	if amount < 0 {
+		b.WriteByte('-')
+	}
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(fraction)
+	return b.String()
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+func TestUpdateInvoiceStatusTransitions(t *testing.T) {
+	db := testutil.DB(t)
+
+	owner, org := newTestOrganization(t, db, 5)
+	org.Settings.ApprovalSettings = models.ApprovalSettings{Enabled: true, AmountThreshold: 100}
+	if err := db.Model(org).Update("settings", org.Settings).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t, db, owner, org)
+
+	invoices := NewInvoiceService(db, NewLogNotifier(), NewUsageService(db, NewLogNotifier(), ""))
+	audit := AuditContext{UserID: owner.ID.String()}
+	orgID := org.ID.String()
+
+	invoice := newTestInvoice(t, db, owner, client, 500)
+	id := invoice.ID.String()
+
+	if _, err := invoices.UpdateInvoiceStatus(audit, id, orgID, models.InvoiceStatusPaid); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Fatalf("draft -> paid: got %v, want ErrInvalidStatusTransition", err)
+	}
+	if _, err := invoices.UpdateInvoiceStatus(audit, id, orgID, models.InvoiceStatusSent); !errors.Is(err, ErrInvoiceApprovalRequired) {
+		t.Fatalf("sending unapproved invoice: got %v, want ErrInvoiceApprovalRequired", err)
+	}
+
+	if _, err := invoices.RequestApproval(audit, id, orgID, ""); err != nil {
+		t.Fatalf("request approval: %v", err)
+	}
+	if _, err := invoices.UpdateInvoiceStatus(audit, id, orgID, models.InvoiceStatusSent); !errors.Is(err, ErrInvoiceApprovalRequired) {
+		t.Fatalf("sending invoice pending approval: got %v, want ErrInvoiceApprovalRequired", err)
+	}
+
+	// Approval is decided by another member; set it directly
+	err := database.WithOrganization(db, orgID, func(tx *gorm.DB) error {
+		return tx.Model(&models.Invoice{}).Where("id = ?", id).
+			Update("approval_status", models.InvoiceApprovalApproved).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		status  models.InvoiceStatus
+		wantErr error
+	}{
+		{models.InvoiceStatusSent, nil},
+		{models.InvoiceStatusDraft, ErrInvalidStatusTransition},
+		{models.InvoiceStatusOverdue, nil},
+		{models.InvoiceStatusPaid, nil},
+		{models.InvoiceStatusCancelled, ErrInvalidStatusTransition},
+		{models.InvoiceStatusSent, ErrInvalidStatusTransition},
+	}
+	for _, step := range steps {
+		updated, err := invoices.UpdateInvoiceStatus(audit, id, orgID, step.status)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("-> %s: got %v, want %v", step.status, err, step.wantErr)
+		}
+		if err == nil && updated.Status != step.status {
+			t.Fatalf("status = %s, want %s", updated.Status, step.status)
+		}
+	}
+}
+
+func TestUpdateInvoiceStatusCancelSkipsApproval(t *testing.T) {
+	db := testutil.DB(t)
+
+	owner, org := newTestOrganization(t, db, 5)
+	org.Settings.ApprovalSettings = models.ApprovalSettings{Enabled: true, AmountThreshold: 100}
+	if err := db.Model(org).Update("settings", org.Settings).Error; err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t, db, owner, org)
+	invoice := newTestInvoice(t, db, owner, client, 500)
+
+	invoices := NewInvoiceService(db, NewLogNotifier(), NewUsageService(db, NewLogNotifier(), ""))
+	updated, err := invoices.UpdateInvoiceStatus(AuditContext{UserID: owner.ID.String()},
+		invoice.ID.String(), org.ID.String(), models.InvoiceStatusCancelled)
+	if err != nil {
+		t.Fatalf("cancelling an unapproved draft failed: %v", err)
+	}
+	if updated.Status != models.InvoiceStatusCancelled {
+		t.Fatalf("status = %s, want cancelled", updated.Status)
+	}
+}
+
+func TestApprovalRequiredForMembersWithoutApprove(t *testing.T) {
+	db := testutil.DB(t)
+
+	owner, org := newTestOrganization(t, db, 5)
+	org.Settings.ApprovalSettings = models.ApprovalSettings{Enabled: true, RequireForOrgUsers: true}
+	if err := db.Model(org).Update("settings", org.Settings).Error; err != nil {
+		t.Fatal(err)
+	}
+	orgID := org.ID.String()
+	client := newTestClient(t, db, owner, org)
+	invoices := NewInvoiceService(db, NewLogNotifier(), NewUsageService(db, NewLogNotifier(), ""))
+
+	// A custom role with less than org_user, which the old role name check let through
+	junior := models.Role{
+		OrganizationID: &orgID,
+		Name:           "Junior",
+		Permissions: models.RolePermissions{
+			models.ResourceInvoices: {models.PermissionCreate, models.PermissionRead},
+		},
+	}
+	if err := db.Create(&junior).Error; err != nil {
+		t.Fatal(err)
+	}
+	approver := models.Role{
+		OrganizationID: &orgID,
+		Name:           "Approver",
+		Permissions: models.RolePermissions{
+			models.ResourceInvoices: {models.PermissionCreate, models.PermissionRead, models.PermissionApprove},
+		},
+	}
+	if err := db.Create(&approver).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		creator  *models.User
+		required bool
+	}{
+		{"org_admin", owner, false},
+		{"org_user", newTestMember(t, db, org, systemRole(t, db, models.RoleOrgUser)), true},
+		{"custom role without approve", newTestMember(t, db, org, junior.ID), true},
+		{"custom role with approve", newTestMember(t, db, org, approver.ID), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := newTestInvoice(t, db, tt.creator, client, 50)
+			_, err := invoices.UpdateInvoiceStatus(AuditContext{UserID: tt.creator.ID.String()},
+				invoice.ID.String(), orgID, models.InvoiceStatusSent)
+			if tt.required && !errors.Is(err, ErrInvoiceApprovalRequired) {
+				t.Fatalf("err = %v, want ErrInvoiceApprovalRequired", err)
+			}
+			if !tt.required && err != nil {
+				t.Fatalf("sending failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestApprovalNotDecidedByCreator(t *testing.T) {
+	db := testutil.DB(t)
+
+	owner, org := newTestOrganization(t, db, 5)
+	org.Settings.ApprovalSettings = models.ApprovalSettings{Enabled: true, AmountThreshold: 100}
+	if err := db.Model(org).Update("settings", org.Settings).Error; err != nil {
+		t.Fatal(err)
+	}
+	orgID := org.ID.String()
+	client := newTestClient(t, db, owner, org)
+	invoices := NewInvoiceService(db, NewLogNotifier(), NewUsageService(db, NewLogNotifier(), ""))
+
+	requester := newTestMember(t, db, org, systemRole(t, db, models.RoleOrgUser))
+	otherAdmin := newTestMember(t, db, org, systemRole(t, db, models.RoleOrgAdmin))
+
+	invoice := newTestInvoice(t, db, owner, client, 500)
+	id := invoice.ID.String()
+	if _, err := invoices.RequestApproval(AuditContext{UserID: requester.ID.String()}, id, orgID, ""); err != nil {
+		t.Fatalf("request approval: %v", err)
+	}
+
+	for _, user := range []*models.User{owner, requester} {
+		if _, err := invoices.ApproveInvoice(AuditContext{UserID: user.ID.String()}, id, orgID, ""); !errors.Is(err, ErrSelfApproval) {
+			t.Fatalf("approval by %s: got %v, want ErrSelfApproval", user.Email, err)
+		}
+	}
+	approval, err := invoices.ApproveInvoice(AuditContext{UserID: otherAdmin.ID.String()}, id, orgID, "")
+	if err != nil {
+		t.Fatalf("approval by another admin failed: %v", err)
+	}
+	if approval.Status != models.InvoiceApprovalApproved {
+		t.Fatalf("approval status = %s, want approved", approval.Status)
+	}
+}
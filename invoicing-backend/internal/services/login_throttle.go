@@ -0,0 +1,171 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// Failures allowed before an account or IP is locked
+	accountFailureThreshold = 5
+	ipFailureThreshold      = 20
+
+	// Counters reset once this long has passed since the last failure or lock
+	loginFailureWindow = 15 * time.Minute
+
+	// Lock duration doubles for every failure past the threshold, up to the maximum
+	baseLockDuration = time.Minute
+	maxLockDuration  = time.Hour
+)
+
+// LoginLockedError is returned when login is refused because of too many failures
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginThrottleService tracks failed logins in Postgres so limits hold across replicas
+type LoginThrottleService struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleService(db *gorm.DB) *LoginThrottleService {
+	return &LoginThrottleService{db: db}
+}
+
+// CheckLocked returns a LoginLockedError if either the account or the IP is locked
+func (s *LoginThrottleService) CheckLocked(email, clientIP string) error {
+	var throttles []models.LoginThrottle
+	if err := s.db.Where("(scope = ? AND key = ?) OR (scope = ? AND key = ?)",
+		models.LoginThrottleScopeAccount, normalizeEmail(email),
+		models.LoginThrottleScopeIP, clientIP).
+		Find(&throttles).Error; err != nil {
+		return fmt.Errorf("failed to check login throttle: %w", err)
+	}
+
+	var retryAfter time.Duration
+	for _, t := range throttles {
+		if t.IsLocked() {
+			if d := time.Until(*t.LockedUntil); d > retryAfter {
+				retryAfter = d
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure increments the account and IP counters and applies a lock once
+// a threshold is crossed. It reports whether the account became locked.
+func (s *LoginThrottleService) RecordFailure(email, clientIP string) (bool, error) {
+	accountLocked, err := s.recordFailure(models.LoginThrottleScopeAccount, normalizeEmail(email), accountFailureThreshold)
+	if err != nil {
+		return false, err
+	}
+
+	if clientIP != "" {
+		if _, err := s.recordFailure(models.LoginThrottleScopeIP, clientIP, ipFailureThreshold); err != nil {
+			return accountLocked, err
+		}
+	}
+
+	return accountLocked, nil
+}
+
+// ResetAccount clears the failure counter for an account (after a successful login or admin unlock)
+func (s *LoginThrottleService) ResetAccount(email string) error {
+	if err := s.db.Where("scope = ? AND key = ?", models.LoginThrottleScopeAccount, normalizeEmail(email)).
+		Delete(&models.LoginThrottle{}).Error; err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+	return nil
+}
+
+// ResetAccountIPs clears the IP locks caused by failed logins to the account, so an
+// unlocked user isn't still blocked on their own network. IPs that also failed
+// against other accounts in the meantime keep their lock, so unlocking one
+// account doesn't lift the limit on an IP guessing passwords across accounts.
+func (s *LoginThrottleService) ResetAccountIPs(email string) error {
+	// An IP lock can outlast the failure window by up to the maximum lock duration
+	since := time.Now().Add(-(loginFailureWindow + maxLockDuration))
+
+	var ips []string
+	if err := s.db.Model(&models.LoginAttempt{}).
+		Where("succeeded = ? AND ip_address <> '' AND created_at > ?", false, since).
+		Group("ip_address").
+		Having("bool_and(email = ?)", normalizeEmail(email)).
+		Pluck("ip_address", &ips).Error; err != nil {
+		return fmt.Errorf("failed to find login failures: %w", err)
+	}
+	if len(ips) == 0 {
+		return nil
+	}
+
+	if err := s.db.Where("scope = ? AND key IN ?", models.LoginThrottleScopeIP, ips).
+		Delete(&models.LoginThrottle{}).Error; err != nil {
+		return fmt.Errorf("failed to reset login throttle: %w", err)
+	}
+	return nil
+}
+
+func (s *LoginThrottleService) recordFailure(scope, key string, threshold int) (bool, error) {
+	var throttle models.LoginThrottle
+
+	// Atomic upsert so concurrent failures on different replicas are all counted
+	if err := s.db.Raw(`
+		INSERT INTO login_throttles (scope, key, failure_count, first_failure_at, last_failure_at)
+		VALUES (?, ?, 1, NOW(), NOW())
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failure_count = CASE
+				WHEN GREATEST(login_throttles.last_failure_at, COALESCE(login_throttles.locked_until, login_throttles.last_failure_at)) < NOW() - make_interval(secs => ?)
+				THEN 1 ELSE login_throttles.failure_count + 1 END,
+			first_failure_at = CASE
+				WHEN GREATEST(login_throttles.last_failure_at, COALESCE(login_throttles.locked_until, login_throttles.last_failure_at)) < NOW() - make_interval(secs => ?)
+				THEN NOW() ELSE login_throttles.first_failure_at END,
+			last_failure_at = NOW()
+		RETURNING scope, key, failure_count, first_failure_at, last_failure_at, locked_until`,
+		scope, key, loginFailureWindow.Seconds(), loginFailureWindow.Seconds()).
+		Scan(&throttle).Error; err != nil {
+		return false, fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	if throttle.FailureCount < threshold {
+		return false, nil
+	}
+
+	lockedUntil := time.Now().Add(lockDuration(throttle.FailureCount - threshold))
+	if err := s.db.Model(&models.LoginThrottle{}).
+		Where("scope = ? AND key = ?", scope, key).
+		Update("locked_until", lockedUntil).Error; err != nil {
+		return false, fmt.Errorf("failed to lock login: %w", err)
+	}
+
+	return true, nil
+}
+
+// lockDuration returns the exponential backoff for the nth failure past the threshold
+func lockDuration(excess int) time.Duration {
+	if excess > 10 {
+		return maxLockDuration
+	}
+	d := time.Duration(float64(baseLockDuration) * math.Pow(2, float64(excess)))
+	if d > maxLockDuration {
+		return maxLockDuration
+	}
+	return d
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
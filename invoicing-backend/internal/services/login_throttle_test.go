@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+func TestLockDuration(t *testing.T) {
+	tests := []struct {
+		excess int
+		want   time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{5, 32 * time.Minute},
+		{6, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := lockDuration(tt.excess); got != tt.want {
+			t.Errorf("lockDuration(%d) = %s, want %s", tt.excess, got, tt.want)
+		}
+	}
+}
+
+// lockIP stores a locked IP throttle and the failed attempts that caused it
+func lockIP(t *testing.T, db *gorm.DB, ip string, emails ...string) {
+	t.Helper()
+
+	for _, email := range emails {
+		if err := db.Create(&models.LoginAttempt{
+			Email:         email,
+			IPAddress:     ip,
+			FailureReason: models.LoginFailureInvalidCredentials,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lockedUntil := time.Now().Add(time.Hour)
+	if err := db.Create(&models.LoginThrottle{
+		Scope:          models.LoginThrottleScopeIP,
+		Key:            ip,
+		FailureCount:   ipFailureThreshold,
+		FirstFailureAt: time.Now(),
+		LastFailureAt:  time.Now(),
+		LockedUntil:    &lockedUntil,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("ip_address = ?", ip).Delete(&models.LoginAttempt{})
+		db.Where("scope = ? AND key = ?", models.LoginThrottleScopeIP, ip).Delete(&models.LoginThrottle{})
+	})
+}
+
+func TestResetAccountIPsOnlyClearsIPsTargetingTheAccount(t *testing.T) {
+	db := testutil.DB(t)
+	throttle := NewLoginThrottleService(db)
+
+	email := uniqueEmail("throttle.example.com")
+	ownIP := "2001:db8::" + uuid.NewString()[:4]
+	sharedIP := "2001:db8:1::" + uuid.NewString()[:4]
+	lockIP(t, db, ownIP, email, email)
+	lockIP(t, db, sharedIP, email, uniqueEmail("throttle.example.com"))
+
+	if err := throttle.ResetAccountIPs(email); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+
+	if err := throttle.CheckLocked(uniqueEmail("throttle.example.com"), ownIP); err != nil {
+		t.Fatalf("IP that only failed against the account is still locked: %v", err)
+	}
+	if err := throttle.CheckLocked(uniqueEmail("throttle.example.com"), sharedIP); err == nil {
+		t.Fatal("IP that failed against other accounts was unlocked")
+	}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMemberNotFound = errors.New("member not found")
+	ErrLastOrgAdmin   = errors.New("organization must keep at least one org_admin")
+)
+
+type MemberService struct {
+	db    *gorm.DB
+	authz *AuthzCache
+}
+
+func NewMemberService(db *gorm.DB, authz *AuthzCache) *MemberService {
+	return &MemberService{db: db, authz: authz}
+}
+
+// Member is a user's membership in an organization
+type Member struct {
+	UserID     string    `json:"user_id"`
+	Email      string    `json:"email"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	Role       string    `json:"role"`
+	RoleID     string    `json:"role_id"`
+	AssignedBy *string   `json:"assigned_by"`
+	AssignedAt time.Time `json:"assigned_at"`
+}
+
+type ChangeMemberRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type TransferAdminRequest struct {
+	UserID string `json:"user_id" validate:"required,uuid"`
+	// Role the current admin moves to after the transfer
+	NewRole string `json:"new_role"`
+}
+
+func (s *MemberService) GetMembers(organizationID string) ([]Member, error) {
+	var userOrgRoles []models.UserOrganizationRole
+	if err := s.db.Preload("User").Preload("Role").
+		Where("organization_id = ?", organizationID).
+		Order("assigned_at ASC").Find(&userOrgRoles).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch members: %w", err)
+	}
+
+	members := make([]Member, 0, len(userOrgRoles))
+	for _, uor := range userOrgRoles {
+		members = append(members, toMember(uor))
+	}
+	return members, nil
+}
+
+// ChangeMemberRole assigns a new role to a member. The actor may only grant roles
+// within their own permissions and may not modify members who outrank them.
+func (s *MemberService) ChangeMemberRole(audit AuditContext, organizationID string, actorRole models.Role, memberID, roleName string) (*Member, error) {
+	role, err := findAssignableRole(s.db, organizationID, roleName, actorRole)
+	if err != nil {
+		return nil, err
+	}
+
+	var member Member
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		uor, err := getMembership(tx, organizationID, memberID)
+		if err != nil {
+			return err
+		}
+
+		if !uor.Role.Permissions.IsSubsetOf(actorRole.Permissions) {
+			return ErrRoleExceedsPermissions
+		}
+
+		if uor.Role.Name == models.RoleOrgAdmin && role.Name != models.RoleOrgAdmin {
+			if err := ensureAnotherOrgAdmin(tx, organizationID, memberID); err != nil {
+				return err
+			}
+		}
+
+		before := toMember(*uor)
+		if err := assignRole(tx, uor, role, audit.UserID); err != nil {
+			return err
+		}
+
+		member = toMember(*uor)
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionRoleChange,
+			models.AuditResourceMember, memberID, before, member)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateMembership(memberID, organizationID)
+	return &member, nil
+}
+
+// RemoveMember removes a user from the organization, never removing the last org_admin
+func (s *MemberService) RemoveMember(audit AuditContext, organizationID string, actorRole models.Role, memberID string) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		uor, err := getMembership(tx, organizationID, memberID)
+		if err != nil {
+			return err
+		}
+
+		if !uor.Role.Permissions.IsSubsetOf(actorRole.Permissions) {
+			return ErrRoleExceedsPermissions
+		}
+
+		if uor.Role.Name == models.RoleOrgAdmin {
+			if err := ensureAnotherOrgAdmin(tx, organizationID, memberID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&models.UserOrganizationRole{}, "id = ?", uor.ID).Error; err != nil {
+			return fmt.Errorf("failed to remove member: %w", err)
+		}
+
+		// Don't leave the removed user pointing at an organization they can't access
+		if err := tx.Model(&models.User{}).
+			Where("id = ? AND current_organization_id = ?", memberID, organizationID).
+			Update("current_organization_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to reset current organization: %w", err)
+		}
+
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionDelete,
+			models.AuditResourceMember, memberID, toMember(*uor), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.authz.InvalidateMembership(memberID, organizationID)
+	return nil
+}
+
+// TransferAdmin hands the actor's org_admin seat to another member in one transaction,
+// so the organization is never left without an admin
+func (s *MemberService) TransferAdmin(audit AuditContext, organizationID string, req *TransferAdminRequest) ([]Member, error) {
+	actorID := audit.UserID
+	newRoleName := req.NewRole
+	if newRoleName == "" {
+		newRoleName = models.RoleOrgUser
+	}
+
+	if req.UserID == actorID {
+		return nil, fmt.Errorf("cannot transfer admin to yourself")
+	}
+
+	var members []Member
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		actor, err := getMembership(tx, organizationID, actorID)
+		if err != nil {
+			return err
+		}
+		if actor.Role.Name != models.RoleOrgAdmin {
+			return fmt.Errorf("only an org_admin can transfer the admin role")
+		}
+
+		target, err := getMembership(tx, organizationID, req.UserID)
+		if err != nil {
+			return err
+		}
+
+		var adminRole, newRole models.Role
+		if err := tx.Where("name = ? AND organization_id IS NULL", models.RoleOrgAdmin).First(&adminRole).Error; err != nil {
+			return fmt.Errorf("role %q not found", models.RoleOrgAdmin)
+		}
+		if err := roleScope(tx, organizationID).Where("name = ?", newRoleName).First(&newRole).Error; err != nil {
+			return fmt.Errorf("role %q not found", newRoleName)
+		}
+		if newRole.Name == models.RolePlatformAdmin {
+			return ErrRoleExceedsPermissions
+		}
+
+		targetBefore, actorBefore := toMember(*target), toMember(*actor)
+
+		// Promote first so there is always an admin at every step
+		if err := assignRole(tx, target, &adminRole, actorID); err != nil {
+			return err
+		}
+		if err := assignRole(tx, actor, &newRole, actorID); err != nil {
+			return err
+		}
+
+		members = []Member{toMember(*target), toMember(*actor)}
+		if err := recordAuditEvent(tx, audit, organizationID, models.AuditActionRoleChange,
+			models.AuditResourceMember, req.UserID, targetBefore, members[0]); err != nil {
+			return err
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionRoleChange,
+			models.AuditResourceMember, actorID, actorBefore, members[1])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateMembership(req.UserID, organizationID)
+	s.authz.InvalidateMembership(actorID, organizationID)
+
+	return members, nil
+}
+
+func getMembership(tx *gorm.DB, organizationID, userID string) (*models.UserOrganizationRole, error) {
+	var uor models.UserOrganizationRole
+	if err := tx.Preload("User").Preload("Role").
+		Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		First(&uor).Error; err != nil {
+		return nil, ErrMemberNotFound
+	}
+	return &uor, nil
+}
+
+func assignRole(tx *gorm.DB, uor *models.UserOrganizationRole, role *models.Role, assignedBy string) error {
+	now := time.Now()
+	if err := tx.Model(&models.UserOrganizationRole{}).Where("id = ?", uor.ID).Updates(map[string]interface{}{
+		"role_id":     role.ID,
+		"assigned_by": assignedBy,
+		"assigned_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	uor.RoleID = role.ID
+	uor.Role = *role
+	uor.AssignedBy = &assignedBy
+	uor.AssignedAt = now
+	return nil
+}
+
+// ensureAnotherOrgAdmin returns ErrLastOrgAdmin unless someone other than userID is an org_admin
+func ensureAnotherOrgAdmin(tx *gorm.DB, organizationID, userID string) error {
+	var count int64
+	if err := tx.Model(&models.UserOrganizationRole{}).
+		Joins("JOIN roles ON roles.id = user_organization_roles.role_id").
+		Where("user_organization_roles.organization_id = ? AND user_organization_roles.user_id <> ? AND roles.name = ?",
+			organizationID, userID, models.RoleOrgAdmin).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if count == 0 {
+		return ErrLastOrgAdmin
+	}
+	return nil
+}
+
+// findAssignableRole looks up a role by name and checks the granter may assign it
+func findAssignableRole(db *gorm.DB, organizationID, roleName string, granterRole models.Role) (*models.Role, error) {
+	if roleName == models.RolePlatformAdmin {
+		return nil, ErrRoleExceedsPermissions
+	}
+
+	var role models.Role
+	if err := roleScope(db, organizationID).Where("name = ?", roleName).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("role %q not found", roleName)
+	}
+
+	if !role.Permissions.IsSubsetOf(granterRole.Permissions) {
+		return nil, ErrRoleExceedsPermissions
+	}
+
+	return &role, nil
+}
+
+func toMember(uor models.UserOrganizationRole) Member {
+	return Member{
+		UserID:     uor.UserID,
+		Email:      uor.User.Email,
+		FirstName:  uor.User.FirstName,
+		LastName:   uor.User.LastName,
+		Role:       uor.Role.Name,
+		RoleID:     uor.RoleID,
+		AssignedBy: uor.AssignedBy,
+		AssignedAt: uor.AssignedAt,
+	}
+}
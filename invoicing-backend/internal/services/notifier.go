@@ -0,0 +1,54 @@
+package services
+
+import (
+	"log"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Notification is a message addressed to a single recipient
+type Notification struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers notifications to users. Implementations may send email,
+// post to chat integrations, etc.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// LogNotifier writes notifications to the application log. It is the default
+// until a delivery provider is configured.
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(notification Notification) error {
+	log.Printf("notification to=%s subject=%q body=%q", notification.To, notification.Subject, notification.Body)
+	return nil
+}
+
+// notifyOrganizationAdmins sends a notification to each admin of the organization.
+// Failures are logged.
+func notifyOrganizationAdmins(db *gorm.DB, notifier Notifier, organizationID, subject, body string) {
+	var memberships []models.UserOrganizationRole
+	if err := db.Preload("User").Preload("Role").
+		Where("organization_id = ?", organizationID).Find(&memberships).Error; err != nil {
+		log.Printf("failed to load admins of organization %s: %v", organizationID, err)
+		return
+	}
+
+	for _, m := range memberships {
+		if m.Role.Name != models.RoleOrgAdmin {
+			continue
+		}
+		if err := notifier.Notify(Notification{To: m.User.Email, Subject: subject, Body: body}); err != nil {
+			log.Printf("failed to notify admin %s of organization %s: %v", m.UserID, organizationID, err)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcStatePurpose     = "oidc_state"
+	oidcLinkStatePurpose = "oidc_link_state"
+)
+
+var (
+	ErrSSONotConfigured   = errors.New("single sign-on is not configured for this organization")
+	ErrSSOEmailNotAllowed = errors.New("email domain is not allowed for this organization")
+	ErrSSOInvalidState    = errors.New("invalid or expired sso state")
+	ErrSSOLinkRequired    = errors.New("an account with this email already exists; sign in and link the identity from your account")
+	ErrSSOIdentityLinked  = errors.New("identity is already linked to another account")
+)
+
+type OIDCService struct {
+	db          *gorm.DB
+	authz       *AuthzCache
+	httpClient  *http.Client
+	redirectURL string
+	frontendURL string
+	lookupTXT   func(ctx context.Context, name string) ([]string, error)
+}
+
+// NewOIDCService creates the service. redirectURL is the public callback URL; link
+// flows are handed over to the frontend at frontendURL to be completed.
+func NewOIDCService(db *gorm.DB, authz *AuthzCache, redirectURL, frontendURL string) *OIDCService {
+	return &OIDCService{
+		db:          db,
+		authz:       authz,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		redirectURL: redirectURL,
+		frontendURL: frontendURL,
+		lookupTXT:   net.DefaultResolver.LookupTXT,
+	}
+}
+
+type SSOConfigRequest struct {
+	Issuer              string   `json:"issuer" validate:"required,url"`
+	ClientID            string   `json:"client_id" validate:"required,max=255"`
+	ClientSecret        string   `json:"client_secret" validate:"omitempty,max=500"`
+	AllowedEmailDomains []string `json:"allowed_email_domains" validate:"dive,fqdn"`
+	DefaultRole         string   `json:"default_role"`
+	Enabled             *bool    `json:"enabled"`
+}
+
+func (s *OIDCService) GetSSOConfig(organizationID string) (*models.OrganizationSSOConfig, error) {
+	var cfg models.OrganizationSSOConfig
+	if err := s.db.Preload("DefaultRole").
+		Where("organization_id = ?", organizationID).First(&cfg).Error; err != nil {
+		return nil, ErrSSONotConfigured
+	}
+	return &cfg, nil
+}
+
+// UpsertSSOConfig creates or replaces the organization's SSO configuration.
+// The issuer is contacted to make sure its metadata can be discovered.
+func (s *OIDCService) UpsertSSOConfig(ctx context.Context, organizationID string, req *SSOConfigRequest) (*models.OrganizationSSOConfig, error) {
+	if err := validateIssuerURL(req.Issuer); err != nil {
+		return nil, err
+	}
+
+	if _, err := discoverOIDCProvider(ctx, s.httpClient, req.Issuer); err != nil {
+		return nil, err
+	}
+
+	roleName := req.DefaultRole
+	if roleName == "" {
+		roleName = models.RoleOrgUser
+	}
+	if roleName == models.RolePlatformAdmin {
+		return nil, fmt.Errorf("platform_admin cannot be used as the default sso role")
+	}
+
+	var role models.Role
+	if err := roleScope(s.db, organizationID).Where("name = ?", roleName).First(&role).Error; err != nil {
+		return nil, fmt.Errorf("role %q not found", roleName)
+	}
+
+	cfg, err := s.GetSSOConfig(organizationID)
+	if err != nil {
+		if req.ClientSecret == "" {
+			return nil, fmt.Errorf("client_secret is required")
+		}
+		cfg = &models.OrganizationSSOConfig{OrganizationID: organizationID, Enabled: true}
+	}
+
+	cfg.Issuer = strings.TrimSuffix(req.Issuer, "/")
+	cfg.ClientID = req.ClientID
+	if req.ClientSecret != "" {
+		cfg.ClientSecret = req.ClientSecret
+	}
+	cfg.AllowedEmailDomains = normalizeDomains(req.AllowedEmailDomains)
+	cfg.DefaultRoleID = role.ID
+	cfg.DefaultRole = role
+	if req.Enabled != nil {
+		cfg.Enabled = *req.Enabled
+	}
+
+	if err := s.db.Omit("DefaultRole", "Organization").Save(cfg).Error; err != nil {
+		return nil, fmt.Errorf("failed to save sso config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func (s *OIDCService) DeleteSSOConfig(organizationID string) error {
+	result := s.db.Where("organization_id = ?", organizationID).Delete(&models.OrganizationSSOConfig{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete sso config: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSSONotConfigured
+	}
+	return nil
+}
+
+// BeginLogin returns the identity provider URL the browser should be sent to.
+// PKCE verifier and nonce travel inside the signed state so that any replica
+// can complete the login.
+func (s *OIDCService) BeginLogin(ctx context.Context, organizationID string) (string, error) {
+	return s.authorizationURL(ctx, organizationID, oidcStatePurpose, nil)
+}
+
+// BeginLink starts linking an identity at the organization's provider to the
+// signed-in user. The flow is finished by CompleteLink with the same user.
+func (s *OIDCService) BeginLink(ctx context.Context, userID, organizationID string) (string, error) {
+	return s.authorizationURL(ctx, organizationID, oidcLinkStatePurpose, jwt.MapClaims{"user_id": userID})
+}
+
+func (s *OIDCService) authorizationURL(ctx context.Context, organizationID, purpose string, extra jwt.MapClaims) (string, error) {
+	cfg, err := s.GetSSOConfig(organizationID)
+	if err != nil || !cfg.Enabled {
+		return "", ErrSSONotConfigured
+	}
+
+	provider, err := discoverOIDCProvider(ctx, s.httpClient, cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	codeVerifier, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
+	nonce, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	claims := jwt.MapClaims{
+		"purpose":         purpose,
+		"organization_id": organizationID,
+		"code_verifier":   codeVerifier,
+		"nonce":           nonce,
+		"exp":             time.Now().Add(10 * time.Minute).Unix(),
+		"iat":             time.Now().Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	state, err := utils.SignClaims(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign state: %w", err)
+	}
+
+	return provider.authCodeURL(cfg.ClientID, s.redirectURL, state, nonce, codeVerifier), nil
+}
+
+// LinkCompletionURL returns the frontend page that finishes a link flow when state
+// belongs to one. The callback cannot tell who the browser is signed in as, so the
+// signed-in user has to post the code to CompleteLink themselves.
+func (s *OIDCService) LinkCompletionURL(code, state string) (string, bool) {
+	if _, err := utils.ParseClaims(state, oidcLinkStatePurpose); err != nil {
+		return "", false
+	}
+
+	params := url.Values{}
+	params.Set("code", code)
+	params.Set("state", state)
+	return strings.TrimSuffix(s.frontendURL, "/") + "/settings/sso/link?" + params.Encode(), true
+}
+
+// CompleteLogin exchanges the authorization code, verifies the ID token and
+// returns the local user, provisioning or linking it as needed
+func (s *OIDCService) CompleteLogin(ctx context.Context, code, state string) (*models.User, error) {
+	st, err := parseOIDCState(state, oidcStatePurpose)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, issuer, idClaims, err := s.authenticate(ctx, st, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.provisionUser(cfg, issuer, idClaims, "")
+}
+
+// CompleteLink finishes a flow started by BeginLink and links the identity to userID
+func (s *OIDCService) CompleteLink(ctx context.Context, userID, code, state string) (*models.User, error) {
+	st, err := parseOIDCState(state, oidcLinkStatePurpose)
+	if err != nil {
+		return nil, err
+	}
+	if st.userID == "" || st.userID != userID {
+		return nil, ErrSSOInvalidState
+	}
+
+	cfg, issuer, idClaims, err := s.authenticate(ctx, st, code)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.provisionUser(cfg, issuer, idClaims, userID)
+}
+
+// oidcState is the content of a verified state parameter
+type oidcState struct {
+	organizationID string
+	codeVerifier   string
+	nonce          string
+	userID         string
+}
+
+func parseOIDCState(state, purpose string) (*oidcState, error) {
+	claims, err := utils.ParseClaims(state, purpose)
+	if err != nil {
+		return nil, ErrSSOInvalidState
+	}
+
+	st := &oidcState{}
+	st.organizationID, _ = claims["organization_id"].(string)
+	st.codeVerifier, _ = claims["code_verifier"].(string)
+	st.nonce, _ = claims["nonce"].(string)
+	st.userID, _ = claims["user_id"].(string)
+	if st.organizationID == "" || st.codeVerifier == "" || st.nonce == "" {
+		return nil, ErrSSOInvalidState
+	}
+	return st, nil
+}
+
+// authenticate redeems the code and returns the verified ID token claims
+func (s *OIDCService) authenticate(ctx context.Context, st *oidcState, code string) (*models.OrganizationSSOConfig, string, *oidcIDTokenClaims, error) {
+	cfg, err := s.GetSSOConfig(st.organizationID)
+	if err != nil || !cfg.Enabled {
+		return nil, "", nil, ErrSSONotConfigured
+	}
+
+	provider, err := discoverOIDCProvider(ctx, s.httpClient, cfg.Issuer)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	token, err := provider.exchangeCode(ctx, cfg.ClientID, cfg.ClientSecret, s.redirectURL, code, st.codeVerifier)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	idClaims, err := provider.verifyIDToken(ctx, token.IDToken, cfg.ClientID, st.nonce)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	if idClaims.Email == "" {
+		return nil, "", nil, fmt.Errorf("identity provider did not return an email address")
+	}
+
+	if !cfg.IsEmailAllowed(idClaims.Email) {
+		return nil, "", nil, ErrSSOEmailNotAllowed
+	}
+
+	return cfg, provider.discovery.Issuer, idClaims, nil
+}
+
+// provisionUser finds the user linked to the external identity, or links or creates
+// one. linkUserID is set when a signed-in user asked to link the identity to their
+// account; without it an existing account with the same email is only linked when
+// canLinkByEmail allows it. The user is then added to the organization if needed.
+func (s *OIDCService) provisionUser(cfg *models.OrganizationSSOConfig, issuer string, claims *oidcIDTokenClaims, linkUserID string) (*models.User, error) {
+	var user *models.User
+	now := time.Now()
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", issuer, claims.Subject).First(&identity).Error
+		switch {
+		case err == nil:
+			if linkUserID != "" && identity.UserID != linkUserID {
+				return ErrSSOIdentityLinked
+			}
+			user = &models.User{}
+			if err := tx.First(user, "id = ?", identity.UserID).Error; err != nil {
+				return fmt.Errorf("linked user not found: %w", err)
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user, err = ssoUser(tx, cfg, claims, linkUserID)
+			if err != nil {
+				return err
+			}
+
+			identity = models.UserIdentity{
+				UserID:  user.ID.String(),
+				Issuer:  issuer,
+				Subject: claims.Subject,
+			}
+		default:
+			return fmt.Errorf("failed to look up identity: %w", err)
+		}
+
+		identity.Email = claims.Email
+		identity.LastLoginAt = &now
+		if err := tx.Save(&identity).Error; err != nil {
+			return fmt.Errorf("failed to link identity: %w", err)
+		}
+
+		return addSSOMember(tx, cfg, user, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateUser(user.ID.String())
+	return user, nil
+}
+
+// ssoUser returns the account a new identity gets linked to: the signed-in user
+// of a link flow, an existing account with the same email, or a new user
+func ssoUser(tx *gorm.DB, cfg *models.OrganizationSSOConfig, claims *oidcIDTokenClaims, linkUserID string) (*models.User, error) {
+	var user models.User
+	if linkUserID != "" {
+		if err := tx.First(&user, "id = ?", linkUserID).Error; err != nil {
+			return nil, fmt.Errorf("user not found: %w", err)
+		}
+		return &user, nil
+	}
+
+	email := strings.ToLower(claims.Email)
+	err := tx.Where("LOWER(email) = ?", email).First(&user).Error
+	switch {
+	case err == nil:
+		linkable, err := canLinkByEmail(tx, cfg, claims)
+		if err != nil {
+			return nil, err
+		}
+		if !linkable {
+			return nil, ErrSSOLinkRequired
+		}
+		return &user, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		firstName, lastName := oidcNames(claims)
+		user = models.User{
+			Email:                 email,
+			FirstName:             firstName,
+			LastName:              lastName,
+			CurrentOrganizationID: &cfg.OrganizationID,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+		return &user, nil
+	default:
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+}
+
+// canLinkByEmail reports whether a login may take over an existing account with the
+// same email. The provider must vouch for the email, the organization must restrict
+// SSO to a list of email domains, and it must have verified it owns the email's domain.
+// An IdP anyone can configure would otherwise be able to sign in as any user.
+func canLinkByEmail(tx *gorm.DB, cfg *models.OrganizationSSOConfig, claims *oidcIDTokenClaims) (bool, error) {
+	if !claims.EmailVerified || len(cfg.AllowedEmailDomains) == 0 || !cfg.IsEmailAllowed(claims.Email) {
+		return false, nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.OrganizationSSODomain{}).
+		Where("organization_id = ? AND LOWER(domain) = ? AND verified_at IS NOT NULL", cfg.OrganizationID, models.EmailDomain(claims.Email)).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check sso domain: %w", err)
+	}
+	return count > 0, nil
+}
+
+// addSSOMember adds the user to the organization with the SSO default role when
+// a seat is free. A pending invitation for the user's email already holds a seat,
+// so it is accepted, with its role, instead of taking another one.
+func addSSOMember(tx *gorm.DB, cfg *models.OrganizationSSOConfig, user *models.User, now time.Time) error {
+	var count int64
+	if err := tx.Model(&models.UserOrganizationRole{}).
+		Where("user_id = ? AND organization_id = ?", user.ID.String(), cfg.OrganizationID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check membership: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	// Serialize seat checks for the organization
+	if err := lockOrganization(tx, cfg.OrganizationID); err != nil {
+		return err
+	}
+
+	var invitation models.OrganizationInvitation
+	err := tx.Where("organization_id = ? AND LOWER(email) = ? AND status = ? AND expires_at > ?",
+		cfg.OrganizationID, strings.ToLower(user.Email), models.InvitationStatusPending, now).
+		First(&invitation).Error
+	invited := err == nil
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to look up invitation: %w", err)
+	}
+
+	roleID := cfg.DefaultRoleID
+	if invited {
+		roleID = invitation.RoleID
+		err = checkMemberSeatAvailable(tx, cfg.OrganizationID)
+	} else {
+		err = checkSeatAvailable(tx, cfg.OrganizationID)
+	}
+	if err != nil {
+		return err
+	}
+
+	userOrgRole := models.UserOrganizationRole{
+		UserID:         user.ID.String(),
+		OrganizationID: cfg.OrganizationID,
+		RoleID:         roleID,
+		AssignedAt:     now,
+	}
+	if err := tx.Create(&userOrgRole).Error; err != nil {
+		return fmt.Errorf("failed to add user to organization: %w", err)
+	}
+
+	if invited {
+		acceptedBy := user.ID.String()
+		if err := tx.Model(&invitation).Updates(map[string]interface{}{
+			"status":      models.InvitationStatusAccepted,
+			"accepted_at": now,
+			"accepted_by": acceptedBy,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update invitation: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func oidcNames(claims *oidcIDTokenClaims) (string, string) {
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && claims.Name != "" {
+		parts := strings.SplitN(claims.Name, " ", 2)
+		firstName = parts[0]
+		if len(parts) > 1 && lastName == "" {
+			lastName = parts[1]
+		}
+	}
+	if firstName == "" {
+		firstName = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	if lastName == "" {
+		lastName = "-"
+	}
+	return firstName, lastName
+}
+
+// validateIssuerURL requires https, except for loopback hosts used by local mock IdPs
+func validateIssuerURL(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid issuer URL")
+	}
+
+	host := u.Hostname()
+	if u.Scheme == "https" || (u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1")) {
+		return nil
+	}
+
+	return fmt.Errorf("issuer must use https")
+}
+
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" {
+			normalized = append(normalized, d)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSSODomainNotFound    = errors.New("sso domain not found")
+	ErrSSODomainTaken       = errors.New("domain is already verified by another organization")
+	ErrSSODomainNotVerified = errors.New("verification TXT record not found")
+)
+
+type SSODomainRequest struct {
+	Domain string `json:"domain" validate:"required,fqdn,max=253"`
+}
+
+// SSODomainStatus describes an email domain claimed for SSO and how to verify it
+type SSODomainStatus struct {
+	ID                string     `json:"id"`
+	Domain            string     `json:"domain"`
+	Verified          bool       `json:"verified"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`
+	VerificationName  string     `json:"verification_record_name,omitempty"`
+	VerificationValue string     `json:"verification_record_value,omitempty"`
+}
+
+func (s *OIDCService) ListSSODomains(organizationID string) ([]SSODomainStatus, error) {
+	var domains []models.OrganizationSSODomain
+	if err := s.db.Where("organization_id = ?", organizationID).
+		Order("domain").Find(&domains).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch sso domains: %w", err)
+	}
+
+	statuses := make([]SSODomainStatus, 0, len(domains))
+	for i := range domains {
+		statuses = append(statuses, *ssoDomainStatus(&domains[i]))
+	}
+	return statuses, nil
+}
+
+// AddSSODomain claims an email domain for the organization. It only counts as owned
+// once VerifySSODomain finds the returned TXT record.
+func (s *OIDCService) AddSSODomain(organizationID, domain string) (*SSODomainStatus, error) {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+
+	var existing models.OrganizationSSODomain
+	err := s.db.Where("organization_id = ? AND LOWER(domain) = ?", organizationID, domain).First(&existing).Error
+	if err == nil {
+		return ssoDomainStatus(&existing), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to look up sso domain: %w", err)
+	}
+
+	token, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	ssoDomain := models.OrganizationSSODomain{
+		OrganizationID:    organizationID,
+		Domain:            domain,
+		VerificationToken: token,
+	}
+	if err := s.db.Omit("Organization").Create(&ssoDomain).Error; err != nil {
+		return nil, fmt.Errorf("failed to add sso domain: %w", err)
+	}
+
+	return ssoDomainStatus(&ssoDomain), nil
+}
+
+// VerifySSODomain looks up the verification TXT record and marks the domain as owned when it matches
+func (s *OIDCService) VerifySSODomain(ctx context.Context, organizationID, domainID string) (*SSODomainStatus, error) {
+	ssoDomain, err := s.getSSODomain(organizationID, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if ssoDomain.IsVerified() {
+		return ssoDomainStatus(ssoDomain), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	records, err := s.lookupTXT(ctx, customDomainRecordPrefix+ssoDomain.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSODomainNotVerified, err)
+	}
+
+	found := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == ssoDomain.VerificationToken {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrSSODomainNotVerified
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.OrganizationSSODomain{}).
+			Where("LOWER(domain) = ? AND organization_id <> ? AND verified_at IS NOT NULL", ssoDomain.Domain, organizationID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check sso domain: %w", err)
+		}
+		if count > 0 {
+			return ErrSSODomainTaken
+		}
+
+		if err := tx.Model(ssoDomain).Update("verified_at", now).Error; err != nil {
+			return fmt.Errorf("failed to verify sso domain: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ssoDomain.VerifiedAt = &now
+	return ssoDomainStatus(ssoDomain), nil
+}
+
+func (s *OIDCService) RemoveSSODomain(organizationID, domainID string) error {
+	result := s.db.Where("id = ? AND organization_id = ?", domainID, organizationID).Delete(&models.OrganizationSSODomain{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove sso domain: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSSODomainNotFound
+	}
+	return nil
+}
+
+func (s *OIDCService) getSSODomain(organizationID, domainID string) (*models.OrganizationSSODomain, error) {
+	var ssoDomain models.OrganizationSSODomain
+	if err := s.db.Where("id = ? AND organization_id = ?", domainID, organizationID).First(&ssoDomain).Error; err != nil {
+		return nil, ErrSSODomainNotFound
+	}
+	return &ssoDomain, nil
+}
+
+func ssoDomainStatus(d *models.OrganizationSSODomain) *SSODomainStatus {
+	status := &SSODomainStatus{
+		ID:         d.ID,
+		Domain:     d.Domain,
+		Verified:   d.IsVerified(),
+		VerifiedAt: d.VerifiedAt,
+	}
+	if !status.Verified {
+		status.VerificationName = customDomainRecordPrefix + d.Domain
+		status.VerificationValue = d.VerificationToken
+	}
+	return status
+}
@@ -0,0 +1,392 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/oidcfake"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+const testRedirectURL = "http://localhost:8080/api/auth/oidc/callback"
+
+func newFakeIdP(t *testing.T, identity oidcfake.Identity) (*oidcfake.Provider, *httptest.Server) {
+	t.Helper()
+
+	idp, err := oidcfake.New("test-client", "test-secret", identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(idp.Handler())
+	t.Cleanup(server.Close)
+	return idp, server
+}
+
+// authorize plays the browser: it opens the authorization URL and returns the
+// code and state the provider redirects back with
+func authorize(t *testing.T, authURL string) (string, string) {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorization returned %d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestOIDCProviderAuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	_, server := newFakeIdP(t, oidcfake.Identity{Subject: "sub-1", Email: "Ada@Example.com", EmailVerified: true, Name: "Ada Lovelace"})
+
+	provider, err := discoverOIDCProvider(ctx, server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+
+	code, state := authorize(t, provider.authCodeURL("test-client", testRedirectURL, "state-1", "nonce-1", "verifier-1"))
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+
+	token, err := provider.exchangeCode(ctx, "test-client", "test-secret", testRedirectURL, code, "verifier-1")
+	if err != nil {
+		t.Fatalf("code exchange failed: %v", err)
+	}
+
+	claims, err := provider.verifyIDToken(ctx, token.IDToken, "test-client", "nonce-1")
+	if err != nil {
+		t.Fatalf("id token rejected: %v", err)
+	}
+	if claims.Subject != "sub-1" || claims.Email != "Ada@Example.com" || !claims.EmailVerified {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+	if first, last := oidcNames(claims); first != "Ada" || last != "Lovelace" {
+		t.Fatalf("names = %q %q", first, last)
+	}
+}
+
+func TestOIDCProviderRejectsInvalidResponses(t *testing.T) {
+	ctx := context.Background()
+	_, server := newFakeIdP(t, oidcfake.Identity{Subject: "sub-1", Email: "ada@example.com"})
+
+	provider, err := discoverOIDCProvider(ctx, server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	newCode := func() string {
+		code, _ := authorize(t, provider.authCodeURL("test-client", testRedirectURL, "state", "nonce", "verifier"))
+		return code
+	}
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		if _, err := provider.exchangeCode(ctx, "test-client", "test-secret", testRedirectURL, newCode(), "other"); err == nil {
+			t.Fatal("exchange succeeded with the wrong code verifier")
+		}
+	})
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		if _, err := provider.exchangeCode(ctx, "test-client", "wrong", testRedirectURL, newCode(), "verifier"); err == nil {
+			t.Fatal("exchange succeeded with the wrong client secret")
+		}
+	})
+
+	t.Run("code reuse", func(t *testing.T) {
+		code := newCode()
+		if _, err := provider.exchangeCode(ctx, "test-client", "test-secret", testRedirectURL, code, "verifier"); err != nil {
+			t.Fatalf("first exchange failed: %v", err)
+		}
+		if _, err := provider.exchangeCode(ctx, "test-client", "test-secret", testRedirectURL, code, "verifier"); err == nil {
+			t.Fatal("code was accepted twice")
+		}
+	})
+
+	token, err := provider.exchangeCode(ctx, "test-client", "test-secret", testRedirectURL, newCode(), "verifier")
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		if _, err := provider.verifyIDToken(ctx, token.IDToken, "test-client", "other"); err == nil {
+			t.Fatal("id token accepted with another nonce")
+		}
+	})
+
+	t.Run("audience mismatch", func(t *testing.T) {
+		if _, err := provider.verifyIDToken(ctx, token.IDToken, "other-client", "nonce"); err == nil {
+			t.Fatal("id token accepted for another client")
+		}
+	})
+
+	t.Run("issuer mismatch", func(t *testing.T) {
+		// The fake names itself after the host it's reached on, so this is another issuer
+		otherIssuer := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+		other, err := discoverOIDCProvider(ctx, server.Client(), otherIssuer)
+		if err != nil {
+			t.Fatalf("discovery failed: %v", err)
+		}
+		if _, err := other.verifyIDToken(ctx, token.IDToken, "test-client", "nonce"); err == nil {
+			t.Fatal("id token accepted from another issuer")
+		}
+	})
+}
+
+// newSSOOrganization creates an organization whose SSO uses the fake provider
+func newSSOOrganization(t *testing.T, db *gorm.DB, service *OIDCService, issuer string, userLimit int, allowedDomains ...string) *models.Organization {
+	t.Helper()
+
+	_, org := newTestOrganization(t, db, userLimit)
+	if _, err := service.UpsertSSOConfig(context.Background(), org.ID.String(), &SSOConfigRequest{
+		Issuer:              issuer,
+		ClientID:            "test-client",
+		ClientSecret:        "test-secret",
+		AllowedEmailDomains: allowedDomains,
+	}); err != nil {
+		t.Fatalf("failed to configure sso: %v", err)
+	}
+	return org
+}
+
+func login(t *testing.T, service *OIDCService, organizationID string) (*models.User, error) {
+	t.Helper()
+
+	authURL, err := service.BeginLogin(context.Background(), organizationID)
+	if err != nil {
+		t.Fatalf("failed to begin login: %v", err)
+	}
+	code, state := authorize(t, authURL)
+	return service.CompleteLogin(context.Background(), code, state)
+}
+
+func TestSSOLoginProvisionsUserJustInTime(t *testing.T) {
+	db := testutil.DB(t)
+	email := uniqueEmail("jit.example.com")
+	_, server := newFakeIdP(t, oidcfake.Identity{Subject: "sub-" + email, Email: email, EmailVerified: true})
+	service := NewOIDCService(db, newTestAuthz(db), testRedirectURL, "http://localhost:3000")
+	org := newSSOOrganization(t, db, service, server.URL, 5)
+
+	user, err := login(t, service, org.ID.String())
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = ?", user.ID) })
+
+	var membership models.UserOrganizationRole
+	if err := db.Preload("Role").Where("user_id = ? AND organization_id = ?", user.ID.String(), org.ID.String()).
+		First(&membership).Error; err != nil {
+		t.Fatalf("user was not added to the organization: %v", err)
+	}
+	if membership.Role.Name != models.RoleOrgUser {
+		t.Fatalf("role = %q, want %q", membership.Role.Name, models.RoleOrgUser)
+	}
+
+	again, err := login(t, service, org.ID.String())
+	if err != nil {
+		t.Fatalf("second login failed: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Fatalf("second login returned another user")
+	}
+}
+
+func TestSSOLoginOnlyLinksExistingAccountsOnVerifiedDomains(t *testing.T) {
+	db := testutil.DB(t)
+	service := NewOIDCService(db, newTestAuthz(db), testRedirectURL, "http://localhost:3000")
+
+	tests := []struct {
+		name            string
+		restrictDomains bool
+		verifyDomain    bool
+		emailVerified   bool
+		wantLinked      bool
+	}{
+		{name: "any domain allowed", verifyDomain: true, emailVerified: true},
+		{name: "domain not verified", restrictDomains: true, emailVerified: true},
+		{name: "email not verified by provider", restrictDomains: true, verifyDomain: true},
+		{name: "verified domain", restrictDomains: true, verifyDomain: true, emailVerified: true, wantLinked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A domain can only be verified by one organization
+			domain := "corp-" + uuid.NewString()[:8] + ".example.com"
+			var allowedDomains []string
+			if tt.restrictDomains {
+				allowedDomains = []string{domain}
+			}
+
+			existing := newTestUser(t, db, uniqueEmail(domain))
+			_, server := newFakeIdP(t, oidcfake.Identity{Subject: "sub-" + existing.Email, Email: existing.Email, EmailVerified: tt.emailVerified})
+			org := newSSOOrganization(t, db, service, server.URL, 5, allowedDomains...)
+
+			if tt.verifyDomain {
+				now := time.Now()
+				if err := db.Omit("Organization").Create(&models.OrganizationSSODomain{
+					OrganizationID:    org.ID.String(),
+					Domain:            domain,
+					VerificationToken: "token",
+					VerifiedAt:        &now,
+				}).Error; err != nil {
+					t.Fatalf("failed to verify domain: %v", err)
+				}
+			}
+
+			user, err := login(t, service, org.ID.String())
+			if !tt.wantLinked {
+				if !errors.Is(err, ErrSSOLinkRequired) {
+					t.Fatalf("err = %v, want ErrSSOLinkRequired", err)
+				}
+				var count int64
+				db.Model(&models.UserIdentity{}).Where("user_id = ?", existing.ID.String()).Count(&count)
+				if count != 0 {
+					t.Fatal("identity was linked to the existing account")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			if user.ID != existing.ID {
+				t.Fatal("login was not linked to the existing account")
+			}
+		})
+	}
+}
+
+func TestSSOLinkFlow(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+	service := NewOIDCService(db, newTestAuthz(db), testRedirectURL, "http://localhost:3000")
+
+	owner, _ := newTestOrganization(t, db, 5)
+	_, server := newFakeIdP(t, oidcfake.Identity{Subject: "sub-" + owner.Email, Email: owner.Email, EmailVerified: true})
+	org := newSSOOrganization(t, db, service, server.URL, 5)
+	other := newTestUser(t, db, uniqueEmail("other.example.com"))
+
+	if _, err := login(t, service, org.ID.String()); !errors.Is(err, ErrSSOLinkRequired) {
+		t.Fatalf("login before linking: err = %v, want ErrSSOLinkRequired", err)
+	}
+
+	authURL, err := service.BeginLink(ctx, owner.ID.String(), org.ID.String())
+	if err != nil {
+		t.Fatalf("failed to begin link: %v", err)
+	}
+	code, state := authorize(t, authURL)
+
+	if linkURL, ok := service.LinkCompletionURL(code, state); !ok || linkURL == "" {
+		t.Fatal("callback did not hand the link flow to the frontend")
+	}
+
+	if _, err := service.CompleteLink(ctx, other.ID.String(), code, state); !errors.Is(err, ErrSSOInvalidState) {
+		t.Fatalf("link by another user: err = %v, want ErrSSOInvalidState", err)
+	}
+	if _, err := service.CompleteLogin(ctx, code, state); !errors.Is(err, ErrSSOInvalidState) {
+		t.Fatalf("link state used for login: err = %v, want ErrSSOInvalidState", err)
+	}
+
+	linked, err := service.CompleteLink(ctx, owner.ID.String(), code, state)
+	if err != nil {
+		t.Fatalf("link failed: %v", err)
+	}
+	if linked.ID != owner.ID {
+		t.Fatal("identity was linked to another user")
+	}
+
+	user, err := login(t, service, org.ID.String())
+	if err != nil {
+		t.Fatalf("login after linking failed: %v", err)
+	}
+	if user.ID != owner.ID {
+		t.Fatal("login after linking returned another user")
+	}
+
+	// The identity can't be moved to another account
+	authURL, err = service.BeginLink(ctx, other.ID.String(), org.ID.String())
+	if err != nil {
+		t.Fatalf("failed to begin link: %v", err)
+	}
+	code, state = authorize(t, authURL)
+	if _, err := service.CompleteLink(ctx, other.ID.String(), code, state); !errors.Is(err, ErrSSOIdentityLinked) {
+		t.Fatalf("relink: err = %v, want ErrSSOIdentityLinked", err)
+	}
+}
+
+func TestSSOLoginChecksSeatLimit(t *testing.T) {
+	db := testutil.DB(t)
+	email := uniqueEmail("seats.example.com")
+	_, server := newFakeIdP(t, oidcfake.Identity{Subject: "sub-" + email, Email: email, EmailVerified: true})
+	service := NewOIDCService(db, newTestAuthz(db), testRedirectURL, "http://localhost:3000")
+
+	// The owner takes the only seat
+	org := newSSOOrganization(t, db, service, server.URL, 1)
+
+	_, err := login(t, service, org.ID.String())
+	if !errors.Is(err, ErrSeatLimitReached) {
+		t.Fatalf("err = %v, want ErrSeatLimitReached", err)
+	}
+
+	var count int64
+	db.Model(&models.User{}).Where("email = ?", email).Count(&count)
+	if count != 0 {
+		t.Fatal("user was provisioned although no seat was free")
+	}
+
+	// A pending invitation already holds a seat and is accepted by the login
+	if err := db.Model(&models.Subscription{}).Where("organization_id = ?", org.ID).
+		Update("monthly_user_limit", 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	invitation := models.OrganizationInvitation{
+		OrganizationID: org.ID.String(),
+		Email:          email,
+		RoleID:         systemRole(t, db, models.RoleOrgViewer),
+		TokenHash:      "test",
+		Status:         models.InvitationStatusPending,
+		ExpiresAt:      time.Now().Add(time.Hour),
+		LastSentAt:     time.Now(),
+	}
+	if err := db.Omit("Organization", "Role", "InvitedByUser").Create(&invitation).Error; err != nil {
+		t.Fatalf("failed to create invitation: %v", err)
+	}
+
+	user, err := login(t, service, org.ID.String())
+	if err != nil {
+		t.Fatalf("invited login failed: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = ?", user.ID) })
+
+	var membership models.UserOrganizationRole
+	if err := db.Where("user_id = ? AND organization_id = ?", user.ID.String(), org.ID.String()).
+		First(&membership).Error; err != nil {
+		t.Fatalf("user was not added to the organization: %v", err)
+	}
+	if membership.RoleID != invitation.RoleID {
+		t.Fatal("member did not get the invited role")
+	}
+
+	db.First(&invitation, "id = ?", invitation.ID)
+	if invitation.Status != models.InvitationStatusAccepted {
+		t.Fatalf("invitation status = %q, want accepted", invitation.Status)
+	}
+}
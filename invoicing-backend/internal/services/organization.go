@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrSubdomainTaken       = errors.New("subdomain is already taken")
+	ErrSubdomainReserved    = errors.New("subdomain is reserved")
+	ErrInvalidSubdomain     = errors.New("subdomain must be 3-63 lowercase letters, digits or hyphens and cannot start or end with a hyphen")
+	ErrUnknownSettings      = errors.New("unknown settings section")
+)
+
+type OrganizationService struct {
+	db         *gorm.DB
+	authz      *AuthzCache
+	validator  *validator.Validate
+	baseDomain string
+	lookupTXT  func(ctx context.Context, name string) ([]string, error)
+}
+
+// NewOrganizationService creates the service. baseDomain is the parent domain of
+// organization subdomains (e.g. app.example.com), or empty when they aren't served.
+func NewOrganizationService(db *gorm.DB, authz *AuthzCache, baseDomain string) *OrganizationService {
+	return &OrganizationService{
+		db:         db,
+		authz:      authz,
+		validator:  validator.New(),
+		baseDomain: strings.ToLower(strings.TrimSuffix(baseDomain, ".")),
+		lookupTXT:  net.DefaultResolver.LookupTXT,
+	}
+}
+
+type CreateOrganizationRequest struct {
+	Name      string `json:"name" validate:"required,min=1,max=255"`
+	Subdomain string `json:"subdomain" validate:"omitempty,min=3,max=63,hostname_rfc1123"`
+}
+
+type UpdateOrganizationRequest struct {
+	Name      *string `json:"name" validate:"omitempty,min=1,max=255"`
+	Subdomain *string `json:"subdomain" validate:"omitempty,min=3,max=63,hostname_rfc1123"`
+}
+
+// UserOrganization is an organization as seen by one of its members
+type UserOrganization struct {
+	models.Organization
+	Role      string `json:"role"`
+	IsCurrent bool   `json:"is_current"`
+}
+
+// GetOrganizationsForUser lists every organization the user belongs to
+func (s *OrganizationService) GetOrganizationsForUser(userID string) ([]UserOrganization, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	var userOrgRoles []models.UserOrganizationRole
+	if err := s.db.Preload("Role").Preload("Organization.Subscription", "status = ?", models.SubscriptionStatusActive).
+		Where("user_id = ?", userID).
+		Order("assigned_at ASC").Find(&userOrgRoles).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch organizations: %w", err)
+	}
+
+	currentOrgID := user.GetDefaultOrganizationID()
+	organizations := make([]UserOrganization, 0, len(userOrgRoles))
+	for _, uor := range userOrgRoles {
+		organizations = append(organizations, UserOrganization{
+			Organization: uor.Organization,
+			Role:         uor.Role.Name,
+			IsCurrent:    uor.OrganizationID == currentOrgID,
+		})
+	}
+	return organizations, nil
+}
+
+func (s *OrganizationService) GetOrganizationByID(organizationID string) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.Preload("Subscription", "status = ?", models.SubscriptionStatusActive).
+		First(&org, "id = ?", organizationID).Error; err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	return &org, nil
+}
+
+// CreateOrganization creates an additional organization owned by the user,
+// with its own free subscription
+func (s *OrganizationService) CreateOrganization(userID string, req *CreateOrganizationRequest) (*models.Organization, error) {
+	var org *models.Organization
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if req.Subdomain != "" {
+			if err := ensureSubdomainAvailable(tx, req.Subdomain, ""); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		org, err = createOrganizationWithOwner(tx, userID, req.Name, req.Subdomain)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateUser(userID)
+	return org, nil
+}
+
+func (s *OrganizationService) UpdateOrganization(audit AuditContext, organizationID string, req *UpdateOrganizationRequest) (*models.Organization, error) {
+	org, err := s.GetOrganizationByID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Subdomain != nil {
+		subdomain := strings.ToLower(*req.Subdomain)
+		if subdomain != "" {
+			if err := ensureSubdomainAvailable(s.db, subdomain, organizationID); err != nil {
+				return nil, err
+			}
+			updates["subdomain"] = subdomain
+		} else {
+			updates["subdomain"] = nil
+		}
+	}
+
+	if len(updates) == 0 {
+		return org, nil
+	}
+
+	before := map[string]interface{}{"name": org.Name, "subdomain": org.Subdomain}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Organization{}).Where("id = ?", organizationID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update organization: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceOrganization, organizationID, before, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateOrganization(organizationID)
+	if req.Subdomain != nil {
+		s.authz.InvalidateSubdomain(org.Subdomain)
+		s.authz.InvalidateSubdomain(*req.Subdomain)
+	}
+
+	return s.GetOrganizationByID(org.ID.String())
+}
+
+// UpdateSettingsSection replaces one section of the organization settings after validating it
+func (s *OrganizationService) UpdateSettingsSection(audit AuditContext, organizationID, section string, payload json.RawMessage) (*models.OrganizationSettings, error) {
+	org, err := s.GetOrganizationByID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := org.Settings
+	var target interface{}
+	switch section {
+	case models.SettingsSectionCompanyAddress:
+		settings.CompanyAddress = models.CompanyAddress{}
+		target = &settings.CompanyAddress
+	case models.SettingsSectionBranding:
+		settings.BrandingSettings = models.BrandingSettings{}
+		target = &settings.BrandingSettings
+	case models.SettingsSectionInvoice:
+		settings.InvoiceSettings = models.InvoiceSettings{}
+		target = &settings.InvoiceSettings
+	case models.SettingsSectionNotification:
+		settings.NotificationSettings = models.NotificationSettings{}
+		target = &settings.NotificationSettings
+	case models.SettingsSectionApproval:
+		settings.ApprovalSettings = models.ApprovalSettings{}
+		target = &settings.ApprovalSettings
+	default:
+		return nil, ErrUnknownSettings
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(payload)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %w", section, err)
+	}
+
+	if err := s.validator.Struct(target); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Organization{}).Where("id = ?", organizationID).
+			Update("settings", settings).Error; err != nil {
+			return fmt.Errorf("failed to update settings: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceOrganizationSettings, organizationID, org.Settings, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateOrganization(organizationID)
+
+	return &settings, nil
+}
+
+// SetCurrentOrganization records the organization used when a request doesn't specify one
+func (s *OrganizationService) SetCurrentOrganization(userID, organizationID string) (*models.Organization, error) {
+	var count int64
+	if err := s.db.Model(&models.UserOrganizationRole{}).
+		Where("user_id = ? AND organization_id = ?", userID, organizationID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check membership: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrOrganizationNotFound
+	}
+
+	if err := s.db.Model(&models.User{}).Where("id = ?", userID).
+		Update("current_organization_id", organizationID).Error; err != nil {
+		return nil, fmt.Errorf("failed to set current organization: %w", err)
+	}
+	s.authz.InvalidateUser(userID)
+
+	return s.GetOrganizationByID(organizationID)
+}
+
+// createOrganizationWithOwner creates an organization, makes the user its org_admin
+// and starts a free subscription with the free plan's limits
+func createOrganizationWithOwner(tx *gorm.DB, userID, name, subdomain string) (*models.Organization, error) {
+	org := models.Organization{
+		Name:      strings.TrimSpace(name),
+		Subdomain: strings.ToLower(subdomain),
+	}
+
+	// An empty subdomain must be stored as NULL to keep the unique index usable
+	query := tx
+	if org.Subdomain == "" {
+		query = tx.Omit("Subdomain")
+	}
+	if err := query.Create(&org).Error; err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	var orgAdminRole models.Role
+	if err := tx.Where("name = ? AND organization_id IS NULL", models.RoleOrgAdmin).First(&orgAdminRole).Error; err != nil {
+		return nil, fmt.Errorf("role %q not found", models.RoleOrgAdmin)
+	}
+
+	userOrgRole := models.UserOrganizationRole{
+		UserID:         userID,
+		OrganizationID: org.ID.String(),
+		RoleID:         orgAdminRole.ID,
+		AssignedAt:     time.Now(),
+	}
+	if err := tx.Create(&userOrgRole).Error; err != nil {
+		return nil, fmt.Errorf("failed to assign user to organization: %w", err)
+	}
+
+	subscription := models.Subscription{
+		OrganizationID: org.ID.String(),
+		Status:         models.SubscriptionStatusActive,
+	}
+	if err := applyCatalogPlan(tx, &subscription, models.SubscriptionPlanFree); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&subscription).Error; err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+	org.Subscription = &subscription
+
+	return &org, nil
+}
+
+// subdomainPattern is a single DNS label, so a subdomain maps to exactly one host
+var subdomainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{1,61}[a-z0-9])$`)
+
+// ensureSubdomainAvailable checks the subdomain is well formed, not reserved
+// and not used by another organization
+func ensureSubdomainAvailable(db *gorm.DB, subdomain, exceptOrganizationID string) error {
+	if !subdomainPattern.MatchString(strings.ToLower(subdomain)) {
+		return ErrInvalidSubdomain
+	}
+	if models.IsReservedSubdomain(subdomain) {
+		return ErrSubdomainReserved
+	}
+
+	var count int64
+	query := db.Unscoped().Model(&models.Organization{}).Where("LOWER(subdomain) = ?", strings.ToLower(subdomain))
+	if exceptOrganizationID != "" {
+		query = query.Where("id <> ?", exceptOrganizationID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check subdomain: %w", err)
+	}
+	if count > 0 {
+		return ErrSubdomainTaken
+	}
+	return nil
+}
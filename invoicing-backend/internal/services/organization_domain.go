@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// customDomainRecordPrefix is prepended to a custom domain to form the TXT record proving ownership
+const customDomainRecordPrefix = "_invoicing-verification."
+
+var (
+	ErrInvalidCustomDomain     = errors.New("invalid custom domain")
+	ErrCustomDomainTaken       = errors.New("custom domain is already in use")
+	ErrCustomDomainNotSet      = errors.New("organization has no custom domain")
+	ErrCustomDomainNotVerified = errors.New("verification TXT record not found")
+)
+
+type CustomDomainRequest struct {
+	Domain string `json:"domain" validate:"required,fqdn,max=253"`
+}
+
+// SubdomainAvailability is the result of a subdomain availability check
+type SubdomainAvailability struct {
+	Subdomain string `json:"subdomain"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// CustomDomainStatus describes an organization's custom domain and how to verify it
+type CustomDomainStatus struct {
+	Domain            string     `json:"domain"`
+	Verified          bool       `json:"verified"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty"`
+	VerificationName  string     `json:"verification_record_name,omitempty"`
+	VerificationValue string     `json:"verification_record_value,omitempty"`
+}
+
+// CheckSubdomainAvailability reports whether a subdomain can be claimed
+func (s *OrganizationService) CheckSubdomainAvailability(subdomain string) (*SubdomainAvailability, error) {
+	subdomain = strings.ToLower(strings.TrimSpace(subdomain))
+	result := &SubdomainAvailability{Subdomain: subdomain, Available: true}
+
+	err := ensureSubdomainAvailable(s.db, subdomain, "")
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrInvalidSubdomain), errors.Is(err, ErrSubdomainReserved), errors.Is(err, ErrSubdomainTaken):
+		result.Available = false
+		result.Reason = err.Error()
+	default:
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// SetCustomDomain assigns a custom domain to the organization. It only takes
+// effect once VerifyCustomDomain finds the returned TXT record.
+func (s *OrganizationService) SetCustomDomain(audit AuditContext, organizationID, domain string) (*CustomDomainStatus, error) {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if s.baseDomain != "" && (domain == s.baseDomain || strings.HasSuffix(domain, "."+s.baseDomain)) {
+		return nil, fmt.Errorf("%w: use a subdomain instead of a domain under %s", ErrInvalidCustomDomain, s.baseDomain)
+	}
+
+	org, err := s.GetOrganizationByID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org.CustomDomain != nil && *org.CustomDomain == domain {
+		return customDomainStatus(org), nil
+	}
+
+	token, err := utils.GenerateRandomToken(24)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	before := customDomainStatus(org)
+	previous := org.CustomDomain
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Unscoped().Model(&models.Organization{}).
+			Where("LOWER(custom_domain) = ? AND id <> ?", domain, organizationID).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check custom domain: %w", err)
+		}
+		if count > 0 {
+			return ErrCustomDomainTaken
+		}
+
+		org.CustomDomain = &domain
+		org.CustomDomainToken = token
+		org.CustomDomainVerifiedAt = nil
+		if err := tx.Model(&models.Organization{}).Where("id = ?", organizationID).Updates(map[string]interface{}{
+			"custom_domain":             domain,
+			"custom_domain_token":       token,
+			"custom_domain_verified_at": nil,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to set custom domain: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceOrganization, organizationID, before, customDomainStatus(org))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		s.authz.InvalidateCustomDomain(*previous)
+	}
+
+	return customDomainStatus(org), nil
+}
+
+// GetCustomDomain returns the organization's custom domain and verification record
+func (s *OrganizationService) GetCustomDomain(organizationID string) (*CustomDomainStatus, error) {
+	org, err := s.GetOrganizationByID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org.CustomDomain == nil {
+		return nil, ErrCustomDomainNotSet
+	}
+	return customDomainStatus(org), nil
+}
+
+// VerifyCustomDomain looks up the verification TXT record and activates the domain when it matches
+func (s *OrganizationService) VerifyCustomDomain(ctx context.Context, audit AuditContext, organizationID string) (*CustomDomainStatus, error) {
+	org, err := s.GetOrganizationByID(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if org.CustomDomain == nil {
+		return nil, ErrCustomDomainNotSet
+	}
+	if org.CustomDomainVerifiedAt != nil {
+		return customDomainStatus(org), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	records, err := s.lookupTXT(ctx, customDomainRecordPrefix+*org.CustomDomain)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCustomDomainNotVerified, err)
+	}
+
+	found := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == org.CustomDomainToken {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, ErrCustomDomainNotVerified
+	}
+
+	before := customDomainStatus(org)
+	now := time.Now()
+	org.CustomDomainVerifiedAt = &now
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Organization{}).Where("id = ?", organizationID).
+			Update("custom_domain_verified_at", now).Error; err != nil {
+			return fmt.Errorf("failed to verify custom domain: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceOrganization, organizationID, before, customDomainStatus(org))
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Drops a cached miss from before the domain was verified
+	s.authz.InvalidateCustomDomain(*org.CustomDomain)
+
+	return customDomainStatus(org), nil
+}
+
+// RemoveCustomDomain detaches the custom domain from the organization
+func (s *OrganizationService) RemoveCustomDomain(audit AuditContext, organizationID string) error {
+	org, err := s.GetOrganizationByID(organizationID)
+	if err != nil {
+		return err
+	}
+	if org.CustomDomain == nil {
+		return ErrCustomDomainNotSet
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Organization{}).Where("id = ?", organizationID).Updates(map[string]interface{}{
+			"custom_domain":             nil,
+			"custom_domain_token":       "",
+			"custom_domain_verified_at": nil,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to remove custom domain: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceOrganization, organizationID, customDomainStatus(org), nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.authz.InvalidateCustomDomain(*org.CustomDomain)
+	return nil
+}
+
+func customDomainStatus(org *models.Organization) *CustomDomainStatus {
+	if org.CustomDomain == nil {
+		return nil
+	}
+
+	status := &CustomDomainStatus{
+		Domain:     *org.CustomDomain,
+		Verified:   org.CustomDomainVerifiedAt != nil,
+		VerifiedAt: org.CustomDomainVerifiedAt,
+	}
+	if !status.Verified {
+		status.VerificationName = customDomainRecordPrefix + *org.CustomDomain
+		status.VerificationValue = org.CustomDomainToken
+	}
+	return status
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+)
+
+func TestCustomDomainHostLookupFollowsVerification(t *testing.T) {
+	db := testutil.DB(t)
+	authz := newTestAuthz(db)
+	organizations := NewOrganizationService(db, authz, "invoicing.example.com")
+
+	owner, org := newTestOrganization(t, db, 5)
+	audit := AuditContext{UserID: owner.ID.String()}
+	domain := "billing-" + uuid.NewString()[:8] + ".example.org"
+
+	status, err := organizations.SetCustomDomain(audit, org.ID.String(), domain)
+	if err != nil {
+		t.Fatalf("set custom domain: %v", err)
+	}
+
+	// Unverified domains don't resolve; the miss is cached
+	if id, err := authz.GetOrganizationIDByCustomDomain(domain); err != nil || id != "" {
+		t.Fatalf("unverified domain resolved to %q (err %v)", id, err)
+	}
+
+	organizations.lookupTXT = func(context.Context, string) ([]string, error) {
+		return []string{status.VerificationValue}, nil
+	}
+	if _, err := organizations.VerifyCustomDomain(context.Background(), audit, org.ID.String()); err != nil {
+		t.Fatalf("verify custom domain: %v", err)
+	}
+	if id, _ := authz.GetOrganizationIDByCustomDomain(domain); id != org.ID.String() {
+		t.Fatalf("verified domain resolved to %q, want %s", id, org.ID)
+	}
+
+	if err := organizations.RemoveCustomDomain(audit, org.ID.String()); err != nil {
+		t.Fatalf("remove custom domain: %v", err)
+	}
+	if id, _ := authz.GetOrganizationIDByCustomDomain(domain); id != "" {
+		t.Fatalf("removed domain still resolves to %q", id)
+	}
+}
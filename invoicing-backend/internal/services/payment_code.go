@@ -0,0 +1,416 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/qrcode"
+	"gorm.io/gorm"
+)
+
+// PaymentCodeType is the standard a payment QR code follows
+type PaymentCodeType string
+
+const (
+	// EPC069-12 SEPA credit transfer, for EUR invoices
+	PaymentCodeEPC PaymentCodeType = "epc"
+	// Swiss QR-bill, for CHF and EUR invoices paid to a Swiss or Liechtenstein IBAN
+	PaymentCodeSwiss PaymentCodeType = "swiss"
+)
+
+var ErrPaymentCodeUnavailable = errors.New("payment code unavailable")
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// PaymentCode is a QR code banking apps scan to prefill a transfer for an invoice
+type PaymentCode struct {
+	Type      PaymentCodeType
+	IBAN      string
+	Creditor  string
+	Reference string
+	Payload   string
+	QR        *qrcode.Code
+}
+
+// InvoicePaymentCode builds a payment QR code for the invoice. An empty codeType
+// picks the standard that fits the invoice's currency and bank account.
+func (s *InvoiceService) InvoicePaymentCode(invoiceID, organizationID string, codeType PaymentCodeType) (*PaymentCode, error) {
+	var invoice *models.Invoice
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var err error
+		invoice, err = getInvoice(tx, invoiceID, organizationID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := invoiceOrganization(s.db, invoice)
+	if err != nil {
+		return nil, err
+	}
+	return buildPaymentCode(org, invoice, codeType)
+}
+
+func buildPaymentCode(org *models.Organization, invoice *models.Invoice, codeType PaymentCodeType) (*PaymentCode, error) {
+	if invoice.Status == models.InvoiceStatusPaid || invoice.Status == models.InvoiceStatusCancelled {
+		return nil, fmt.Errorf("%w: the invoice is %s", ErrPaymentCodeUnavailable, invoice.Status)
+	}
+	if invoice.TotalAmount < 0.01 || invoice.TotalAmount > 999999999.99 {
+		return nil, fmt.Errorf("%w: the invoice total is out of range", ErrPaymentCodeUnavailable)
+	}
+
+	if codeType == "" {
+		codeType = defaultPaymentCodeType(invoice)
+	}
+
+	var code *PaymentCode
+	var err error
+	switch codeType {
+	case PaymentCodeEPC:
+		code, err = epcPaymentCode(invoice)
+	case PaymentCodeSwiss:
+		code, err = swissPaymentCode(org, invoice)
+	default:
+		return nil, fmt.Errorf("%w: no bank transfer payment method fits the invoice currency", ErrPaymentCodeUnavailable)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	code.QR, err = qrcode.Encode([]byte(code.Payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payment code: %w", err)
+	}
+	return code, nil
+}
+
+// defaultPaymentCodeType prefers a Swiss QR-bill when the invoice can be paid to a
+// Swiss or Liechtenstein account, and an EPC code for other EUR invoices
+func defaultPaymentCodeType(invoice *models.Invoice) PaymentCodeType {
+	currency := strings.ToUpper(invoice.Currency)
+	if (currency == "CHF" || currency == "EUR") && transferAccount(invoice, isSwissIBAN) != nil {
+		return PaymentCodeSwiss
+	}
+	if currency == "EUR" && transferAccount(invoice, nil) != nil {
+		return PaymentCodeEPC
+	}
+	return ""
+}
+
+// transferAccount returns the first bank transfer method of the invoice whose IBAN
+// is accepted; a nil accept takes any
+func transferAccount(invoice *models.Invoice, accept func(iban string) bool) *models.PaymentMethod {
+	for i := range invoice.PaymentMethods {
+		method := &invoice.PaymentMethods[i]
+		if method.Type == models.PaymentMethodBankTransfer && method.IBAN != "" && (accept == nil || accept(method.IBAN)) {
+			return method
+		}
+	}
+	return nil
+}
+
+// epcPaymentCode builds an EPC069-12 version 002 payload with the invoice number
+// as ISO 11649 creditor reference, or as remittance text when it can't be one
+func epcPaymentCode(invoice *models.Invoice) (*PaymentCode, error) {
+	if !strings.EqualFold(invoice.Currency, "EUR") {
+		return nil, fmt.Errorf("%w: EPC QR codes are for EUR invoices", ErrPaymentCodeUnavailable)
+	}
+	account := transferAccount(invoice, nil)
+	if account == nil {
+		return nil, fmt.Errorf("%w: the invoice has no bank transfer payment method", ErrPaymentCodeUnavailable)
+	}
+
+	reference := creditorReference(invoice.InvoiceNumber)
+	text := ""
+	if reference == "" {
+		text = truncate("Invoice "+invoice.InvoiceNumber, 140)
+	}
+	lines := []string{
+		"BCD", "002", "1", "SCT",
+		account.BIC,
+		truncate(account.AccountHolder, 70),
+		account.IBAN,
+		"EUR" + strconv.FormatFloat(invoice.TotalAmount, 'f', 2, 64),
+		"", // purpose
+		reference,
+		text,
+	}
+
+	return &PaymentCode{
+		Type:      PaymentCodeEPC,
+		IBAN:      account.IBAN,
+		Creditor:  account.AccountHolder,
+		Reference: reference,
+		// Trailing empty elements are left out
+		Payload: strings.TrimRight(strings.Join(lines, "\n"), "\n"),
+	}, nil
+}
+
+// swissPaymentCode builds a Swiss QR-bill (version 2.0) payload. QR-IBANs carry a
+// QR reference and other IBANs an ISO 11649 creditor reference, both derived from
+// the invoice number.
+func swissPaymentCode(org *models.Organization, invoice *models.Invoice) (*PaymentCode, error) {
+	currency := strings.ToUpper(invoice.Currency)
+	if currency != "CHF" && currency != "EUR" {
+		return nil, fmt.Errorf("%w: Swiss QR-bills are for CHF and EUR invoices", ErrPaymentCodeUnavailable)
+	}
+	account := transferAccount(invoice, isSwissIBAN)
+	if account == nil {
+		return nil, fmt.Errorf("%w: the invoice has no bank transfer payment method with a Swiss or Liechtenstein IBAN", ErrPaymentCodeUnavailable)
+	}
+	creditor, ok := swissAddress(account.AccountHolder, org.Settings.CompanyAddress)
+	if !ok {
+		return nil, fmt.Errorf("%w: the company address needs a postal code, city and two-letter country code", ErrPaymentCodeUnavailable)
+	}
+
+	referenceType, reference := "NON", ""
+	if isQRIBAN(account.IBAN) {
+		referenceType, reference = "QRR", qrReference(invoice.InvoiceNumber)
+		if reference == "" {
+			return nil, fmt.Errorf("%w: QR-IBANs need an invoice number with digits", ErrPaymentCodeUnavailable)
+		}
+	} else if reference = creditorReference(invoice.InvoiceNumber); reference != "" {
+		referenceType = "SCOR"
+	}
+
+	// The debtor is optional, so clients without a complete address are left out
+	client := invoice.Client
+	debtor, ok := swissAddress(client.Name, models.CompanyAddress{
+		AddressLine1: client.AddressLine1,
+		City:         client.City,
+		PostalCode:   client.PostalCode,
+		Country:      client.Country,
+	})
+	if !ok {
+		debtor = make([]string, 7)
+	}
+
+	lines := []string{"SPC", "0200", "1", account.IBAN}
+	lines = append(lines, creditor...)
+	lines = append(lines, make([]string, 7)...) // ultimate creditor, reserved
+	lines = append(lines, strconv.FormatFloat(invoice.TotalAmount, 'f', 2, 64), currency)
+	lines = append(lines, debtor...)
+	lines = append(lines, referenceType, reference, truncate("Invoice "+invoice.InvoiceNumber, 140), "EPD")
+
+	return &PaymentCode{
+		Type:      PaymentCodeSwiss,
+		IBAN:      account.IBAN,
+		Creditor:  account.AccountHolder,
+		Reference: reference,
+		Payload:   strings.Join(lines, "\n"),
+	}, nil
+}
+
+// swissAddress returns the seven elements of a structured (type S) QR-bill address
+func swissAddress(name string, a models.CompanyAddress) ([]string, bool) {
+	country := strings.ToUpper(strings.TrimSpace(a.Country))
+	if name == "" || a.PostalCode == "" || a.City == "" || !countryCodePattern.MatchString(country) {
+		return nil, false
+	}
+	return []string{
+		"S",
+		truncate(name, 70),
+		truncate(a.AddressLine1, 70),
+		"", // building number, part of the street line
+		truncate(a.PostalCode, 16),
+		truncate(a.City, 35),
+		country,
+	}, true
+}
+
+func isSwissIBAN(iban string) bool {
+	return strings.HasPrefix(iban, "CH") || strings.HasPrefix(iban, "LI")
+}
+
+// isQRIBAN reports whether a Swiss or Liechtenstein IBAN has a QR-IID (30000-31999)
+func isQRIBAN(iban string) bool {
+	if !isSwissIBAN(iban) || len(iban) < 9 {
+		return false
+	}
+	iid, err := strconv.Atoi(iban[4:9])
+	return err == nil && iid >= 30000 && iid <= 31999
+}
+
+// creditorReference builds an ISO 11649 (RF) reference from the letters and digits
+// of the invoice number, or returns "" when they don't fit
+func creditorReference(invoiceNumber string) string {
+	var body strings.Builder
+	for _, r := range strings.ToUpper(invoiceNumber) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			body.WriteRune(r)
+		}
+	}
+	if body.Len() == 0 || body.Len() > 21 {
+		return ""
+	}
+	return fmt.Sprintf("RF%02d%s", 98-mod97(body.String()+"RF00"), body.String())
+}
+
+// qrReference builds a 27-digit QR reference from the digits of the invoice number
+// and a modulo 10 recursive check digit, or returns "" when it has no digits
+func qrReference(invoiceNumber string) string {
+	var digits strings.Builder
+	for _, r := range invoiceNumber {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	if strings.Trim(digits.String(), "0") == "" {
+		return ""
+	}
+
+	reference := digits.String()
+	if len(reference) > 26 {
+		reference = reference[len(reference)-26:]
+	}
+	reference = strings.Repeat("0", 26-len(reference)) + reference
+
+	table := [10]int{0, 9, 4, 6, 8, 2, 7, 1, 3, 5}
+	carry := 0
+	for _, r := range reference {
+		carry = table[(carry+int(r-'0'))%10]
+	}
+	return reference + strconv.Itoa((10-carry)%10)
+}
+
+// FormatPaymentReference groups a reference for printing: QR references in blocks
+// of five from the right, creditor references in blocks of four
+func FormatPaymentReference(reference string) string {
+	if strings.HasPrefix(reference, "RF") {
+		return FormatIBAN(reference)
+	}
+	var b strings.Builder
+	for i, r := range reference {
+		if i > 0 && (len(reference)-i)%5 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func truncate(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if runes := []rune(s); len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
+// paymentCodeShape is a filled rectangle of a payment code, in modules from the
+// top-left corner of the symbol
+type paymentCodeShape struct {
+	x, y, w, h float64
+	dark       bool
+}
+
+// Swiss cross proportions relative to the side of its square: the white border, and
+// the length and width of the cross arms
+const (
+	swissCrossBorder = 0.7 / 19.8
+	swissCrossLength = 11.0 / 19.8
+	swissCrossWidth  = 3.3 / 19.8
+)
+
+// shapes draws the symbol as runs of dark modules per row, with the Swiss cross
+// (7 mm on the 46 mm symbol) over the center of Swiss QR-bills
+func (c *PaymentCode) shapes() []paymentCodeShape {
+	var shapes []paymentCodeShape
+	size := c.QR.Size
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; {
+			if !c.QR.Dark(x, y) {
+				x++
+				continue
+			}
+			start := x
+			for x < size && c.QR.Dark(x, y) {
+				x++
+			}
+			shapes = append(shapes, paymentCodeShape{x: float64(start), y: float64(y), w: float64(x - start), h: 1, dark: true})
+		}
+	}
+
+	if c.Type == PaymentCodeSwiss {
+		side := float64(size) * 7 / 46
+		center := float64(size) / 2
+		centered := func(w, h float64, dark bool) paymentCodeShape {
+			return paymentCodeShape{x: center - w/2, y: center - h/2, w: w, h: h, dark: dark}
+		}
+		inner := side * (1 - 2*swissCrossBorder)
+		shapes = append(shapes,
+			centered(side, side, false),
+			centered(inner, inner, true),
+			centered(side*swissCrossWidth, side*swissCrossLength, false),
+			centered(side*swissCrossLength, side*swissCrossWidth, false),
+		)
+	}
+	return shapes
+}
+
+// paymentCodeQuietZone is the light margin around the symbol, in modules
+const paymentCodeQuietZone = 4
+
+// PNG renders the code with scale pixels per module
+func (c *PaymentCode) PNG(scale int) ([]byte, error) {
+	side := (c.QR.Size + 2*paymentCodeQuietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	offset := float64(paymentCodeQuietZone)
+	for _, shape := range c.shapes() {
+		gray := color.Gray{Y: 0xff}
+		if shape.dark {
+			gray = color.Gray{}
+		}
+		px := func(v float64) int { return int(math.Round((v + offset) * float64(scale))) }
+		for y := px(shape.y); y < px(shape.y+shape.h); y++ {
+			for x := px(shape.x); x < px(shape.x+shape.w); x++ {
+				img.SetGray(x, y, gray)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode payment code PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as a scalable image with one unit per module
+func (c *PaymentCode) SVG() []byte {
+	side := c.QR.Size + 2*paymentCodeQuietZone
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`,
+		side, side, side*8, side*8)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`, side, side)
+	for _, shape := range c.shapes() {
+		fill := "#fff"
+		if shape.dark {
+			fill = "#000"
+		}
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`,
+			svgNumber(shape.x+paymentCodeQuietZone), svgNumber(shape.y+paymentCodeQuietZone),
+			svgNumber(shape.w), svgNumber(shape.h), fill)
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes()
+}
+
+func svgNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/qrcode"
+)
+
+// scanPaymentCode renders the code as PNG and reads it back the way a banking app
+// would, sampling the center of each module
+func scanPaymentCode(t *testing.T, code *PaymentCode) *qrcode.Symbol {
+	t.Helper()
+
+	const scale = 4
+	data, err := code.PNG(scale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size := img.Bounds().Dx()/scale - 2*paymentCodeQuietZone
+	symbol, err := qrcode.Decode(size, func(x, y int) bool {
+		r, _, _, _ := img.At((x+paymentCodeQuietZone)*scale+scale/2, (y+paymentCodeQuietZone)*scale+scale/2).RGBA()
+		return r < 0x8000
+	})
+	if err != nil {
+		t.Fatalf("payment code unreadable: %v", err)
+	}
+	return symbol
+}
+
+func testPaymentInvoice(currency, iban string) *models.Invoice {
+	return &models.Invoice{
+		InvoiceNumber: "INV-20260042",
+		Status:        models.InvoiceStatusSent,
+		Currency:      currency,
+		TotalAmount:   1234.5,
+		Client: models.Client{
+			Name:         "Muster AG",
+			AddressLine1: "Musterweg 7",
+			PostalCode:   "3000",
+			City:         "Bern",
+			Country:      "CH",
+		},
+		PaymentMethods: []models.PaymentMethod{
+			{Type: models.PaymentMethodCustom, Name: "Cash"},
+			{Type: models.PaymentMethodBankTransfer, AccountHolder: "Acme GmbH", IBAN: iban, BIC: "COBADEFFXXX"},
+		},
+	}
+}
+
+// validCreditorReference checks the ISO 11649 check digits independently of creditorReference
+func validCreditorReference(reference string) bool {
+	if len(reference) < 5 || !strings.HasPrefix(reference, "RF") {
+		return false
+	}
+	var digits strings.Builder
+	for _, r := range reference[4:] + reference[:4] {
+		if r >= 'A' && r <= 'Z' {
+			digits.WriteString(big.NewInt(int64(r - 'A' + 10)).String())
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+func TestEPCPaymentCode(t *testing.T) {
+	invoice := testPaymentInvoice("EUR", "DE89370400440532013000")
+
+	code, err := buildPaymentCode(&models.Organization{}, invoice, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code.Type != PaymentCodeEPC {
+		t.Fatalf("type = %s, want epc", code.Type)
+	}
+	if !validCreditorReference(code.Reference) || !strings.HasSuffix(code.Reference, "INV20260042") {
+		t.Fatalf("reference %q is no creditor reference of the invoice number", code.Reference)
+	}
+
+	symbol := scanPaymentCode(t, code)
+	want := strings.Join([]string{
+		"BCD", "002", "1", "SCT",
+		"COBADEFFXXX",
+		"Acme GmbH",
+		"DE89370400440532013000",
+		"EUR1234.50",
+		"",
+		code.Reference,
+	}, "\n")
+	if string(symbol.Data) != want {
+		t.Fatalf("scanned payload:\n%q\nwant:\n%q", symbol.Data, want)
+	}
+	// EPC069-12 allows up to version 13 at level M
+	if symbol.Version != 6 || symbol.Level != 'M' {
+		t.Fatalf("version %d at level %c, want version 6 at level M", symbol.Version, symbol.Level)
+	}
+	if symbol.Corrected != 0 {
+		t.Fatalf("%d codewords needed repair", symbol.Corrected)
+	}
+}
+
+func TestSwissPaymentCode(t *testing.T) {
+	org := &models.Organization{Settings: models.OrganizationSettings{CompanyAddress: models.CompanyAddress{
+		AddressLine1: "Bahnhofstrasse 1",
+		PostalCode:   "8001",
+		City:         "Zürich",
+		Country:      "ch",
+	}}}
+
+	tests := []struct {
+		name          string
+		iban          string
+		referenceType string
+		version       int
+	}{
+		{"QR-IBAN", "CH4431999123000889012", "QRR", 10},
+		{"IBAN", "CH9300762011623852957", "SCOR", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := testPaymentInvoice("CHF", tt.iban)
+
+			code, err := buildPaymentCode(org, invoice, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if code.Type != PaymentCodeSwiss {
+				t.Fatalf("type = %s, want swiss", code.Type)
+			}
+			switch tt.referenceType {
+			case "QRR":
+				if len(code.Reference) != 27 || !strings.HasSuffix(code.Reference[:26], "20260042") {
+					t.Fatalf("reference %q is no QR reference of the invoice number", code.Reference)
+				}
+			case "SCOR":
+				if !validCreditorReference(code.Reference) {
+					t.Fatalf("reference %q is no creditor reference", code.Reference)
+				}
+			}
+
+			symbol := scanPaymentCode(t, code)
+			want := strings.Join([]string{
+				"SPC", "0200", "1",
+				tt.iban,
+				"S", "Acme GmbH", "Bahnhofstrasse 1", "", "8001", "Zürich", "CH",
+				"", "", "", "", "", "", "",
+				"1234.50", "CHF",
+				"S", "Muster AG", "Musterweg 7", "", "3000", "Bern", "CH",
+				tt.referenceType, code.Reference,
+				"Invoice INV-20260042",
+				"EPD",
+			}, "\n")
+			if string(symbol.Data) != want {
+				t.Fatalf("scanned payload:\n%q\nwant:\n%q", symbol.Data, want)
+			}
+			// The QR-bill standard prescribes level M and allows up to version 25
+			if symbol.Version != tt.version || symbol.Level != 'M' {
+				t.Fatalf("version %d at level %c, want version %d at level M", symbol.Version, symbol.Level, tt.version)
+			}
+			// The Swiss cross covers part of the symbol; error correction restores it
+			if symbol.Corrected == 0 {
+				t.Fatal("Swiss cross covered no codewords")
+			}
+		})
+	}
+}
+
+func TestPaymentCodeUnavailable(t *testing.T) {
+	org := &models.Organization{}
+	tests := []struct {
+		name     string
+		invoice  *models.Invoice
+		codeType PaymentCodeType
+	}{
+		{"EPC for CHF", testPaymentInvoice("CHF", "DE89370400440532013000"), PaymentCodeEPC},
+		{"Swiss without Swiss IBAN", testPaymentInvoice("CHF", "DE89370400440532013000"), PaymentCodeSwiss},
+		{"Swiss without company address", testPaymentInvoice("CHF", "CH9300762011623852957"), PaymentCodeSwiss},
+		{"no bank account", &models.Invoice{Currency: "EUR", TotalAmount: 10, Status: models.InvoiceStatusSent}, ""},
+		{"paid", func() *models.Invoice {
+			invoice := testPaymentInvoice("EUR", "DE89370400440532013000")
+			invoice.Status = models.InvoiceStatusPaid
+			return invoice
+		}(), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := buildPaymentCode(org, tt.invoice, tt.codeType); !errors.Is(err, ErrPaymentCodeUnavailable) {
+				t.Fatalf("err = %v, want ErrPaymentCodeUnavailable", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+)
+
+// PaymentGateway collects invoice payments from an organization's clients.
+// Implementations talk to the gateway's API; the InvoicePaymentService owns the
+// local payment and invoice state.
+type PaymentGateway interface {
+	// Name is the models.PaymentGateway* value stored on payments
+	Name() string
+	// CreateCheckout opens a hosted checkout for paying params.Amount
+	CreateCheckout(ctx context.Context, params PaymentCheckoutParams) (*PaymentCheckout, error)
+	// ParseWebhook verifies the request and decodes the event
+	ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*PaymentEvent, error)
+}
+
+type PaymentCheckoutParams struct {
+	OrganizationID   string
+	OrganizationName string
+	InvoiceID        string
+	InvoiceNumber    string
+	Amount           float64
+	Currency         string
+	// CustomerEmail prefills the checkout, if the gateway supports it
+	CustomerEmail string
+	SuccessURL    string
+	CancelURL     string
+}
+
+// PaymentCheckout is the gateway page where the client pays
+type PaymentCheckout struct {
+	SessionID   string
+	CheckoutURL string
+}
+
+// PaymentEvent is a verified webhook event. Payment is nil for events that
+// don't concern an invoice payment.
+type PaymentEvent struct {
+	ID      string
+	Type    string
+	Payment *GatewayPayment
+}
+
+// GatewayPayment is the outcome of a checkout as reported by the gateway
+type GatewayPayment struct {
+	SessionID string
+	PaymentID string
+	// OrganizationID and InvoiceID are echoed back from the checkout
+	OrganizationID string
+	InvoiceID      string
+	Status         models.InvoicePaymentStatus
+	Amount         float64
+	Currency       string
+}
+
+// Currencies without minor units, which gateways expect as whole amounts
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// toMinorUnits converts an amount to the currency's smallest unit, e.g. cents
+func toMinorUnits(amount float64, currency string) int64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// fromMinorUnits converts an amount in the currency's smallest unit back
+func fromMinorUnits(amount int64, currency string) float64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
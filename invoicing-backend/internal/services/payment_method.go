@@ -0,0 +1,318 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPaymentMethodNotFound = errors.New("payment method not found")
+	ErrInvalidPaymentMethod  = errors.New("invalid payment method")
+)
+
+var (
+	bicPattern            = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	achAccountPattern     = regexp.MustCompile(`^[0-9]{4,17}$`)
+	paypalMeNamePattern   = regexp.MustCompile(`^[A-Za-z0-9]{1,20}$`)
+	ibanCharactersPattern = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+)
+
+// IBAN lengths by country code, from the SWIFT IBAN registry
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22, "BH": 22, "BR": 29,
+	"BY": 28, "CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22, "DK": 18, "DO": 28, "EE": 20, "EG": 29,
+	"ES": 24, "FI": 18, "FO": 18, "FR": 27, "GB": 22, "GE": 22, "GI": 23, "GL": 18, "GR": 27, "GT": 28,
+	"HR": 21, "HU": 28, "IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27, "JO": 30, "KW": 30, "KZ": 20,
+	"LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MD": 24, "ME": 22, "MK": 19,
+	"MR": 27, "MT": 31, "MU": 30, "NL": 18, "NO": 15, "PK": 24, "PL": 28, "PS": 29, "PT": 25, "QA": 29,
+	"RO": 24, "RS": 22, "SA": 24, "SC": 31, "SE": 24, "SI": 19, "SK": 24, "SM": 27, "ST": 25, "SV": 28,
+	"TL": 23, "TN": 24, "TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20,
+}
+
+// PaymentMethodService manages the payment methods organizations print on invoices
+type PaymentMethodService struct {
+	db *gorm.DB
+}
+
+func NewPaymentMethodService(db *gorm.DB) *PaymentMethodService {
+	return &PaymentMethodService{db: db}
+}
+
+// PaymentMethodRequest creates or replaces a payment method. Fields that don't
+// apply to the type are cleared.
+type PaymentMethodRequest struct {
+	Type             models.PaymentMethodType `json:"type" validate:"required,oneof=bank_transfer ach paypal_me custom"`
+	Name             string                   `json:"name" validate:"required,max=100"`
+	IsDefault        bool                     `json:"is_default"`
+	AccountHolder    string                   `json:"account_holder" validate:"max=140"`
+	BankName         string                   `json:"bank_name" validate:"max=140"`
+	IBAN             string                   `json:"iban" validate:"max=50"`
+	BIC              string                   `json:"bic" validate:"max=20"`
+	RoutingNumber    string                   `json:"routing_number" validate:"max=20"`
+	AccountNumber    string                   `json:"account_number" validate:"max=30"`
+	PayPalMeUsername string                   `json:"paypal_me_username" validate:"max=50"`
+	Instructions     string                   `json:"instructions" validate:"max=2000"`
+}
+
+func (s *PaymentMethodService) ListPaymentMethods(organizationID string) ([]models.PaymentMethod, error) {
+	var methods []models.PaymentMethod
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		return tx.Where("organization_id = ?", organizationID).Order("created_at").Find(&methods).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch payment methods: %w", err)
+	}
+	return methods, nil
+}
+
+func (s *PaymentMethodService) CreatePaymentMethod(audit AuditContext, organizationID string, req *PaymentMethodRequest) (*models.PaymentMethod, error) {
+	method := &models.PaymentMethod{OrganizationID: organizationID}
+	if err := applyPaymentMethodRequest(method, req); err != nil {
+		return nil, err
+	}
+
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if err := tx.Create(method).Error; err != nil {
+			return fmt.Errorf("failed to create payment method: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionCreate,
+			models.AuditResourcePaymentMethod, method.ID, nil, method)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+func (s *PaymentMethodService) UpdatePaymentMethod(audit AuditContext, organizationID, methodID string, req *PaymentMethodRequest) (*models.PaymentMethod, error) {
+	var method models.PaymentMethod
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND organization_id = ?", methodID, organizationID).First(&method).Error; err != nil {
+			return ErrPaymentMethodNotFound
+		}
+		before := method
+
+		if err := applyPaymentMethodRequest(&method, req); err != nil {
+			return err
+		}
+		if err := tx.Save(&method).Error; err != nil {
+			return fmt.Errorf("failed to update payment method: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourcePaymentMethod, method.ID, &before, &method)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// DeletePaymentMethod removes the method; invoices that selected it stop printing it
+func (s *PaymentMethodService) DeletePaymentMethod(audit AuditContext, organizationID, methodID string) error {
+	return database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		var method models.PaymentMethod
+		if err := tx.Where("id = ? AND organization_id = ?", methodID, organizationID).First(&method).Error; err != nil {
+			return ErrPaymentMethodNotFound
+		}
+
+		if err := tx.Delete(&method).Error; err != nil {
+			return fmt.Errorf("failed to delete payment method: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionDelete,
+			models.AuditResourcePaymentMethod, method.ID, &method, nil)
+	})
+}
+
+// applyPaymentMethodRequest validates the request for its type and copies it onto method
+func applyPaymentMethodRequest(method *models.PaymentMethod, req *PaymentMethodRequest) error {
+	*method = models.PaymentMethod{
+		ID:             method.ID,
+		OrganizationID: method.OrganizationID,
+		Type:           req.Type,
+		Name:           strings.TrimSpace(req.Name),
+		IsDefault:      req.IsDefault,
+		Instructions:   strings.TrimSpace(req.Instructions),
+		CreatedAt:      method.CreatedAt,
+	}
+
+	switch req.Type {
+	case models.PaymentMethodBankTransfer:
+		method.AccountHolder = strings.TrimSpace(req.AccountHolder)
+		method.BankName = strings.TrimSpace(req.BankName)
+		method.IBAN = NormalizeIBAN(req.IBAN)
+		method.BIC = strings.ToUpper(strings.ReplaceAll(req.BIC, " ", ""))
+		if method.AccountHolder == "" {
+			return fmt.Errorf("%w: account_holder is required for bank transfers", ErrInvalidPaymentMethod)
+		}
+		if !ValidIBAN(method.IBAN) {
+			return fmt.Errorf("%w: iban is not a valid IBAN", ErrInvalidPaymentMethod)
+		}
+		if method.BIC != "" && !bicPattern.MatchString(method.BIC) {
+			return fmt.Errorf("%w: bic must be 8 or 11 characters", ErrInvalidPaymentMethod)
+		}
+
+	case models.PaymentMethodACH:
+		method.AccountHolder = strings.TrimSpace(req.AccountHolder)
+		method.BankName = strings.TrimSpace(req.BankName)
+		method.RoutingNumber = strings.TrimSpace(req.RoutingNumber)
+		method.AccountNumber = strings.TrimSpace(req.AccountNumber)
+		if method.AccountHolder == "" {
+			return fmt.Errorf("%w: account_holder is required for ACH transfers", ErrInvalidPaymentMethod)
+		}
+		if !ValidRoutingNumber(method.RoutingNumber) {
+			return fmt.Errorf("%w: routing_number is not a valid ABA routing number", ErrInvalidPaymentMethod)
+		}
+		if !achAccountPattern.MatchString(method.AccountNumber) {
+			return fmt.Errorf("%w: account_number must be 4-17 digits", ErrInvalidPaymentMethod)
+		}
+
+	case models.PaymentMethodPayPalMe:
+		method.PayPalMeUsername = strings.TrimPrefix(strings.TrimSpace(req.PayPalMeUsername), "@")
+		if !paypalMeNamePattern.MatchString(method.PayPalMeUsername) {
+			return fmt.Errorf("%w: paypal_me_username must be 1-20 letters or digits", ErrInvalidPaymentMethod)
+		}
+
+	case models.PaymentMethodCustom:
+		if method.Instructions == "" {
+			return fmt.Errorf("%w: instructions are required for custom payment methods", ErrInvalidPaymentMethod)
+		}
+
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPaymentMethod, req.Type)
+	}
+	return nil
+}
+
+// NormalizeIBAN returns the IBAN in electronic format: upper case without spaces
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(iban), ""))
+}
+
+// ValidIBAN checks an IBAN in electronic format: its length for the country and
+// the ISO 7064 mod-97 check digits
+func ValidIBAN(iban string) bool {
+	if !ibanCharactersPattern.MatchString(iban) {
+		return false
+	}
+	if length, ok := ibanLengths[iban[:2]]; ok && len(iban) != length {
+		return false
+	}
+
+	// Move the country code and check digits to the end
+	return mod97(iban[4:]+iban[:4]) == 1
+}
+
+// mod97 computes the ISO 7064 MOD 97-10 remainder of an alphanumeric string,
+// reading letters as 10-35; it returns -1 for other characters
+func mod97(s string) int64 {
+	var digits strings.Builder
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	if !ok {
+		return -1
+	}
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64()
+}
+
+// FormatIBAN groups an electronic-format IBAN in blocks of four for printing
+func FormatIBAN(iban string) string {
+	var b strings.Builder
+	for i, r := range iban {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ValidRoutingNumber checks an ABA routing number's 3-7-1 checksum
+func ValidRoutingNumber(routing string) bool {
+	if len(routing) != 9 {
+		return false
+	}
+	weights := [9]int{3, 7, 1, 3, 7, 1, 3, 7, 1}
+	sum := 0
+	for i, r := range routing {
+		if r < '0' || r > '9' {
+			return false
+		}
+		sum += int(r-'0') * weights[i]
+	}
+	return sum%10 == 0
+}
+
+// checkPaymentMethods verifies that every selected method belongs to the organization
+// and returns the selection without duplicates
+func checkPaymentMethods(tx *gorm.DB, organizationID string, ids models.PaymentMethodIDs) (models.PaymentMethodIDs, error) {
+	selected := models.PaymentMethodIDs{}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			selected = append(selected, id)
+		}
+	}
+	if len(selected) == 0 {
+		return selected, nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.PaymentMethod{}).
+		Where("organization_id = ? AND id IN ?", organizationID, []string(selected)).
+		Count(&count).Error; err != nil {
+		return nil, ErrPaymentMethodNotFound // malformed IDs
+	}
+	if int(count) != len(selected) {
+		return nil, ErrPaymentMethodNotFound
+	}
+	return selected, nil
+}
+
+// loadPaymentMethods fills in the payment methods printed on each invoice
+func loadPaymentMethods(tx *gorm.DB, organizationID string, invoices ...*models.Invoice) error {
+	if len(invoices) == 0 {
+		return nil
+	}
+
+	var methods []models.PaymentMethod
+	if err := tx.Where("organization_id = ?", organizationID).Order("created_at").Find(&methods).Error; err != nil {
+		return fmt.Errorf("failed to load payment methods: %w", err)
+	}
+
+	byID := make(map[string]models.PaymentMethod, len(methods))
+	for _, m := range methods {
+		byID[m.ID] = m
+	}
+	for _, invoice := range invoices {
+		invoice.PaymentMethods = []models.PaymentMethod{}
+		if len(invoice.PaymentMethodIDs) == 0 {
+			for _, m := range methods {
+				if m.IsDefault {
+					invoice.PaymentMethods = append(invoice.PaymentMethods, m)
+				}
+			}
+			continue
+		}
+		// Deleted methods are skipped
+		for _, id := range invoice.PaymentMethodIDs {
+			if m, ok := byID[id]; ok {
+				invoice.PaymentMethods = append(invoice.PaymentMethods, m)
+			}
+		}
+	}
+	return nil
+}
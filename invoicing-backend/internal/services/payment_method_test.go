@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+)
+
+func TestValidIBAN(t *testing.T) {
+	tests := []struct {
+		name  string
+		iban  string
+		valid bool
+	}{
+		{"Germany", "DE89370400440532013000", true},
+		{"United Kingdom", "GB82WEST12345698765432", true},
+		{"France with letters in the account", "FR1420041010050500013M02606", true},
+		{"Switzerland", "CH9300762011623852957", true},
+		{"Netherlands", "NL91ABNA0417164300", true},
+		{"Belgium", "BE68539007547034", true},
+		{"Norway, the shortest", "NO9386011117947", true},
+		{"lowercase and spaced", "de89 3704 0044 0532 0130 00", true},
+		{"tabs and newlines", "DE89\t3704\n0044 0532 0130 00", true},
+
+		{"bad check digits", "DE89370400440532013001", false},
+		{"swapped check digits", "DE98370400440532013000", false},
+		{"swapped account digits", "DE89370400440532010300", false},
+		{"too short for the country", "DE8937040044053201300", false},
+		{"too long for the country", "DE893704004405320130000", false},
+		{"Swiss length with German country code", "DE9300762011623852957", false},
+		{"dashes", "DE89-3704-0044-0532-0130-00", false},
+		{"no country code", "8937040044053201300000", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidIBAN(NormalizeIBAN(tt.iban)); got != tt.valid {
+				t.Fatalf("ValidIBAN(%q) = %v, want %v", tt.iban, got, tt.valid)
+			}
+		})
+	}
+
+	// ValidIBAN takes the electronic format; callers normalize first
+	if ValidIBAN("de89370400440532013000") || ValidIBAN("DE89 3704 0044 0532 0130 00") {
+		t.Fatal("IBAN accepted without normalizing")
+	}
+}
+
+func TestMod97(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+	}{
+		{"370400440532013000DE89", 1},
+		{"97", 0},
+		{"A", 10},
+		{"Z", 35},
+		{"ZZ", 3535 % 97},
+		{"12-3", -1},
+		{"", -1},
+	}
+	for _, tt := range tests {
+		if got := mod97(tt.s); got != tt.want {
+			t.Errorf("mod97(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFormatIBAN(t *testing.T) {
+	tests := map[string]string{
+		"DE89370400440532013000":      "DE89 3704 0044 0532 0130 00",
+		"FR1420041010050500013M02606": "FR14 2004 1010 0505 0001 3M02 606",
+		"BE68539007547034":            "BE68 5390 0754 7034",
+		"":                            "",
+	}
+	for iban, want := range tests {
+		if got := FormatIBAN(iban); got != want {
+			t.Errorf("FormatIBAN(%q) = %q, want %q", iban, got, want)
+		}
+	}
+}
+
+func TestValidRoutingNumber(t *testing.T) {
+	tests := []struct {
+		routing string
+		valid   bool
+	}{
+		{"011000015", true},
+		{"021000021", true},
+		{"026009593", true},
+		{"111000025", true},
+		{"021000022", false},
+		{"120000021", false},
+		{"02100002", false},
+		{"0210000210", false},
+		{"02100002A", false},
+		{"021 00002", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidRoutingNumber(tt.routing); got != tt.valid {
+			t.Errorf("ValidRoutingNumber(%q) = %v, want %v", tt.routing, got, tt.valid)
+		}
+	}
+}
+
+func TestApplyPaymentMethodRequestBankDetails(t *testing.T) {
+	var method models.PaymentMethod
+	err := applyPaymentMethodRequest(&method, &PaymentMethodRequest{
+		Type:          models.PaymentMethodBankTransfer,
+		Name:          "Bank",
+		AccountHolder: "Acme GmbH",
+		IBAN:          "de89 3704 0044 0532 0130 00",
+		BIC:           "cobade ffxxx",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method.IBAN != "DE89370400440532013000" || method.BIC != "COBADEFFXXX" {
+		t.Fatalf("stored IBAN %q and BIC %q, want the electronic format", method.IBAN, method.BIC)
+	}
+
+	invalid := []*PaymentMethodRequest{
+		{Type: models.PaymentMethodBankTransfer, Name: "Bank", AccountHolder: "Acme GmbH", IBAN: "DE89370400440532013001"},
+		{Type: models.PaymentMethodACH, Name: "ACH", AccountHolder: "Acme Inc", RoutingNumber: "021000022", AccountNumber: "123456789"},
+	}
+	for _, req := range invalid {
+		if err := applyPaymentMethodRequest(&models.PaymentMethod{}, req); !errors.Is(err, ErrInvalidPaymentMethod) {
+			t.Errorf("%s: err = %v, want ErrInvalidPaymentMethod", req.Type, err)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+)
+
+// PayPal subscription statuses
+const (
+	PayPalStatusApprovalPending = "APPROVAL_PENDING"
+	PayPalStatusApproved        = "APPROVED"
+	PayPalStatusActive          = "ACTIVE"
+	PayPalStatusSuspended       = "SUSPENDED"
+	PayPalStatusCancelled       = "CANCELLED"
+	PayPalStatusExpired         = "EXPIRED"
+)
+
+// PayPalSubscription is the subset of PayPal's subscription resource we rely on.
+// It is also the resource of BILLING.SUBSCRIPTION.* webhook events.
+type PayPalSubscription struct {
+	ID          string             `json:"id"`
+	PlanID      string             `json:"plan_id"`
+	Status      string             `json:"status"`
+	CustomID    string             `json:"custom_id"`
+	StartTime   *time.Time         `json:"start_time"`
+	BillingInfo *PayPalBillingInfo `json:"billing_info"`
+	Links       []PayPalLink       `json:"links"`
+}
+
+type PayPalBillingInfo struct {
+	NextBillingTime *time.Time `json:"next_billing_time"`
+	LastPayment     *struct {
+		Time *time.Time `json:"time"`
+	} `json:"last_payment"`
+}
+
+type PayPalLink struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+// ApproveURL returns the link the payer follows to approve the subscription
+func (s *PayPalSubscription) ApproveURL() string {
+	for _, link := range s.Links {
+		if link.Rel == "approve" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// PayPalCreateSubscription describes a new subscription. CustomID is echoed back
+// in webhook events and carries our organization ID.
+type PayPalCreateSubscription struct {
+	PlanID    string
+	CustomID  string
+	ReturnURL string
+	CancelURL string
+}
+
+// PayPalClient is the part of the PayPal REST API used for subscriptions
+type PayPalClient interface {
+	CreateSubscription(ctx context.Context, req PayPalCreateSubscription) (*PayPalSubscription, error)
+	GetSubscription(ctx context.Context, subscriptionID string) (*PayPalSubscription, error)
+	// RevisePlan moves the subscription to another plan; the payer approves the change via the returned approve link
+	RevisePlan(ctx context.Context, subscriptionID, planID, returnURL, cancelURL string) (*PayPalSubscription, error)
+	CancelSubscription(ctx context.Context, subscriptionID, reason string) error
+	// CreateOrder, CaptureOrder and GetOrder collect one-off invoice payments
+	CreateOrder(ctx context.Context, req PayPalCreateOrder) (*PayPalOrder, error)
+	CaptureOrder(ctx context.Context, orderID string) (*PayPalOrder, error)
+	GetOrder(ctx context.Context, orderID string) (*PayPalOrder, error)
+	// VerifyWebhookSignature asks PayPal whether the event was signed for the given webhook
+	VerifyWebhookSignature(ctx context.Context, webhookID string, headers http.Header, body []byte) (bool, error)
+}
+
+// paypalHTTPClient talks to the PayPal REST API (or a local fake) with client credentials
+type paypalHTTPClient struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewPayPalClient returns a client for the PayPal REST API at baseURL,
+// e.g. https://api-m.sandbox.paypal.com
+func NewPayPalClient(baseURL, clientID, clientSecret string) PayPalClient {
+	return &paypalHTTPClient{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *paypalHTTPClient) CreateSubscription(ctx context.Context, req PayPalCreateSubscription) (*PayPalSubscription, error) {
+	body := map[string]interface{}{
+		"plan_id":   req.PlanID,
+		"custom_id": req.CustomID,
+		"application_context": map[string]string{
+			"return_url":          req.ReturnURL,
+			"cancel_url":          req.CancelURL,
+			"user_action":         "SUBSCRIBE_NOW",
+			"shipping_preference": "NO_SHIPPING",
+		},
+	}
+
+	var subscription PayPalSubscription
+	if err := p.do(ctx, http.MethodPost, "/v1/billing/subscriptions", body, &subscription); err != nil {
+		return nil, fmt.Errorf("failed to create PayPal subscription: %w", err)
+	}
+	return &subscription, nil
+}
+
+func (p *paypalHTTPClient) GetSubscription(ctx context.Context, subscriptionID string) (*PayPalSubscription, error) {
+	var subscription PayPalSubscription
+	if err := p.do(ctx, http.MethodGet, "/v1/billing/subscriptions/"+url.PathEscape(subscriptionID), nil, &subscription); err != nil {
+		return nil, fmt.Errorf("failed to fetch PayPal subscription: %w", err)
+	}
+	return &subscription, nil
+}
+
+func (p *paypalHTTPClient) RevisePlan(ctx context.Context, subscriptionID, planID, returnURL, cancelURL string) (*PayPalSubscription, error) {
+	body := map[string]interface{}{
+		"plan_id": planID,
+		"application_context": map[string]string{
+			"return_url": returnURL,
+			"cancel_url": cancelURL,
+		},
+	}
+
+	var subscription PayPalSubscription
+	if err := p.do(ctx, http.MethodPost, "/v1/billing/subscriptions/"+url.PathEscape(subscriptionID)+"/revise", body, &subscription); err != nil {
+		return nil, fmt.Errorf("failed to revise PayPal subscription: %w", err)
+	}
+	subscription.ID = subscriptionID
+	return &subscription, nil
+}
+
+func (p *paypalHTTPClient) CancelSubscription(ctx context.Context, subscriptionID, reason string) error {
+	if reason == "" {
+		reason = "Cancelled by customer"
+	}
+	body := map[string]string{"reason": reason}
+	if err := p.do(ctx, http.MethodPost, "/v1/billing/subscriptions/"+url.PathEscape(subscriptionID)+"/cancel", body, nil); err != nil {
+		return fmt.Errorf("failed to cancel PayPal subscription: %w", err)
+	}
+	return nil
+}
+
+func (p *paypalHTTPClient) CreateOrder(ctx context.Context, req PayPalCreateOrder) (*PayPalOrder, error) {
+	body := map[string]interface{}{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]interface{}{{
+			"reference_id": req.ReferenceID,
+			"custom_id":    req.CustomID,
+			"description":  req.Description,
+			"amount":       req.Amount,
+		}},
+		"application_context": map[string]string{
+			"brand_name":          req.BrandName,
+			"return_url":          req.ReturnURL,
+			"cancel_url":          req.CancelURL,
+			"user_action":         "PAY_NOW",
+			"shipping_preference": "NO_SHIPPING",
+		},
+	}
+
+	var order PayPalOrder
+	if err := p.do(ctx, http.MethodPost, "/v2/checkout/orders", body, &order); err != nil {
+		return nil, fmt.Errorf("failed to create PayPal order: %w", err)
+	}
+	return &order, nil
+}
+
+func (p *paypalHTTPClient) CaptureOrder(ctx context.Context, orderID string) (*PayPalOrder, error) {
+	var order PayPalOrder
+	if err := p.do(ctx, http.MethodPost, "/v2/checkout/orders/"+url.PathEscape(orderID)+"/capture", map[string]string{}, &order); err != nil {
+		return nil, fmt.Errorf("failed to capture PayPal order: %w", err)
+	}
+	return &order, nil
+}
+
+func (p *paypalHTTPClient) GetOrder(ctx context.Context, orderID string) (*PayPalOrder, error) {
+	var order PayPalOrder
+	if err := p.do(ctx, http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(orderID), nil, &order); err != nil {
+		return nil, fmt.Errorf("failed to fetch PayPal order: %w", err)
+	}
+	return &order, nil
+}
+
+func (p *paypalHTTPClient) VerifyWebhookSignature(ctx context.Context, webhookID string, headers http.Header, body []byte) (bool, error) {
+	req := map[string]interface{}{
+		"auth_algo":         headers.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          headers.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   headers.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  headers.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": headers.Get("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        webhookID,
+		"webhook_event":     json.RawMessage(body),
+	}
+
+	var resp struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := p.do(ctx, http.MethodPost, "/v1/notifications/verify-webhook-signature", req, &resp); err != nil {
+		return false, fmt.Errorf("failed to verify PayPal webhook signature: %w", err)
+	}
+	return resp.VerificationStatus == "SUCCESS", nil
+}
+
+// do sends an authenticated JSON request and decodes the response into out (if not nil)
+func (p *paypalHTTPClient) do(ctx context.Context, method, path string, in, out interface{}) error {
+	token, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		p.mu.Lock()
+		p.accessToken = ""
+		p.mu.Unlock()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// token returns a cached OAuth access token, fetching a new one shortly before it expires
+func (p *paypalHTTPClient) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.accessToken != "" && time.Now().Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(p.clientID, p.clientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch PayPal access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch PayPal access token: status %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("failed to decode PayPal access token: %w", err)
+	}
+
+	p.accessToken = tokenResp.AccessToken
+	p.expiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
+
+// PayPal webhook event types we act on
+const (
+	PayPalEventSubscriptionActivated   = "BILLING.SUBSCRIPTION.ACTIVATED"
+	PayPalEventSubscriptionUpdated     = "BILLING.SUBSCRIPTION.UPDATED"
+	PayPalEventSubscriptionReactivated = "BILLING.SUBSCRIPTION.RE-ACTIVATED"
+	PayPalEventSubscriptionSuspended   = "BILLING.SUBSCRIPTION.SUSPENDED"
+	PayPalEventSubscriptionCancelled   = "BILLING.SUBSCRIPTION.CANCELLED"
+	PayPalEventSubscriptionExpired     = "BILLING.SUBSCRIPTION.EXPIRED"
+	PayPalEventPaymentFailed           = "BILLING.SUBSCRIPTION.PAYMENT.FAILED"
+	PayPalEventSaleCompleted           = "PAYMENT.SALE.COMPLETED"
+)
+
+// paypalBillingProvider bills through PayPal subscriptions
+type paypalBillingProvider struct {
+	client    PayPalClient
+	plans     map[models.SubscriptionPlan]string
+	webhookID string
+}
+
+// NewPayPalBillingProvider bills through PayPal. plans maps our plans to PayPal plan IDs.
+func NewPayPalBillingProvider(client PayPalClient, plans map[models.SubscriptionPlan]string, webhookID string) BillingProvider {
+	return &paypalBillingProvider{client: client, plans: plans, webhookID: webhookID}
+}
+
+func (p *paypalBillingProvider) Name() string {
+	return models.BillingProviderPayPal
+}
+
+// SupportsCoupons is false: PayPal plans carry their price, with no discounts
+func (p *paypalBillingProvider) SupportsCoupons() bool {
+	return false
+}
+
+// Checkout creates a subscription, or revises the existing one; the payer approves
+// either on PayPal. PayPal subscriptions can't take our coupons.
+func (p *paypalBillingProvider) Checkout(ctx context.Context, params CheckoutParams) (*BillingCheckout, error) {
+	planID := p.plans[params.Plan]
+	if planID == "" {
+		return nil, ErrPlanNotPurchasable
+	}
+	if params.Coupon != nil {
+		return nil, ErrCouponNotSupported
+	}
+
+	var subscription *PayPalSubscription
+	var err error
+	if params.Subscription.PayPalSubscriptionID != "" {
+		subscription, err = p.client.RevisePlan(ctx, params.Subscription.PayPalSubscriptionID, planID, params.SuccessURL, params.CancelURL)
+	} else {
+		subscription, err = p.client.CreateSubscription(ctx, PayPalCreateSubscription{
+			PlanID:    planID,
+			CustomID:  params.OrganizationID,
+			ReturnURL: params.SuccessURL,
+			CancelURL: params.CancelURL,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &BillingCheckout{
+		Provider:               models.BillingProviderPayPal,
+		CheckoutURL:            subscription.ApproveURL(),
+		ProviderSubscriptionID: subscription.ID,
+	}, nil
+}
+
+func (p *paypalBillingProvider) PortalURL(ctx context.Context, subscription *models.Subscription, returnURL string) (string, error) {
+	return "", ErrPortalNotSupported
+}
+
+func (p *paypalBillingProvider) Cancel(ctx context.Context, subscription *models.Subscription, reason string) error {
+	return p.client.CancelSubscription(ctx, subscription.PayPalSubscriptionID, reason)
+}
+
+func (p *paypalBillingProvider) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*BillingEvent, error) {
+	verified, err := p.client.VerifyWebhookSignature(ctx, p.webhookID, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	var event struct {
+		ID        string          `json:"id"`
+		EventType string          `json:"event_type"`
+		Resource  json.RawMessage `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" || event.EventType == "" {
+		return nil, ErrInvalidWebhookPayload
+	}
+
+	subscription, err := p.eventSubscription(ctx, event.EventType, event.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	billingEvent := &BillingEvent{ID: event.ID, Type: event.EventType}
+	if subscription != nil {
+		billingEvent.Subscription = p.providerSubscription(subscription)
+	}
+	return billingEvent, nil
+}
+
+// eventSubscription returns the subscription state the event reports,
+// or nil for events that don't affect subscriptions
+func (p *paypalBillingProvider) eventSubscription(ctx context.Context, eventType string, resource json.RawMessage) (*PayPalSubscription, error) {
+	switch eventType {
+	case PayPalEventSubscriptionActivated, PayPalEventSubscriptionUpdated, PayPalEventSubscriptionReactivated,
+		PayPalEventSubscriptionSuspended, PayPalEventSubscriptionCancelled, PayPalEventSubscriptionExpired:
+		var subscription PayPalSubscription
+		if err := json.Unmarshal(resource, &subscription); err != nil || subscription.ID == "" {
+			return nil, ErrInvalidWebhookPayload
+		}
+		// PayPal retries failed deliveries for days, so an event may arrive after
+		// later changes; apply the subscription's current state instead of the event's
+		return p.client.GetSubscription(ctx, subscription.ID)
+
+	case PayPalEventPaymentFailed:
+		var subscription PayPalSubscription
+		if err := json.Unmarshal(resource, &subscription); err != nil || subscription.ID == "" {
+			return nil, ErrInvalidWebhookPayload
+		}
+		subscription.Status = PayPalStatusSuspended
+		return &subscription, nil
+
+	case PayPalEventSaleCompleted:
+		// A renewal payment: the sale only references the subscription, so fetch
+		// it for the new billing period
+		var sale struct {
+			BillingAgreementID string `json:"billing_agreement_id"`
+		}
+		if err := json.Unmarshal(resource, &sale); err != nil {
+			return nil, ErrInvalidWebhookPayload
+		}
+		if sale.BillingAgreementID == "" {
+			return nil, nil // one-off payment
+		}
+		return p.client.GetSubscription(ctx, sale.BillingAgreementID)
+	}
+
+	return nil, nil
+}
+
+func (p *paypalBillingProvider) providerSubscription(subscription *PayPalSubscription) *ProviderSubscription {
+	result := &ProviderSubscription{
+		ID:             subscription.ID,
+		PlanID:         subscription.PlanID,
+		Plan:           planForProviderPlan(p.plans, subscription.PlanID),
+		OrganizationID: subscription.CustomID,
+		PeriodStart:    subscription.StartTime,
+	}
+	if info := subscription.BillingInfo; info != nil {
+		if info.LastPayment != nil && info.LastPayment.Time != nil {
+			result.PeriodStart = info.LastPayment.Time
+		}
+		result.PeriodEnd = info.NextBillingTime
+	}
+
+	switch subscription.Status {
+	case PayPalStatusActive:
+		result.Status = models.SubscriptionStatusActive
+	case PayPalStatusSuspended:
+		result.Status = models.SubscriptionStatusPastDue
+	case PayPalStatusCancelled, PayPalStatusExpired:
+		result.Status = models.SubscriptionStatusCancelled
+	}
+	return result
+}
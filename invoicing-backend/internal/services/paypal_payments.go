@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+)
+
+// PayPal order and capture statuses
+const (
+	PayPalOrderCompleted   = "COMPLETED"
+	PayPalCaptureCompleted = "COMPLETED"
+	PayPalCaptureDeclined  = "DECLINED"
+	PayPalCaptureFailed    = "FAILED"
+)
+
+// PayPal events that settle an invoice payment
+const (
+	PayPalEventOrderApproved    = "CHECKOUT.ORDER.APPROVED"
+	PayPalEventCaptureCompleted = "PAYMENT.CAPTURE.COMPLETED"
+	PayPalEventCaptureDenied    = "PAYMENT.CAPTURE.DENIED"
+)
+
+// PayPalOrder is the subset of PayPal's Orders v2 resource we rely on.
+// It is also the resource of CHECKOUT.ORDER.* webhook events.
+type PayPalOrder struct {
+	ID            string               `json:"id"`
+	Status        string               `json:"status"`
+	PurchaseUnits []PayPalPurchaseUnit `json:"purchase_units"`
+	Links         []PayPalLink         `json:"links"`
+}
+
+type PayPalPurchaseUnit struct {
+	ReferenceID string      `json:"reference_id"`
+	CustomID    string      `json:"custom_id"`
+	Amount      PayPalMoney `json:"amount"`
+	Payments    *struct {
+		Captures []PayPalCapture `json:"captures"`
+	} `json:"payments"`
+}
+
+type PayPalMoney struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+// PayPalCapture is a captured order payment, also the resource of PAYMENT.CAPTURE.* events
+type PayPalCapture struct {
+	ID                string      `json:"id"`
+	Status            string      `json:"status"`
+	CustomID          string      `json:"custom_id"`
+	Amount            PayPalMoney `json:"amount"`
+	SupplementaryData struct {
+		RelatedIDs struct {
+			OrderID string `json:"order_id"`
+		} `json:"related_ids"`
+	} `json:"supplementary_data"`
+}
+
+// ApproveURL returns the link the payer follows to approve the order
+func (o *PayPalOrder) ApproveURL() string {
+	for _, link := range o.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// PayPalCreateOrder describes a new order. ReferenceID carries our invoice ID
+// and CustomID our organization ID; both are echoed back in webhook events.
+type PayPalCreateOrder struct {
+	ReferenceID string
+	CustomID    string
+	Description string
+	Amount      PayPalMoney
+	BrandName   string
+	ReturnURL   string
+	CancelURL   string
+}
+
+// paypalMoney formats an amount the way PayPal expects it
+func paypalMoney(amount float64, currency string) PayPalMoney {
+	currency = strings.ToUpper(currency)
+	decimals := 2
+	if zeroDecimalCurrencies[currency] {
+		decimals = 0
+	}
+	return PayPalMoney{CurrencyCode: currency, Value: strconv.FormatFloat(amount, 'f', decimals, 64)}
+}
+
+// paypalPaymentGateway collects invoice payments with PayPal orders. Approved
+// orders are captured when their webhook arrives.
+type paypalPaymentGateway struct {
+	client    PayPalClient
+	webhookID string
+}
+
+// NewPayPalPaymentGateway collects payments through client. webhookID identifies
+// the invoice payments webhook for signature verification.
+func NewPayPalPaymentGateway(client PayPalClient, webhookID string) PaymentGateway {
+	return &paypalPaymentGateway{client: client, webhookID: webhookID}
+}
+
+func (g *paypalPaymentGateway) Name() string {
+	return models.PaymentGatewayPayPal
+}
+
+func (g *paypalPaymentGateway) CreateCheckout(ctx context.Context, params PaymentCheckoutParams) (*PaymentCheckout, error) {
+	order, err := g.client.CreateOrder(ctx, PayPalCreateOrder{
+		ReferenceID: params.InvoiceID,
+		CustomID:    params.OrganizationID,
+		Description: fmt.Sprintf("Invoice %s", params.InvoiceNumber),
+		Amount:      paypalMoney(params.Amount, params.Currency),
+		BrandName:   params.OrganizationName,
+		ReturnURL:   params.SuccessURL,
+		CancelURL:   params.CancelURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	approveURL := order.ApproveURL()
+	if approveURL == "" {
+		return nil, fmt.Errorf("PayPal order %s has no approve link", order.ID)
+	}
+	return &PaymentCheckout{SessionID: order.ID, CheckoutURL: approveURL}, nil
+}
+
+func (g *paypalPaymentGateway) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*PaymentEvent, error) {
+	verified, err := g.client.VerifyWebhookSignature(ctx, g.webhookID, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, ErrInvalidWebhookSignature
+	}
+
+	var event struct {
+		ID        string          `json:"id"`
+		EventType string          `json:"event_type"`
+		Resource  json.RawMessage `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" || event.EventType == "" {
+		return nil, ErrInvalidWebhookPayload
+	}
+
+	paymentEvent := &PaymentEvent{ID: event.ID, Type: event.EventType}
+	switch event.EventType {
+	case PayPalEventOrderApproved:
+		var order PayPalOrder
+		if err := json.Unmarshal(event.Resource, &order); err != nil || order.ID == "" {
+			return nil, ErrInvalidWebhookPayload
+		}
+		payment, err := g.captureOrder(ctx, &order)
+		if err != nil {
+			return nil, err
+		}
+		paymentEvent.Payment = payment
+
+	case PayPalEventCaptureCompleted, PayPalEventCaptureDenied:
+		var capture PayPalCapture
+		if err := json.Unmarshal(event.Resource, &capture); err != nil || capture.ID == "" {
+			return nil, ErrInvalidWebhookPayload
+		}
+		orderID := capture.SupplementaryData.RelatedIDs.OrderID
+		if orderID == "" || capture.CustomID == "" {
+			return paymentEvent, nil // not an order payment of ours
+		}
+		paymentEvent.Payment = capturePayment(orderID, capture.CustomID, "", &capture)
+	}
+
+	return paymentEvent, nil
+}
+
+// captureOrder captures an approved order. The capture is repeated when the
+// event is redelivered, in which case PayPal refuses it and the order is
+// fetched to learn the outcome of the first one.
+func (g *paypalPaymentGateway) captureOrder(ctx context.Context, approved *PayPalOrder) (*GatewayPayment, error) {
+	if len(approved.PurchaseUnits) == 0 || approved.PurchaseUnits[0].ReferenceID == "" {
+		return nil, nil // created elsewhere
+	}
+
+	order, err := g.client.CaptureOrder(ctx, approved.ID)
+	if err != nil {
+		var getErr error
+		order, getErr = g.client.GetOrder(ctx, approved.ID)
+		if getErr != nil || order.Status != PayPalOrderCompleted {
+			return nil, err
+		}
+	}
+	if len(order.PurchaseUnits) == 0 || order.PurchaseUnits[0].Payments == nil || len(order.PurchaseUnits[0].Payments.Captures) == 0 {
+		return nil, fmt.Errorf("PayPal order %s has no capture", order.ID)
+	}
+
+	unit := approved.PurchaseUnits[0]
+	return capturePayment(order.ID, unit.CustomID, unit.ReferenceID, &order.PurchaseUnits[0].Payments.Captures[0]), nil
+}
+
+// capturePayment maps a capture to the payment outcome. Pending captures (e.g.
+// under review) return nil and are settled by a later PAYMENT.CAPTURE.* event.
+func capturePayment(orderID, organizationID, invoiceID string, capture *PayPalCapture) *GatewayPayment {
+	var status models.InvoicePaymentStatus
+	switch capture.Status {
+	case PayPalCaptureCompleted:
+		status = models.InvoicePaymentSucceeded
+	case PayPalCaptureDeclined, PayPalCaptureFailed:
+		status = models.InvoicePaymentFailed
+	default:
+		return nil
+	}
+
+	amount, _ := strconv.ParseFloat(capture.Amount.Value, 64)
+	return &GatewayPayment{
+		SessionID:      orderID,
+		PaymentID:      capture.ID,
+		OrganizationID: organizationID,
+		InvoiceID:      invoiceID,
+		Status:         status,
+		Amount:         amount,
+		Currency:       capture.Amount.CurrencyCode,
+	}
+}
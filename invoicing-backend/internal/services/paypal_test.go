@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/paypalfake"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+)
+
+const testPayPalSignature = "test-signature"
+
+// newFakePayPal starts a fake PayPal whose subscription webhooks are collected
+// instead of being delivered to the API
+func newFakePayPal(t *testing.T) (*paypalfake.Server, *httptest.Server, <-chan webhookDelivery) {
+	t.Helper()
+
+	receiver, deliveries := newWebhookReceiver(t)
+	fake := paypalfake.New(testPayPalSignature, receiver.URL, "")
+	server := httptest.NewServer(fake.Handler())
+	t.Cleanup(server.Close)
+	return fake, server, deliveries
+}
+
+func newTestPayPalProvider(server *httptest.Server) BillingProvider {
+	client := NewPayPalClient(server.URL, "test-client", "test-secret")
+	return NewPayPalBillingProvider(client, map[models.SubscriptionPlan]string{models.SubscriptionPlanPro: "P-PRO"}, "WH-TEST")
+}
+
+func TestPayPalWebhookSignature(t *testing.T) {
+	ctx := context.Background()
+	fake, server, _ := newFakePayPal(t)
+	provider := newTestPayPalProvider(server)
+
+	subscription, err := NewPayPalClient(server.URL, "test-client", "test-secret").CreateSubscription(ctx, PayPalCreateSubscription{
+		PlanID:   "P-PRO",
+		CustomID: "org-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers, body, err := fake.Event(PayPalEventSubscriptionActivated, "subscription", map[string]string{"id": subscription.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := provider.ParseWebhook(ctx, headers, body)
+	if err != nil {
+		t.Fatalf("signed event rejected: %v", err)
+	}
+	if event.Subscription == nil || event.Subscription.ID != subscription.ID || event.Subscription.OrganizationID != "org-1" {
+		t.Fatalf("event subscription = %+v, want %s of org-1", event.Subscription, subscription.ID)
+	}
+
+	forged := headers.Clone()
+	forged.Set("PAYPAL-TRANSMISSION-SIG", "forged")
+	if _, err := provider.ParseWebhook(ctx, forged, body); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("forged signature: err = %v, want ErrInvalidWebhookSignature", err)
+	}
+
+	unsigned := headers.Clone()
+	unsigned.Del("PAYPAL-TRANSMISSION-SIG")
+	if _, err := provider.ParseWebhook(ctx, unsigned, body); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("missing signature: err = %v, want ErrInvalidWebhookSignature", err)
+	}
+}
+
+func TestPayPalLateEventReportsCurrentState(t *testing.T) {
+	ctx := context.Background()
+	fake, server, deliveries := newFakePayPal(t)
+	provider := newTestPayPalProvider(server)
+
+	checkout, err := provider.Checkout(ctx, CheckoutParams{
+		OrganizationID: "org-1",
+		Plan:           models.SubscriptionPlanPro,
+		Subscription:   &models.Subscription{},
+		SuccessURL:     "http://app.example.com/settings/billing?checkout=success",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	followCheckout(t, checkout.CheckoutURL)
+	activated := <-deliveries
+
+	if err := provider.Cancel(ctx, &models.Subscription{PayPalSubscriptionID: checkout.ProviderSubscriptionID}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The activation is retried after the cancellation, under a new event ID
+	var original struct {
+		Resource json.RawMessage `json:"resource"`
+	}
+	if err := json.Unmarshal(activated.body, &original); err != nil {
+		t.Fatal(err)
+	}
+	headers, body, err := fake.Event(PayPalEventSubscriptionActivated, "subscription", original.Resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := provider.ParseWebhook(ctx, headers, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Subscription.Status != models.SubscriptionStatusCancelled {
+		t.Fatalf("late activation reports status %q, want cancelled", event.Subscription.Status)
+	}
+}
+
+func TestPayPalSubscriptionLifecycle(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+	fake, server, deliveries := newFakePayPal(t)
+
+	owner, org := newTestOrganization(t, db, 5)
+	audit := AuditContext{UserID: owner.ID.String()}
+	subscriptions := NewSubscriptionService(db, newTestAuthz(db), NewLogNotifier(),
+		[]BillingProvider{newTestPayPalProvider(server)}, models.BillingProviderPayPal, "http://app.example.com", TrialConfig{})
+
+	checkout, err := subscriptions.StartCheckout(ctx, audit, org.ID.String(), &CheckoutRequest{PlanType: models.SubscriptionPlanPro})
+	if err != nil {
+		t.Fatalf("checkout failed: %v", err)
+	}
+	if checkout.Provider != models.BillingProviderPayPal || checkout.CheckoutURL == "" {
+		t.Fatalf("checkout = %+v, want a PayPal approve link", checkout)
+	}
+
+	followCheckout(t, checkout.CheckoutURL)
+	activated := <-deliveries
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderPayPal, activated.headers, activated.body); err != nil {
+		t.Fatalf("activation webhook failed: %v", err)
+	}
+
+	subscription, err := subscriptions.GetSubscription(org.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscription.PlanType != models.SubscriptionPlanPro || subscription.PayPalSubscriptionID != checkout.ProviderSubscriptionID {
+		t.Fatalf("after activation plan = %s, PayPal subscription = %q; want pro, %q",
+			subscription.PlanType, subscription.PayPalSubscriptionID, checkout.ProviderSubscriptionID)
+	}
+
+	// PayPal redelivers the same event
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderPayPal, activated.headers, activated.body); err != nil {
+		t.Fatalf("redelivery failed: %v", err)
+	}
+	var event struct {
+		ID       string          `json:"id"`
+		Resource json.RawMessage `json:"resource"`
+	}
+	if err := json.Unmarshal(activated.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	var recorded int64
+	if err := db.Model(&models.BillingWebhookEvent{}).
+		Where("provider = ? AND event_id = ?", models.BillingProviderPayPal, event.ID).Count(&recorded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if recorded != 1 {
+		t.Fatalf("event recorded %d times, want 1", recorded)
+	}
+
+	if _, err := subscriptions.CancelSubscription(ctx, audit, org.ID.String(), ""); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	cancelled := <-deliveries
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderPayPal, cancelled.headers, cancelled.body); err != nil {
+		t.Fatalf("cancellation webhook failed: %v", err)
+	}
+
+	// A retried activation with a new event ID must not bring the subscription back
+	headers, body, err := fake.Event(PayPalEventSubscriptionActivated, "subscription", event.Resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderPayPal, headers, body); err != nil {
+		t.Fatalf("late activation failed: %v", err)
+	}
+
+	subscription, err = subscriptions.GetSubscription(org.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscription.PlanType != models.SubscriptionPlanFree || subscription.PayPalSubscriptionID != "" {
+		t.Fatalf("after cancellation plan = %s, PayPal subscription = %q; want free and unlinked",
+			subscription.PlanType, subscription.PayPalSubscriptionID)
+	}
+
+	headers.Set("PAYPAL-TRANSMISSION-SIG", "forged")
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderPayPal, headers, body); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("forged webhook: err = %v, want ErrInvalidWebhookSignature", err)
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type PlanService struct {
+	db *gorm.DB
+}
+
+func NewPlanService(db *gorm.DB) *PlanService {
+	return &PlanService{db: db}
+}
+
+// ListPlans returns the public plans with their prices, cheapest first
+func (s *PlanService) ListPlans() ([]models.Plan, error) {
+	var plans []models.Plan
+	if err := s.db.Preload("Prices").Where("is_public = ?", true).
+		Order("sort_order ASC, code ASC").Find(&plans).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch plans: %w", err)
+	}
+	return plans, nil
+}
+
+// findPlan loads a plan of the catalog with its prices
+func findPlan(db *gorm.DB, code models.SubscriptionPlan) (*models.Plan, error) {
+	var plan models.Plan
+	if err := db.Preload("Prices").First(&plan, "code = ?", code).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUnknownPlan
+		}
+		return nil, fmt.Errorf("failed to fetch plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// applyCatalogPlan moves the subscription to the catalog plan with the given code
+func applyCatalogPlan(db *gorm.DB, subscription *models.Subscription, code models.SubscriptionPlan) error {
+	plan, err := findPlan(db, code)
+	if err != nil {
+		return err
+	}
+	subscription.ApplyPlan(plan)
+	return nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+type ChangePlanRequest struct {
+	PlanType models.SubscriptionPlan `json:"plan_type" validate:"required,max=50"`
+	// Provider is used when moving from the free plan to a paid one
+	Provider string `json:"provider" validate:"omitempty,oneof=paypal stripe"`
+	// CouponCode is redeemed for the new plan; the free plan takes no coupon
+	CouponCode string `json:"coupon_code" validate:"omitempty,max=50"`
+	// Preview returns the proration and limit check without changing anything
+	Preview bool `json:"preview"`
+}
+
+// Proration is the cost of switching plans part way through the billing period.
+// AmountDue is negative when the unused time is worth more than the new plan; the
+// provider credits it against the next invoice. Discount is the organization's
+// coupon taken off the charge.
+type Proration struct {
+	FromPlan          models.SubscriptionPlan `json:"from_plan"`
+	ToPlan            models.SubscriptionPlan `json:"to_plan"`
+	PeriodStart       *time.Time              `json:"period_start"`
+	PeriodEnd         *time.Time              `json:"period_end"`
+	RemainingFraction float64                 `json:"remaining_fraction"`
+	Credit            float64                 `json:"credit"`
+	Charge            float64                 `json:"charge"`
+	Discount          float64                 `json:"discount"`
+	CouponCode        string                  `json:"coupon_code,omitempty"`
+	AmountDue         float64                 `json:"amount_due"`
+	Currency          string                  `json:"currency"`
+}
+
+// PlanChange is the result of a plan change. Applied is false while a paid change
+// waits for the customer to check out or for the provider's webhook.
+type PlanChange struct {
+	Proration    Proration            `json:"proration"`
+	Subscription *models.Subscription `json:"subscription,omitempty"`
+	Checkout     *BillingCheckout     `json:"checkout,omitempty"`
+	// OverLimits lists the new plan's limits the organization's current usage exceeds
+	OverLimits       []UsageLimitError `json:"over_limits"`
+	LimitGraceEndsAt *time.Time        `json:"limit_grace_ends_at,omitempty"`
+	Applied          bool              `json:"applied"`
+}
+
+// ChangePlan upgrades or downgrades the organization. Paid plans go through the
+// billing provider; moving to the free plan cancels the paid subscription. An
+// organization left over the new plan's limits gets PlanDowngradeGracePeriod to
+// get back under them.
+func (s *SubscriptionService) ChangePlan(ctx context.Context, audit AuditContext, organizationID string, req *ChangePlanRequest) (*PlanChange, error) {
+	subscription, err := currentSubscription(s.db, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	// Trials and lapsed subscriptions can be turned into a subscription to the same plan
+	if subscription.PlanType == req.PlanType && subscription.IsActive() && subscription.TrialEnd == nil {
+		return nil, ErrAlreadyOnPlan
+	}
+
+	fromPlan, err := findPlan(s.db, subscription.PlanType)
+	if err != nil {
+		return nil, err
+	}
+	toPlan, err := findPlan(s.db, req.PlanType)
+	if err != nil {
+		return nil, err
+	}
+	if req.CouponCode != "" && req.PlanType == models.SubscriptionPlanFree {
+		return nil, ErrCouponNotApplicable
+	}
+	coupon, err := s.planChangeCoupon(organizationID, subscription, req)
+	if err != nil {
+		return nil, err
+	}
+
+	target := *subscription
+	target.ApplyPlan(toPlan)
+	overages, err := limitOverages(s.db, &target)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &PlanChange{
+		Proration:  prorate(subscription, fromPlan, toPlan, coupon, time.Now()),
+		OverLimits: overages,
+	}
+	if req.Preview {
+		return change, nil
+	}
+
+	switch {
+	case req.PlanType == models.SubscriptionPlanFree && subscription.ProviderSubscriptionID() != "":
+		subscription, err = s.CancelSubscription(ctx, audit, organizationID, "Changed to the free plan")
+		if err != nil {
+			return nil, err
+		}
+		change.Applied = true
+
+	case req.PlanType == models.SubscriptionPlanFree:
+		// Nothing to cancel with a provider, e.g. a plan granted by a platform admin
+		subscription, err = s.applyPlan(audit, organizationID, toPlan)
+		if err != nil {
+			return nil, err
+		}
+		change.Applied = true
+
+	default:
+		change.Checkout, err = s.StartCheckout(ctx, audit, organizationID, &CheckoutRequest{
+			PlanType:   req.PlanType,
+			Provider:   req.Provider,
+			CouponCode: req.CouponCode,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	change.Subscription = subscription
+	change.LimitGraceEndsAt = subscription.LimitGraceEndsAt
+	return change, nil
+}
+
+// CheckLimitGrace returns the limits the organization still exceeds once its grace
+// period has ended. A subscription back under its limits has the grace period cleared.
+func (s *SubscriptionService) CheckLimitGrace(subscription *models.Subscription) ([]UsageLimitError, error) {
+	if !subscription.LimitGraceExpired() {
+		return nil, nil
+	}
+
+	overages, err := limitOverages(s.db, subscription)
+	if err != nil {
+		return nil, err
+	}
+	if len(overages) > 0 {
+		return overages, nil
+	}
+
+	if err := s.db.Model(&models.Subscription{}).Where("id = ?", subscription.ID).
+		Update("limit_grace_ends_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("failed to clear limit grace period: %w", err)
+	}
+	s.authz.InvalidateOrganization(subscription.OrganizationID)
+	return nil, nil
+}
+
+// planChangeCoupon returns the coupon discounting the new plan: the one in the
+// request, which is checked but not yet redeemed, or the organization's current one
+func (s *SubscriptionService) planChangeCoupon(organizationID string, subscription *models.Subscription, req *ChangePlanRequest) (*models.Coupon, error) {
+	now := time.Now()
+	if req.CouponCode != "" {
+		return checkCoupon(s.db, organizationID, req.CouponCode, req.PlanType, now)
+	}
+
+	redemption, err := subscriptionDiscount(s.db, subscription, now)
+	if err != nil || redemption == nil || !redemption.Coupon.AppliesTo(req.PlanType) {
+		return nil, err
+	}
+	return redemption.Coupon, nil
+}
+
+// applyPlan changes the plan of a subscription that isn't billed by a provider. It
+// ends a trial and reactivates an expired subscription.
+func (s *SubscriptionService) applyPlan(audit AuditContext, organizationID string, plan *models.Plan) (*models.Subscription, error) {
+	var subscription *models.Subscription
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		var err error
+		subscription, err = currentSubscription(tx, organizationID)
+		if err != nil {
+			return err
+		}
+		before := *subscription
+
+		subscription.ApplyPlan(plan)
+		subscription.Status = models.SubscriptionStatusActive
+		subscription.TrialEnd = nil
+		subscription.CurrentPeriodStart = nil
+		subscription.CurrentPeriodEnd = nil
+		if _, err := reconcileLimitGrace(tx, subscription); err != nil {
+			return err
+		}
+		if err := tx.Omit("Organization").Save(subscription).Error; err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceSubscription, subscription.ID, &before, subscription)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateOrganization(organizationID)
+
+	return subscription, nil
+}
+
+// prorate quotes a switch from one plan to another at now, in DefaultPlanCurrency.
+// Without a current paid billing period the new plan is charged in full. Moving to
+// the free plan cancels immediately without a refund. coupon, if not nil, is taken
+// off the charge.
+func prorate(subscription *models.Subscription, fromPlan, toPlan *models.Plan, coupon *models.Coupon, now time.Time) Proration {
+	proration := Proration{
+		FromPlan:          fromPlan.Code,
+		ToPlan:            toPlan.Code,
+		RemainingFraction: 1,
+		Currency:          models.DefaultPlanCurrency,
+	}
+	fromPrice, _ := fromPlan.Price(models.DefaultPlanCurrency)
+	toPrice, _ := toPlan.Price(models.DefaultPlanCurrency)
+
+	start, end := subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd
+	inPeriod := subscription.ProviderSubscriptionID() != "" && start != nil && end != nil &&
+		end.After(*start) && now.Before(*end)
+
+	if inPeriod {
+		proration.PeriodStart, proration.PeriodEnd = start, end
+		remaining := float64(end.Sub(now)) / float64(end.Sub(*start))
+		proration.RemainingFraction = math.Round(math.Min(remaining, 1)*10000) / 10000
+		if toPlan.Code != models.SubscriptionPlanFree {
+			proration.Credit = roundCents(fromPrice * proration.RemainingFraction)
+		}
+	}
+
+	proration.Charge = roundCents(toPrice * proration.RemainingFraction)
+	if coupon != nil {
+		proration.Discount = coupon.Discount(proration.Charge, proration.Currency)
+		proration.CouponCode = coupon.Code
+	}
+	proration.AmountDue = roundCents(proration.Charge - proration.Discount - proration.Credit)
+	return proration
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
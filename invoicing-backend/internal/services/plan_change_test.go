@@ -0,0 +1,93 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+)
+
+func testPlan(code models.SubscriptionPlan, price float64) *models.Plan {
+	return &models.Plan{
+		Code:   code,
+		Prices: []models.PlanPrice{{Currency: models.DefaultPlanCurrency, Amount: price}},
+	}
+}
+
+func TestProrate(t *testing.T) {
+	free := testPlan(models.SubscriptionPlanFree, 0)
+	pro := testPlan(models.SubscriptionPlanPro, 15)
+	business := testPlan(models.SubscriptionPlanBusiness, 50)
+
+	// A 30 day billing period
+	start := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 30)
+	halfway := start.Add(15 * 24 * time.Hour)
+	paid := &models.Subscription{
+		BillingProvider:      models.BillingProviderStripe,
+		StripeSubscriptionID: "sub_1",
+		CurrentPeriodStart:   &start,
+		CurrentPeriodEnd:     &end,
+	}
+
+	percent := &models.Coupon{Code: "TWENTY", DiscountType: models.CouponDiscountPercent, PercentOff: 20}
+	fixed := &models.Coupon{Code: "TEN", DiscountType: models.CouponDiscountFixed, AmountOff: 10, Currency: "USD"}
+	fixedEUR := &models.Coupon{Code: "TENEUR", DiscountType: models.CouponDiscountFixed, AmountOff: 10, Currency: "EUR"}
+	large := &models.Coupon{Code: "HUNDRED", DiscountType: models.CouponDiscountFixed, AmountOff: 100, Currency: "USD"}
+
+	tests := []struct {
+		name         string
+		subscription *models.Subscription
+		from, to     *models.Plan
+		coupon       *models.Coupon
+		now          time.Time
+		fraction     float64
+		credit       float64
+		charge       float64
+		discount     float64
+		due          float64
+		inPeriod     bool
+	}{
+		{"upgrade halfway", paid, pro, business, nil, halfway, 0.5, 7.5, 25, 0, 17.5, true},
+		{"upgrade at a quarter left", paid, pro, business, nil, start.Add(22*24*time.Hour + 12*time.Hour), 0.25, 3.75, 12.5, 0, 8.75, true},
+		{"upgrade at period start", paid, pro, business, nil, start, 1, 15, 50, 0, 35, true},
+		{"downgrade credits the difference", paid, business, pro, nil, halfway, 0.5, 25, 7.5, 0, -17.5, true},
+		{"percent coupon", paid, pro, business, percent, halfway, 0.5, 7.5, 25, 5, 12.5, true},
+		{"fixed coupon", paid, pro, business, fixed, halfway, 0.5, 7.5, 25, 10, 7.5, true},
+		{"fixed coupon in another currency", paid, pro, business, fixedEUR, halfway, 0.5, 7.5, 25, 0, 17.5, true},
+		{"coupon larger than the charge", paid, pro, business, large, halfway, 0.5, 7.5, 25, 25, -7.5, true},
+		{"to free without refund", paid, pro, free, nil, halfway, 0.5, 0, 0, 0, 0, true},
+		{"from free", &models.Subscription{}, free, pro, nil, halfway, 1, 0, 15, 0, 15, false},
+		{"from free with coupon", &models.Subscription{}, free, pro, percent, halfway, 1, 0, 15, 3, 12, false},
+		{"period ended", paid, pro, business, nil, end.Add(time.Hour), 1, 0, 50, 0, 50, false},
+		{"no billing period", &models.Subscription{
+			BillingProvider:      models.BillingProviderStripe,
+			StripeSubscriptionID: "sub_1",
+		}, pro, business, nil, halfway, 1, 0, 50, 0, 50, false},
+		{"period without provider subscription", &models.Subscription{
+			CurrentPeriodStart: &start,
+			CurrentPeriodEnd:   &end,
+		}, pro, business, nil, halfway, 1, 0, 50, 0, 50, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := prorate(tt.subscription, tt.from, tt.to, tt.coupon, tt.now)
+
+			if got.RemainingFraction != tt.fraction || got.Credit != tt.credit || got.Charge != tt.charge ||
+				got.Discount != tt.discount || got.AmountDue != tt.due {
+				t.Errorf("fraction %v, credit %v, charge %v, discount %v, due %v; want %v, %v, %v, %v, %v",
+					got.RemainingFraction, got.Credit, got.Charge, got.Discount, got.AmountDue,
+					tt.fraction, tt.credit, tt.charge, tt.discount, tt.due)
+			}
+			if (got.PeriodStart != nil) != tt.inPeriod || (got.PeriodEnd != nil) != tt.inPeriod {
+				t.Errorf("period %v - %v, want in period %v", got.PeriodStart, got.PeriodEnd, tt.inPeriod)
+			}
+			if got.FromPlan != tt.from.Code || got.ToPlan != tt.to.Code || got.Currency != models.DefaultPlanCurrency {
+				t.Errorf("quote from %s to %s in %s", got.FromPlan, got.ToPlan, got.Currency)
+			}
+			if tt.coupon != nil && got.CouponCode != tt.coupon.Code {
+				t.Errorf("coupon code = %q, want %q", got.CouponCode, tt.coupon.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound          = errors.New("user not found")
+	ErrCannotImpersonate     = errors.New("platform admins cannot be impersonated")
+	ErrOrganizationSuspended = errors.New("organization is already suspended")
+	ErrOrganizationActive    = errors.New("organization is not suspended")
+	ErrUnknownPlan           = errors.New("unknown subscription plan")
+)
+
+// PlatformAdminService backs the cross-tenant admin console. Every mutating
+// action is written to the platform admin action log.
+type PlatformAdminService struct {
+	db             *gorm.DB
+	authz          *AuthzCache
+	sessionService *SessionService
+}
+
+func NewPlatformAdminService(db *gorm.DB, authz *AuthzCache, sessionService *SessionService) *PlatformAdminService {
+	return &PlatformAdminService{db: db, authz: authz, sessionService: sessionService}
+}
+
+// AdminActor identifies the platform admin performing an action
+type AdminActor struct {
+	AdminID   string
+	IPAddress string
+	UserAgent string
+}
+
+// AdminSearchParams filters and paginates admin searches
+type AdminSearchParams struct {
+	Query  string
+	Status string
+	Limit  int
+	Offset int
+}
+
+type AdminActionFilter struct {
+	AdminID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Limit      int
+	Offset     int
+}
+
+type SuspendOrganizationRequest struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+type AdminChangePlanRequest struct {
+	PlanType models.SubscriptionPlan `json:"plan_type" validate:"required"`
+	Reason   string                  `json:"reason" validate:"required,max=1000"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"required,max=1000"`
+}
+
+// OrganizationUsage is an organization's current resource usage
+type OrganizationUsage struct {
+	Members            int64 `json:"members"`
+	PendingInvitations int64 `json:"pending_invitations"`
+	Clients            int64 `json:"clients"`
+	InvoicesThisMonth  int64 `json:"invoices_this_month"`
+	InvoicesTotal      int64 `json:"invoices_total"`
+}
+
+// AdminOrganization is an organization with its subscription and usage
+type AdminOrganization struct {
+	models.Organization
+	Usage OrganizationUsage `json:"usage"`
+}
+
+// AdminMembership is one of a user's organization memberships
+type AdminMembership struct {
+	OrganizationID   string `json:"organization_id"`
+	OrganizationName string `json:"organization_name"`
+	Role             string `json:"role"`
+}
+
+// AdminUser is a user with all of their memberships
+type AdminUser struct {
+	ID                    string            `json:"id"`
+	Email                 string            `json:"email"`
+	FirstName             string            `json:"first_name"`
+	LastName              string            `json:"last_name"`
+	CompanyName           string            `json:"company_name"`
+	CurrentOrganizationID *string           `json:"current_organization_id"`
+	CreatedAt             time.Time         `json:"created_at"`
+	Memberships           []AdminMembership `json:"memberships"`
+}
+
+// ImpersonationResult is returned when an impersonation session starts
+type ImpersonationResult struct {
+	Token          string    `json:"token"`
+	SessionID      string    `json:"session_id"`
+	UserID         string    `json:"user_id"`
+	ImpersonatorID string    `json:"impersonator_id"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// SearchOrganizations finds organizations by name, subdomain or ID across all tenants
+func (s *PlatformAdminService) SearchOrganizations(params AdminSearchParams) ([]AdminOrganization, int64, error) {
+	query := s.db.Model(&models.Organization{})
+	if q := strings.TrimSpace(params.Query); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(name) LIKE ? OR LOWER(subdomain) LIKE ? OR CAST(id AS TEXT) = ?", like, like, q)
+	}
+	switch params.Status {
+	case "suspended":
+		query = query.Where("suspended_at IS NOT NULL")
+	case "active":
+		query = query.Where("suspended_at IS NULL")
+	}
+
+	// Reusable for both the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count organizations: %w", err)
+	}
+
+	var orgs []models.Organization
+	if err := query.Preload("Subscription", "status <> ?", models.SubscriptionStatusCancelled).
+		Order("created_at DESC").Limit(params.Limit).Offset(params.Offset).
+		Find(&orgs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search organizations: %w", err)
+	}
+
+	results := make([]AdminOrganization, 0, len(orgs))
+	for _, org := range orgs {
+		usage, err := s.organizationUsage(org.ID.String())
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, AdminOrganization{Organization: org, Usage: *usage})
+	}
+	return results, total, nil
+}
+
+func (s *PlatformAdminService) GetOrganization(organizationID string) (*AdminOrganization, error) {
+	var org models.Organization
+	if err := s.db.Preload("Subscription", "status <> ?", models.SubscriptionStatusCancelled).
+		First(&org, "id = ?", organizationID).Error; err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	usage, err := s.organizationUsage(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminOrganization{Organization: org, Usage: *usage}, nil
+}
+
+// SearchUsers finds users by email, name or ID across all tenants
+func (s *PlatformAdminService) SearchUsers(params AdminSearchParams) ([]AdminUser, int64, error) {
+	query := s.db.Model(&models.User{})
+	if q := strings.TrimSpace(params.Query); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(first_name || ' ' || last_name) LIKE ? OR CAST(id AS TEXT) = ?", like, like, q)
+	}
+
+	// Reusable for both the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []models.User
+	if err := query.Preload("UserOrganizationRoles.Role").Preload("UserOrganizationRoles.Organization").
+		Order("created_at DESC").Limit(params.Limit).Offset(params.Offset).
+		Find(&users).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	results := make([]AdminUser, 0, len(users))
+	for _, user := range users {
+		results = append(results, toAdminUser(user))
+	}
+	return results, total, nil
+}
+
+func (s *PlatformAdminService) GetUser(userID string) (*AdminUser, error) {
+	var user models.User
+	if err := s.db.Preload("UserOrganizationRoles.Role").Preload("UserOrganizationRoles.Organization").
+		First(&user, "id = ?", userID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	adminUser := toAdminUser(user)
+	return &adminUser, nil
+}
+
+// SuspendOrganization blocks all access to an organization except for platform admins
+func (s *PlatformAdminService) SuspendOrganization(actor AdminActor, organizationID, reason string) (*AdminOrganization, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		var org models.Organization
+		if err := tx.First(&org, "id = ?", organizationID).Error; err != nil {
+			return ErrOrganizationNotFound
+		}
+		if org.IsSuspended() {
+			return ErrOrganizationSuspended
+		}
+
+		if err := tx.Model(&org).UpdateColumns(map[string]interface{}{
+			"suspended_at":     time.Now(),
+			"suspended_reason": reason,
+			"suspended_by":     actor.AdminID,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to suspend organization: %w", err)
+		}
+
+		return recordAdminAction(tx, actor, models.AdminActionSuspendOrganization, "organization", organizationID,
+			models.JSONMap{"reason": reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateOrganization(organizationID)
+	return s.GetOrganization(organizationID)
+}
+
+func (s *PlatformAdminService) UnsuspendOrganization(actor AdminActor, organizationID, reason string) (*AdminOrganization, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		var org models.Organization
+		if err := tx.First(&org, "id = ?", organizationID).Error; err != nil {
+			return ErrOrganizationNotFound
+		}
+		if !org.IsSuspended() {
+			return ErrOrganizationActive
+		}
+
+		if err := tx.Model(&org).UpdateColumns(map[string]interface{}{
+			"suspended_at":     nil,
+			"suspended_reason": "",
+			"suspended_by":     nil,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to unsuspend organization: %w", err)
+		}
+
+		return recordAdminAction(tx, actor, models.AdminActionUnsuspendOrganization, "organization", organizationID,
+			models.JSONMap{"reason": reason, "suspended_reason": org.SuspendedReason})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateOrganization(organizationID)
+	return s.GetOrganization(organizationID)
+}
+
+// ChangePlan moves an organization to another plan without going through billing
+func (s *PlatformAdminService) ChangePlan(actor AdminActor, organizationID string, req *AdminChangePlanRequest) (*models.Subscription, error) {
+	plan, err := findPlan(s.db, req.PlanType)
+	if err != nil {
+		return nil, err
+	}
+
+	var subscription models.Subscription
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+
+		if err := tx.First(&models.Organization{}, "id = ?", organizationID).Error; err != nil {
+			return ErrOrganizationNotFound
+		}
+
+		err := tx.Where("organization_id = ? AND status <> ?", organizationID, models.SubscriptionStatusCancelled).
+			Order("created_at DESC").First(&subscription).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to fetch subscription: %w", err)
+		}
+		previousPlan := subscription.PlanType
+
+		subscription.OrganizationID = organizationID
+		subscription.Status = models.SubscriptionStatusActive
+		subscription.TrialEnd = nil
+		subscription.ApplyPlan(plan)
+		if _, err := reconcileLimitGrace(tx, &subscription); err != nil {
+			return err
+		}
+		if err := tx.Omit("Organization").Save(&subscription).Error; err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+
+		return recordAdminAction(tx, actor, models.AdminActionChangePlan, "organization", organizationID,
+			models.JSONMap{"reason": req.Reason, "from": previousPlan, "to": req.PlanType})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateOrganization(organizationID)
+
+	return &subscription, nil
+}
+
+// ImpersonateUser starts a short-lived, audited session acting as the user
+func (s *PlatformAdminService) ImpersonateUser(actor AdminActor, userID, reason string) (*ImpersonationResult, error) {
+	var user models.User
+	if err := s.db.Preload("UserOrganizationRoles.Role").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	if user.ID.String() == actor.AdminID {
+		return nil, fmt.Errorf("cannot impersonate yourself")
+	}
+	for _, uor := range user.UserOrganizationRoles {
+		if uor.Role.Name == models.RolePlatformAdmin {
+			return nil, ErrCannotImpersonate
+		}
+	}
+
+	session, token, err := s.sessionService.CreateImpersonationSession(actor.AdminID, user.ID, reason, actor.IPAddress, actor.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImpersonationResult{
+		Token:          token,
+		SessionID:      session.ID,
+		UserID:         session.UserID,
+		ImpersonatorID: actor.AdminID,
+		ExpiresAt:      session.ExpiresAt,
+	}, nil
+}
+
+// GetActions lists the platform admin action log, newest first
+func (s *PlatformAdminService) GetActions(filter AdminActionFilter) ([]models.PlatformAdminAction, int64, error) {
+	query := s.db.Model(&models.PlatformAdminAction{})
+	if filter.AdminID != "" {
+		query = query.Where("admin_id = ?", filter.AdminID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+
+	// Reusable for both the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count admin actions: %w", err)
+	}
+
+	var actions []models.PlatformAdminAction
+	if err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).
+		Find(&actions).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch admin actions: %w", err)
+	}
+	return actions, total, nil
+}
+
+func (s *PlatformAdminService) organizationUsage(organizationID string) (*OrganizationUsage, error) {
+	var usage OrganizationUsage
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	counts := []struct {
+		query *gorm.DB
+		dest  *int64
+	}{
+		{s.db.Model(&models.UserOrganizationRole{}).Where("organization_id = ?", organizationID), &usage.Members},
+		{s.db.Model(&models.OrganizationInvitation{}).Where("organization_id = ? AND status = ? AND expires_at > ?",
+			organizationID, models.InvitationStatusPending, now), &usage.PendingInvitations},
+	}
+	for _, c := range counts {
+		if err := c.query.Count(c.dest).Error; err != nil {
+			return nil, fmt.Errorf("failed to compute usage: %w", err)
+		}
+	}
+
+	// Clients and invoices are tenant tables behind row-level security
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Client{}).Where("organization_id = ?", organizationID).Count(&usage.Clients).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Invoice{}).Where("organization_id = ? AND created_at >= ?", organizationID, monthStart).
+			Count(&usage.InvoicesThisMonth).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Invoice{}).Where("organization_id = ?", organizationID).Count(&usage.InvoicesTotal).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute usage: %w", err)
+	}
+
+	return &usage, nil
+}
+
+func recordAdminAction(tx *gorm.DB, actor AdminActor, action, targetType, targetID string, details models.JSONMap) error {
+	if err := tx.Create(&models.PlatformAdminAction{
+		AdminID:    &actor.AdminID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		IPAddress:  actor.IPAddress,
+		UserAgent:  actor.UserAgent,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to record admin action: %w", err)
+	}
+	return nil
+}
+
+func toAdminUser(user models.User) AdminUser {
+	memberships := make([]AdminMembership, 0, len(user.UserOrganizationRoles))
+	for _, uor := range user.UserOrganizationRoles {
+		memberships = append(memberships, AdminMembership{
+			OrganizationID:   uor.OrganizationID,
+			OrganizationName: uor.Organization.Name,
+			Role:             uor.Role.Name,
+		})
+	}
+
+	return AdminUser{
+		ID:                    user.ID.String(),
+		Email:                 user.Email,
+		FirstName:             user.FirstName,
+		LastName:              user.LastName,
+		CompanyName:           user.CompanyName,
+		CurrentOrganizationID: user.CurrentOrganizationID,
+		CreatedAt:             user.CreatedAt,
+		Memberships:           memberships,
+	}
+}
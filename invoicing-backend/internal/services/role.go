@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoleNotFound        = errors.New("role not found")
+	ErrSystemRoleImmutable = errors.New("system roles cannot be modified")
+	ErrRoleNameTaken       = errors.New("role name is already in use")
+	ErrRoleInUse           = errors.New("role is still assigned to members or invitations")
+)
+
+type RoleService struct {
+	db    *gorm.DB
+	authz *AuthzCache
+}
+
+func NewRoleService(db *gorm.DB, authz *AuthzCache) *RoleService {
+	return &RoleService{db: db, authz: authz}
+}
+
+type CreateRoleRequest struct {
+	Name        string                 `json:"name" validate:"required,min=1,max=100"`
+	Description string                 `json:"description" validate:"max=1000"`
+	Permissions models.RolePermissions `json:"permissions" validate:"required"`
+}
+
+type UpdateRoleRequest struct {
+	Name        *string                `json:"name" validate:"omitempty,min=1,max=100"`
+	Description *string                `json:"description" validate:"omitempty,max=1000"`
+	Permissions models.RolePermissions `json:"permissions"`
+}
+
+// GetRoles lists the system roles that can be granted in an organization plus its custom roles
+func (s *RoleService) GetRoles(organizationID string) ([]models.Role, error) {
+	var roles []models.Role
+	if err := roleScope(s.db, organizationID).
+		Where("name <> ?", models.RolePlatformAdmin).
+		Order("is_system_role DESC, name ASC").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (s *RoleService) GetRoleByID(organizationID, roleID string) (*models.Role, error) {
+	var role models.Role
+	if err := roleScope(s.db, organizationID).First(&role, "id = ?", roleID).Error; err != nil {
+		return nil, ErrRoleNotFound
+	}
+	return &role, nil
+}
+
+// CreateRole defines a custom role. The creator may only grant permissions they hold.
+func (s *RoleService) CreateRole(audit AuditContext, organizationID string, creatorRole models.Role, req *CreateRoleRequest) (*models.Role, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkRoleDefinition(organizationID, "", name, req.Permissions, creatorRole); err != nil {
+		return nil, err
+	}
+
+	role := models.Role{
+		OrganizationID: &organizationID,
+		Name:           name,
+		Description:    req.Description,
+		Permissions:    req.Permissions,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return fmt.Errorf("failed to create role: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionCreate,
+			models.AuditResourceRole, role.ID, nil, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+func (s *RoleService) UpdateRole(audit AuditContext, organizationID, roleID string, actorRole models.Role, req *UpdateRoleRequest) (*models.Role, error) {
+	role, err := s.GetRoleByID(organizationID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if !role.IsCustom() {
+		return nil, ErrSystemRoleImmutable
+	}
+
+	// Editing a role changes what its current holders can do, so the actor must hold both
+	if !role.Permissions.IsSubsetOf(actorRole.Permissions) {
+		return nil, ErrRoleExceedsPermissions
+	}
+
+	before := *role
+	if req.Name != nil {
+		role.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		role.Permissions = req.Permissions
+	}
+
+	if err := s.checkRoleDefinition(organizationID, role.ID, role.Name, role.Permissions, actorRole); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Select("name", "description", "permissions").Updates(role).Error; err != nil {
+			return fmt.Errorf("failed to update role: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+			models.AuditResourceRole, role.ID, &before, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Members holding the role get its new permissions on their next request
+	s.authz.InvalidateOrganization(organizationID)
+	return role, nil
+}
+
+// DeleteRole removes a custom role that is no longer assigned to anyone
+func (s *RoleService) DeleteRole(audit AuditContext, organizationID, roleID string, actorRole models.Role) error {
+	role, err := s.GetRoleByID(organizationID, roleID)
+	if err != nil {
+		return err
+	}
+	if !role.IsCustom() {
+		return ErrSystemRoleImmutable
+	}
+	if !role.Permissions.IsSubsetOf(actorRole.Permissions) {
+		return ErrRoleExceedsPermissions
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var members, invitations, ssoConfigs int64
+		if err := tx.Model(&models.UserOrganizationRole{}).Where("role_id = ?", role.ID).Count(&members).Error; err != nil {
+			return fmt.Errorf("failed to check role usage: %w", err)
+		}
+		if err := tx.Model(&models.OrganizationInvitation{}).
+			Where("role_id = ? AND status = ?", role.ID, models.InvitationStatusPending).
+			Count(&invitations).Error; err != nil {
+			return fmt.Errorf("failed to check role usage: %w", err)
+		}
+		if err := tx.Model(&models.OrganizationSSOConfig{}).Where("default_role_id = ?", role.ID).
+			Count(&ssoConfigs).Error; err != nil {
+			return fmt.Errorf("failed to check role usage: %w", err)
+		}
+		if members > 0 || invitations > 0 || ssoConfigs > 0 {
+			return ErrRoleInUse
+		}
+
+		// Accepted, revoked and expired invitations still reference the role
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.OrganizationInvitation{}).Error; err != nil {
+			return fmt.Errorf("failed to delete old invitations: %w", err)
+		}
+
+		if err := tx.Delete(role).Error; err != nil {
+			return fmt.Errorf("failed to delete role: %w", err)
+		}
+		return recordAuditEvent(tx, audit, organizationID, models.AuditActionDelete,
+			models.AuditResourceRole, role.ID, role, nil)
+	})
+}
+
+func (s *RoleService) checkRoleDefinition(organizationID, roleID, name string, permissions models.RolePermissions, actorRole models.Role) error {
+	if name == "" {
+		return fmt.Errorf("role name is required")
+	}
+	if models.IsReservedRoleName(name) {
+		return ErrRoleNameTaken
+	}
+
+	if err := permissions.Validate(); err != nil {
+		return err
+	}
+	if !permissions.IsSubsetOf(actorRole.Permissions) {
+		return ErrRoleExceedsPermissions
+	}
+
+	var count int64
+	query := s.db.Model(&models.Role{}).Where("organization_id = ? AND name = ?", organizationID, name)
+	if roleID != "" {
+		query = query.Where("id <> ?", roleID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check role name: %w", err)
+	}
+	if count > 0 {
+		return ErrRoleNameTaken
+	}
+	return nil
+}
+
+// roleScope limits a role query to system roles and the organization's custom roles
+func roleScope(db *gorm.DB, organizationID string) *gorm.DB {
+	return db.Where("organization_id IS NULL OR organization_id = ?", organizationID)
+}
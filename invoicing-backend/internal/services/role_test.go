@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+)
+
+func TestCreateRoleGuards(t *testing.T) {
+	db := testutil.DB(t)
+	roles := NewRoleService(db, newTestAuthz(db))
+
+	owner, org := newTestOrganization(t, db, 5)
+	orgID := org.ID.String()
+	audit := AuditContext{UserID: owner.ID.String()}
+	admin := models.Role{Name: models.RoleOrgAdmin, Permissions: models.GetDefaultPermissions(models.RoleOrgAdmin)}
+	user := models.Role{Name: models.RoleOrgUser, Permissions: models.GetDefaultPermissions(models.RoleOrgUser)}
+
+	role, err := roles.CreateRole(audit, orgID, admin, &CreateRoleRequest{
+		Name:        " Approvers ",
+		Permissions: models.RolePermissions{models.ResourceInvoices: {models.PermissionRead, models.PermissionApprove}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create role: %v", err)
+	}
+	if role.Name != "Approvers" || !role.IsCustom() || *role.OrganizationID != orgID {
+		t.Fatalf("created role %q of organization %v", role.Name, role.OrganizationID)
+	}
+
+	tests := []struct {
+		name        string
+		roleName    string
+		permissions models.RolePermissions
+		actor       models.Role
+		wantErr     error
+	}{
+		{"grants an action the creator lacks", "Approvers 2",
+			models.RolePermissions{models.ResourceInvoices: {models.PermissionApprove}}, user, ErrRoleExceedsPermissions},
+		{"grants manage over the creator's actions", "Invoice managers",
+			models.RolePermissions{models.ResourceInvoices: {models.PermissionManage}}, user, ErrRoleExceedsPermissions},
+		{"grants a resource the creator lacks", "Billing",
+			models.RolePermissions{models.ResourceSubscription: {models.PermissionRead}}, user, ErrRoleExceedsPermissions},
+		{"system role name", models.RoleOrgAdmin,
+			models.RolePermissions{models.ResourceInvoices: {models.PermissionRead}}, admin, ErrRoleNameTaken},
+		{"name of another custom role", "Approvers",
+			models.RolePermissions{models.ResourceInvoices: {models.PermissionRead}}, admin, ErrRoleNameTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := roles.CreateRole(audit, orgID, tt.actor, &CreateRoleRequest{Name: tt.roleName, Permissions: tt.permissions})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Unknown grants are refused before the creator's permissions are compared
+	for _, permissions := range []models.RolePermissions{
+		{"payments": {models.PermissionRead}},
+		{models.ResourceInvoices: {"export"}},
+	} {
+		if _, err := roles.CreateRole(audit, orgID, admin, &CreateRoleRequest{Name: "Unknown", Permissions: permissions}); err == nil {
+			t.Errorf("role with %v created", permissions)
+		}
+	}
+}
+
+func TestUpdateAndDeleteRoleGuards(t *testing.T) {
+	db := testutil.DB(t)
+	roles := NewRoleService(db, newTestAuthz(db))
+
+	owner, org := newTestOrganization(t, db, 5)
+	orgID := org.ID.String()
+	audit := AuditContext{UserID: owner.ID.String()}
+	admin := models.Role{Name: models.RoleOrgAdmin, Permissions: models.GetDefaultPermissions(models.RoleOrgAdmin)}
+	user := models.Role{Name: models.RoleOrgUser, Permissions: models.GetDefaultPermissions(models.RoleOrgUser)}
+	invoiceManager := models.Role{Name: "Invoice managers", Permissions: models.RolePermissions{models.ResourceInvoices: {models.PermissionManage}}}
+
+	approvers, err := roles.CreateRole(audit, orgID, admin, &CreateRoleRequest{
+		Name:        "Approvers",
+		Permissions: models.RolePermissions{models.ResourceInvoices: {models.PermissionRead, models.PermissionApprove}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create role: %v", err)
+	}
+	if _, err := roles.CreateRole(audit, orgID, admin, &CreateRoleRequest{
+		Name:        "Readers",
+		Permissions: models.RolePermissions{models.ResourceInvoices: {models.PermissionRead}},
+	}); err != nil {
+		t.Fatalf("failed to create role: %v", err)
+	}
+
+	description := "Signs off large invoices"
+	rename := "Readers"
+	tests := []struct {
+		name    string
+		actor   models.Role
+		req     UpdateRoleRequest
+		wantErr error
+	}{
+		// Editing changes what the role's holders can do, even without new permissions
+		{"actor lacks the role's permissions", user, UpdateRoleRequest{Description: &description}, ErrRoleExceedsPermissions},
+		{"grants a resource the actor lacks", invoiceManager, UpdateRoleRequest{
+			Permissions: models.RolePermissions{models.ResourceInvoices: {models.PermissionRead}, models.ResourceClients: {models.PermissionRead}},
+		}, ErrRoleExceedsPermissions},
+		{"name of another custom role", admin, UpdateRoleRequest{Name: &rename}, ErrRoleNameTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := roles.UpdateRole(audit, orgID, approvers.ID, tt.actor, &tt.req); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := roles.UpdateRole(audit, orgID, approvers.ID, admin, &UpdateRoleRequest{
+		Permissions: models.RolePermissions{models.ResourceInvoices: {"export"}},
+	}); err == nil {
+		t.Fatal("role updated with an unknown action")
+	}
+
+	stored, err := roles.GetRoleByID(orgID, approvers.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Approvers" || stored.Description != "" || !stored.HasPermission(models.ResourceInvoices, models.PermissionApprove) ||
+		stored.HasPermission(models.ResourceClients, models.PermissionRead) {
+		t.Fatalf("refused updates changed the role: %+v", stored)
+	}
+
+	// An actor holding every permission of both versions may edit it
+	updated, err := roles.UpdateRole(audit, orgID, approvers.ID, invoiceManager, &UpdateRoleRequest{
+		Description: &description,
+		Permissions: models.RolePermissions{models.ResourceInvoices: {models.PermissionRead, models.PermissionUpdate, models.PermissionApprove}},
+	})
+	if err != nil {
+		t.Fatalf("failed to update role: %v", err)
+	}
+	if updated.Description != description || !updated.HasPermission(models.ResourceInvoices, models.PermissionUpdate) {
+		t.Fatalf("updated role = %+v", updated)
+	}
+
+	t.Run("system roles", func(t *testing.T) {
+		viewer := systemRole(t, db, models.RoleOrgViewer)
+		if _, err := roles.UpdateRole(audit, orgID, viewer, admin, &UpdateRoleRequest{Description: &description}); !errors.Is(err, ErrSystemRoleImmutable) {
+			t.Fatalf("update err = %v, want ErrSystemRoleImmutable", err)
+		}
+		if err := roles.DeleteRole(audit, orgID, viewer, admin); !errors.Is(err, ErrSystemRoleImmutable) {
+			t.Fatalf("delete err = %v, want ErrSystemRoleImmutable", err)
+		}
+	})
+
+	t.Run("other organization", func(t *testing.T) {
+		_, other := newTestOrganization(t, db, 5)
+		if _, err := roles.UpdateRole(audit, other.ID.String(), approvers.ID, admin, &UpdateRoleRequest{Description: &description}); !errors.Is(err, ErrRoleNotFound) {
+			t.Fatalf("update err = %v, want ErrRoleNotFound", err)
+		}
+		if err := roles.DeleteRole(audit, other.ID.String(), approvers.ID, admin); !errors.Is(err, ErrRoleNotFound) {
+			t.Fatalf("delete err = %v, want ErrRoleNotFound", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := roles.DeleteRole(audit, orgID, approvers.ID, user); !errors.Is(err, ErrRoleExceedsPermissions) {
+			t.Fatalf("err = %v, want ErrRoleExceedsPermissions", err)
+		}
+
+		member := newTestMember(t, db, org, approvers.ID)
+		if err := roles.DeleteRole(audit, orgID, approvers.ID, admin); !errors.Is(err, ErrRoleInUse) {
+			t.Fatalf("err = %v, want ErrRoleInUse", err)
+		}
+
+		if err := db.Where("user_id = ?", member.ID).Delete(&models.UserOrganizationRole{}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := roles.DeleteRole(audit, orgID, approvers.ID, admin); err != nil {
+			t.Fatalf("failed to delete role: %v", err)
+		}
+		if _, err := roles.GetRoleByID(orgID, approvers.ID); !errors.Is(err, ErrRoleNotFound) {
+			t.Fatalf("deleted role lookup err = %v, want ErrRoleNotFound", err)
+		}
+	})
+}
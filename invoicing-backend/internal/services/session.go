@@ -0,0 +1,206 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	sessionLifetime = 24 * time.Hour
+
+	// Impersonation tokens are short-lived and cannot be refreshed
+	impersonationLifetime = 30 * time.Minute
+
+	// last_seen_at is only written when older than this, to avoid a write per request
+	sessionTouchInterval = time.Minute
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionInactive = errors.New("session revoked or expired")
+)
+
+type SessionService struct {
+	db    *gorm.DB
+	authz *AuthzCache
+}
+
+func NewSessionService(db *gorm.DB, authz *AuthzCache) *SessionService {
+	return &SessionService{db: db, authz: authz}
+}
+
+// CreateSession records a new login session and returns it with its signed token
+func (s *SessionService) CreateSession(userID uuid.UUID, clientIP, userAgent string) (*models.UserSession, string, error) {
+	now := time.Now()
+	session := &models.UserSession{
+		UserID:     userID.String(),
+		UserAgent:  userAgent,
+		IPAddress:  clientIP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(sessionLifetime),
+	}
+
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	token, err := utils.GenerateJWT(userID, session.ID, session.ExpiresAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return session, token, nil
+}
+
+// CreateImpersonationSession starts a session in which a platform admin acts as
+// the user. The start is recorded in the platform admin action log.
+func (s *SessionService) CreateImpersonationSession(adminID string, userID uuid.UUID, reason, clientIP, userAgent string) (*models.UserSession, string, error) {
+	now := time.Now()
+	session := &models.UserSession{
+		UserID:              userID.String(),
+		UserAgent:           userAgent,
+		IPAddress:           clientIP,
+		LastSeenAt:          now,
+		ExpiresAt:           now.Add(impersonationLifetime),
+		ImpersonatorID:      &adminID,
+		ImpersonationReason: reason,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+
+		return tx.Create(&models.PlatformAdminAction{
+			AdminID:    &adminID,
+			Action:     models.AdminActionImpersonationStart,
+			TargetType: "user",
+			TargetID:   userID.String(),
+			SessionID:  &session.ID,
+			Details:    models.JSONMap{"reason": reason, "expires_at": session.ExpiresAt},
+			IPAddress:  clientIP,
+			UserAgent:  userAgent,
+		}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := utils.GenerateImpersonationJWT(userID, session.ID, adminID, session.ExpiresAt)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return session, token, nil
+}
+
+// RecordImpersonatedRequest logs a request made with an impersonation session
+func (s *SessionService) RecordImpersonatedRequest(session *models.UserSession, method, path string, status int, clientIP, userAgent string) {
+	if err := s.db.Create(&models.PlatformAdminAction{
+		AdminID:    session.ImpersonatorID,
+		Action:     models.AdminActionImpersonationRequest,
+		TargetType: "user",
+		TargetID:   session.UserID,
+		SessionID:  &session.ID,
+		Details:    models.JSONMap{"method": method, "path": path, "status": status},
+		IPAddress:  clientIP,
+		UserAgent:  userAgent,
+	}).Error; err != nil {
+		log.Printf("failed to record impersonated request for session %s: %v", session.ID, err)
+	}
+}
+
+// ValidateSession checks that the session backing a token is still active and
+// records activity from the given IP
+func (s *SessionService) ValidateSession(sessionID, userID, clientIP string) (*models.UserSession, error) {
+	var session models.UserSession
+	if err := s.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	if !session.IsActive() {
+		return nil, ErrSessionInactive
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval || session.IPAddress != clientIP {
+		now := time.Now()
+		if err := s.db.Model(&session).UpdateColumns(map[string]interface{}{
+			"last_seen_at": now,
+			"ip_address":   clientIP,
+		}).Error; err != nil {
+			log.Printf("failed to update session %s activity: %v", session.ID, err)
+		}
+		session.LastSeenAt = now
+		session.IPAddress = clientIP
+	}
+
+	return &session, nil
+}
+
+// GetActiveSessions lists the user's sessions that are neither revoked nor expired
+func (s *SessionService) GetActiveSessions(userID string) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes one of the user's own sessions
+func (s *SessionService) RevokeSession(sessionID, userID string) error {
+	result := s.db.Model(&models.UserSession{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_by": userID})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeOtherSessions revokes all of the user's sessions except the current one
+func (s *SessionService) RevokeOtherSessions(userID, currentSessionID string) (int64, error) {
+	result := s.db.Model(&models.UserSession{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, currentSessionID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_by": userID})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// ForceLogoutMember logs a member out of the given organization. Sessions are shared
+// by all of the user's organizations, so they aren't revoked; the membership instead
+// stops accepting sessions started before now, which also leaves other organizations
+// alone. It returns the number of active sessions that lost access.
+func (s *SessionService) ForceLogoutMember(organizationID, memberID string) (int64, error) {
+	now := time.Now()
+	result := s.db.Model(&models.UserOrganizationRole{}).
+		Where("user_id = ? AND organization_id = ?", memberID, organizationID).
+		Update("sessions_revoked_at", now)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, fmt.Errorf("user not found")
+	}
+	s.authz.InvalidateMembership(memberID, organizationID)
+
+	var count int64
+	if err := s.db.Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ? AND created_at < ?", memberID, now, now).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+)
+
+func TestForceLogoutMemberOnlyAffectsTheOrganization(t *testing.T) {
+	db := testutil.DB(t)
+	sessions := NewSessionService(db, newTestAuthz(db))
+
+	_, orgA := newTestOrganization(t, db, 5)
+	_, orgB := newTestOrganization(t, db, 5)
+	member := newTestUser(t, db, uniqueEmail("sessions.example.com"))
+	for _, orgID := range []string{orgA.ID.String(), orgB.ID.String()} {
+		if err := db.Create(&models.UserOrganizationRole{
+			UserID:         member.ID.String(),
+			OrganizationID: orgID,
+			RoleID:         systemRole(t, db, models.RoleOrgUser),
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	session, _, err := sessions.CreateSession(member.ID, "192.0.2.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked, err := sessions.ForceLogoutMember(orgA.ID.String(), member.ID.String())
+	if err != nil {
+		t.Fatalf("force logout failed: %v", err)
+	}
+	if revoked != 1 {
+		t.Fatalf("revoked = %d, want 1", revoked)
+	}
+
+	// The session itself stays valid for the member's other organizations
+	active, err := sessions.ValidateSession(session.ID, member.ID.String(), "192.0.2.1")
+	if err != nil {
+		t.Fatalf("session was revoked globally: %v", err)
+	}
+
+	authz := newTestAuthz(db)
+	membershipA, err := authz.GetMembership(member.ID.String(), orgA.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !membershipA.RevokesSession(active.CreatedAt) {
+		t.Fatal("session still reaches the organization it was logged out of")
+	}
+
+	membershipB, err := authz.GetMembership(member.ID.String(), orgB.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if membershipB.RevokesSession(active.CreatedAt) {
+		t.Fatal("force logout affected another organization")
+	}
+
+	if _, err := sessions.ForceLogoutMember(orgA.ID.String(), newTestUser(t, db, uniqueEmail("sessions.example.com")).ID.String()); err == nil {
+		t.Fatal("force logout of a non-member succeeded")
+	}
+}
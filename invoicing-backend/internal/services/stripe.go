@@ -0,0 +1,403 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+)
+
+// Stripe webhook event types we act on
+const (
+	StripeEventSubscriptionCreated = "customer.subscription.created"
+	StripeEventSubscriptionUpdated = "customer.subscription.updated"
+	StripeEventSubscriptionDeleted = "customer.subscription.deleted"
+)
+
+// Signed webhooks older than this are rejected to limit replays
+const stripeWebhookTolerance = 5 * time.Minute
+
+// stripeSubscription is the subset of Stripe's subscription object we rely on
+type stripeSubscription struct {
+	ID                 string            `json:"id"`
+	Customer           string            `json:"customer"`
+	Status             string            `json:"status"`
+	CurrentPeriodStart int64             `json:"current_period_start"`
+	CurrentPeriodEnd   int64             `json:"current_period_end"`
+	Metadata           map[string]string `json:"metadata"`
+	Items              struct {
+		Data []struct {
+			ID                 string `json:"id"`
+			CurrentPeriodStart int64  `json:"current_period_start"`
+			CurrentPeriodEnd   int64  `json:"current_period_end"`
+			Price              struct {
+				ID string `json:"id"`
+			} `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// stripeAPIError is a non-2xx response of the Stripe API
+type stripeAPIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+}
+
+func (e *stripeAPIError) Error() string {
+	return fmt.Sprintf("%s %s returned %d: %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// stripeAPI sends form-encoded requests to the Stripe API authenticated with a secret key
+type stripeAPI struct {
+	baseURL    string
+	secretKey  string
+	httpClient *http.Client
+}
+
+func newStripeAPI(baseURL, secretKey string) *stripeAPI {
+	return &stripeAPI{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		secretKey:  secretKey,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// stripeBillingProvider bills through Stripe Checkout and Stripe Billing subscriptions
+type stripeBillingProvider struct {
+	api           *stripeAPI
+	webhookSecret string
+	prices        map[models.SubscriptionPlan]string
+}
+
+// NewStripeBillingProvider bills through the Stripe API at baseURL (https://api.stripe.com,
+// or a Stripe-compatible stub). prices maps our plans to Stripe price IDs.
+func NewStripeBillingProvider(baseURL, secretKey, webhookSecret string, prices map[models.SubscriptionPlan]string) BillingProvider {
+	return &stripeBillingProvider{
+		api:           newStripeAPI(baseURL, secretKey),
+		webhookSecret: webhookSecret,
+		prices:        prices,
+	}
+}
+
+func (p *stripeBillingProvider) Name() string {
+	return models.BillingProviderStripe
+}
+
+func (p *stripeBillingProvider) SupportsCoupons() bool {
+	return true
+}
+
+// Checkout opens a Checkout Session for a new subscription. An existing subscription
+// is moved to the new price directly, with Stripe's proration. A coupon is created
+// at Stripe on first use and applied to the subscription.
+func (p *stripeBillingProvider) Checkout(ctx context.Context, params CheckoutParams) (*BillingCheckout, error) {
+	priceID := p.prices[params.Plan]
+	if priceID == "" {
+		return nil, ErrPlanNotPurchasable
+	}
+	if params.Coupon != nil {
+		if err := p.ensureCoupon(ctx, params.Coupon); err != nil {
+			return nil, err
+		}
+	}
+
+	if subscriptionID := params.Subscription.StripeSubscriptionID; subscriptionID != "" {
+		var current stripeSubscription
+		if err := p.api.do(ctx, http.MethodGet, "/v1/subscriptions/"+url.PathEscape(subscriptionID), nil, &current); err != nil {
+			return nil, fmt.Errorf("failed to fetch Stripe subscription: %w", err)
+		}
+		if len(current.Items.Data) == 0 {
+			return nil, fmt.Errorf("subscription %s has no items", subscriptionID)
+		}
+
+		form := url.Values{
+			"items[0][id]":       {current.Items.Data[0].ID},
+			"items[0][price]":    {priceID},
+			"proration_behavior": {"create_prorations"},
+		}
+		if params.Coupon != nil {
+			form.Set("discounts[0][coupon]", params.Coupon.Code)
+		}
+		if err := p.api.do(ctx, http.MethodPost, "/v1/subscriptions/"+url.PathEscape(subscriptionID), form, nil); err != nil {
+			return nil, fmt.Errorf("failed to update Stripe subscription: %w", err)
+		}
+		return &BillingCheckout{
+			Provider:               models.BillingProviderStripe,
+			ProviderSubscriptionID: subscriptionID,
+			CustomerID:             current.Customer,
+		}, nil
+	}
+
+	customerID := params.Subscription.StripeCustomerID
+	if customerID == "" {
+		var customer struct {
+			ID string `json:"id"`
+		}
+		form := url.Values{
+			"name":                      {params.OrganizationName},
+			"metadata[organization_id]": {params.OrganizationID},
+		}
+		if err := p.api.do(ctx, http.MethodPost, "/v1/customers", form, &customer); err != nil {
+			return nil, fmt.Errorf("failed to create Stripe customer: %w", err)
+		}
+		customerID = customer.ID
+	}
+
+	var session struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	form := url.Values{
+		"mode":                    {"subscription"},
+		"customer":                {customerID},
+		"client_reference_id":     {params.OrganizationID},
+		"line_items[0][price]":    {priceID},
+		"line_items[0][quantity]": {"1"},
+		"success_url":             {params.SuccessURL},
+		"cancel_url":              {params.CancelURL},
+		"subscription_data[metadata][organization_id]": {params.OrganizationID},
+	}
+	if params.Coupon != nil {
+		form.Set("discounts[0][coupon]", params.Coupon.Code)
+	}
+	if err := p.api.do(ctx, http.MethodPost, "/v1/checkout/sessions", form, &session); err != nil {
+		return nil, fmt.Errorf("failed to create Stripe checkout session: %w", err)
+	}
+
+	return &BillingCheckout{
+		Provider:    models.BillingProviderStripe,
+		CheckoutURL: session.URL,
+		CustomerID:  customerID,
+	}, nil
+}
+
+// ensureCoupon creates the Stripe coupon with the coupon's code as its ID, unless
+// it exists. Redemption limits and expiry are enforced by us, not by Stripe.
+func (p *stripeBillingProvider) ensureCoupon(ctx context.Context, coupon *models.Coupon) error {
+	path := "/v1/coupons/" + url.PathEscape(coupon.Code)
+	err := p.api.do(ctx, http.MethodGet, path, nil, nil)
+	if err == nil {
+		return nil
+	}
+	var apiErr *stripeAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to fetch Stripe coupon: %w", err)
+	}
+
+	form := url.Values{
+		"id":       {coupon.Code},
+		"name":     {coupon.Name},
+		"duration": {coupon.Duration},
+	}
+	switch coupon.DiscountType {
+	case models.CouponDiscountPercent:
+		form.Set("percent_off", strconv.FormatFloat(coupon.PercentOff, 'f', -1, 64))
+	case models.CouponDiscountFixed:
+		form.Set("amount_off", strconv.FormatInt(toMinorUnits(coupon.AmountOff, coupon.Currency), 10))
+		form.Set("currency", strings.ToLower(coupon.Currency))
+	}
+	if coupon.Duration == models.CouponDurationRepeating {
+		form.Set("duration_in_months", strconv.Itoa(coupon.DurationInMonths))
+	}
+	if err := p.api.do(ctx, http.MethodPost, "/v1/coupons", form, nil); err != nil {
+		return fmt.Errorf("failed to create Stripe coupon: %w", err)
+	}
+	return nil
+}
+
+// PortalURL opens a customer portal session for the organization's Stripe customer
+func (p *stripeBillingProvider) PortalURL(ctx context.Context, subscription *models.Subscription, returnURL string) (string, error) {
+	if subscription.StripeCustomerID == "" {
+		return "", ErrNoBillingSubscription
+	}
+
+	var session struct {
+		URL string `json:"url"`
+	}
+	form := url.Values{
+		"customer":   {subscription.StripeCustomerID},
+		"return_url": {returnURL},
+	}
+	if err := p.api.do(ctx, http.MethodPost, "/v1/billing_portal/sessions", form, &session); err != nil {
+		return "", fmt.Errorf("failed to create Stripe portal session: %w", err)
+	}
+	return session.URL, nil
+}
+
+// Cancel ends the subscription immediately
+func (p *stripeBillingProvider) Cancel(ctx context.Context, subscription *models.Subscription, reason string) error {
+	form := url.Values{}
+	if reason != "" {
+		form.Set("cancellation_details[comment]", reason)
+	}
+	if err := p.api.do(ctx, http.MethodDelete, "/v1/subscriptions/"+url.PathEscape(subscription.StripeSubscriptionID), form, nil); err != nil {
+		return fmt.Errorf("failed to cancel Stripe subscription: %w", err)
+	}
+	return nil
+}
+
+func (p *stripeBillingProvider) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*BillingEvent, error) {
+	if err := verifyStripeSignature(p.webhookSecret, headers.Get("Stripe-Signature"), body, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" || event.Type == "" {
+		return nil, ErrInvalidWebhookPayload
+	}
+
+	billingEvent := &BillingEvent{ID: event.ID, Type: event.Type}
+	switch event.Type {
+	case StripeEventSubscriptionCreated, StripeEventSubscriptionUpdated, StripeEventSubscriptionDeleted:
+		var subscription stripeSubscription
+		if err := json.Unmarshal(event.Data.Object, &subscription); err != nil || subscription.ID == "" {
+			return nil, ErrInvalidWebhookPayload
+		}
+		// Stripe doesn't order deliveries and retries for days, so an event may
+		// arrive after later changes; apply the subscription's current state
+		if err := p.api.do(ctx, http.MethodGet, "/v1/subscriptions/"+url.PathEscape(subscription.ID), nil, &subscription); err != nil {
+			return nil, fmt.Errorf("failed to fetch Stripe subscription: %w", err)
+		}
+		billingEvent.Subscription = p.providerSubscription(&subscription)
+	}
+	return billingEvent, nil
+}
+
+// verifyStripeSignature checks the Stripe-Signature header: an HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the endpoint secret
+func verifyStripeSignature(secret, header string, body []byte, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidWebhookSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > stripeWebhookTolerance || age < -stripeWebhookTolerance {
+		return ErrInvalidWebhookSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, signature := range signatures {
+		if given, err := hex.DecodeString(signature); err == nil && hmac.Equal(given, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidWebhookSignature
+}
+
+func (p *stripeBillingProvider) providerSubscription(subscription *stripeSubscription) *ProviderSubscription {
+	result := &ProviderSubscription{
+		ID:             subscription.ID,
+		CustomerID:     subscription.Customer,
+		OrganizationID: subscription.Metadata["organization_id"],
+	}
+
+	// Newer API versions report the billing period per item
+	periodStart, periodEnd := subscription.CurrentPeriodStart, subscription.CurrentPeriodEnd
+	if len(subscription.Items.Data) > 0 {
+		item := subscription.Items.Data[0]
+		result.PlanID = item.Price.ID
+		result.Plan = planForProviderPlan(p.prices, item.Price.ID)
+		if periodStart == 0 {
+			periodStart, periodEnd = item.CurrentPeriodStart, item.CurrentPeriodEnd
+		}
+	}
+	if periodStart > 0 {
+		start := time.Unix(periodStart, 0).UTC()
+		result.PeriodStart = &start
+	}
+	if periodEnd > 0 {
+		end := time.Unix(periodEnd, 0).UTC()
+		result.PeriodEnd = &end
+	}
+
+	switch subscription.Status {
+	case "active", "trialing":
+		result.Status = models.SubscriptionStatusActive
+	case "past_due", "unpaid":
+		result.Status = models.SubscriptionStatusPastDue
+	case "canceled", "incomplete_expired":
+		result.Status = models.SubscriptionStatusCancelled
+	}
+	return result
+}
+
+// do sends a form-encoded request authenticated with the secret key and decodes
+// the response into out (if not nil)
+func (a *stripeAPI) do(ctx context.Context, method, path string, form url.Values, out interface{}) error {
+	endpoint := a.baseURL + path
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodDelete {
+		if len(form) > 0 {
+			endpoint += "?" + form.Encode()
+		}
+	} else if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.secretKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var stripeErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4<<10)).Decode(&stripeErr)
+		return &stripeAPIError{Method: method, Path: path, StatusCode: resp.StatusCode, Message: stripeErr.Error.Message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
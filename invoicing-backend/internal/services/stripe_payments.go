@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+)
+
+// Stripe Checkout events that settle an invoice payment
+const (
+	StripeEventCheckoutCompleted             = "checkout.session.completed"
+	StripeEventCheckoutAsyncPaymentSucceeded = "checkout.session.async_payment_succeeded"
+	StripeEventCheckoutAsyncPaymentFailed    = "checkout.session.async_payment_failed"
+	StripeEventCheckoutExpired               = "checkout.session.expired"
+)
+
+// stripeCheckoutSession is the subset of Stripe's Checkout Session object we rely on
+type stripeCheckoutSession struct {
+	ID            string            `json:"id"`
+	Mode          string            `json:"mode"`
+	PaymentStatus string            `json:"payment_status"`
+	PaymentIntent string            `json:"payment_intent"`
+	AmountTotal   int64             `json:"amount_total"`
+	Currency      string            `json:"currency"`
+	Metadata      map[string]string `json:"metadata"`
+}
+
+// stripePaymentGateway collects invoice payments with one-off Stripe Checkout sessions
+type stripePaymentGateway struct {
+	api           *stripeAPI
+	webhookSecret string
+}
+
+// NewStripePaymentGateway collects payments through the Stripe API at baseURL.
+// webhookSecret is the signing secret of the invoice payments webhook endpoint.
+func NewStripePaymentGateway(baseURL, secretKey, webhookSecret string) PaymentGateway {
+	return &stripePaymentGateway{api: newStripeAPI(baseURL, secretKey), webhookSecret: webhookSecret}
+}
+
+func (g *stripePaymentGateway) Name() string {
+	return models.PaymentGatewayStripe
+}
+
+func (g *stripePaymentGateway) CreateCheckout(ctx context.Context, params PaymentCheckoutParams) (*PaymentCheckout, error) {
+	var session struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	form := url.Values{
+		"mode":                                   {"payment"},
+		"client_reference_id":                    {params.InvoiceID},
+		"success_url":                            {params.SuccessURL},
+		"cancel_url":                             {params.CancelURL},
+		"line_items[0][price_data][currency]":    {strings.ToLower(params.Currency)},
+		"line_items[0][price_data][unit_amount]": {strconv.FormatInt(toMinorUnits(params.Amount, params.Currency), 10)},
+		"line_items[0][price_data][product_data][name]": {fmt.Sprintf("Invoice %s from %s", params.InvoiceNumber, params.OrganizationName)},
+		"line_items[0][quantity]":                       {"1"},
+		"metadata[organization_id]":                     {params.OrganizationID},
+		"metadata[invoice_id]":                          {params.InvoiceID},
+		"payment_intent_data[metadata][invoice_id]":     {params.InvoiceID},
+	}
+	if params.CustomerEmail != "" {
+		form.Set("customer_email", params.CustomerEmail)
+	}
+	if err := g.api.do(ctx, http.MethodPost, "/v1/checkout/sessions", form, &session); err != nil {
+		return nil, fmt.Errorf("failed to create Stripe checkout session: %w", err)
+	}
+
+	return &PaymentCheckout{SessionID: session.ID, CheckoutURL: session.URL}, nil
+}
+
+func (g *stripePaymentGateway) ParseWebhook(ctx context.Context, headers http.Header, body []byte) (*PaymentEvent, error) {
+	if err := verifyStripeSignature(g.webhookSecret, headers.Get("Stripe-Signature"), body, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" || event.Type == "" {
+		return nil, ErrInvalidWebhookPayload
+	}
+
+	paymentEvent := &PaymentEvent{ID: event.ID, Type: event.Type}
+	var status models.InvoicePaymentStatus
+	switch event.Type {
+	case StripeEventCheckoutCompleted, StripeEventCheckoutAsyncPaymentSucceeded:
+		status = models.InvoicePaymentSucceeded
+	case StripeEventCheckoutAsyncPaymentFailed:
+		status = models.InvoicePaymentFailed
+	case StripeEventCheckoutExpired:
+		status = models.InvoicePaymentExpired
+	default:
+		return paymentEvent, nil
+	}
+
+	var session stripeCheckoutSession
+	if err := json.Unmarshal(event.Data.Object, &session); err != nil || session.ID == "" {
+		return nil, ErrInvalidWebhookPayload
+	}
+	// Subscription checkouts and sessions created elsewhere
+	if session.Mode != "payment" || session.Metadata["invoice_id"] == "" {
+		return paymentEvent, nil
+	}
+	// Bank debits complete the session before the money arrives; async_payment_succeeded follows
+	if status == models.InvoicePaymentSucceeded && session.PaymentStatus != "paid" {
+		return paymentEvent, nil
+	}
+
+	paymentEvent.Payment = &GatewayPayment{
+		SessionID:      session.ID,
+		PaymentID:      session.PaymentIntent,
+		OrganizationID: session.Metadata["organization_id"],
+		InvoiceID:      session.Metadata["invoice_id"],
+		Status:         status,
+		Amount:         fromMinorUnits(session.AmountTotal, session.Currency),
+		Currency:       strings.ToUpper(session.Currency),
+	}
+	return paymentEvent, nil
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/stripefake"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+)
+
+const testStripeWebhookSecret = "whsec_test"
+
+// newFakeStripe starts a fake Stripe whose subscription webhooks are collected
+// instead of being delivered to the API
+func newFakeStripe(t *testing.T) (*stripefake.Server, *httptest.Server, <-chan webhookDelivery) {
+	t.Helper()
+
+	receiver, deliveries := newWebhookReceiver(t)
+	fake := stripefake.New(receiver.URL, testStripeWebhookSecret, "", "")
+	server := httptest.NewServer(fake.Handler())
+	t.Cleanup(server.Close)
+	return fake, server, deliveries
+}
+
+func newTestStripeProvider(server *httptest.Server) BillingProvider {
+	return NewStripeBillingProvider(server.URL, "sk_test", testStripeWebhookSecret,
+		map[models.SubscriptionPlan]string{models.SubscriptionPlanPro: "price_pro"})
+}
+
+// eventObject returns the data.object of a Stripe event
+func eventObject(t *testing.T, body []byte) json.RawMessage {
+	t.Helper()
+
+	var event struct {
+		Data struct {
+			Object json.RawMessage `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	return event.Data.Object
+}
+
+func TestStripeWebhookSignature(t *testing.T) {
+	ctx := context.Background()
+	fake, server, _ := newFakeStripe(t)
+	provider := newTestStripeProvider(server)
+
+	// Events that don't touch subscriptions are verified without calling back to Stripe
+	invoice := map[string]string{"id": "in_1", "object": "invoice"}
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		secret string
+		at     time.Time
+		valid  bool
+	}{
+		{"current", testStripeWebhookSecret, now, true},
+		{"within tolerance", testStripeWebhookSecret, now.Add(-stripeWebhookTolerance + time.Minute), true},
+		{"too old", testStripeWebhookSecret, now.Add(-stripeWebhookTolerance - time.Minute), false},
+		{"too far ahead", testStripeWebhookSecret, now.Add(stripeWebhookTolerance + time.Minute), false},
+		{"wrong secret", "whsec_other", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, body, err := fake.Event(tt.secret, "invoice.paid", invoice, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = provider.ParseWebhook(ctx, headers, body)
+			if tt.valid && err != nil {
+				t.Fatalf("event rejected: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidWebhookSignature) {
+				t.Fatalf("err = %v, want ErrInvalidWebhookSignature", err)
+			}
+		})
+	}
+
+	t.Run("tampered body", func(t *testing.T) {
+		headers, body, err := fake.Event(testStripeWebhookSecret, "invoice.paid", invoice, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tampered := bytes.Replace(body, []byte(`"in_1"`), []byte(`"in_2"`), 1)
+		if _, err := provider.ParseWebhook(ctx, headers, tampered); !errors.Is(err, ErrInvalidWebhookSignature) {
+			t.Fatalf("err = %v, want ErrInvalidWebhookSignature", err)
+		}
+	})
+
+	t.Run("rotated secret", func(t *testing.T) {
+		// During a secret rotation Stripe signs with both secrets
+		headers, body, err := fake.Event(testStripeWebhookSecret, "invoice.paid", invoice, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		old, _, err := fake.Event("whsec_old", "invoice.paid", invoice, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers.Set("Stripe-Signature", old.Get("Stripe-Signature")+","+headers.Get("Stripe-Signature"))
+		if _, err := provider.ParseWebhook(ctx, headers, body); err != nil {
+			t.Fatalf("event rejected: %v", err)
+		}
+	})
+}
+
+func TestStripeLateEventReportsCurrentState(t *testing.T) {
+	ctx := context.Background()
+	fake, server, deliveries := newFakeStripe(t)
+	provider := newTestStripeProvider(server)
+
+	checkout, err := provider.Checkout(ctx, CheckoutParams{
+		OrganizationID: "org-1",
+		Plan:           models.SubscriptionPlanPro,
+		Subscription:   &models.Subscription{},
+		SuccessURL:     "http://app.example.com/settings/billing?checkout=success",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	followCheckout(t, checkout.CheckoutURL)
+	created := <-deliveries
+
+	event, err := provider.ParseWebhook(ctx, created.headers, created.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Subscription.Status != models.SubscriptionStatusActive || event.Subscription.Plan != models.SubscriptionPlanPro ||
+		event.Subscription.OrganizationID != "org-1" || event.Subscription.CustomerID != checkout.CustomerID {
+		t.Fatalf("created event subscription = %+v, want active pro of org-1", event.Subscription)
+	}
+
+	if err := provider.Cancel(ctx, &models.Subscription{StripeSubscriptionID: event.Subscription.ID}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The created event arrives after the cancellation, under a new event ID
+	headers, body, err := fake.Event(testStripeWebhookSecret, StripeEventSubscriptionCreated, eventObject(t, created.body), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err = provider.ParseWebhook(ctx, headers, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Subscription.Status != models.SubscriptionStatusCancelled {
+		t.Fatalf("late event reports status %q, want cancelled", event.Subscription.Status)
+	}
+}
+
+func TestStripeSubscriptionLifecycle(t *testing.T) {
+	db := testutil.DB(t)
+	ctx := context.Background()
+	fake, server, deliveries := newFakeStripe(t)
+
+	owner, org := newTestOrganization(t, db, 5)
+	audit := AuditContext{UserID: owner.ID.String()}
+	subscriptions := NewSubscriptionService(db, newTestAuthz(db), NewLogNotifier(),
+		[]BillingProvider{newTestStripeProvider(server)}, models.BillingProviderStripe, "http://app.example.com", TrialConfig{})
+
+	checkout, err := subscriptions.StartCheckout(ctx, audit, org.ID.String(), &CheckoutRequest{PlanType: models.SubscriptionPlanPro})
+	if err != nil {
+		t.Fatalf("checkout failed: %v", err)
+	}
+	if checkout.Provider != models.BillingProviderStripe || checkout.CheckoutURL == "" {
+		t.Fatalf("checkout = %+v, want a Stripe checkout URL", checkout)
+	}
+
+	followCheckout(t, checkout.CheckoutURL)
+	created := <-deliveries
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderStripe, created.headers, created.body); err != nil {
+		t.Fatalf("created webhook failed: %v", err)
+	}
+
+	subscription, err := subscriptions.GetSubscription(org.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscription.PlanType != models.SubscriptionPlanPro || subscription.StripeSubscriptionID == "" ||
+		subscription.StripeCustomerID != checkout.CustomerID {
+		t.Fatalf("after checkout plan = %s, Stripe subscription = %q, customer = %q; want pro, linked, %q",
+			subscription.PlanType, subscription.StripeSubscriptionID, subscription.StripeCustomerID, checkout.CustomerID)
+	}
+	if subscription.CurrentPeriodEnd == nil {
+		t.Fatal("billing period not set")
+	}
+
+	// Stripe retries the same event, e.g. after a timeout
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderStripe, created.headers, created.body); err != nil {
+		t.Fatalf("redelivery failed: %v", err)
+	}
+	var event struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(created.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	var recorded int64
+	if err := db.Model(&models.BillingWebhookEvent{}).
+		Where("provider = ? AND event_id = ?", models.BillingProviderStripe, event.ID).Count(&recorded).Error; err != nil {
+		t.Fatal(err)
+	}
+	if recorded != 1 {
+		t.Fatalf("event recorded %d times, want 1", recorded)
+	}
+
+	if _, err := subscriptions.CancelSubscription(ctx, audit, org.ID.String(), ""); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	deleted := <-deliveries
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderStripe, deleted.headers, deleted.body); err != nil {
+		t.Fatalf("deleted webhook failed: %v", err)
+	}
+
+	// An update sent before the cancellation but delivered after it must not relink the subscription
+	headers, body, err := fake.Event(testStripeWebhookSecret, StripeEventSubscriptionUpdated, eventObject(t, created.body), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderStripe, headers, body); err != nil {
+		t.Fatalf("late update failed: %v", err)
+	}
+
+	subscription, err = subscriptions.GetSubscription(org.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subscription.PlanType != models.SubscriptionPlanFree || subscription.StripeSubscriptionID != "" {
+		t.Fatalf("after cancellation plan = %s, Stripe subscription = %q; want free and unlinked",
+			subscription.PlanType, subscription.StripeSubscriptionID)
+	}
+	if subscription.StripeCustomerID != checkout.CustomerID {
+		t.Fatal("Stripe customer was dropped with the subscription")
+	}
+
+	headers, body, err = fake.Event(testStripeWebhookSecret, StripeEventSubscriptionUpdated, eventObject(t, created.body),
+		time.Now().Add(-stripeWebhookTolerance-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := subscriptions.HandleWebhook(ctx, models.BillingProviderStripe, headers, body); !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Fatalf("stale webhook: err = %v, want ErrInvalidWebhookSignature", err)
+	}
+}
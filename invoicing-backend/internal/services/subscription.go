@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSubscriptionNotFound         = errors.New("subscription not found")
+	ErrPaymentProviderNotConfigured = errors.New("payment provider is not configured")
+	ErrPlanNotPurchasable           = errors.New("plan is not available for purchase")
+	ErrAlreadyOnPlan                = errors.New("organization is already subscribed to this plan")
+	ErrNoBillingSubscription        = errors.New("organization has no paid subscription")
+	ErrBillingProviderMismatch      = errors.New("organization is billed by another provider; cancel that subscription first")
+	ErrInvalidWebhookSignature      = errors.New("invalid webhook signature")
+	ErrInvalidWebhookPayload        = errors.New("invalid webhook payload")
+)
+
+type CheckoutRequest struct {
+	PlanType models.SubscriptionPlan `json:"plan_type" validate:"required,max=50"`
+	// Provider defaults to the organization's current provider, then the platform default
+	Provider string `json:"provider" validate:"omitempty,oneof=paypal stripe"`
+	// CouponCode is redeemed once the provider accepted the checkout
+	CouponCode string `json:"coupon_code" validate:"omitempty,max=50"`
+}
+
+type CancelSubscriptionRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type SubscriptionService struct {
+	db              *gorm.DB
+	authz           *AuthzCache
+	notifier        Notifier
+	providers       map[string]BillingProvider
+	defaultProvider string
+	frontendURL     string
+	trial           TrialConfig
+}
+
+// NewSubscriptionService bills through the configured providers. Organizations
+// without a paid subscription check out with defaultProvider unless they pick one.
+func NewSubscriptionService(db *gorm.DB, authz *AuthzCache, notifier Notifier, providers []BillingProvider, defaultProvider, frontendURL string, trial TrialConfig) *SubscriptionService {
+	s := &SubscriptionService{
+		db:              db,
+		authz:           authz,
+		notifier:        notifier,
+		providers:       make(map[string]BillingProvider),
+		defaultProvider: defaultProvider,
+		frontendURL:     frontendURL,
+		trial:           trial,
+	}
+	for _, provider := range providers {
+		s.providers[provider.Name()] = provider
+	}
+	return s
+}
+
+// GetSubscription returns the organization's current subscription, whatever its status
+func (s *SubscriptionService) GetSubscription(organizationID string) (*models.Subscription, error) {
+	return currentSubscription(s.db, organizationID)
+}
+
+// StartCheckout subscribes the organization to a paid plan, or changes the plan
+// of its paid subscription, with the organization's billing provider. A coupon in
+// the request is redeemed once the provider accepted the checkout; otherwise a
+// coupon redeemed but not yet billed is passed to providers that take coupons.
+func (s *SubscriptionService) StartCheckout(ctx context.Context, audit AuditContext, organizationID string, req *CheckoutRequest) (*BillingCheckout, error) {
+	if _, err := findPlan(s.db, req.PlanType); err != nil {
+		return nil, err
+	}
+
+	subscription, err := currentSubscription(s.db, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	providerName := req.Provider
+	if providerName == "" {
+		providerName = subscription.BillingProvider
+	}
+	if providerName == "" {
+		providerName = s.defaultProvider
+	}
+
+	if subscription.ProviderSubscriptionID() != "" {
+		if subscription.BillingProvider != providerName {
+			return nil, ErrBillingProviderMismatch
+		}
+		if subscription.PlanType == req.PlanType {
+			return nil, ErrAlreadyOnPlan
+		}
+	}
+
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrPaymentProviderNotConfigured
+	}
+
+	var org models.Organization
+	if err := s.db.Select("id", "name").First(&org, "id = ?", organizationID).Error; err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+
+	params := CheckoutParams{
+		OrganizationID:   organizationID,
+		OrganizationName: org.Name,
+		Plan:             req.PlanType,
+		Subscription:     subscription,
+		SuccessURL:       s.frontendURL + "/settings/billing?checkout=success",
+		CancelURL:        s.frontendURL + "/settings/billing?checkout=cancelled",
+	}
+	// A discount already billed stays on the provider's subscription
+	now := time.Now()
+	redemption, err := subscriptionDiscount(s.db, subscription, now)
+	if err != nil {
+		return nil, err
+	}
+	if req.CouponCode != "" {
+		// Checked here and redeemed after the checkout, so a failed checkout keeps nothing
+		if !provider.SupportsCoupons() {
+			return nil, ErrCouponNotSupported
+		}
+		if redemption != nil && redemption.AppliedAt != nil {
+			return nil, ErrDiscountActive
+		}
+		params.Coupon, err = checkCoupon(s.db, organizationID, req.CouponCode, req.PlanType, now)
+		if err != nil {
+			return nil, err
+		}
+	} else if redemption != nil && redemption.AppliedAt == nil && redemption.Coupon.AppliesTo(req.PlanType) && provider.SupportsCoupons() {
+		params.Coupon = redemption.Coupon
+	}
+
+	checkout, err := provider.Checkout(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the provider's customer so later checkouts and portal links reuse it
+	if providerName == models.BillingProviderStripe && checkout.CustomerID != "" && checkout.CustomerID != subscription.StripeCustomerID {
+		if err := s.db.Model(subscription).Update("stripe_customer_id", checkout.CustomerID).Error; err != nil {
+			return nil, fmt.Errorf("failed to store Stripe customer: %w", err)
+		}
+	}
+
+	if req.CouponCode != "" {
+		if _, err := redeemForOrganization(s.db, audit, organizationID, req.CouponCode, req.PlanType); err != nil {
+			return nil, err
+		}
+		s.authz.InvalidateOrganization(organizationID)
+	}
+
+	return checkout, nil
+}
+
+// GetPortalURL returns the provider's self-service page for payment details and invoices
+func (s *SubscriptionService) GetPortalURL(ctx context.Context, organizationID string) (string, error) {
+	subscription, err := currentSubscription(s.db, organizationID)
+	if err != nil {
+		return "", err
+	}
+	if subscription.BillingProvider == "" {
+		return "", ErrNoBillingSubscription
+	}
+
+	provider, ok := s.providers[subscription.BillingProvider]
+	if !ok {
+		return "", ErrPaymentProviderNotConfigured
+	}
+	return provider.PortalURL(ctx, subscription, s.frontendURL+"/settings/billing")
+}
+
+// CancelSubscription cancels the paid subscription with its provider and moves the
+// organization back to the free plan
+func (s *SubscriptionService) CancelSubscription(ctx context.Context, audit AuditContext, organizationID, reason string) (*models.Subscription, error) {
+	subscription, err := currentSubscription(s.db, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	providerSubscriptionID := subscription.ProviderSubscriptionID()
+	if providerSubscriptionID == "" {
+		return nil, ErrNoBillingSubscription
+	}
+
+	provider, ok := s.providers[subscription.BillingProvider]
+	if !ok {
+		return nil, ErrPaymentProviderNotConfigured
+	}
+	if err := provider.Cancel(ctx, subscription, reason); err != nil {
+		return nil, err
+	}
+
+	// The cancellation webhook that follows finds nothing left to change
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		_, err := applyProviderSubscription(tx, audit, provider.Name(), &ProviderSubscription{
+			ID:     providerSubscriptionID,
+			Status: models.SubscriptionStatusCancelled,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.authz.InvalidateOrganization(organizationID)
+
+	return currentSubscription(s.db, organizationID)
+}
+
+// HandleWebhook verifies and applies a billing provider's webhook event. Each event
+// is applied once; redeliveries of a processed event are acknowledged without effect.
+func (s *SubscriptionService) HandleWebhook(ctx context.Context, providerName string, headers http.Header, body []byte) error {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return ErrPaymentProviderNotConfigured
+	}
+
+	event, err := provider.ParseWebhook(ctx, headers, body)
+	if err != nil {
+		return err
+	}
+
+	var organizationID string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		record := models.BillingWebhookEvent{
+			Provider:  providerName,
+			EventID:   event.ID,
+			EventType: event.Type,
+		}
+		if event.Subscription != nil {
+			record.ResourceID = event.Subscription.ID
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return fmt.Errorf("failed to record webhook event: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil // already processed
+		}
+
+		if event.Subscription == nil {
+			return nil
+		}
+
+		organizationID, err = applyProviderSubscription(tx, AuditContext{}, providerName, event.Subscription)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if organizationID != "" {
+		s.authz.InvalidateOrganization(organizationID)
+	}
+	return nil
+}
+
+// applyProviderSubscription updates the local subscription linked to the provider's
+// subscription and returns its organization ID, or "" if nothing was linked
+func applyProviderSubscription(tx *gorm.DB, audit AuditContext, providerName string, providerSubscription *ProviderSubscription) (string, error) {
+	column := providerSubscriptionColumn(providerName)
+	if column == "" {
+		return "", ErrPaymentProviderNotConfigured
+	}
+
+	var subscription models.Subscription
+	err := tx.Where(column+" = ?", providerSubscription.ID).Order("created_at DESC").First(&subscription).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// A new subscription isn't linked yet; the provider echoes our organization ID
+		if providerSubscription.Status != models.SubscriptionStatusActive || providerSubscription.OrganizationID == "" {
+			return "", nil
+		}
+		err = tx.Where("organization_id = ? AND status <> ?", providerSubscription.OrganizationID, models.SubscriptionStatusCancelled).
+			Order("created_at DESC").First(&subscription).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("ignoring %s subscription %s: no matching subscription", providerName, providerSubscription.ID)
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch subscription: %w", err)
+	}
+
+	// Serialize with quota checks and other billing changes of the organization
+	organizationID := subscription.OrganizationID
+	if err := lockOrganization(tx, organizationID); err != nil {
+		return "", err
+	}
+	if err := tx.First(&subscription, "id = ?", subscription.ID).Error; err != nil {
+		return "", fmt.Errorf("failed to fetch subscription: %w", err)
+	}
+	before := subscription
+
+	switch providerSubscription.Status {
+	case models.SubscriptionStatusActive:
+		if providerSubscription.Plan == "" {
+			log.Printf("ignoring %s subscription %s: unknown plan %s", providerName, providerSubscription.ID, providerSubscription.PlanID)
+			return "", nil
+		}
+		if current := subscription.ProviderSubscriptionID(); current != "" && current != providerSubscription.ID {
+			log.Printf("organization %s replaced %s subscription %s with %s subscription %s",
+				organizationID, subscription.BillingProvider, current, providerName, providerSubscription.ID)
+			unlinkProviderSubscription(&subscription)
+		}
+		if err := applyCatalogPlan(tx, &subscription, providerSubscription.Plan); err != nil {
+			return "", err
+		}
+		subscription.Status = models.SubscriptionStatusActive
+		subscription.TrialEnd = nil
+		subscription.BillingProvider = providerName
+		linkProviderSubscription(&subscription, providerSubscription)
+		subscription.CurrentPeriodStart = providerSubscription.PeriodStart
+		subscription.CurrentPeriodEnd = providerSubscription.PeriodEnd
+		if err := startDiscount(tx, &subscription, providerSubscription.PeriodEnd, time.Now()); err != nil {
+			return "", err
+		}
+
+	case models.SubscriptionStatusPastDue:
+		subscription.Status = models.SubscriptionStatusPastDue
+
+	case models.SubscriptionStatusCancelled:
+		if err := applyCatalogPlan(tx, &subscription, models.SubscriptionPlanFree); err != nil {
+			return "", err
+		}
+		subscription.Status = models.SubscriptionStatusActive
+		unlinkProviderSubscription(&subscription)
+		subscription.CurrentPeriodStart = nil
+		subscription.CurrentPeriodEnd = nil
+		if err := endDiscount(tx, &subscription, time.Now()); err != nil {
+			return "", err
+		}
+
+	default:
+		return "", nil
+	}
+
+	if _, err := reconcileLimitGrace(tx, &subscription); err != nil {
+		return "", err
+	}
+
+	if err := tx.Omit("Organization").Save(&subscription).Error; err != nil {
+		return "", fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	if err := recordAuditEvent(tx, audit, organizationID, models.AuditActionUpdate,
+		models.AuditResourceSubscription, subscription.ID, &before, &subscription); err != nil {
+		return "", err
+	}
+	return organizationID, nil
+}
+
+// providerSubscriptionColumn is the subscriptions column holding the provider's subscription ID
+func providerSubscriptionColumn(providerName string) string {
+	switch providerName {
+	case models.BillingProviderPayPal:
+		return "paypal_subscription_id"
+	case models.BillingProviderStripe:
+		return "stripe_subscription_id"
+	}
+	return ""
+}
+
+// linkProviderSubscription stores the provider's IDs for the subscription's BillingProvider
+func linkProviderSubscription(subscription *models.Subscription, providerSubscription *ProviderSubscription) {
+	switch subscription.BillingProvider {
+	case models.BillingProviderPayPal:
+		subscription.PayPalSubscriptionID = providerSubscription.ID
+		subscription.PayPalPlanID = providerSubscription.PlanID
+	case models.BillingProviderStripe:
+		subscription.StripeSubscriptionID = providerSubscription.ID
+		subscription.StripePriceID = providerSubscription.PlanID
+		if providerSubscription.CustomerID != "" {
+			subscription.StripeCustomerID = providerSubscription.CustomerID
+		}
+	}
+}
+
+// unlinkProviderSubscription forgets the ended subscription. The Stripe customer
+// is kept for portal links and later checkouts.
+func unlinkProviderSubscription(subscription *models.Subscription) {
+	switch subscription.BillingProvider {
+	case models.BillingProviderPayPal:
+		subscription.PayPalSubscriptionID = ""
+		subscription.PayPalPlanID = ""
+	case models.BillingProviderStripe:
+		subscription.StripeSubscriptionID = ""
+		subscription.StripePriceID = ""
+	}
+}
+
+// currentSubscription returns the organization's latest subscription that wasn't cancelled
+func currentSubscription(db *gorm.DB, organizationID string) (*models.Subscription, error) {
+	var subscription models.Subscription
+	if err := db.Where("organization_id = ? AND status <> ?", organizationID, models.SubscriptionStatusCancelled).
+		Order("created_at DESC").First(&subscription).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch subscription: %w", err)
+	}
+	return &subscription, nil
+}
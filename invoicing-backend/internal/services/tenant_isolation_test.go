@@ -0,0 +1,164 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+func TestTenantIsolationBlocksCrossTenantReads(t *testing.T) {
+	db := testutil.DB(t)
+	app := testutil.AppDB(t)
+
+	ownerA, orgA := newTestOrganization(t, db, 5)
+	_, orgB := newTestOrganization(t, db, 5)
+	client := newTestClient(t, app, ownerA, orgA)
+
+	clients := NewClientService(app, NewUsageService(app, NewLogNotifier(), ""))
+	if _, err := clients.GetClientByID(client.ID.String(), ownerA.ID.String(), orgB.ID.String()); err == nil {
+		t.Fatal("client was readable from another organization")
+	}
+
+	// Queries without an organization_id filter only see the scoped organization
+	var visible []models.Client
+	err := database.WithOrganization(app, orgB.ID.String(), func(tx *gorm.DB) error {
+		return tx.Unscoped().Find(&visible).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range visible {
+		if c.OrganizationID != orgB.ID.String() {
+			t.Fatalf("organization B sees client %s of organization %s", c.ID, c.OrganizationID)
+		}
+	}
+
+	// Outside a tenant scope tenant tables appear empty
+	var count int64
+	if err := app.Model(&models.Client{}).Where("id = ?", client.ID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatal("client is visible without an organization context")
+	}
+}
+
+func TestTenantIsolationBlocksCrossTenantWrites(t *testing.T) {
+	db := testutil.DB(t)
+	app := testutil.AppDB(t)
+
+	ownerA, orgA := newTestOrganization(t, db, 5)
+	ownerB, orgB := newTestOrganization(t, db, 5)
+	client := newTestClient(t, app, ownerA, orgA)
+
+	clients := NewClientService(app, NewUsageService(app, NewLogNotifier(), ""))
+	audit := AuditContext{UserID: ownerB.ID.String()}
+	if _, err := clients.UpdateClient(audit, client.ID.String(), orgB.ID.String(), &models.Client{Name: "Hijacked"}); err == nil {
+		t.Fatal("client was updated from another organization")
+	}
+	if err := clients.DeleteClient(audit, client.ID.String(), orgB.ID.String()); err == nil {
+		t.Fatal("client was deleted from another organization")
+	}
+
+	err := database.WithOrganization(app, orgB.ID.String(), func(tx *gorm.DB) error {
+		result := tx.Model(&models.Client{}).Where("id = ?", client.ID).Update("name", "Hijacked")
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 0 {
+			t.Errorf("update without organization filter changed %d rows", result.RowsAffected)
+		}
+
+		result = tx.Exec("DELETE FROM clients WHERE id = ?", client.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 0 {
+			t.Errorf("delete without organization filter removed %d rows", result.RowsAffected)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Inserting a row for another organization violates the policy
+	err = database.WithOrganization(app, orgB.ID.String(), func(tx *gorm.DB) error {
+		return tx.Create(&models.Client{
+			UserID:         ownerB.ID.String(),
+			OrganizationID: orgA.ID.String(),
+			Name:           "Planted",
+			Email:          uniqueEmail("client.example.com"),
+		}).Error
+	})
+	if err == nil {
+		t.Fatal("client was inserted into another organization")
+	}
+
+	got, err := clients.GetClientByID(client.ID.String(), ownerA.ID.String(), orgA.ID.String())
+	if err != nil {
+		t.Fatalf("client of organization A is gone: %v", err)
+	}
+	if got.Name != client.Name {
+		t.Fatalf("client name = %q, want %q", got.Name, client.Name)
+	}
+}
+
+func TestWithOrganizationNestedScope(t *testing.T) {
+	db := testutil.DB(t)
+	app := testutil.AppDB(t)
+
+	ownerA, orgA := newTestOrganization(t, db, 5)
+	_, orgB := newTestOrganization(t, db, 5)
+	client := newTestClient(t, app, ownerA, orgA)
+
+	err := app.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		err := database.WithOrganization(tx, orgA.ID.String(), func(tx *gorm.DB) error {
+			return tx.Model(&models.Client{}).Where("id = ?", client.ID).Count(&count).Error
+		})
+		if err != nil {
+			return err
+		}
+		if count != 1 {
+			t.Errorf("nested scope sees %d clients, want 1", count)
+		}
+
+		// The caller's (empty) context is restored when the nested scope returns
+		if err := tx.Model(&models.Client{}).Where("id = ?", client.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != 0 {
+			t.Error("organization context leaked out of the nested scope")
+		}
+
+		return database.WithOrganization(tx, orgA.ID.String(), func(tx *gorm.DB) error {
+			if err := database.WithOrganization(tx, orgA.ID.String(), func(*gorm.DB) error { return nil }); err != nil {
+				t.Errorf("re-entering the same organization failed: %v", err)
+			}
+			if err := database.WithOrganization(tx, orgB.ID.String(), func(*gorm.DB) error { return nil }); err == nil {
+				t.Error("switched a scoped transaction to another organization")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyTenantIsolation(t *testing.T) {
+	db := testutil.DB(t)
+	app := testutil.AppDB(t)
+
+	if err := database.VerifyTenantIsolation(app); err != nil {
+		t.Fatalf("application role rejected: %v", err)
+	}
+	// The test database URL runs the migrations, so it owns the tables
+	if err := database.VerifyTenantIsolation(db); err == nil {
+		t.Fatal("table owner accepted")
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// TrialConfig is the trial new organizations start with. A zero Duration disables
+// trials, so new organizations start on the free plan.
+type TrialConfig struct {
+	Plan     models.SubscriptionPlan
+	Duration time.Duration
+	// ReminderBefore is how long before the trial ends organization admins are told
+	ReminderBefore time.Duration
+}
+
+// startSubscription sets up the first subscription of a new organization: a trial
+// of the trial plan, or the free plan
+func startSubscription(tx *gorm.DB, subscription *models.Subscription, trial TrialConfig) error {
+	subscription.Status = models.SubscriptionStatusActive
+	if trial.Duration <= 0 || trial.Plan == "" {
+		return applyCatalogPlan(tx, subscription, models.SubscriptionPlanFree)
+	}
+
+	if err := applyCatalogPlan(tx, subscription, trial.Plan); err != nil {
+		return err
+	}
+	trialEnd := time.Now().Add(trial.Duration)
+	subscription.TrialEnd = &trialEnd
+	return nil
+}
+
+// RunLifecycle runs ProcessLifecycle every interval until ctx is done
+func (s *SubscriptionService) RunLifecycle(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.ProcessLifecycle(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessLifecycle sends trial reminders, moves organizations whose trial ended to
+// the free plan, and expires paid plans whose period ended without a billing provider
+// renewing them. Each subscription is handled in its own transaction under the
+// organization lock, so running it on several replicas is safe.
+func (s *SubscriptionService) ProcessLifecycle(now time.Time) {
+	s.sendTrialReminders(now)
+	s.endTrials(now)
+	s.expireSubscriptions(now)
+}
+
+func (s *SubscriptionService) sendTrialReminders(now time.Time) {
+	if s.trial.ReminderBefore <= 0 {
+		return
+	}
+
+	var subscriptions []models.Subscription
+	if err := s.db.Where("status = ? AND trial_end > ? AND trial_end <= ? AND trial_reminder_sent_at IS NULL",
+		models.SubscriptionStatusActive, now, now.Add(s.trial.ReminderBefore)).
+		Find(&subscriptions).Error; err != nil {
+		log.Printf("failed to fetch trials ending soon: %v", err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		// Claim the reminder so another replica doesn't send it too
+		result := s.db.Model(&models.Subscription{}).
+			Where("id = ? AND trial_reminder_sent_at IS NULL", subscription.ID).
+			Update("trial_reminder_sent_at", now)
+		if result.Error != nil {
+			log.Printf("failed to mark trial reminder for subscription %s: %v", subscription.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		notifyOrganizationAdmins(s.db, s.notifier, subscription.OrganizationID,
+			"Your trial ends soon",
+			fmt.Sprintf("Your %s trial ends on %s. Choose a plan at %s/settings/billing to keep your features; "+
+				"otherwise your organization moves to the free plan.",
+				subscription.PlanType, subscription.TrialEnd.Format("January 2, 2006"), s.frontendURL))
+	}
+}
+
+func (s *SubscriptionService) endTrials(now time.Time) {
+	var subscriptions []models.Subscription
+	if err := s.db.Where("status = ? AND trial_end <= ?", models.SubscriptionStatusActive, now).
+		Find(&subscriptions).Error; err != nil {
+		log.Printf("failed to fetch ended trials: %v", err)
+		return
+	}
+
+	for _, candidate := range subscriptions {
+		var overages []UsageLimitError
+		changed, err := s.updateLockedSubscription(candidate.ID, func(tx *gorm.DB, subscription *models.Subscription) (bool, error) {
+			// Converted to a paid plan in the meantime
+			if subscription.TrialEnd == nil || subscription.TrialEnd.After(now) || subscription.ProviderSubscriptionID() != "" {
+				return false, nil
+			}
+
+			if err := applyCatalogPlan(tx, subscription, models.SubscriptionPlanFree); err != nil {
+				return false, err
+			}
+			subscription.TrialEnd = nil
+			subscription.TrialReminderSentAt = nil
+
+			var err error
+			overages, err = reconcileLimitGrace(tx, subscription)
+			return true, err
+		})
+		if err != nil {
+			log.Printf("failed to end trial of subscription %s: %v", candidate.ID, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		body := fmt.Sprintf("Your trial has ended and your organization is now on the free plan. "+
+			"Upgrade at %s/settings/billing at any time.", s.frontendURL)
+		if len(overages) > 0 {
+			body += fmt.Sprintf(" Your organization exceeds the free plan's limits; remove clients or members "+
+				"or upgrade within %d days, after which it becomes read-only.", int(PlanDowngradeGracePeriod.Hours()/24))
+		}
+		notifyOrganizationAdmins(s.db, s.notifier, candidate.OrganizationID, "Your trial has ended", body)
+	}
+}
+
+func (s *SubscriptionService) expireSubscriptions(now time.Time) {
+	var subscriptions []models.Subscription
+	if err := s.db.Where("status = ? AND current_period_end < ? AND plan_type <> ?",
+		models.SubscriptionStatusActive, now, models.SubscriptionPlanFree).
+		Where("COALESCE(paypal_subscription_id, '') = '' AND COALESCE(stripe_subscription_id, '') = ''").
+		Find(&subscriptions).Error; err != nil {
+		log.Printf("failed to fetch ended subscriptions: %v", err)
+		return
+	}
+
+	for _, candidate := range subscriptions {
+		changed, err := s.updateLockedSubscription(candidate.ID, func(tx *gorm.DB, subscription *models.Subscription) (bool, error) {
+			if !subscription.IsActive() || !subscription.IsExpired() || subscription.ProviderSubscriptionID() != "" {
+				return false, nil
+			}
+			subscription.Status = models.SubscriptionStatusExpired
+			return true, nil
+		})
+		if err != nil {
+			log.Printf("failed to expire subscription %s: %v", candidate.ID, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		notifyOrganizationAdmins(s.db, s.notifier, candidate.OrganizationID, "Your subscription has expired",
+			fmt.Sprintf("Your %s subscription has expired and your organization is read-only. "+
+				"Renew or change your plan at %s/settings/billing.", candidate.PlanType, s.frontendURL))
+	}
+}
+
+// updateLockedSubscription reloads the subscription under the organization lock and
+// saves it with an audit event if update reports a change
+func (s *SubscriptionService) updateLockedSubscription(subscriptionID string, update func(tx *gorm.DB, subscription *models.Subscription) (bool, error)) (bool, error) {
+	var organizationID string
+	changed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var subscription models.Subscription
+		if err := tx.First(&subscription, "id = ?", subscriptionID).Error; err != nil {
+			return fmt.Errorf("failed to fetch subscription: %w", err)
+		}
+		organizationID = subscription.OrganizationID
+		if err := lockOrganization(tx, organizationID); err != nil {
+			return err
+		}
+		if err := tx.First(&subscription, "id = ?", subscriptionID).Error; err != nil {
+			return fmt.Errorf("failed to fetch subscription: %w", err)
+		}
+		before := subscription
+
+		var err error
+		if changed, err = update(tx, &subscription); err != nil || !changed {
+			return err
+		}
+
+		if err := tx.Omit("Organization").Save(&subscription).Error; err != nil {
+			return fmt.Errorf("failed to update subscription: %w", err)
+		}
+		return recordAuditEvent(tx, AuditContext{}, organizationID, models.AuditActionUpdate,
+			models.AuditResourceSubscription, subscription.ID, &before, &subscription)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if changed {
+		s.authz.InvalidateOrganization(organizationID)
+	}
+	return changed, nil
+}
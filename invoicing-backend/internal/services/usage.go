@@ -0,0 +1,274 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Admins are notified when usage reaches these percentages of a limit
+var usageThresholds = []int{80, 100}
+
+// Months of history returned with the usage report, including the current one
+const usageHistoryMonths = 12
+
+// UsageMetric is the usage of one resource against its limit (-1 means unlimited)
+type UsageMetric struct {
+	Usage int64 `json:"usage"`
+	Limit int   `json:"limit"`
+}
+
+// MonthlyUsage is the usage of one calendar month (UTC)
+type MonthlyUsage struct {
+	Month string `json:"month"`
+	// Invoices created during the month
+	Invoices int64 `json:"invoices"`
+	// Clients at the end of the month
+	Clients int64 `json:"clients"`
+}
+
+// UsageReport is the organization's usage in the current billing period
+type UsageReport struct {
+	PlanType    models.SubscriptionPlan `json:"plan_type"`
+	PeriodStart time.Time               `json:"period_start"`
+	PeriodEnd   time.Time               `json:"period_end"`
+	Invoices    UsageMetric             `json:"invoices"`
+	Clients     UsageMetric             `json:"clients"`
+	Users       UsageMetric             `json:"users"`
+	History     []MonthlyUsage          `json:"history"`
+}
+
+type UsageService struct {
+	db          *gorm.DB
+	notifier    Notifier
+	frontendURL string
+}
+
+func NewUsageService(db *gorm.DB, notifier Notifier, frontendURL string) *UsageService {
+	return &UsageService{db: db, notifier: notifier, frontendURL: frontendURL}
+}
+
+// GetUsage returns current-period usage against the plan's limits, and monthly history
+func (s *UsageService) GetUsage(organizationID string) (*UsageReport, error) {
+	subscription, err := currentSubscription(s.db, organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &UsageReport{PlanType: subscription.PlanType}
+	report.PeriodStart, report.PeriodEnd = subscription.UsagePeriod(now)
+
+	for _, metric := range []struct {
+		resource string
+		target   *UsageMetric
+	}{
+		{UsageResourceInvoices, &report.Invoices},
+		{UsageResourceClients, &report.Clients},
+		{UsageResourceUsers, &report.Users},
+	} {
+		usage, err := resourceUsage(s.db, subscription, metric.resource, now)
+		if err != nil {
+			return nil, err
+		}
+		*metric.target = UsageMetric{Usage: usage, Limit: usageLimit(subscription, metric.resource)}
+	}
+
+	report.History, err = s.usageHistory(organizationID, now)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// NotifyThresholds tells the organization's admins when usage of the resource has
+// reached 80% or 100% of its limit. Each threshold is announced once per billing period.
+// Call it after committing the change that used the resource.
+func (s *UsageService) NotifyThresholds(organizationID, resource string) {
+	if s == nil {
+		return
+	}
+
+	subscription, err := currentSubscription(s.db, organizationID)
+	if err != nil {
+		log.Printf("failed to check usage thresholds of organization %s: %v", organizationID, err)
+		return
+	}
+	limit := usageLimit(subscription, resource)
+	if limit <= 0 {
+		return
+	}
+
+	now := time.Now()
+	usage, err := resourceUsage(s.db, subscription, resource, now)
+	if err != nil {
+		log.Printf("failed to check usage thresholds of organization %s: %v", organizationID, err)
+		return
+	}
+	periodStart, _ := subscription.UsagePeriod(now)
+
+	// Announce only the highest threshold newly reached
+	reached := 0
+	for _, threshold := range usageThresholds {
+		if usage*100 < int64(threshold)*int64(limit) {
+			break
+		}
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UsageThresholdNotification{
+			OrganizationID: organizationID,
+			Resource:       resource,
+			Threshold:      threshold,
+			PeriodStart:    periodStart,
+		})
+		if result.Error != nil {
+			log.Printf("failed to record usage threshold for organization %s: %v", organizationID, result.Error)
+			return
+		}
+		if result.RowsAffected > 0 {
+			reached = threshold
+		}
+	}
+	if reached == 0 {
+		return
+	}
+
+	subject := fmt.Sprintf("You have used %d%% of your %s limit", reached, resource)
+	body := fmt.Sprintf("Your organization has used %d of %d %s on the %s plan.", usage, limit, resource, subscription.PlanType)
+	if reached >= 100 {
+		subject = fmt.Sprintf("You have reached your %s limit", resource)
+		body += " Creating more is blocked"
+		if resource == UsageResourceInvoices {
+			body += " until the next billing period"
+		}
+		body += "."
+	}
+	body += fmt.Sprintf(" Upgrade at %s/settings/billing.", s.frontendURL)
+
+	notifyOrganizationAdmins(s.db, s.notifier, organizationID, subject, body)
+}
+
+// usageHistory sums usage events per calendar month over the last usageHistoryMonths
+func (s *UsageService) usageHistory(organizationID string, now time.Time) ([]MonthlyUsage, error) {
+	now = now.UTC()
+	windowStart := time.Date(now.Year(), now.Month()-usageHistoryMonths+1, 1, 0, 0, 0, 0, time.UTC)
+
+	var clientsBefore int64
+	var rows []struct {
+		Month    time.Time
+		Resource string
+		Quantity int64
+	}
+	err := database.WithOrganization(s.db, organizationID, func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UsageEvent{}).
+			Where("organization_id = ? AND resource = ? AND occurred_at < ?", organizationID, UsageResourceClients, windowStart).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&clientsBefore).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UsageEvent{}).
+			Select("date_trunc('month', occurred_at AT TIME ZONE 'UTC') AS month, resource, SUM(quantity) AS quantity").
+			Where("organization_id = ? AND occurred_at >= ?", organizationID, windowStart).
+			Group("month, resource").Scan(&rows).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load usage history: %w", err)
+	}
+
+	history := make([]MonthlyUsage, usageHistoryMonths)
+	index := make(map[string]int, usageHistoryMonths)
+	for i := range history {
+		month := windowStart.AddDate(0, i, 0).Format("2006-01")
+		history[i].Month = month
+		index[month] = i
+	}
+
+	clientChanges := make([]int64, usageHistoryMonths)
+	for _, row := range rows {
+		i, ok := index[row.Month.Format("2006-01")]
+		if !ok {
+			continue
+		}
+		switch row.Resource {
+		case UsageResourceInvoices:
+			history[i].Invoices = row.Quantity
+		case UsageResourceClients:
+			clientChanges[i] = row.Quantity
+		}
+	}
+
+	clients := clientsBefore
+	for i := range history {
+		clients += clientChanges[i]
+		history[i].Clients = clients
+	}
+	return history, nil
+}
+
+// recordUsage meters a change of a resource in the transaction that made it
+func recordUsage(tx *gorm.DB, organizationID, resource, resourceID string, quantity int) error {
+	event := models.UsageEvent{
+		OrganizationID: organizationID,
+		Resource:       resource,
+		ResourceID:     resourceID,
+		Quantity:       quantity,
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	return nil
+}
+
+// resourceUsage is the usage counted against the resource's limit: invoices created in
+// the current billing period, and the clients and seats the organization has now
+func resourceUsage(db *gorm.DB, subscription *models.Subscription, resource string, now time.Time) (int64, error) {
+	organizationID := subscription.OrganizationID
+	if resource == UsageResourceUsers {
+		return seatUsage(db, organizationID)
+	}
+
+	var usage int64
+	err := database.WithOrganization(db, organizationID, func(tx *gorm.DB) error {
+		if resource == UsageResourceInvoices {
+			var err error
+			usage, err = periodInvoiceUsage(tx, subscription, now)
+			return err
+		}
+		return tx.Model(&models.Client{}).
+			Where("organization_id = ? AND deleted_at IS NULL", organizationID).
+			Count(&usage).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", resource, err)
+	}
+	return usage, nil
+}
+
+// periodInvoiceUsage sums invoice usage events of the current billing period. Deleting
+// an invoice doesn't record an event, so it doesn't give back quota.
+func periodInvoiceUsage(tx *gorm.DB, subscription *models.Subscription, now time.Time) (int64, error) {
+	periodStart, _ := subscription.UsagePeriod(now)
+
+	var usage int64
+	if err := tx.Model(&models.UsageEvent{}).
+		Where("organization_id = ? AND resource = ? AND occurred_at >= ?",
+			subscription.OrganizationID, UsageResourceInvoices, periodStart).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&usage).Error; err != nil {
+		return 0, fmt.Errorf("failed to sum invoice usage: %w", err)
+	}
+	return usage, nil
+}
+
+func usageLimit(subscription *models.Subscription, resource string) int {
+	switch resource {
+	case UsageResourceInvoices:
+		return subscription.MonthlyInvoiceLimit
+	case UsageResourceClients:
+		return subscription.MonthlyClientLimit
+	case UsageResourceUsers:
+		return subscription.MonthlyUserLimit
+	}
+	return -1
+}
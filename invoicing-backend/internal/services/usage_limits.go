@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"gorm.io/gorm"
+)
+
+// Resources metered against subscription limits
+const (
+	UsageResourceInvoices = "invoices"
+	UsageResourceClients  = "clients"
+	UsageResourceUsers    = "users"
+)
+
+var ErrUsageLimitReached = errors.New("usage limit reached")
+
+// PlanDowngradeGracePeriod is how long an organization left over its limits by a
+// plan change may keep working normally. After it the organization is read-only
+// until it is back under its limits.
+const PlanDowngradeGracePeriod = 14 * 24 * time.Hour
+
+// UsageLimitError reports which limit was hit, with the usage it was checked against
+type UsageLimitError struct {
+	Resource string `json:"resource"`
+	Usage    int64  `json:"usage"`
+	Limit    int    `json:"limit"`
+}
+
+func (e *UsageLimitError) Error() string {
+	return fmt.Sprintf("%s limit reached (%d of %d)", e.Resource, e.Usage, e.Limit)
+}
+
+// Is matches ErrUsageLimitReached, and ErrSeatLimitReached for user limits
+func (e *UsageLimitError) Is(target error) bool {
+	return target == ErrUsageLimitReached || (e.Resource == UsageResourceUsers && target == ErrSeatLimitReached)
+}
+
+// The quota checks below must run in the transaction that inserts the row. They lock
+// the organization first, so concurrent creates are serialized and can't both pass.
+
+// checkInvoiceQuota counts invoices created in the current billing period from the
+// usage events. Deleted invoices still count, so deleting one doesn't give back quota.
+func checkInvoiceQuota(tx *gorm.DB, organizationID string) error {
+	subscription, err := lockedSubscription(tx, organizationID)
+	if err != nil {
+		return err
+	}
+	if subscription.MonthlyInvoiceLimit == -1 {
+		return nil
+	}
+
+	count, err := periodInvoiceUsage(tx, subscription, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !subscription.CanCreateInvoices(int(count)) {
+		return &UsageLimitError{Resource: UsageResourceInvoices, Usage: count, Limit: subscription.MonthlyInvoiceLimit}
+	}
+	return nil
+}
+
+// checkClientQuota counts the organization's clients, excluding deleted ones
+func checkClientQuota(tx *gorm.DB, organizationID string) error {
+	subscription, err := lockedSubscription(tx, organizationID)
+	if err != nil {
+		return err
+	}
+	if subscription.MonthlyClientLimit == -1 {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.Client{}).
+		Where("organization_id = ? AND deleted_at IS NULL", organizationID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count clients: %w", err)
+	}
+
+	if !subscription.CanCreateClients(int(count)) {
+		return &UsageLimitError{Resource: UsageResourceClients, Usage: count, Limit: subscription.MonthlyClientLimit}
+	}
+	return nil
+}
+
+// lockedSubscription locks the organization and returns its active subscription
+func lockedSubscription(tx *gorm.DB, organizationID string) (*models.Subscription, error) {
+	if err := lockOrganization(tx, organizationID); err != nil {
+		return nil, err
+	}
+	return activeSubscription(tx, organizationID)
+}
+
+// limitOverages lists the limits the organization currently exceeds. Only standing
+// usage is considered; the invoice quota resets every month and just blocks creates.
+func limitOverages(tx *gorm.DB, subscription *models.Subscription) ([]UsageLimitError, error) {
+	var overages []UsageLimitError
+
+	if subscription.MonthlyClientLimit != -1 {
+		var clients int64
+		// Clients are a tenant table behind row-level security
+		err := database.WithOrganization(tx, subscription.OrganizationID, func(tx *gorm.DB) error {
+			return tx.Model(&models.Client{}).
+				Where("organization_id = ? AND deleted_at IS NULL", subscription.OrganizationID).
+				Count(&clients).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count clients: %w", err)
+		}
+		if clients > int64(subscription.MonthlyClientLimit) {
+			overages = append(overages, UsageLimitError{Resource: UsageResourceClients, Usage: clients, Limit: subscription.MonthlyClientLimit})
+		}
+	}
+
+	if subscription.MonthlyUserLimit != -1 {
+		seats, err := seatUsage(tx, subscription.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		if seats > int64(subscription.MonthlyUserLimit) {
+			overages = append(overages, UsageLimitError{Resource: UsageResourceUsers, Usage: seats, Limit: subscription.MonthlyUserLimit})
+		}
+	}
+
+	return overages, nil
+}
+
+// reconcileLimitGrace starts the grace period when a plan change leaves the organization
+// over its limits, and ends it once it is back under them. The caller saves the subscription.
+func reconcileLimitGrace(tx *gorm.DB, subscription *models.Subscription) ([]UsageLimitError, error) {
+	overages, err := limitOverages(tx, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(overages) == 0 {
+		subscription.LimitGraceEndsAt = nil
+	} else if subscription.LimitGraceEndsAt == nil {
+		graceEndsAt := time.Now().Add(PlanDowngradeGracePeriod)
+		subscription.LimitGraceEndsAt = &graceEndsAt
+	}
+	return overages, nil
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yourusername/invoicing-backend/internal/database"
+	"github.com/yourusername/invoicing-backend/internal/models"
+	"github.com/yourusername/invoicing-backend/internal/testutil"
+	"gorm.io/gorm"
+)
+
+const quotaRaceAttempts = 12
+
+// setLimit changes one monthly limit of the organization's subscription
+func setLimit(t *testing.T, db *gorm.DB, org *models.Organization, column string, limit int) {
+	t.Helper()
+
+	if err := db.Model(&models.Subscription{}).Where("organization_id = ?", org.ID).
+		Update(column, limit).Error; err != nil {
+		t.Fatalf("failed to set %s: %v", column, err)
+	}
+}
+
+// raceCreates runs create concurrently and returns how many calls succeeded. Every
+// failure must be a usage limit error.
+func raceCreates(t *testing.T, create func(i int) error) int {
+	t.Helper()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		start     = make(chan struct{})
+	)
+	for i := 0; i < quotaRaceAttempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			err := create(i)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case !errors.Is(err, ErrUsageLimitReached):
+				t.Errorf("create %d: unexpected error: %v", i, err)
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return succeeded
+}
+
+func TestClientQuotaHoldsUnderConcurrentCreates(t *testing.T) {
+	db := testutil.DB(t)
+	app := testutil.AppDB(t)
+
+	const limit = 3
+	owner, org := newTestOrganization(t, db, 5)
+	setLimit(t, db, org, "monthly_client_limit", limit)
+
+	clients := NewClientService(app, NewUsageService(app, NewLogNotifier(), ""))
+	audit := AuditContext{UserID: owner.ID.String()}
+	succeeded := raceCreates(t, func(int) error {
+		_, err := clients.CreateClient(audit, org.ID.String(),
+			&models.Client{Name: "Concurrent client", Email: uniqueEmail("client.example.com")})
+		return err
+	})
+	if succeeded != limit {
+		t.Errorf("%d creates succeeded, want %d", succeeded, limit)
+	}
+
+	var stored int64
+	err := database.WithOrganization(app, org.ID.String(), func(tx *gorm.DB) error {
+		return tx.Model(&models.Client{}).Where("organization_id = ?", org.ID).Count(&stored).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != limit {
+		t.Fatalf("organization has %d clients, limit is %d", stored, limit)
+	}
+}
+
+func TestInvoiceQuotaHoldsUnderConcurrentCreates(t *testing.T) {
+	db := testutil.DB(t)
+	app := testutil.AppDB(t)
+
+	const limit = 4
+	owner, org := newTestOrganization(t, db, 5)
+	client := newTestClient(t, app, owner, org)
+	setLimit(t, db, org, "monthly_invoice_limit", limit)
+
+	invoices := NewInvoiceService(app, NewLogNotifier(), NewUsageService(app, NewLogNotifier(), ""))
+	audit := AuditContext{UserID: owner.ID.String()}
+	succeeded := raceCreates(t, func(int) error {
+		_, err := invoices.CreateInvoice(audit, org.ID.String(), &models.Invoice{
+			ClientID: client.ID.String(),
+			DueDate:  time.Now().AddDate(0, 0, 30),
+			Currency: "EUR",
+			InvoiceItems: []models.InvoiceItem{
+				{Description: "Concurrent", Quantity: 1, UnitPrice: 10, TotalPrice: 10},
+			},
+		})
+		return err
+	})
+	if succeeded != limit {
+		t.Errorf("%d creates succeeded, want %d", succeeded, limit)
+	}
+
+	var stored int64
+	err := database.WithOrganization(app, org.ID.String(), func(tx *gorm.DB) error {
+		return tx.Model(&models.Invoice{}).Where("organization_id = ?", org.ID).Count(&stored).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != limit {
+		t.Fatalf("organization has %d invoices, limit is %d", stored, limit)
+	}
+}
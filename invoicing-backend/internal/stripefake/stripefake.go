@@ -0,0 +1,426 @@
+// Package stripefake is an in-memory stand-in for the parts of the Stripe API
+// used for SaaS billing and invoice payments, for local development and tests
+// without a Stripe account.
+//
+// Opening a checkout URL completes the checkout; subscription changes deliver
+// webhooks signed with WebhookSecret to WebhookURL and invoice payments deliver
+// webhooks signed with PaymentsWebhookSecret to PaymentsWebhookURL. Links point
+// at the host the server was reached on.
+package stripefake
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type item struct {
+	ID                 string            `json:"id"`
+	Price              map[string]string `json:"price"`
+	CurrentPeriodStart int64             `json:"current_period_start"`
+	CurrentPeriodEnd   int64             `json:"current_period_end"`
+}
+
+type subscription struct {
+	ID        string            `json:"id"`
+	Object    string            `json:"object"`
+	Customer  string            `json:"customer"`
+	Status    string            `json:"status"`
+	Metadata  map[string]string `json:"metadata"`
+	Discounts []string          `json:"discounts"`
+	Items     struct {
+		Data []item `json:"data"`
+	} `json:"items"`
+}
+
+type checkoutSession struct {
+	ID         string
+	Mode       string
+	Customer   string
+	Price      string
+	Coupon     string
+	SuccessURL string
+	Metadata   map[string]string
+
+	// One-off payments carry an inline price
+	UnitAmount int64
+	Currency   string
+}
+
+// Server holds the checkout sessions, subscriptions and coupons in memory
+type Server struct {
+	// WebhookURL receives customer.subscription.* events signed with WebhookSecret
+	WebhookURL    string
+	WebhookSecret string
+	// PaymentsWebhookURL receives checkout.session.completed events of one-off
+	// payments, signed with PaymentsWebhookSecret
+	PaymentsWebhookURL    string
+	PaymentsWebhookSecret string
+
+	mu            sync.Mutex
+	sessions      map[string]*checkoutSession
+	subscriptions map[string]*subscription
+	coupons       map[string]map[string]string
+}
+
+// New creates an empty server delivering webhooks to the given endpoints
+func New(webhookURL, webhookSecret, paymentsWebhookURL, paymentsWebhookSecret string) *Server {
+	return &Server{
+		WebhookURL:            webhookURL,
+		WebhookSecret:         webhookSecret,
+		PaymentsWebhookURL:    paymentsWebhookURL,
+		PaymentsWebhookSecret: paymentsWebhookSecret,
+		sessions:              make(map[string]*checkoutSession),
+		subscriptions:         make(map[string]*subscription),
+		coupons:               make(map[string]map[string]string),
+	}
+}
+
+func (f *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/customers", f.createCustomer)
+	mux.HandleFunc("POST /v1/coupons", f.createCoupon)
+	mux.HandleFunc("GET /v1/coupons/{id}", f.getCoupon)
+	mux.HandleFunc("POST /v1/checkout/sessions", f.createCheckoutSession)
+	mux.HandleFunc("POST /v1/billing_portal/sessions", f.createPortalSession)
+	mux.HandleFunc("GET /v1/subscriptions/{id}", f.getSubscription)
+	mux.HandleFunc("POST /v1/subscriptions/{id}", f.updateSubscription)
+	mux.HandleFunc("DELETE /v1/subscriptions/{id}", f.cancelSubscription)
+	mux.HandleFunc("GET /checkout/{id}", f.completeCheckout)
+	mux.HandleFunc("GET /portal/{customer}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Customer portal for %s\n", r.PathValue("customer"))
+	})
+	return mux
+}
+
+func (f *Server) createCustomer(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"id": "cus_" + newID(), "object": "customer"})
+}
+
+func (f *Server) createCoupon(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("id") == "" || r.PostForm.Get("duration") == "" {
+		stripeError(w, http.StatusBadRequest, "id and duration are required")
+		return
+	}
+
+	coupon := map[string]string{"object": "coupon"}
+	for _, key := range []string{"id", "name", "duration", "duration_in_months", "percent_off", "amount_off", "currency"} {
+		if value := r.PostForm.Get(key); value != "" {
+			coupon[key] = value
+		}
+	}
+
+	f.mu.Lock()
+	_, exists := f.coupons[coupon["id"]]
+	if !exists {
+		f.coupons[coupon["id"]] = coupon
+	}
+	f.mu.Unlock()
+
+	if exists {
+		stripeError(w, http.StatusBadRequest, "Coupon already exists")
+		return
+	}
+	writeJSON(w, http.StatusOK, coupon)
+}
+
+func (f *Server) getCoupon(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	coupon, ok := f.coupons[r.PathValue("id")]
+	if !ok {
+		stripeError(w, http.StatusNotFound, "No such coupon")
+		return
+	}
+	writeJSON(w, http.StatusOK, coupon)
+}
+
+func (f *Server) createCheckoutSession(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		stripeError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+	if r.PostForm.Get("mode") == "payment" {
+		f.createPaymentSession(w, r)
+		return
+	}
+	if r.PostForm.Get("line_items[0][price]") == "" {
+		stripeError(w, http.StatusBadRequest, "line_items[0][price] is required")
+		return
+	}
+
+	session := &checkoutSession{
+		ID:         "cs_" + newID(),
+		Mode:       "subscription",
+		Customer:   r.PostForm.Get("customer"),
+		Price:      r.PostForm.Get("line_items[0][price]"),
+		Coupon:     r.PostForm.Get("discounts[0][coupon]"),
+		SuccessURL: r.PostForm.Get("success_url"),
+		Metadata:   map[string]string{"organization_id": r.PostForm.Get("subscription_data[metadata][organization_id]")},
+	}
+
+	f.mu.Lock()
+	_, couponExists := f.coupons[session.Coupon]
+	if session.Coupon == "" || couponExists {
+		f.sessions[session.ID] = session
+	}
+	f.mu.Unlock()
+
+	if session.Coupon != "" && !couponExists {
+		stripeError(w, http.StatusBadRequest, "No such coupon: "+session.Coupon)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"id":     session.ID,
+		"object": "checkout.session",
+		"url":    "http://" + r.Host + "/checkout/" + session.ID,
+	})
+}
+
+// createPaymentSession opens a one-off payment checkout with an inline price
+func (f *Server) createPaymentSession(w http.ResponseWriter, r *http.Request) {
+	unitAmount, err := strconv.ParseInt(r.PostForm.Get("line_items[0][price_data][unit_amount]"), 10, 64)
+	currency := r.PostForm.Get("line_items[0][price_data][currency]")
+	if err != nil || unitAmount <= 0 || currency == "" {
+		stripeError(w, http.StatusBadRequest, "line_items[0][price_data] requires a positive unit_amount and a currency")
+		return
+	}
+
+	session := &checkoutSession{
+		ID:         "cs_" + newID(),
+		Mode:       "payment",
+		SuccessURL: r.PostForm.Get("success_url"),
+		Metadata: map[string]string{
+			"organization_id": r.PostForm.Get("metadata[organization_id]"),
+			"invoice_id":      r.PostForm.Get("metadata[invoice_id]"),
+		},
+		UnitAmount: unitAmount,
+		Currency:   currency,
+	}
+
+	f.mu.Lock()
+	f.sessions[session.ID] = session
+	f.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"id":     session.ID,
+		"object": "checkout.session",
+		"url":    "http://" + r.Host + "/checkout/" + session.ID,
+	})
+}
+
+func (f *Server) createPortalSession(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("customer") == "" {
+		stripeError(w, http.StatusBadRequest, "customer is required")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"object": "billing_portal.session",
+		"url":    "http://" + r.Host + "/portal/" + r.PostForm.Get("customer"),
+	})
+}
+
+func (f *Server) getSubscription(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sub, ok := f.subscriptions[r.PathValue("id")]
+	if !ok {
+		stripeError(w, http.StatusNotFound, "No such subscription")
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+func (f *Server) updateSubscription(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		stripeError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+
+	f.mu.Lock()
+	sub, ok := f.subscriptions[r.PathValue("id")]
+	if ok && len(sub.Items.Data) > 0 {
+		if price := r.PostForm.Get("items[0][price]"); price != "" {
+			sub.Items.Data[0].Price = map[string]string{"id": price}
+		}
+	}
+	if coupon := r.PostForm.Get("discounts[0][coupon]"); ok && coupon != "" {
+		sub.Discounts = []string{coupon}
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		stripeError(w, http.StatusNotFound, "No such subscription")
+		return
+	}
+
+	f.deliver(f.WebhookURL, f.WebhookSecret, "customer.subscription.updated", sub.ID, sub)
+	f.getSubscription(w, r)
+}
+
+func (f *Server) cancelSubscription(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	sub, ok := f.subscriptions[r.PathValue("id")]
+	if ok {
+		sub.Status = "canceled"
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		stripeError(w, http.StatusNotFound, "No such subscription")
+		return
+	}
+
+	go f.deliver(f.WebhookURL, f.WebhookSecret, "customer.subscription.deleted", sub.ID, sub)
+	f.getSubscription(w, r)
+}
+
+// completeCheckout plays the customer paying on the hosted checkout page
+func (f *Server) completeCheckout(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	session, ok := f.sessions[r.PathValue("id")]
+	if !ok {
+		f.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	delete(f.sessions, session.ID)
+
+	if session.Mode == "payment" {
+		f.mu.Unlock()
+		f.completePayment(w, r, session)
+		return
+	}
+
+	now := time.Now()
+	sub := &subscription{
+		ID:       "sub_" + newID(),
+		Object:   "subscription",
+		Customer: session.Customer,
+		Status:   "active",
+		Metadata: session.Metadata,
+	}
+	if session.Coupon != "" {
+		sub.Discounts = []string{session.Coupon}
+	}
+	sub.Items.Data = []item{{
+		ID:                 "si_" + newID(),
+		Price:              map[string]string{"id": session.Price},
+		CurrentPeriodStart: now.Unix(),
+		CurrentPeriodEnd:   now.AddDate(0, 1, 0).Unix(),
+	}}
+	f.subscriptions[sub.ID] = sub
+	f.mu.Unlock()
+
+	f.deliver(f.WebhookURL, f.WebhookSecret, "customer.subscription.created", sub.ID, sub)
+
+	if session.SuccessURL != "" {
+		http.Redirect(w, r, session.SuccessURL, http.StatusFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, sub)
+}
+
+// completePayment plays the customer paying a one-off checkout by card
+func (f *Server) completePayment(w http.ResponseWriter, r *http.Request, session *checkoutSession) {
+	completed := map[string]interface{}{
+		"id":             session.ID,
+		"object":         "checkout.session",
+		"mode":           session.Mode,
+		"payment_status": "paid",
+		"payment_intent": "pi_" + newID(),
+		"amount_total":   session.UnitAmount,
+		"currency":       session.Currency,
+		"metadata":       session.Metadata,
+	}
+	f.deliver(f.PaymentsWebhookURL, f.PaymentsWebhookSecret, "checkout.session.completed", session.ID, completed)
+
+	if session.SuccessURL != "" {
+		http.Redirect(w, r, session.SuccessURL, http.StatusFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, completed)
+}
+
+// Event returns the headers and body of an event with a new ID, signed like
+// Stripe at the given time: an HMAC-SHA256 of "<timestamp>.<body>" keyed with secret
+func (f *Server) Event(secret, eventType string, object interface{}, at time.Time) (http.Header, []byte, error) {
+	f.mu.Lock()
+	body, err := json.Marshal(map[string]interface{}{
+		"id":      "evt_" + newID(),
+		"object":  "event",
+		"type":    eventType,
+		"created": at.Unix(),
+		"data":    map[string]interface{}{"object": object},
+	})
+	f.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	timestamp := fmt.Sprint(at.Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Stripe-Signature", "t="+timestamp+",v1="+hex.EncodeToString(mac.Sum(nil)))
+	return headers, body, nil
+}
+
+func (f *Server) deliver(url, secret, eventType, objectID string, object interface{}) {
+	if url == "" {
+		return
+	}
+	headers, body, err := f.Event(secret, eventType, object, time.Now())
+	if err != nil {
+		log.Printf("failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("failed to build %s webhook: %v", eventType, err)
+		return
+	}
+	req.Header = headers
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("failed to deliver %s webhook: %v", eventType, err)
+		return
+	}
+	resp.Body.Close()
+	log.Printf("delivered %s for %s: %d", eventType, objectID, resp.StatusCode)
+}
+
+func stripeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{"type": "invalid_request_error", "message": message},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,121 @@
+// Package testutil holds helpers for tests that need PostgreSQL.
+package testutil
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	once  sync.Once
+	db    *gorm.DB
+	dbErr error
+
+	appOnce  sync.Once
+	appDB    *gorm.DB
+	appDBErr error
+)
+
+// AppRole is the login role AppDB connects as. Like the application's role it
+// owns nothing and inherits its privileges from invoicing_tenant.
+const AppRole = "invoicing_app_test"
+
+// DatabaseURL returns TEST_DATABASE_URL, skipping the test when it is not set
+func DatabaseURL(t testing.TB) string {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	return url
+}
+
+// DB connects to TEST_DATABASE_URL with all migrations applied. The connection is
+// shared by the tests of a package, so tests create their own organizations and
+// users instead of relying on an empty database.
+func DB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	url := DatabaseURL(t)
+	once.Do(func() {
+		if dbErr = migrateUp(url); dbErr != nil {
+			return
+		}
+		db, dbErr = gorm.Open(postgres.Open(url), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+	})
+	if dbErr != nil {
+		t.Fatalf("failed to set up test database: %v", dbErr)
+	}
+	return db
+}
+
+// AppDB connects to TEST_DATABASE_URL as AppRole, which row-level security applies
+// to. TEST_DATABASE_URL must be allowed to create roles.
+func AppDB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	owner := DB(t)
+	appOnce.Do(func() {
+		appDBErr = owner.Exec(`DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = '` + AppRole + `') THEN
+				CREATE ROLE ` + AppRole + ` LOGIN PASSWORD '` + AppRole + `';
+			END IF;
+		END
+		$$`).Error
+		if appDBErr != nil {
+			return
+		}
+		if appDBErr = owner.Exec("GRANT invoicing_tenant TO " + AppRole).Error; appDBErr != nil {
+			return
+		}
+
+		var u *url.URL
+		if u, appDBErr = url.Parse(DatabaseURL(t)); appDBErr != nil {
+			return
+		}
+		u.User = url.UserPassword(AppRole, AppRole)
+		appDB, appDBErr = gorm.Open(postgres.Open(u.String()), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+	})
+	if appDBErr != nil {
+		t.Fatalf("failed to connect as %s: %v", AppRole, appDBErr)
+	}
+	return appDB
+}
+
+// MigrationsDir is the absolute path of the migrations directory
+func MigrationsDir() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+}
+
+func migrateUp(url string) error {
+	m, err := migrate.New("file://"+MigrationsDir(), url)
+	if err != nil {
+		return fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	return nil
+}
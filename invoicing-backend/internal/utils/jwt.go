@@ -18,10 +18,20 @@ func getJWTSecret() string {
 	return secret
 }
 
-func GenerateJWT(userID uuid.UUID) (string, error) {
+// SessionClaims are the claims carried by a login token
+type SessionClaims struct {
+	UserID    uuid.UUID
+	SessionID string
+	// Set on impersonation tokens to the platform admin acting as the user
+	ImpersonatorID string
+}
+
+// GenerateJWT issues a login token bound to a server-side session
+func GenerateJWT(userID uuid.UUID, sessionID string, expiresAt time.Time) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID.String(),
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+		"sid":     sessionID,
+		"exp":     expiresAt.Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
@@ -29,7 +39,23 @@ func GenerateJWT(userID uuid.UUID) (string, error) {
 	return token.SignedString(jwtSecret)
 }
 
-func ValidateJWT(tokenString string) (*uuid.UUID, error) {
+// GenerateImpersonationJWT issues a login token for a platform admin acting as
+// another user. The impersonator is named in the token so that it is visible to clients.
+func GenerateImpersonationJWT(userID uuid.UUID, sessionID, impersonatorID string, expiresAt time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id":         userID.String(),
+		"sid":             sessionID,
+		"impersonator_id": impersonatorID,
+		"exp":             expiresAt.Unix(),
+		"iat":             time.Now().Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// ParseJWT validates a login token and returns its claims
+func ParseJWT(tokenString string) (*SessionClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, jwt.ErrSignatureInvalid
@@ -50,6 +76,11 @@ func ValidateJWT(tokenString string) (*uuid.UUID, error) {
 		return nil, jwt.ErrSignatureInvalid
 	}
 
+	// Purpose-bound tokens from SignClaims are never valid login tokens
+	if _, hasPurpose := claims["purpose"]; hasPurpose {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
 	userIDStr, ok := claims["user_id"].(string)
 	if !ok {
 		return nil, jwt.ErrSignatureInvalid
@@ -60,5 +91,48 @@ func ValidateJWT(tokenString string) (*uuid.UUID, error) {
 		return nil, err
 	}
 
-	return &userID, nil
+	sessionID, _ := claims["sid"].(string)
+	impersonatorID, _ := claims["impersonator_id"].(string)
+
+	return &SessionClaims{UserID: userID, SessionID: sessionID, ImpersonatorID: impersonatorID}, nil
+}
+
+// ValidateJWT validates a login token and returns the user ID
+func ValidateJWT(tokenString string) (*uuid.UUID, error) {
+	claims, err := ParseJWT(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return &claims.UserID, nil
+}
+
+// SignClaims signs arbitrary claims with the application secret. It is used for
+// short-lived, purpose-bound tokens (e.g. OIDC state) that are not login tokens.
+func SignClaims(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// ParseClaims validates a token produced by SignClaims and checks its purpose claim
+func ParseClaims(tokenString, purpose string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	if p, _ := claims["purpose"].(string); p != purpose {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	return claims, nil
 }
@@ -20,6 +20,14 @@ func ErrorResponse(c *gin.Context, statusCode int, message string) {
 	})
 }
 
+func ErrorResponseWithDetails(c *gin.Context, statusCode int, message string, details interface{}) {
+	c.JSON(statusCode, gin.H{
+		"success": false,
+		"error":   message,
+		"details": details,
+	})
+}
+
 func ValidationErrorResponse(c *gin.Context, err error) {
 	c.JSON(http.StatusBadRequest, gin.H{
 		"success": false,
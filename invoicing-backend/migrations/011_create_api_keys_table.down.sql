@@ -0,0 +1,5 @@
+-- Drop api_keys table and indexes
+DROP INDEX IF EXISTS idx_api_keys_created_by;
+DROP INDEX IF EXISTS idx_api_keys_organization_id;
+DROP INDEX IF EXISTS idx_api_keys_key_hash;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,23 @@
+-- Create api_keys table for organization-scoped machine access
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    permissions JSONB NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Keys are looked up by hash on every request
+CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys(key_hash);
+
+-- Indexes for performance
+CREATE INDEX idx_api_keys_organization_id ON api_keys(organization_id);
+CREATE INDEX idx_api_keys_created_by ON api_keys(created_by);
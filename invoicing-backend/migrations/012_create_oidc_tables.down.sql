@@ -0,0 +1,7 @@
+-- Drop OIDC tables and indexes
+DROP INDEX IF EXISTS idx_user_identities_user_id;
+DROP INDEX IF EXISTS idx_user_identities_issuer_subject;
+DROP TABLE IF EXISTS user_identities;
+
+DROP INDEX IF EXISTS idx_organization_sso_configs_organization_id;
+DROP TABLE IF EXISTS organization_sso_configs;
@@ -0,0 +1,8 @@
+-- Drop login security tables and indexes
+DROP INDEX IF EXISTS idx_login_attempts_user_id;
+DROP INDEX IF EXISTS idx_login_attempts_ip_created;
+DROP INDEX IF EXISTS idx_login_attempts_email_created;
+DROP TABLE IF EXISTS login_attempts;
+
+DROP INDEX IF EXISTS idx_login_throttles_locked_until;
+DROP TABLE IF EXISTS login_throttles;
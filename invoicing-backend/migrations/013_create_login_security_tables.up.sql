@@ -0,0 +1,29 @@
+-- Failed-login counters shared by all server replicas.
+-- scope is 'account' (key = lower-cased email) or 'ip' (key = client IP).
+CREATE TABLE login_throttles (
+    scope VARCHAR(20) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failure_count INTEGER NOT NULL DEFAULT 0,
+    first_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idx_login_throttles_locked_until ON login_throttles(locked_until);
+
+-- Audit trail of login attempts
+CREATE TABLE login_attempts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    email VARCHAR(255) NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    succeeded BOOLEAN NOT NULL DEFAULT FALSE,
+    failure_reason VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_attempts_email_created ON login_attempts(email, created_at DESC);
+CREATE INDEX idx_login_attempts_ip_created ON login_attempts(ip_address, created_at DESC);
+CREATE INDEX idx_login_attempts_user_id ON login_attempts(user_id);
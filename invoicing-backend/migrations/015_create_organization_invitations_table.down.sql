@@ -0,0 +1,5 @@
+-- Drop organization_invitations table and indexes
+DROP INDEX IF EXISTS idx_organization_invitations_status;
+DROP INDEX IF EXISTS idx_organization_invitations_organization_id;
+DROP INDEX IF EXISTS idx_organization_invitations_pending_email;
+DROP TABLE IF EXISTS organization_invitations;
@@ -0,0 +1,25 @@
+-- Invitations for adding members to an organization
+CREATE TABLE organization_invitations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role_id UUID NOT NULL REFERENCES roles(id),
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    send_count INTEGER NOT NULL DEFAULT 1,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    accepted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Only one pending invitation per email per organization
+CREATE UNIQUE INDEX idx_organization_invitations_pending_email ON organization_invitations(organization_id, LOWER(email))
+WHERE status = 'pending';
+
+-- Indexes for performance
+CREATE INDEX idx_organization_invitations_organization_id ON organization_invitations(organization_id);
+CREATE INDEX idx_organization_invitations_status ON organization_invitations(status);
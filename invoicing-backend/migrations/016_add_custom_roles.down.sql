@@ -0,0 +1,7 @@
+-- Remove custom roles and restore globally unique role names
+DELETE FROM roles WHERE organization_id IS NOT NULL;
+DROP INDEX IF EXISTS idx_roles_organization_id;
+DROP INDEX IF EXISTS idx_roles_organization_name;
+DROP INDEX IF EXISTS idx_roles_system_name;
+ALTER TABLE roles DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE roles ADD CONSTRAINT roles_name_key UNIQUE (name);
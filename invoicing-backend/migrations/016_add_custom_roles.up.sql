@@ -0,0 +1,10 @@
+-- Allow organizations to define their own roles next to the global system roles
+ALTER TABLE roles ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+-- Role names are unique among system roles and within each organization
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS roles_name_key;
+CREATE UNIQUE INDEX idx_roles_system_name ON roles(name) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX idx_roles_organization_name ON roles(organization_id, name) WHERE organization_id IS NOT NULL;
+
+-- Indexes for performance
+CREATE INDEX idx_roles_organization_id ON roles(organization_id);
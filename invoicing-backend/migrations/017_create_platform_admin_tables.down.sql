@@ -0,0 +1,13 @@
+-- Drop platform admin tables and columns
+DROP INDEX IF EXISTS idx_platform_admin_actions_created_at;
+DROP INDEX IF EXISTS idx_platform_admin_actions_session_id;
+DROP INDEX IF EXISTS idx_platform_admin_actions_target;
+DROP INDEX IF EXISTS idx_platform_admin_actions_admin_id;
+DROP INDEX IF EXISTS idx_user_sessions_impersonator_id;
+DROP INDEX IF EXISTS idx_organizations_suspended_at;
+DROP TABLE IF EXISTS platform_admin_actions;
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS impersonation_reason;
+ALTER TABLE user_sessions DROP COLUMN IF EXISTS impersonator_id;
+ALTER TABLE organizations DROP COLUMN IF EXISTS suspended_by;
+ALTER TABLE organizations DROP COLUMN IF EXISTS suspended_reason;
+ALTER TABLE organizations DROP COLUMN IF EXISTS suspended_at;
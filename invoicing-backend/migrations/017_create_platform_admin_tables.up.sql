@@ -0,0 +1,30 @@
+-- Organizations can be suspended by platform admins
+ALTER TABLE organizations ADD COLUMN suspended_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE organizations ADD COLUMN suspended_reason TEXT;
+ALTER TABLE organizations ADD COLUMN suspended_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- Impersonation sessions record which platform admin is acting as the user
+ALTER TABLE user_sessions ADD COLUMN impersonator_id UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE user_sessions ADD COLUMN impersonation_reason TEXT;
+
+-- Append-only record of everything platform admins do, including impersonated requests
+CREATE TABLE platform_admin_actions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    admin_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(100) NOT NULL,
+    target_type VARCHAR(50) NOT NULL,
+    target_id VARCHAR(255) NOT NULL,
+    session_id UUID REFERENCES user_sessions(id) ON DELETE SET NULL,
+    details JSONB NOT NULL DEFAULT '{}',
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Indexes for performance
+CREATE INDEX idx_organizations_suspended_at ON organizations(suspended_at);
+CREATE INDEX idx_user_sessions_impersonator_id ON user_sessions(impersonator_id);
+CREATE INDEX idx_platform_admin_actions_admin_id ON platform_admin_actions(admin_id);
+CREATE INDEX idx_platform_admin_actions_target ON platform_admin_actions(target_type, target_id);
+CREATE INDEX idx_platform_admin_actions_session_id ON platform_admin_actions(session_id);
+CREATE INDEX idx_platform_admin_actions_created_at ON platform_admin_actions(created_at);
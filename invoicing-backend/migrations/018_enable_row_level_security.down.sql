@@ -0,0 +1,21 @@
+-- Disable row-level security on tenant tables
+DROP POLICY IF EXISTS tenant_isolation ON invoice_items;
+ALTER TABLE invoice_items NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE invoice_items DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON invoices;
+ALTER TABLE invoices NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE invoices DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON clients;
+ALTER TABLE clients NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE clients DISABLE ROW LEVEL SECURITY;
+
+DROP FUNCTION IF EXISTS enable_tenant_rls(REGCLASS);
+DROP FUNCTION IF EXISTS current_organization_id();
+
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE USAGE, SELECT ON SEQUENCES FROM invoicing_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE SELECT, INSERT, UPDATE, DELETE ON TABLES FROM invoicing_tenant;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM invoicing_tenant;
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM invoicing_tenant;
+REVOKE USAGE ON SCHEMA public FROM invoicing_tenant;
@@ -0,0 +1,52 @@
+-- Row-level security for tenant tables. Tenant-scoped transactions set
+-- app.current_organization_id with SET LOCAL; policies then only expose rows of
+-- that organization. invoicing_tenant holds the table privileges; the application
+-- logs in as a role granted it that owns no tables, since owners bypass the
+-- policies. Migrations run as the table owner, which is never granted the role.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'invoicing_tenant') THEN
+        CREATE ROLE invoicing_tenant NOLOGIN;
+    END IF;
+    -- The application's login role; if it doesn't exist yet, scripts/init-app-role.sh
+    -- grants the role when it creates it
+    IF EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'invoicing_app') THEN
+        GRANT invoicing_tenant TO invoicing_app;
+    END IF;
+END
+$$;
+
+GRANT USAGE ON SCHEMA public TO invoicing_tenant;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO invoicing_tenant;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO invoicing_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO invoicing_tenant;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO invoicing_tenant;
+
+-- Organization of the current transaction, NULL when none is set
+CREATE OR REPLACE FUNCTION current_organization_id() RETURNS UUID AS $$
+    SELECT NULLIF(current_setting('app.current_organization_id', true), '')::UUID
+$$ LANGUAGE SQL STABLE;
+
+-- Enables RLS on a table with an organization_id column. Future tenant tables
+-- call this from their migration: SELECT enable_tenant_rls('table_name');
+CREATE OR REPLACE FUNCTION enable_tenant_rls(target REGCLASS) RETURNS VOID AS $$
+BEGIN
+    EXECUTE format('ALTER TABLE %s ENABLE ROW LEVEL SECURITY', target);
+    EXECUTE format('ALTER TABLE %s FORCE ROW LEVEL SECURITY', target);
+    EXECUTE format('DROP POLICY IF EXISTS tenant_isolation ON %s', target);
+    EXECUTE format(
+        'CREATE POLICY tenant_isolation ON %s USING (organization_id = current_organization_id()) WITH CHECK (organization_id = current_organization_id())',
+        target
+    );
+END
+$$ LANGUAGE plpgsql;
+
+SELECT enable_tenant_rls('clients');
+SELECT enable_tenant_rls('invoices');
+
+-- Invoice items inherit visibility from their invoice
+ALTER TABLE invoice_items ENABLE ROW LEVEL SECURITY;
+ALTER TABLE invoice_items FORCE ROW LEVEL SECURITY;
+CREATE POLICY tenant_isolation ON invoice_items
+    USING (EXISTS (SELECT 1 FROM invoices WHERE invoices.id = invoice_items.invoice_id))
+    WITH CHECK (EXISTS (SELECT 1 FROM invoices WHERE invoices.id = invoice_items.invoice_id));
@@ -0,0 +1,45 @@
+-- Append-only audit log of mutating actions within an organization.
+-- organization_id and actor columns are not foreign keys so the history
+-- outlives the rows it describes.
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL,
+    actor_type VARCHAR(20) NOT NULL,
+    actor_user_id UUID,
+    actor_api_key_id UUID,
+    impersonator_id UUID,
+    action VARCHAR(50) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id VARCHAR(255) NOT NULL,
+    changes JSONB NOT NULL DEFAULT '{}',
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    request_id VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Reject any modification of recorded events
+CREATE OR REPLACE FUNCTION prevent_audit_event_changes() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION prevent_audit_event_changes();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION prevent_audit_event_changes();
+
+REVOKE UPDATE, DELETE, TRUNCATE ON audit_events FROM invoicing_tenant;
+
+-- Tenant table: only the current organization's events are visible
+SELECT enable_tenant_rls('audit_events');
+
+-- Indexes for performance
+CREATE INDEX idx_audit_events_organization_created_at ON audit_events(organization_id, created_at DESC);
+CREATE INDEX idx_audit_events_resource ON audit_events(organization_id, resource_type, resource_id);
+CREATE INDEX idx_audit_events_actor_user_id ON audit_events(actor_user_id);
+CREATE INDEX idx_audit_events_request_id ON audit_events(request_id);
@@ -0,0 +1,7 @@
+-- Drop invoice approvals and the approve permission
+UPDATE roles
+SET permissions = jsonb_set(permissions, '{invoices}', (permissions->'invoices') - 'approve')
+WHERE permissions ? 'invoices';
+
+ALTER TABLE invoices DROP COLUMN IF EXISTS approval_status;
+DROP TABLE IF EXISTS invoice_approvals;
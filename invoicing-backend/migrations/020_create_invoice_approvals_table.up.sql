@@ -0,0 +1,32 @@
+-- Approval requests for draft invoices. Organizations configure which invoices
+-- need approval in settings.approval_settings.
+CREATE TABLE invoice_approvals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    requested_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    request_comment TEXT,
+    total_amount DECIMAL(12,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled')),
+    decided_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    decision_comment TEXT,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Latest approval outcome, empty when the invoice was never submitted
+ALTER TABLE invoices ADD COLUMN approval_status VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Org admins can approve; custom roles opt in with invoices:approve
+UPDATE roles
+SET permissions = jsonb_set(permissions, '{invoices}', COALESCE(permissions->'invoices', '[]'::jsonb) || '["approve"]'::jsonb)
+WHERE name = 'org_admin' AND organization_id IS NULL
+  AND NOT COALESCE(permissions->'invoices', '[]'::jsonb) ? 'approve';
+
+SELECT enable_tenant_rls('invoice_approvals');
+
+-- Indexes for performance
+CREATE INDEX idx_invoice_approvals_organization_id ON invoice_approvals(organization_id);
+CREATE INDEX idx_invoice_approvals_invoice_id ON invoice_approvals(invoice_id);
+CREATE UNIQUE INDEX idx_invoice_approvals_pending ON invoice_approvals(invoice_id) WHERE status = 'pending';
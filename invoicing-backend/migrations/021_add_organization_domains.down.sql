@@ -0,0 +1,5 @@
+-- Remove organization custom domains
+DROP INDEX IF EXISTS idx_organizations_custom_domain;
+ALTER TABLE organizations DROP COLUMN IF EXISTS custom_domain_verified_at;
+ALTER TABLE organizations DROP COLUMN IF EXISTS custom_domain_token;
+ALTER TABLE organizations DROP COLUMN IF EXISTS custom_domain;
@@ -0,0 +1,8 @@
+-- Custom domains for white-labelled organization portals. A domain only
+-- resolves to its organization once the DNS TXT verification succeeded.
+ALTER TABLE organizations ADD COLUMN custom_domain VARCHAR(255);
+ALTER TABLE organizations ADD COLUMN custom_domain_token VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE organizations ADD COLUMN custom_domain_verified_at TIMESTAMP WITH TIME ZONE;
+
+-- Indexes for performance
+CREATE UNIQUE INDEX idx_organizations_custom_domain ON organizations(LOWER(custom_domain)) WHERE custom_domain IS NOT NULL;
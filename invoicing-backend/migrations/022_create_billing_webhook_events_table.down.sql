@@ -0,0 +1,4 @@
+-- Drop billing_webhook_events table and indexes
+DROP INDEX IF EXISTS idx_billing_webhook_events_resource_id;
+DROP INDEX IF EXISTS idx_billing_webhook_events_provider_event;
+DROP TABLE IF EXISTS billing_webhook_events;
@@ -0,0 +1,14 @@
+-- Processed billing provider webhooks. The unique (provider, event_id) pair makes
+-- redelivered events no-ops.
+CREATE TABLE billing_webhook_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(20) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    resource_id VARCHAR(255),
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Indexes for performance
+CREATE UNIQUE INDEX idx_billing_webhook_events_provider_event ON billing_webhook_events(provider, event_id);
+CREATE INDEX idx_billing_webhook_events_resource_id ON billing_webhook_events(resource_id);
@@ -0,0 +1,7 @@
+-- Drop Stripe billing columns
+DROP INDEX IF EXISTS idx_subscriptions_stripe_subscription_id;
+DROP INDEX IF EXISTS idx_subscriptions_stripe_customer_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS stripe_price_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS stripe_subscription_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS stripe_customer_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS billing_provider;
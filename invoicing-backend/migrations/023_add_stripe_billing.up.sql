@@ -0,0 +1,13 @@
+-- Stripe as an alternative billing provider. billing_provider records which
+-- provider bills the organization.
+ALTER TABLE subscriptions ADD COLUMN billing_provider VARCHAR(20);
+ALTER TABLE subscriptions ADD COLUMN stripe_customer_id VARCHAR(255);
+ALTER TABLE subscriptions ADD COLUMN stripe_subscription_id VARCHAR(255);
+ALTER TABLE subscriptions ADD COLUMN stripe_price_id VARCHAR(255);
+
+UPDATE subscriptions SET billing_provider = 'paypal'
+WHERE paypal_subscription_id IS NOT NULL AND paypal_subscription_id <> '';
+
+-- Indexes for performance
+CREATE INDEX idx_subscriptions_stripe_customer_id ON subscriptions(stripe_customer_id);
+CREATE INDEX idx_subscriptions_stripe_subscription_id ON subscriptions(stripe_subscription_id);
@@ -0,0 +1,2 @@
+-- Drop the limit grace period; corrected plan limits are kept
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS limit_grace_ends_at;
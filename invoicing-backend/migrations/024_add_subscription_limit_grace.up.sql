@@ -0,0 +1,11 @@
+-- Grace period for organizations left over their limits by a plan change. After
+-- limit_grace_ends_at the organization is read-only until it is back under its limits.
+ALTER TABLE subscriptions ADD COLUMN limit_grace_ends_at TIMESTAMPTZ;
+
+-- Registration used to store 10/50/3 regardless of plan; limits now always follow the plan
+UPDATE subscriptions SET monthly_invoice_limit = 5, monthly_client_limit = 2, monthly_user_limit = 1
+WHERE plan_type = 'free';
+UPDATE subscriptions SET monthly_invoice_limit = 100, monthly_client_limit = -1, monthly_user_limit = 5
+WHERE plan_type = 'pro';
+UPDATE subscriptions SET monthly_invoice_limit = -1, monthly_client_limit = -1, monthly_user_limit = -1
+WHERE plan_type = 'business';
@@ -0,0 +1,12 @@
+-- Restore the fixed plan enum; subscriptions on plans outside it fall back to free
+DROP INDEX IF EXISTS idx_subscriptions_plan_type;
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS fk_subscriptions_plan_type;
+
+CREATE TYPE subscription_plan AS ENUM ('free', 'pro', 'business');
+UPDATE subscriptions SET plan_type = 'free' WHERE plan_type NOT IN ('free', 'pro', 'business');
+ALTER TABLE subscriptions ALTER COLUMN plan_type DROP DEFAULT;
+ALTER TABLE subscriptions ALTER COLUMN plan_type TYPE subscription_plan USING plan_type::subscription_plan;
+ALTER TABLE subscriptions ALTER COLUMN plan_type SET DEFAULT 'free';
+
+DROP TABLE IF EXISTS plan_prices;
+DROP TABLE IF EXISTS plans;
@@ -0,0 +1,49 @@
+-- Plan catalog. Subscriptions copy a plan's limits when they move to it; feature
+-- entitlements are read from the plan on each request.
+CREATE TABLE plans (
+    code VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    billing_interval VARCHAR(10) NOT NULL DEFAULT 'month' CHECK (billing_interval IN ('month', 'year')),
+    monthly_invoice_limit INTEGER NOT NULL,
+    monthly_client_limit INTEGER NOT NULL,
+    monthly_user_limit INTEGER NOT NULL,
+    features JSONB NOT NULL DEFAULT '{}',
+    is_public BOOLEAN NOT NULL DEFAULT TRUE,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Price of a plan per billing interval, one row per currency
+CREATE TABLE plan_prices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    plan_code VARCHAR(50) NOT NULL REFERENCES plans(code) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL CHECK (amount >= 0)
+);
+
+-- Limits: -1 means unlimited
+INSERT INTO plans (code, name, description, monthly_invoice_limit, monthly_client_limit, monthly_user_limit, features, sort_order) VALUES
+    ('free', 'Free', 'For trying things out', 5, 2, 1,
+        '{"recurring_invoices": false, "custom_roles": false, "api_keys": false, "sso": false}', 0),
+    ('pro', 'Pro', 'For freelancers and small teams', 100, -1, 5,
+        '{"recurring_invoices": true, "custom_roles": false, "api_keys": true, "sso": false}', 1),
+    ('business', 'Business', 'For growing companies', -1, -1, -1,
+        '{"recurring_invoices": true, "custom_roles": true, "api_keys": true, "sso": true}', 2);
+
+INSERT INTO plan_prices (plan_code, currency, amount) VALUES
+    ('free', 'USD', 0),
+    ('pro', 'USD', 15),
+    ('business', 'USD', 50);
+
+-- Plans are no longer a fixed set
+ALTER TABLE subscriptions ALTER COLUMN plan_type DROP DEFAULT;
+ALTER TABLE subscriptions ALTER COLUMN plan_type TYPE VARCHAR(50) USING plan_type::TEXT;
+ALTER TABLE subscriptions ALTER COLUMN plan_type SET DEFAULT 'free';
+ALTER TABLE subscriptions ADD CONSTRAINT fk_subscriptions_plan_type FOREIGN KEY (plan_type) REFERENCES plans(code);
+DROP TYPE subscription_plan;
+
+-- Indexes for performance
+CREATE UNIQUE INDEX idx_plan_prices_plan_currency ON plan_prices(plan_code, currency);
+CREATE INDEX idx_subscriptions_plan_type ON subscriptions(plan_type);
@@ -0,0 +1,6 @@
+-- Revert trial tracking and date-only billing periods
+DROP INDEX IF EXISTS idx_subscriptions_trial_end;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_reminder_sent_at;
+ALTER TABLE subscriptions ALTER COLUMN current_period_end TYPE DATE USING current_period_end::DATE;
+ALTER TABLE subscriptions ALTER COLUMN current_period_start TYPE DATE USING current_period_start::DATE;
+ALTER TABLE subscriptions ALTER COLUMN trial_end TYPE DATE USING trial_end::DATE;
@@ -0,0 +1,13 @@
+-- Trials and expiry need times, not dates: a DATE period end expired subscriptions
+-- at midnight before the period actually ended
+ALTER TABLE subscriptions ALTER COLUMN trial_end TYPE TIMESTAMPTZ USING trial_end::TIMESTAMPTZ;
+ALTER TABLE subscriptions ALTER COLUMN current_period_start TYPE TIMESTAMPTZ USING current_period_start::TIMESTAMPTZ;
+ALTER TABLE subscriptions ALTER COLUMN current_period_end TYPE TIMESTAMPTZ USING current_period_end::TIMESTAMPTZ;
+ALTER TABLE subscriptions ADD COLUMN trial_reminder_sent_at TIMESTAMPTZ;
+
+-- The free plan has no billing period and never expires
+UPDATE subscriptions SET current_period_start = NULL, current_period_end = NULL
+WHERE plan_type = 'free';
+
+-- Indexes for performance
+CREATE INDEX idx_subscriptions_trial_end ON subscriptions(trial_end) WHERE trial_end IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Drop usage metering
+DROP TABLE IF EXISTS usage_threshold_notifications;
+DROP TABLE IF EXISTS usage_events;
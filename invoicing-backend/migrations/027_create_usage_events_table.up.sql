@@ -0,0 +1,45 @@
+-- Metered usage: +1 when an invoice or client is created, -1 when a client is deleted.
+-- Invoice quotas sum the events of the current billing period.
+CREATE TABLE usage_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    resource VARCHAR(20) NOT NULL,
+    resource_id UUID,
+    quantity INTEGER NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Usage thresholds (80%, 100%) admins were notified about, once per billing period
+CREATE TABLE usage_threshold_notifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    resource VARCHAR(20) NOT NULL,
+    threshold INTEGER NOT NULL,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Backfill from existing rows. Row-level security is forced on clients and invoices,
+-- which would hide every row from this migration; lift it while copying.
+ALTER TABLE clients NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE invoices NO FORCE ROW LEVEL SECURITY;
+
+INSERT INTO usage_events (organization_id, resource, resource_id, quantity, occurred_at)
+SELECT organization_id, 'invoices', id, 1, created_at FROM invoices WHERE organization_id IS NOT NULL;
+
+INSERT INTO usage_events (organization_id, resource, resource_id, quantity, occurred_at)
+SELECT organization_id, 'clients', id, 1, created_at FROM clients WHERE organization_id IS NOT NULL;
+
+INSERT INTO usage_events (organization_id, resource, resource_id, quantity, occurred_at)
+SELECT organization_id, 'clients', id, -1, deleted_at FROM clients
+WHERE organization_id IS NOT NULL AND deleted_at IS NOT NULL;
+
+ALTER TABLE clients FORCE ROW LEVEL SECURITY;
+ALTER TABLE invoices FORCE ROW LEVEL SECURITY;
+
+SELECT enable_tenant_rls('usage_events');
+
+-- Indexes for performance
+CREATE INDEX idx_usage_events_organization_resource ON usage_events(organization_id, resource, occurred_at);
+CREATE UNIQUE INDEX idx_usage_threshold_notifications_unique
+    ON usage_threshold_notifications(organization_id, resource, threshold, period_start);
@@ -0,0 +1,4 @@
+-- Drop coupons
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS coupon_redemption_id;
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP TABLE IF EXISTS coupons;
@@ -0,0 +1,39 @@
+-- Coupons discount paid plans. Organizations redeem them by code at sign-up or on a
+-- plan change; the discount starts with the paid subscription.
+CREATE TABLE coupons (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    discount_type VARCHAR(10) NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+    percent_off DECIMAL(5,2) NOT NULL DEFAULT 0 CHECK (percent_off >= 0 AND percent_off <= 100),
+    amount_off DECIMAL(12,2) NOT NULL DEFAULT 0 CHECK (amount_off >= 0),
+    currency VARCHAR(3),
+    duration VARCHAR(10) NOT NULL CHECK (duration IN ('once', 'repeating', 'forever')),
+    duration_in_months INTEGER NOT NULL DEFAULT 0,
+    max_redemptions INTEGER,
+    times_redeemed INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    plans JSONB NOT NULL DEFAULT '[]',
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- One redemption per coupon and organization
+CREATE TABLE coupon_redemptions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    coupon_id UUID NOT NULL REFERENCES coupons(id) ON DELETE CASCADE,
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    redeemed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    applied_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- The organization's current discount
+ALTER TABLE subscriptions ADD COLUMN coupon_redemption_id UUID REFERENCES coupon_redemptions(id) ON DELETE SET NULL;
+
+-- Indexes for performance
+CREATE UNIQUE INDEX idx_coupon_redemptions_coupon_organization ON coupon_redemptions(coupon_id, organization_id);
+CREATE INDEX idx_coupon_redemptions_organization_id ON coupon_redemptions(organization_id);
@@ -0,0 +1,4 @@
+-- Drop invoice payments
+ALTER TABLE invoices DROP COLUMN IF EXISTS payment_link_token_hash;
+ALTER TABLE invoices DROP COLUMN IF EXISTS paid_at;
+DROP TABLE IF EXISTS invoice_payments;
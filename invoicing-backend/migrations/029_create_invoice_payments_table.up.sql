@@ -0,0 +1,28 @@
+-- Online payments of invoices through a gateway checkout opened from the
+-- invoice's payment link
+CREATE TABLE invoice_payments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    gateway VARCHAR(20) NOT NULL CHECK (gateway IN ('stripe', 'paypal')),
+    session_id VARCHAR(255) NOT NULL,
+    gateway_payment_id VARCHAR(255),
+    amount DECIMAL(12,2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed', 'expired')),
+    paid_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- When an online payment settled the invoice, and the hash of the nonce in its
+-- current payment link
+ALTER TABLE invoices ADD COLUMN paid_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE invoices ADD COLUMN payment_link_token_hash VARCHAR(64);
+
+SELECT enable_tenant_rls('invoice_payments');
+
+-- Indexes for performance
+CREATE INDEX idx_invoice_payments_organization_id ON invoice_payments(organization_id);
+CREATE INDEX idx_invoice_payments_invoice_id ON invoice_payments(invoice_id);
+CREATE UNIQUE INDEX idx_invoice_payments_gateway_session ON invoice_payments(gateway, session_id);
@@ -0,0 +1,3 @@
+-- Drop payment methods
+ALTER TABLE invoices DROP COLUMN IF EXISTS payment_method_ids;
+DROP TABLE IF EXISTS payment_methods;
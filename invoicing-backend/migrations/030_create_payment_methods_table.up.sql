@@ -0,0 +1,28 @@
+-- Payment instructions (bank details, PayPal.me, free text) organizations print
+-- on their invoices
+CREATE TABLE payment_methods (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('bank_transfer', 'ach', 'paypal_me', 'custom')),
+    name VARCHAR(100) NOT NULL,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    account_holder VARCHAR(140),
+    bank_name VARCHAR(140),
+    iban VARCHAR(34),
+    bic VARCHAR(11),
+    routing_number VARCHAR(9),
+    account_number VARCHAR(17),
+    paypal_me_username VARCHAR(20),
+    instructions TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Payment methods selected for the invoice, in print order; empty prints the
+-- organization's defaults
+ALTER TABLE invoices ADD COLUMN payment_method_ids JSONB NOT NULL DEFAULT '[]';
+
+SELECT enable_tenant_rls('payment_methods');
+
+-- Indexes for performance
+CREATE INDEX idx_payment_methods_organization_id ON payment_methods(organization_id);
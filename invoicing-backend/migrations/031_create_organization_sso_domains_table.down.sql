@@ -0,0 +1,2 @@
+-- Drop SSO domains
+DROP TABLE IF EXISTS organization_sso_domains;
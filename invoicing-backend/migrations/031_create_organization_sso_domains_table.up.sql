@@ -0,0 +1,15 @@
+-- Email domains an organization proved it owns through a DNS TXT record. SSO only
+-- links a login to an existing account by email on a verified domain.
+CREATE TABLE organization_sso_domains (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    domain VARCHAR(253) NOT NULL,
+    verification_token VARCHAR(64) NOT NULL,
+    verified_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- An organization claims a domain once, and only one organization can verify it
+CREATE UNIQUE INDEX idx_organization_sso_domains_organization_domain ON organization_sso_domains(organization_id, LOWER(domain));
+CREATE UNIQUE INDEX idx_organization_sso_domains_verified_domain ON organization_sso_domains(LOWER(domain)) WHERE verified_at IS NOT NULL;
+CREATE INDEX idx_organization_sso_domains_organization_id ON organization_sso_domains(organization_id);
@@ -0,0 +1,2 @@
+-- Remove organization-scoped session revocation
+ALTER TABLE user_organization_roles DROP COLUMN IF EXISTS sessions_revoked_at;
@@ -0,0 +1,3 @@
+-- Sessions started before this time can no longer act in the organization; set when
+-- an organization admin force-logs a member out
+ALTER TABLE user_organization_roles ADD COLUMN sessions_revoked_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,5 @@
+-- Restore the original payment statuses; partial and mismatched payments count as failed
+UPDATE invoice_payments SET status = 'failed' WHERE status IN ('partial', 'mismatched');
+ALTER TABLE invoice_payments DROP CONSTRAINT IF EXISTS invoice_payments_status_check;
+ALTER TABLE invoice_payments ADD CONSTRAINT invoice_payments_status_check
+    CHECK (status IN ('pending', 'succeeded', 'failed', 'expired'));
@@ -0,0 +1,5 @@
+-- Payments whose amount or currency differs from the invoice total are recorded
+-- without marking the invoice paid
+ALTER TABLE invoice_payments DROP CONSTRAINT IF EXISTS invoice_payments_status_check;
+ALTER TABLE invoice_payments ADD CONSTRAINT invoice_payments_status_check
+    CHECK (status IN ('pending', 'succeeded', 'failed', 'expired', 'partial', 'mismatched'));
@@ -117,20 +117,23 @@ func createUserOrganization(db *gorm.DB, user *models.User) error {
 
 	// Create free subscription
 	subscription := models.Subscription{
-		OrganizationID:      organization.ID.String(),
-		PlanType:            models.SubscriptionPlanFree,
-		Status:              models.SubscriptionStatusActive,
-		MonthlyInvoiceLimit: 10,
-		MonthlyClientLimit:  50,
-		MonthlyUserLimit:    3,
+		OrganizationID: organization.ID.String(),
+		Status:         models.SubscriptionStatusActive,
+	}
+	var plan models.Plan
+	if err := tx.First(&plan, "code = ?", models.SubscriptionPlanFree).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to fetch free plan: %w", err)
 	}
+	subscription.ApplyPlan(&plan)
 
 	if err := tx.Create(&subscription).Error; err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to create subscription: %w", err)
 	}
 
-	// Update existing clients and invoices to belong to this organization
+	// Update existing clients and invoices to belong to this organization.
+	// This moves rows across tenants, so it needs a role that bypasses row-level security.
 	if err := tx.Model(&models.Client{}).Where("user_id = ?", user.ID.String()).Update("organization_id", organization.ID.String()).Error; err != nil {
 		log.Printf("Warning: Failed to update clients for user %s: %v\n", user.Email, err)
 	}
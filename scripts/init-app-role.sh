@@ -0,0 +1,26 @@
+#!/bin/bash
+
+# Invoicing SaaS - Application Database Role
+# Creates the login role the backend connects as. It owns no tables and inherits
+# its privileges from invoicing_tenant, so row-level security applies to it.
+# Migrations keep running as the database owner (MIGRATION_DATABASE_URL).
+#
+# Runs from /docker-entrypoint-initdb.d on a fresh volume; for an existing one:
+#   make db-app-role
+
+set -e
+
+psql -v ON_ERROR_STOP=1 \
+    -v app_password="${APP_DB_PASSWORD:-app_password}" \
+    --username "${POSTGRES_USER:-postgres}" \
+    --dbname "${POSTGRES_DB:-invoicing}" <<-'EOSQL'
+	SELECT 'CREATE ROLE invoicing_tenant NOLOGIN'
+	WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'invoicing_tenant')
+	\gexec
+
+	SELECT format('CREATE ROLE invoicing_app LOGIN PASSWORD %L', :'app_password')
+	WHERE NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'invoicing_app')
+	\gexec
+
+	GRANT invoicing_tenant TO invoicing_app;
+EOSQL